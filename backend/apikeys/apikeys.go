@@ -0,0 +1,184 @@
+// Package apikeys issues API keys to service accounts, the non-human users of
+// CI pipelines and scripts. A key looks like rsk_<id>_<secret>; only the
+// rsk_<id> prefix and a hash of the whole key are stored.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/rbac"
+)
+
+// KeyPrefix starts every key, which lets Auth tell keys from JWTs
+const KeyPrefix = "rsk_"
+
+// Key lifetimes
+const (
+	DefaultTTL = 90 * 24 * time.Hour
+	MaxTTL     = 365 * 24 * time.Hour
+)
+
+// Errors returned for rejected keys and accounts
+var (
+	ErrInvalidKey     = errors.New("invalid API key")
+	ErrInvalidAccount = errors.New("invalid service account")
+	ErrAccountExists  = errors.New("service account already exists")
+	ErrInvalidTTL     = errors.New("invalid key lifetime")
+)
+
+// accountRoles are the global roles a service account may have. Admin is
+// left out so that a leaked key can't manage users or other keys.
+var accountRoles = map[string]bool{"editor": true, "viewer": true}
+
+// ServiceAccount is a non-human user owning API keys. Its user ID in team
+// memberships and service grants is UserID().
+type ServiceAccount struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Roles       []string  `json:"roles"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UserID is the account's ID as used by rbac
+func (a *ServiceAccount) UserID() string {
+	return "sa:" + a.ID
+}
+
+// Key is an API key without its secret
+type Key struct {
+	ID               string            `json:"id"`
+	ServiceAccountID string            `json:"service_account_id"`
+	Name             string            `json:"name"`
+	Prefix           string            `json:"prefix"`
+	Scopes           []rbac.Permission `json:"scopes"`
+	ExpiresAt        time.Time         `json:"expires_at"`
+	LastUsedAt       *time.Time        `json:"last_used_at"`
+	RevokedAt        *time.Time        `json:"revoked_at"`
+	RotatedFrom      *string           `json:"rotated_from"`
+	CreatedBy        string            `json:"created_by"`
+	CreatedAt        time.Time         `json:"created_at"`
+}
+
+// ScopeNames returns the scopes of a key as strings
+func (k *Key) ScopeNames() []string {
+	names := make([]string, len(k.Scopes))
+	for i, p := range k.Scopes {
+		names[i] = string(p)
+	}
+	return names
+}
+
+// Principal is who a valid key acts as
+type Principal struct {
+	Account ServiceAccount
+	Key     Key
+}
+
+// IsKey reports whether a credential looks like an API key rather than a JWT
+func IsKey(credential string) bool {
+	return strings.HasPrefix(credential, KeyPrefix)
+}
+
+// Generate returns a new key and the prefix stored to look it up
+func Generate() (key, prefix string, err error) {
+	id := make([]byte, 5)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = KeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// Parse returns the stored prefix of a key
+func Parse(key string) (string, error) {
+	if !IsKey(key) {
+		return "", ErrInvalidKey
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, KeyPrefix), "_")
+	if !ok || len(id) != 10 || secret == "" {
+		return "", ErrInvalidKey
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", ErrInvalidKey
+	}
+	return KeyPrefix + id, nil
+}
+
+// hashKey is the stored form of a key
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// matches compares a key with a stored hash in constant time
+func matches(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashKey(key)), []byte(hash)) == 1
+}
+
+// ParseTTL reads a key lifetime such as "30d" or "12h". Empty means
+// DefaultTTL; lifetimes above MaxTTL are rejected.
+func ParseTTL(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return DefaultTTL, nil
+	}
+	var ttl time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidTTL, s)
+		}
+		ttl = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidTTL, s)
+		}
+		ttl = d
+	}
+	if ttl <= 0 || ttl > MaxTTL {
+		return 0, fmt.Errorf("%w: %q must be positive and at most %d days", ErrInvalidTTL, s, int(MaxTTL.Hours()/24))
+	}
+	return ttl, nil
+}
+
+// validateRoles checks the roles of a service account, defaulting to viewer
+func validateRoles(roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return []string{"viewer"}, nil
+	}
+	for _, role := range roles {
+		if !accountRoles[role] {
+			return nil, fmt.Errorf("%w: role %q is not allowed, use editor or viewer", ErrInvalidAccount, role)
+		}
+	}
+	return roles, nil
+}
+
+// validateScopes checks the scopes of a key. Keys need at least one.
+func validateScopes(names []string) ([]rbac.Permission, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidKey)
+	}
+	scopes, err := rbac.ParsePermissions(names)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return scopes, nil
+}
@@ -0,0 +1,115 @@
+package apikeys
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/rbac"
+)
+
+func TestGenerateAndParse(t *testing.T) {
+	key, prefix, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsKey(key) || !strings.HasPrefix(key, prefix+"_") {
+		t.Fatalf("key %q doesn't start with prefix %q", key, prefix)
+	}
+	got, err := Parse(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != prefix {
+		t.Errorf("Parse = %q, want %q", got, prefix)
+	}
+	if other, _, _ := Generate(); other == key {
+		t.Error("Generate returned the same key twice")
+	}
+	if !matches(key, hashKey(key)) {
+		t.Error("key doesn't match its own hash")
+	}
+	if matches(key+"x", hashKey(key)) {
+		t.Error("changed key matches the hash")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"jwt", "eyJhbGciOiJIUzI1NiJ9.e30.sig"},
+		{"no secret", "rsk_0123456789"},
+		{"empty secret", "rsk_0123456789_"},
+		{"short id", "rsk_0123_secret"},
+		{"id not hex", "rsk_012345678z_secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Parse(%q) error = %v, want ErrInvalidKey", tt.key, err)
+			}
+		})
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", DefaultTTL, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"365d", MaxTTL, false},
+		{"366d", 0, true},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTTL(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTTL(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidTTL) {
+				t.Errorf("error = %v, want ErrInvalidTTL", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTTL(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	if got, _ := validateRoles(nil); !reflect.DeepEqual(got, []string{"viewer"}) {
+		t.Errorf("default roles = %v, want [viewer]", got)
+	}
+	if _, err := validateRoles([]string{"editor"}); err != nil {
+		t.Errorf("editor rejected: %v", err)
+	}
+	if _, err := validateRoles([]string{"viewer", "admin"}); !errors.Is(err, ErrInvalidAccount) {
+		t.Errorf("admin error = %v, want ErrInvalidAccount", err)
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	got, err := validateScopes([]string{"incident:write", "slo:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []rbac.Permission{rbac.IncidentWrite, rbac.SLORead}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scopes = %v, want %v", got, want)
+	}
+	for _, names := range [][]string{nil, {"incident:delete"}} {
+		if _, err := validateScopes(names); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("validateScopes(%v) error = %v, want ErrInvalidKey", names, err)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/rbac"
+)
+
+// lastUsedInterval limits how often last_used_at is written for busy keys
+const lastUsedInterval = time.Minute
+
+// Store keeps service accounts and their keys
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateAccount stores a new service account in a.OrgID. Names are unique
+// within an organization.
+func (s *Store) CreateAccount(ctx context.Context, a *ServiceAccount) error {
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidAccount)
+	}
+	roles, err := validateRoles(a.Roles)
+	if err != nil {
+		return err
+	}
+	a.Roles = roles
+	data, _ := json.Marshal(a.Roles)
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO service_accounts (org_id, name, description, roles, created_by) VALUES ($1, $2, $3, $4::jsonb, $5)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+	`, a.OrgID, a.Name, a.Description, string(data), a.CreatedBy).Scan(&a.ID, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", ErrAccountExists, a.Name)
+	}
+	return err
+}
+
+// ListAccounts returns the service accounts of an organization
+func (s *Store) ListAccounts(ctx context.Context, orgID string) ([]ServiceAccount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, org_id, name, description, roles, created_by, created_at
+		FROM service_accounts WHERE org_id = $1 ORDER BY name
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]ServiceAccount, 0)
+	for rows.Next() {
+		var a ServiceAccount
+		var roles []byte
+		if err := rows.Scan(&a.ID, &a.OrgID, &a.Name, &a.Description, &roles, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(roles, &a.Roles)
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// GetAccount returns one service account of an organization, or sql.ErrNoRows
+func (s *Store) GetAccount(ctx context.Context, orgID, id string) (*ServiceAccount, error) {
+	var a ServiceAccount
+	var roles []byte
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, org_id, name, description, roles, created_by, created_at
+		FROM service_accounts WHERE id::text = $1 AND org_id = $2
+	`, id, orgID).Scan(&a.ID, &a.OrgID, &a.Name, &a.Description, &roles, &a.CreatedBy, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(roles, &a.Roles)
+	return &a, nil
+}
+
+// DeleteAccount deletes a service account of an organization with its keys,
+// team memberships and service grants, or returns sql.ErrNoRows
+func (s *Store) DeleteAccount(ctx context.Context, orgID, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM service_accounts WHERE id::text = $1 AND org_id = $2`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	userID := (&ServiceAccount{ID: id}).UserID()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team_members WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM service_grants WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const keyColumns = `id, service_account_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, rotated_from, created_by, created_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanKey(row scanner, extra ...interface{}) (*Key, error) {
+	var k Key
+	var scopes []byte
+	var rotatedFrom sql.NullString
+	dest := []interface{}{&k.ID, &k.ServiceAccountID, &k.Name, &k.Prefix, &scopes, &k.ExpiresAt,
+		&k.LastUsedAt, &k.RevokedAt, &rotatedFrom, &k.CreatedBy, &k.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	var names []string
+	json.Unmarshal(scopes, &names)
+	k.Scopes, _ = rbac.ParsePermissions(names)
+	if rotatedFrom.Valid {
+		k.RotatedFrom = &rotatedFrom.String
+	}
+	return &k, nil
+}
+
+// ListKeys returns the keys of a service account, newest first
+func (s *Store) ListKeys(ctx context.Context, accountID string) ([]Key, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+keyColumns+` FROM api_keys
+		WHERE service_account_id::text = $1
+		ORDER BY created_at DESC
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]Key, 0)
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// CreateKey issues a key for an existing service account. The returned
+// plaintext key is not stored and can't be read again.
+func (s *Store) CreateKey(ctx context.Context, accountID, name string, scopes []string, ttl time.Duration, createdBy string) (*Key, string, error) {
+	perms, err := validateScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.insertKey(ctx, s.db, accountID, name, perms, ttl, nil, createdBy)
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) insertKey(ctx context.Context, q queryer, accountID, name string, scopes []rbac.Permission,
+	ttl time.Duration, rotatedFrom *string, createdBy string) (*Key, string, error) {
+	plaintext, prefix, err := Generate()
+	if err != nil {
+		return nil, "", err
+	}
+	data, _ := json.Marshal(scopes)
+	k, err := scanKey(q.QueryRowContext(ctx, `
+		INSERT INTO api_keys (service_account_id, name, prefix, key_hash, scopes, expires_at, rotated_from, created_by)
+		VALUES ($1, $2, $3, $4, $5::jsonb, NOW() + $6 * INTERVAL '1 second', $7, $8)
+		RETURNING `+keyColumns,
+		accountID, strings.TrimSpace(name), prefix, hashKey(plaintext), string(data), int64(ttl.Seconds()),
+		rotatedFrom, createdBy))
+	if err != nil {
+		return nil, "", err
+	}
+	return k, plaintext, nil
+}
+
+// RotateKey issues a replacement for an active key with the same name, scopes
+// and lifetime. The old key keeps working for grace, or stops at once when
+// grace is zero.
+func (s *Store) RotateKey(ctx context.Context, accountID, keyID string, grace time.Duration, createdBy string) (*Key, string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var active bool
+	old, err := scanKey(tx.QueryRowContext(ctx, `
+		SELECT `+keyColumns+`, revoked_at IS NULL AND expires_at > NOW() FROM api_keys
+		WHERE id::text = $1 AND service_account_id::text = $2
+		FOR UPDATE
+	`, keyID, accountID), &active)
+	if err != nil {
+		return nil, "", err
+	}
+	if !active {
+		return nil, "", fmt.Errorf("%w: key %s is revoked or expired", ErrInvalidKey, old.Prefix)
+	}
+
+	ttl := old.ExpiresAt.Sub(old.CreatedAt)
+	if ttl <= 0 || ttl > MaxTTL {
+		ttl = DefaultTTL
+	}
+	k, plaintext, err := s.insertKey(ctx, tx, accountID, old.Name, old.Scopes, ttl, &old.ID, createdBy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if grace <= 0 {
+		_, err = tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1`, old.ID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE api_keys SET expires_at = LEAST(expires_at, NOW() + $2 * INTERVAL '1 second') WHERE id = $1
+		`, old.ID, int64(grace.Seconds()))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return k, plaintext, nil
+}
+
+// RevokeKey stops a key from working, or returns sql.ErrNoRows
+func (s *Store) RevokeKey(ctx context.Context, accountID, keyID string) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE id::text = $1 AND service_account_id::text = $2 AND revoked_at IS NULL
+	`, keyID, accountID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Authenticate returns who a key acts as. Unknown, revoked and expired keys
+// all return ErrInvalidKey.
+func (s *Store) Authenticate(ctx context.Context, plaintext string) (*Principal, error) {
+	prefix, err := Parse(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	var hash string
+	var active, stale bool
+	var a ServiceAccount
+	var roles []byte
+	k, err := scanKey(s.db.QueryRowContext(ctx, `
+		SELECT k.`+strings.ReplaceAll(keyColumns, ", ", ", k.")+`,
+		       k.key_hash, k.revoked_at IS NULL AND k.expires_at > NOW(),
+		       k.last_used_at IS NULL OR k.last_used_at < NOW() - $2 * INTERVAL '1 second',
+		       a.id, a.org_id, a.name, a.description, a.roles, a.created_by, a.created_at
+		FROM api_keys k
+		JOIN service_accounts a ON a.id = k.service_account_id
+		WHERE k.prefix = $1
+	`, prefix, int64(lastUsedInterval.Seconds())),
+		&hash, &active, &stale, &a.ID, &a.OrgID, &a.Name, &a.Description, &roles, &a.CreatedBy, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !matches(plaintext, hash) || !active {
+		return nil, ErrInvalidKey
+	}
+	json.Unmarshal(roles, &a.Roles)
+
+	if stale {
+		if _, err := s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, k.ID); err != nil {
+			return nil, fmt.Errorf("failed to update key usage: %w", err)
+		}
+	}
+	return &Principal{Account: a, Key: *k}, nil
+}
@@ -0,0 +1,230 @@
+// Package audit stores a tamper-evident record of who changed what. Each
+// entry's hash covers its content and the previous entry's hash, so editing or
+// removing a row breaks the chain from that row on.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Actions
+const (
+	ActionLogin        = "login"
+	ActionLoginAttempt = "login_attempt"
+	ActionRegister     = "register"
+	ActionTokenRefresh = "token_refresh"
+
+	ActionIncidentCreate = "incident.create"
+	ActionIncidentUpdate = "incident.update"
+	ActionRootCause      = "incident.root_cause"
+
+	ActionSLOCreate    = "slo.create"
+	ActionSLOUpdate    = "slo.update"
+	ActionSLODelete    = "slo.delete"
+	ActionSLOImport    = "slo.import"
+	ActionPolicyCreate = "slo_policy.create"
+	ActionPolicyDelete = "slo_policy.delete"
+
+	ActionTeamCreate = "team.create"
+	ActionTeamDelete = "team.delete"
+
+	ActionOrgCreate = "organization.create"
+	ActionOrgAssign = "organization.assign"
+
+	ActionServiceAccountCreate = "service_account.create"
+	ActionServiceAccountDelete = "service_account.delete"
+	ActionAPIKeyCreate         = "api_key.create"
+	ActionAPIKeyRotate         = "api_key.rotate"
+	ActionAPIKeyRevoke         = "api_key.revoke"
+
+	ActionRoleChange  = "role.change"
+	ActionRemediation = "remediation"
+)
+
+// lockKey serialises writers so every entry chains to the one before it
+const lockKey = 7_241_153_040
+
+// genesisHash is the previous hash of the first entry
+var genesisHash = strings.Repeat("0", 64)
+
+// Entry is one audit record
+type Entry struct {
+	ID           int64           `json:"id"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	ActorID      string          `json:"actor_id"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Success      bool            `json:"success"`
+	Details      string          `json:"details,omitempty"`
+	IPAddress    string          `json:"ip_address"`
+	RequestID    string          `json:"request_id"`
+	APIKeyID     string          `json:"api_key_id,omitempty"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+// hashedEntry is the part of an entry covered by its hash, in a fixed order
+type hashedEntry struct {
+	OccurredAt   string          `json:"occurred_at"`
+	ActorID      string          `json:"actor_id"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Success      bool            `json:"success"`
+	Details      string          `json:"details"`
+	IPAddress    string          `json:"ip_address"`
+	RequestID    string          `json:"request_id"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	// Added after the first entries were written; omitted when empty so
+	// their hashes don't change
+	APIKeyID string `json:"api_key_id,omitempty"`
+}
+
+// computeHash returns the hash of e chained to prevHash
+func computeHash(prevHash string, e *Entry) (string, error) {
+	content, err := json.Marshal(hashedEntry{
+		OccurredAt:   e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		ActorID:      e.ActorID,
+		Actor:        e.Actor,
+		Action:       e.Action,
+		ResourceType: e.ResourceType,
+		ResourceID:   e.ResourceID,
+		Success:      e.Success,
+		Details:      e.Details,
+		IPAddress:    e.IPAddress,
+		RequestID:    e.RequestID,
+		Before:       e.Before,
+		After:        e.After,
+		APIKeyID:     e.APIKeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(prevHash+"\n"), content...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// compactJSON validates and compacts a before or after value
+func compactJSON(value json.RawMessage) (json.RawMessage, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Value marshals v for use as an entry's before or after value. It returns
+// nil if v can't be marshalled.
+func Value(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// Recorder writes and reads the audit log
+type Recorder struct {
+	db *sql.DB
+}
+
+// NewRecorder creates a recorder
+func NewRecorder(db *sql.DB) *Recorder {
+	return &Recorder{db: db}
+}
+
+func nullJSON(value json.RawMessage) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
+
+// Record appends e to the log, setting its ID, time and hashes
+func (r *Recorder) Record(ctx context.Context, e *Entry) error {
+	var err error
+	if e.Before, err = compactJSON(e.Before); err != nil {
+		return fmt.Errorf("invalid before value: %w", err)
+	}
+	if e.After, err = compactJSON(e.After); err != nil {
+		return fmt.Errorf("invalid after value: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	e.PrevHash = genesisHash
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&e.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read last audit entry: %w", err)
+	}
+
+	// Postgres keeps microseconds; truncate so the hash can be recomputed
+	e.OccurredAt = time.Now().UTC().Truncate(time.Microsecond)
+	if e.Hash, err = computeHash(e.PrevHash, e); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO audit_log (occurred_at, actor_id, actor, action, resource_type, resource_id, success,
+		                       details, ip_address, request_id, api_key_id, before_value, after_value, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id
+	`, e.OccurredAt, e.ActorID, e.Actor, e.Action, e.ResourceType, e.ResourceID, e.Success,
+		e.Details, e.IPAddress, e.RequestID, e.APIKeyID, nullJSON(e.Before), nullJSON(e.After), e.PrevHash, e.Hash).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return tx.Commit()
+}
+
+const entryColumns = `id, occurred_at, actor_id, actor, action, resource_type, resource_id, success,
+	details, ip_address, request_id, api_key_id, before_value, after_value, prev_hash, hash`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEntry(row scanner) (Entry, error) {
+	var e Entry
+	var before, after []byte
+	err := row.Scan(&e.ID, &e.OccurredAt, &e.ActorID, &e.Actor, &e.Action, &e.ResourceType, &e.ResourceID,
+		&e.Success, &e.Details, &e.IPAddress, &e.RequestID, &e.APIKeyID, &before, &after, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return e, err
+	}
+	e.OccurredAt = e.OccurredAt.UTC()
+	if before != nil {
+		e.Before = before
+	}
+	if after != nil {
+		e.After = after
+	}
+	return e, nil
+}
@@ -0,0 +1,200 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// chain builds n hash-chained entries
+func chain(t *testing.T, n int) []Entry {
+	t.Helper()
+	entries := make([]Entry, n)
+	prev := genesisHash
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := range entries {
+		e := &entries[i]
+		e.ID = int64(i + 1)
+		e.OccurredAt = start.Add(time.Duration(i) * time.Minute)
+		e.Actor = "alice"
+		e.Action = ActionIncidentUpdate
+		e.ResourceType = "incident"
+		e.ResourceID = "inc-1"
+		e.Success = true
+		e.Before = json.RawMessage(`{"status":"open"}`)
+		e.After = json.RawMessage(`{"status":"resolved"}`)
+		e.PrevHash = prev
+		hash, err := computeHash(prev, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Hash = hash
+		prev = hash
+	}
+	return entries
+}
+
+func TestVerifyChain(t *testing.T) {
+	tests := []struct {
+		name      string
+		tamper    func([]Entry) []Entry
+		wantValid bool
+		wantBadID int64
+	}{
+		{name: "intact", tamper: func(e []Entry) []Entry { return e }, wantValid: true},
+		{
+			name: "changed content",
+			tamper: func(e []Entry) []Entry {
+				e[1].After = json.RawMessage(`{"status":"open"}`)
+				return e
+			},
+			wantBadID: 2,
+		},
+		{
+			name: "changed actor",
+			tamper: func(e []Entry) []Entry {
+				e[2].Actor = "mallory"
+				return e
+			},
+			wantBadID: 3,
+		},
+		{
+			name: "changed api key",
+			tamper: func(e []Entry) []Entry {
+				e[0].APIKeyID = "key-1"
+				return e
+			},
+			wantBadID: 1,
+		},
+		{
+			name:      "removed entry",
+			tamper:    func(e []Entry) []Entry { return append(e[:1], e[2:]...) },
+			wantBadID: 3,
+		},
+		{
+			name: "rehashed entry",
+			tamper: func(e []Entry) []Entry {
+				e[1].Details = "edited"
+				e[1].Hash, _ = computeHash(e[1].PrevHash, &e[1])
+				return e
+			},
+			wantBadID: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verification{Valid: true}
+			verifyChain(genesisHash, tt.tamper(chain(t, 4)), v)
+			if v.Valid != tt.wantValid || v.BadID != tt.wantBadID {
+				t.Errorf("verifyChain() = valid %v, bad ID %d; want valid %v, bad ID %d", v.Valid, v.BadID, tt.wantValid, tt.wantBadID)
+			}
+		})
+	}
+}
+
+func TestComputeHashIgnoresFormatting(t *testing.T) {
+	a := chain(t, 1)[0]
+	b := a
+	b.After = json.RawMessage("{\n  \"status\": \"resolved\"\n}")
+	b.OccurredAt = a.OccurredAt.In(time.FixedZone("CEST", 2*60*60))
+
+	hashA, _ := computeHash(genesisHash, &a)
+	hashB, _ := computeHash(genesisHash, &b)
+	if hashA != hashB {
+		t.Errorf("computeHash() differs for the same content: %s != %s", hashA, hashB)
+	}
+}
+
+// Entries written before api_key_id existed must keep their hashes
+func TestComputeHashWithoutAPIKey(t *testing.T) {
+	e := chain(t, 1)[0]
+	content, _ := json.Marshal(struct {
+		OccurredAt   string          `json:"occurred_at"`
+		ActorID      string          `json:"actor_id"`
+		Actor        string          `json:"actor"`
+		Action       string          `json:"action"`
+		ResourceType string          `json:"resource_type"`
+		ResourceID   string          `json:"resource_id"`
+		Success      bool            `json:"success"`
+		Details      string          `json:"details"`
+		IPAddress    string          `json:"ip_address"`
+		RequestID    string          `json:"request_id"`
+		Before       json.RawMessage `json:"before"`
+		After        json.RawMessage `json:"after"`
+	}{e.OccurredAt.Format(time.RFC3339Nano), e.ActorID, e.Actor, e.Action, e.ResourceType, e.ResourceID,
+		e.Success, e.Details, e.IPAddress, e.RequestID, e.Before, e.After})
+	sum := sha256.Sum256(append([]byte(genesisHash+"\n"), content...))
+	if want := hex.EncodeToString(sum[:]); e.Hash != want {
+		t.Errorf("hash = %s, want %s", e.Hash, want)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantWhere string
+		wantArgs  int
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "empty", query: "", wantLimit: DefaultLimit},
+		{
+			name:      "all filters",
+			query:     "actor=alice&action=slo.update&resource_type=slo&resource_id=42&from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00Z&cursor=100&limit=20",
+			wantWhere: "WHERE (actor = $1 OR actor_id = $1) AND action = $2 AND resource_type = $3 AND resource_id = $4 AND occurred_at >= $5 AND occurred_at < $6 AND id < $7",
+			wantArgs:  7,
+			wantLimit: 20,
+		},
+		{
+			name:      "api key",
+			query:     "api_key_id=key-1&actor=ci",
+			wantWhere: "WHERE (actor = $1 OR actor_id = $1) AND api_key_id = $2",
+			wantArgs:  2,
+			wantLimit: DefaultLimit,
+		},
+		{name: "limit capped", query: "limit=100000", wantLimit: MaxLimit},
+		{name: "bad time", query: "from=yesterday", wantErr: true},
+		{name: "inverted range", query: "from=2024-06-01T00:00:00Z&to=2024-05-01T00:00:00Z", wantErr: true},
+		{name: "bad cursor", query: "cursor=abc", wantErr: true},
+		{name: "bad limit", query: "limit=-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			f, err := ParseFilter(q)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseFilter(%q) = %+v, want error", tt.query, f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) error = %v", tt.query, err)
+			}
+			where, args := f.where()
+			if where != tt.wantWhere || len(args) != tt.wantArgs {
+				t.Errorf("where() = %q with %d args, want %q with %d", where, len(args), tt.wantWhere, tt.wantArgs)
+			}
+			if f.Limit != tt.wantLimit {
+				t.Errorf("Limit = %d, want %d", f.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestCompactJSON(t *testing.T) {
+	got, err := compactJSON(json.RawMessage("{ \"a\": [1, 2] }"))
+	if err != nil || !reflect.DeepEqual(got, json.RawMessage(`{"a":[1,2]}`)) {
+		t.Errorf("compactJSON() = %s, %v", got, err)
+	}
+	if _, err := compactJSON(json.RawMessage("{")); err == nil {
+		t.Error("compactJSON() accepted invalid JSON")
+	}
+}
@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFilter is returned for malformed audit query parameters
+var ErrInvalidFilter = errors.New("invalid audit filter")
+
+// Page sizes
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Filter selects audit entries. Empty fields match everything. Before is a
+// cursor: only entries with a lower ID are returned.
+type Filter struct {
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	APIKeyID     string
+	From         time.Time
+	To           time.Time
+	Before       int64
+	Limit        int
+}
+
+// ParseFilter reads a filter from query parameters: actor, action,
+// resource_type, resource_id, api_key_id, from and to (RFC 3339), cursor and
+// limit
+func ParseFilter(q url.Values) (Filter, error) {
+	f := Filter{
+		Actor:        q.Get("actor"),
+		Action:       q.Get("action"),
+		ResourceType: q.Get("resource_type"),
+		ResourceID:   q.Get("resource_id"),
+		APIKeyID:     q.Get("api_key_id"),
+		Limit:        DefaultLimit,
+	}
+	for name, dst := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("%w: %s must be an RFC 3339 time", ErrInvalidFilter, name)
+			}
+			*dst = t
+		}
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return f, fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor <= 0 {
+			return f, fmt.Errorf("%w: invalid cursor", ErrInvalidFilter)
+		}
+		f.Before = cursor
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return f, fmt.Errorf("%w: invalid limit", ErrInvalidFilter)
+		}
+		if limit > MaxLimit {
+			limit = MaxLimit
+		}
+		f.Limit = limit
+	}
+	return f, nil
+}
+
+// where builds the WHERE clause and arguments for f
+func (f Filter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if f.Actor != "" {
+		add("(actor = ? OR actor_id = ?)", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.ResourceType != "" {
+		add("resource_type = ?", f.ResourceType)
+	}
+	if f.ResourceID != "" {
+		add("resource_id = ?", f.ResourceID)
+	}
+	if f.APIKeyID != "" {
+		add("api_key_id = ?", f.APIKeyID)
+	}
+	if !f.From.IsZero() {
+		add("occurred_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		add("occurred_at < ?", f.To)
+	}
+	if f.Before > 0 {
+		add("id < ?", f.Before)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// Page is one page of entries, newest first. NextCursor is set when there
+// may be more entries.
+type Page struct {
+	Entries    []Entry `json:"entries"`
+	NextCursor int64   `json:"next_cursor,omitempty"`
+}
+
+// Query returns the entries matching f, newest first
+func (r *Recorder) Query(ctx context.Context, f Filter) (*Page, error) {
+	if f.Limit <= 0 || f.Limit > MaxLimit {
+		f.Limit = DefaultLimit
+	}
+	where, args := f.where()
+	args = append(args, f.Limit)
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s FROM audit_log %s ORDER BY id DESC LIMIT $%d
+	`, entryColumns, where, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	page := &Page{Entries: []Entry{}}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Entries) == f.Limit {
+		page.NextCursor = page.Entries[len(page.Entries)-1].ID
+	}
+	return page, nil
+}
+
+// Verification is the result of checking the hash chain
+type Verification struct {
+	Valid   bool   `json:"valid"`
+	Checked int64  `json:"checked"`
+	BadID   int64  `json:"bad_id,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// verifyChain checks entries in ID order, continuing from prevHash
+func verifyChain(prevHash string, entries []Entry, v *Verification) string {
+	for i := range entries {
+		e := &entries[i]
+		if e.PrevHash != prevHash {
+			v.Valid, v.BadID, v.Reason = false, e.ID, "previous hash does not match the preceding entry"
+			return prevHash
+		}
+		hash, err := computeHash(prevHash, e)
+		if err != nil || hash != e.Hash {
+			v.Valid, v.BadID, v.Reason = false, e.ID, "content does not match its hash"
+			return prevHash
+		}
+		v.Checked++
+		prevHash = e.Hash
+	}
+	return prevHash
+}
+
+// Verify recomputes the hash chain and reports the first entry that was
+// changed, or that follows a removed entry
+func (r *Recorder) Verify(ctx context.Context) (*Verification, error) {
+	const batch = 1000
+	v := &Verification{Valid: true}
+	prevHash := genesisHash
+	var lastID int64
+
+	for {
+		rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+			SELECT %s FROM audit_log WHERE id > $1 ORDER BY id LIMIT %d
+		`, entryColumns, batch), lastID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		var entries []Entry
+		for rows.Next() {
+			e, err := scanEntry(rows)
+			if err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+			}
+			entries = append(entries, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		prevHash = verifyChain(prevHash, entries, v)
+		if !v.Valid || len(entries) < batch {
+			return v, nil
+		}
+		lastID = entries[len(entries)-1].ID
+	}
+}
@@ -0,0 +1,186 @@
+// Package auth resolves who is making a request: through OIDC single sign-on,
+// or from the identity headers of a trusted Grafana plugin proxy. Local
+// username and password login lives in the middleware package.
+package auth
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Authentication modes. Several can be enabled at once.
+const (
+	ModeLocal   = "local"
+	ModeOIDC    = "oidc"
+	ModeGrafana = "grafana"
+	// ModeNone treats every request as an admin. For local development only.
+	ModeNone = "none"
+)
+
+// Roles used by RequireRole
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Identity is an authenticated user
+type Identity struct {
+	Provider string
+	Subject  string
+	Username string
+	Email    string
+	OrgID    string
+	Roles    []string
+}
+
+// Config selects the enabled modes and configures each of them
+type Config struct {
+	Modes   []string
+	Roles   RoleMapping
+	Grafana GrafanaConfig
+	OIDC    OIDCConfig
+	// TrustedProxies may set X-Forwarded-For and X-Real-IP; see ClientIP
+	TrustedProxies []*net.IPNet
+}
+
+// Enabled reports whether mode is enabled
+func (c Config) Enabled(mode string) bool {
+	for _, m := range c.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseModes parses a comma separated list of modes
+func ParseModes(value string) ([]string, error) {
+	var modes []string
+	for _, m := range strings.Split(value, ",") {
+		m = strings.ToLower(strings.TrimSpace(m))
+		switch m {
+		case "":
+			continue
+		case ModeLocal, ModeOIDC, ModeGrafana, ModeNone:
+			modes = append(modes, m)
+		default:
+			return nil, fmt.Errorf("unknown auth mode %q (use local, oidc, grafana or none)", m)
+		}
+	}
+	if len(modes) == 0 {
+		return nil, fmt.Errorf("no auth mode set")
+	}
+	for _, m := range modes {
+		if m == ModeNone && len(modes) > 1 {
+			return nil, fmt.Errorf("auth mode none can't be combined with other modes")
+		}
+	}
+	return modes, nil
+}
+
+// LoadConfigFromEnv reads AUTH_MODE (default local; AUTH_ENABLED=false is
+// the same as none), AUTH_ROLE_MAPPING, AUTH_DEFAULT_ROLE, TRUSTED_PROXIES and
+// the settings of the enabled modes
+func LoadConfigFromEnv() (Config, error) {
+	modeValue := os.Getenv("AUTH_MODE")
+	if modeValue == "" {
+		modeValue = ModeLocal
+		if os.Getenv("AUTH_ENABLED") == "false" {
+			modeValue = ModeNone
+		}
+	}
+	modes, err := ParseModes(modeValue)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid AUTH_MODE: %w", err)
+	}
+	config := Config{Modes: modes}
+
+	defaultRole := os.Getenv("AUTH_DEFAULT_ROLE")
+	if defaultRole == "" {
+		defaultRole = RoleViewer
+	}
+	if config.Roles, err = ParseRoleMapping(os.Getenv("AUTH_ROLE_MAPPING"), defaultRole); err != nil {
+		return config, fmt.Errorf("invalid AUTH_ROLE_MAPPING: %w", err)
+	}
+	if config.TrustedProxies, err = ParseCIDRs(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		return config, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	if config.Enabled(ModeGrafana) {
+		if config.Grafana, err = loadGrafanaConfig(); err != nil {
+			return config, err
+		}
+	}
+	if config.Enabled(ModeOIDC) {
+		if config.OIDC, err = loadOIDCConfig(); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// RoleMapping maps IdP groups and Grafana roles to Reliability Studio roles.
+// Names are matched case-insensitively; admin, editor and viewer always map to
+// themselves.
+type RoleMapping struct {
+	roles       map[string][]string
+	defaultRole string
+}
+
+// ParseRoleMapping parses a comma separated list of name=role pairs such as
+// "Admin=admin,sre-oncall=editor". A name may be listed more than once to
+// grant several roles. defaultRole is given to users no name maps for; empty
+// gives them no roles.
+func ParseRoleMapping(value, defaultRole string) (RoleMapping, error) {
+	m := RoleMapping{roles: make(map[string][]string), defaultRole: defaultRole}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, role, ok := strings.Cut(pair, "=")
+		name, role = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(role)
+		if !ok || name == "" || role == "" {
+			return m, fmt.Errorf("invalid role mapping %q (use name=role)", pair)
+		}
+		m.roles[name] = append(m.roles[name], role)
+	}
+	// Explicit entries for the built-in names replace the identity mapping
+	for _, role := range []string{RoleAdmin, RoleEditor, RoleViewer} {
+		if _, ok := m.roles[role]; !ok {
+			m.roles[role] = []string{role}
+		}
+	}
+	return m, nil
+}
+
+// Map returns the roles for a user's groups or Grafana roles, sorted and
+// without duplicates
+func (m RoleMapping) Map(names []string) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, name := range names {
+		for _, role := range m.roles[strings.ToLower(strings.TrimSpace(name))] {
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	if len(roles) == 0 && m.defaultRole != "" {
+		roles = []string{m.defaultRole}
+	}
+	sort.Strings(roles)
+	return roles
+}
@@ -0,0 +1,458 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"single", "local", []string{"local"}, false},
+		{"several", "local, OIDC,grafana", []string{"local", "oidc", "grafana"}, false},
+		{"none alone", "none", []string{"none"}, false},
+		{"none combined", "none,local", nil, true},
+		{"unknown", "ldap", nil, true},
+		{"empty", " , ", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseModes(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("modes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoleMapping(t *testing.T) {
+	m, err := ParseRoleMapping("Admin=admin, sre-oncall=editor, sre-oncall=viewer, viewer=editor", RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"grafana admin", []string{"Admin"}, []string{"admin"}},
+		{"case insensitive", []string{"SRE-Oncall"}, []string{"editor", "viewer"}},
+		{"builtin identity", []string{"editor"}, []string{"editor"}},
+		{"builtin overridden", []string{"viewer"}, []string{"editor"}},
+		{"duplicates removed", []string{"editor", "sre-oncall"}, []string{"editor", "viewer"}},
+		{"no match gets default", []string{"marketing"}, []string{"viewer"}},
+		{"nothing gets default", nil, []string{"viewer"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Map(tt.names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Map(%v) = %v, want %v", tt.names, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseRoleMapping("admin", RoleViewer); err == nil {
+		t.Error("expected an error for a pair without a role")
+	}
+	noDefault, _ := ParseRoleMapping("", "")
+	if got := noDefault.Map([]string{"marketing"}); len(got) != 0 {
+		t.Errorf("Map without default = %v, want no roles", got)
+	}
+}
+
+func TestGrafanaIdentity(t *testing.T) {
+	proxies, err := ParseCIDRs("10.0.0.0/8, 192.168.1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, _ := ParseRoleMapping("Admin=admin,Editor=editor", RoleViewer)
+	base := GrafanaConfig{
+		UserHeader:   "X-Grafana-User",
+		EmailHeader:  "X-Grafana-Email",
+		OrgHeader:    "X-Grafana-Org-Id",
+		RoleHeader:   "X-Grafana-Role",
+		SecretHeader: "X-Grafana-Proxy-Secret",
+	}
+	withSecret := base
+	withSecret.Secret = "s3cret"
+	withProxies := base
+	withProxies.TrustedProxies = proxies
+
+	tests := []struct {
+		name      string
+		config    GrafanaConfig
+		remote    string
+		headers   map[string]string
+		wantOK    bool
+		wantErr   bool
+		wantRoles []string
+	}{
+		{
+			name:      "secret matches",
+			config:    withSecret,
+			remote:    "203.0.113.9:5000",
+			headers:   map[string]string{"X-Grafana-User": "alice", "X-Grafana-Role": "Admin", "X-Grafana-Proxy-Secret": "s3cret"},
+			wantOK:    true,
+			wantRoles: []string{"admin"},
+		},
+		{
+			name:    "wrong secret",
+			config:  withSecret,
+			remote:  "203.0.113.9:5000",
+			headers: map[string]string{"X-Grafana-User": "alice", "X-Grafana-Proxy-Secret": "guess"},
+			wantOK:  true,
+			wantErr: true,
+		},
+		{
+			name:      "trusted proxy range",
+			config:    withProxies,
+			remote:    "10.1.2.3:5000",
+			headers:   map[string]string{"X-Grafana-User": "bob", "X-Grafana-Role": "Editor"},
+			wantOK:    true,
+			wantRoles: []string{"editor"},
+		},
+		{
+			name:      "trusted single address",
+			config:    withProxies,
+			remote:    "192.168.1.5:5000",
+			headers:   map[string]string{"X-Grafana-User": "bob"},
+			wantOK:    true,
+			wantRoles: []string{"viewer"},
+		},
+		{
+			name:    "untrusted address ignores forwarded for",
+			config:  withProxies,
+			remote:  "203.0.113.9:5000",
+			headers: map[string]string{"X-Grafana-User": "bob", "X-Forwarded-For": "10.1.2.3"},
+			wantOK:  true,
+			wantErr: true,
+		},
+		{
+			name:    "no user header",
+			config:  withSecret,
+			remote:  "203.0.113.9:5000",
+			headers: map[string]string{"X-Grafana-Proxy-Secret": "s3cret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/incidents", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			id, ok, err := tt.config.Identity(r, roles)
+			if ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Fatalf("ok = %v, err = %v; want ok %v, wantErr %v", ok, err, tt.wantOK, tt.wantErr)
+			}
+			if tt.wantRoles != nil && !reflect.DeepEqual(id.Roles, tt.wantRoles) {
+				t.Errorf("roles = %v, want %v", id.Roles, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseCIDRs("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"direct", "203.0.113.9:5000", nil, "203.0.113.9"},
+		{"IPv6", "[2001:db8::1]:5000", nil, "2001:db8::1"},
+		{"untrusted peer ignores forwarded for", "203.0.113.9:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.9"},
+		{"untrusted peer ignores real ip", "203.0.113.9:5000", map[string]string{"X-Real-IP": "198.51.100.1"}, "203.0.113.9"},
+		{"trusted proxy", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed hop before the proxy", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "192.0.2.77, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of proxies", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+		{"garbage hop", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "nonsense, 10.0.0.3"}, "10.0.0.3"},
+		{"trusted real ip", "10.0.0.2:5000", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/auth/login", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := ClientIP(r, proxies); got != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginState(t *testing.T) {
+	secret := []byte("test-secret")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state, err := NewLoginState(10*time.Minute, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := state.Seal(secret)
+
+	got, err := OpenLoginState(secret, sealed, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("OpenLoginState: %v", err)
+	}
+	if got != state {
+		t.Errorf("state = %+v, want %+v", got, state)
+	}
+
+	encoded, sig, _ := strings.Cut(sealed, ".")
+	tampered, _ := json.Marshal(LoginState{State: "attacker", Expires: state.Expires})
+	tests := []struct {
+		name   string
+		secret []byte
+		sealed string
+		now    time.Time
+	}{
+		{"expired", secret, sealed, now.Add(11 * time.Minute)},
+		{"wrong secret", []byte("other"), sealed, now},
+		{"tampered payload", secret, base64.RawURLEncoding.EncodeToString(tampered) + "." + sig, now},
+		{"no signature", secret, encoded, now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := OpenLoginState(tt.secret, tt.sealed, tt.now); err != ErrInvalidState {
+				t.Errorf("err = %v, want ErrInvalidState", err)
+			}
+		})
+	}
+}
+
+func TestClaimStrings(t *testing.T) {
+	claims := map[string]interface{}{
+		"groups":       []interface{}{"sre", "dev", 3},
+		"role":         "admin",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"editor"}},
+	}
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"groups", []string{"sre", "dev"}},
+		{"role", []string{"admin"}},
+		{"realm_access.roles", []string{"editor"}},
+		{"realm_access.missing", nil},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := claimStrings(claims, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("claimStrings(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// mockIdP is a minimal OIDC provider: discovery, JWKS and a token endpoint
+// that checks the PKCE verifier of the one code it hands out
+type mockIdP struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	code      string
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, code: "auth-code"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != idp.code || pkceChallenge(r.Form.Get("code_verifier")) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":   idp.server.URL,
+			"aud":   r.Form.Get("client_id"),
+			"sub":   "user-123",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": idp.nonce,
+		}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test-key"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "id_token": signed})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize plays the user signing in: it records the challenge and nonce
+// from the login URL like the real authorization endpoint would
+func (idp *mockIdP) authorize(t *testing.T, loginURL string) {
+	t.Helper()
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("response_type") != "code" {
+		t.Fatalf("unexpected login URL %s", loginURL)
+	}
+	idp.challenge, idp.nonce = q.Get("code_challenge"), q.Get("nonce")
+}
+
+func TestOIDCFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	roles, _ := ParseRoleMapping("sre=editor,platform-admins=admin", RoleViewer)
+	config := OIDCConfig{
+		IssuerURL:   idp.server.URL,
+		ClientID:    "reliability-studio",
+		RedirectURL: "http://localhost:9000/api/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+		RolesClaim:  "groups",
+	}
+	provider, err := NewOIDCProvider(context.Background(), config, roles)
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		claims       jwt.MapClaims
+		verifier     string
+		nonce        string
+		wantErr      bool
+		wantUsername string
+		wantRoles    []string
+	}{
+		{
+			name:         "groups mapped to roles",
+			claims:       jwt.MapClaims{"preferred_username": "alice", "email": "alice@example.com", "groups": []string{"sre", "platform-admins"}},
+			wantUsername: "alice",
+			wantRoles:    []string{"admin", "editor"},
+		},
+		{
+			name:         "email as username and default role",
+			claims:       jwt.MapClaims{"email": "bob@example.com"},
+			wantUsername: "bob@example.com",
+			wantRoles:    []string{"viewer"},
+		},
+		{
+			name:     "wrong verifier",
+			verifier: "not-the-verifier",
+			wantErr:  true,
+		},
+		{
+			name:    "nonce mismatch",
+			nonce:   "replayed-nonce",
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			claims:  jwt.MapClaims{"aud": "another-client"},
+			wantErr: true,
+		},
+		{
+			name:    "expired token",
+			claims:  jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := NewLoginState(time.Minute, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			idp.claims = tt.claims
+			idp.authorize(t, provider.AuthCodeURL(state.State, state.Nonce, state.Verifier))
+
+			verifier, nonce := state.Verifier, state.Nonce
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			id, err := provider.Exchange(context.Background(), idp.code, verifier, nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if id.Subject != "user-123" || id.Provider != ModeOIDC {
+				t.Errorf("identity = %+v", id)
+			}
+			if id.Username != tt.wantUsername {
+				t.Errorf("username = %q, want %q", id.Username, tt.wantUsername)
+			}
+			if !reflect.DeepEqual(id.Roles, tt.wantRoles) {
+				t.Errorf("roles = %v, want %v", id.Roles, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestNewOIDCProviderIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(idp.server.URL, "http://"))
+	config := OIDCConfig{IssuerURL: "http://localhost:" + port, ClientID: "x"}
+	if _, err := NewOIDCProvider(context.Background(), config, RoleMapping{}); err == nil {
+		t.Error("expected an issuer mismatch error")
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrUntrustedProxy is returned when Grafana identity headers come from a
+// client that isn't a trusted proxy
+var ErrUntrustedProxy = errors.New("identity headers from untrusted client")
+
+// GrafanaConfig trusts the user, org and role headers set by the Grafana
+// plugin proxy. Requests must carry the shared secret header, come from a
+// trusted proxy address, or both when both are set.
+type GrafanaConfig struct {
+	UserHeader     string
+	EmailHeader    string
+	OrgHeader      string
+	RoleHeader     string
+	SecretHeader   string
+	Secret         string
+	TrustedProxies []*net.IPNet
+}
+
+func loadGrafanaConfig() (GrafanaConfig, error) {
+	c := GrafanaConfig{
+		UserHeader:   getEnv("GRAFANA_USER_HEADER", "X-Grafana-User"),
+		EmailHeader:  getEnv("GRAFANA_EMAIL_HEADER", "X-Grafana-Email"),
+		OrgHeader:    getEnv("GRAFANA_ORG_HEADER", "X-Grafana-Org-Id"),
+		RoleHeader:   getEnv("GRAFANA_ROLE_HEADER", "X-Grafana-Role"),
+		SecretHeader: getEnv("GRAFANA_PROXY_SECRET_HEADER", "X-Grafana-Proxy-Secret"),
+		Secret:       os.Getenv("GRAFANA_PROXY_SECRET"),
+	}
+	var err error
+	if c.TrustedProxies, err = ParseCIDRs(os.Getenv("GRAFANA_TRUSTED_PROXIES")); err != nil {
+		return c, fmt.Errorf("invalid GRAFANA_TRUSTED_PROXIES: %w", err)
+	}
+	if c.Secret == "" && len(c.TrustedProxies) == 0 {
+		return c, fmt.Errorf("auth mode grafana needs GRAFANA_PROXY_SECRET or GRAFANA_TRUSTED_PROXIES")
+	}
+	return c, nil
+}
+
+// ParseCIDRs parses a comma separated list of CIDRs or single addresses
+func ParseCIDRs(value string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// trusted checks the shared secret and the address of the connecting peer.
+// Forwarded-for headers are ignored; they are set by the client.
+func (c GrafanaConfig) trusted(r *http.Request) bool {
+	if c.Secret != "" {
+		got := r.Header.Get(c.SecretHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(c.Secret)) != 1 {
+			return false
+		}
+	}
+	if len(c.TrustedProxies) > 0 {
+		return containsIP(c.TrustedProxies, remoteIP(r))
+	}
+	return true
+}
+
+// Identity returns the user named by the proxy headers. ok is false when the
+// request has no user header; an error means the headers can't be trusted.
+func (c GrafanaConfig) Identity(r *http.Request, roles RoleMapping) (identity *Identity, ok bool, err error) {
+	user := strings.TrimSpace(r.Header.Get(c.UserHeader))
+	if user == "" {
+		return nil, false, nil
+	}
+	if !c.trusted(r) {
+		return nil, true, ErrUntrustedProxy
+	}
+
+	var grafanaRoles []string
+	for _, role := range strings.Split(r.Header.Get(c.RoleHeader), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			grafanaRoles = append(grafanaRoles, role)
+		}
+	}
+	return &Identity{
+		Provider: ModeGrafana,
+		Subject:  user,
+		Username: user,
+		Email:    strings.TrimSpace(r.Header.Get(c.EmailHeader)),
+		OrgID:    strings.TrimSpace(r.Header.Get(c.OrgHeader)),
+		Roles:    roles.Map(grafanaRoles),
+	}, true, nil
+}
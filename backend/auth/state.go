@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidState is returned for a missing, tampered or expired login state
+var ErrInvalidState = errors.New("invalid or expired login state")
+
+// LoginState is kept in a signed cookie between sending the user to the
+// identity provider and the callback, so any replica can finish the login
+type LoginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Expires  int64  `json:"expires"`
+}
+
+// NewLoginState creates a state with fresh random values
+func NewLoginState(ttl time.Duration, now time.Time) (LoginState, error) {
+	var s LoginState
+	var err error
+	for _, dst := range []*string{&s.State, &s.Nonce, &s.Verifier} {
+		if *dst, err = RandomToken(); err != nil {
+			return s, err
+		}
+	}
+	s.Expires = now.Add(ttl).Unix()
+	return s, nil
+}
+
+// Seal encodes and signs s
+func (s LoginState) Seal(secret []byte) string {
+	payload, _ := json.Marshal(s)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(secret, encoded)
+}
+
+// OpenLoginState verifies a sealed state and checks that it hasn't expired
+func OpenLoginState(secret []byte, sealed string, now time.Time) (LoginState, error) {
+	var s LoginState
+	encoded, sig, ok := strings.Cut(sealed, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(sign(secret, encoded))) {
+		return s, ErrInvalidState
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(payload, &s) != nil {
+		return s, ErrInvalidState
+	}
+	if now.Unix() > s.Expires {
+		return s, ErrInvalidState
+	}
+	return s, nil
+}
+
+func sign(secret []byte, value string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("oidc-login-state:" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -5,6 +5,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -14,13 +17,19 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// KubernetesClient talks to one or more clusters. The embedded clientset is the
+// default cluster; use InCluster to get a client bound to another configured context.
 type KubernetesClient struct {
-	clientset *kubernetes.Clientset
+	clientset      *kubernetes.Clientset
+	clusterName    string
+	clusters       map[string]*kubernetes.Clientset
+	defaultCluster string
 }
 
 type PodStatus struct {
 	Name      string            `json:"name"`
 	Namespace string            `json:"namespace"`
+	Cluster   string            `json:"cluster,omitempty"`
 	Status    string            `json:"status"`
 	Restarts  int32             `json:"restarts"`
 	Age       string            `json:"age"`
@@ -35,29 +44,96 @@ type K8sEvent struct {
 	Object    string    `json:"object"`
 }
 
+// NewKubernetesClient builds a client from KUBE_CONTEXTS, a comma-separated list of
+// kubeconfig contexts (read from KUBECONFIG or ~/.kube/config). Each context is
+// addressable by name as a cluster; the first one is the default. Without
+// KUBE_CONTEXTS the in-cluster config is used as the only cluster.
 func NewKubernetesClient() (*KubernetesClient, error) {
-	var config *rest.Config
-	var err error
-	kubeconfigPath := "" // Use default logic
-
-	if kubeconfigPath != "" {
-		// Use kubeconfig file
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
-	} else {
-		// Use in-cluster config
-		config, err = rest.InClusterConfig()
+	contexts := splitList(os.Getenv("KUBE_CONTEXTS"))
+	if len(contexts) == 0 {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes config: %w", err)
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		return newMultiClusterClient(map[string]*kubernetes.Clientset{InClusterName: clientset}, InClusterName), nil
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create kubernetes config: %w", err)
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	clusters := make(map[string]*kubernetes.Clientset, len(contexts))
+	for _, name := range contexts {
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			loadingRules,
+			&clientcmd.ConfigOverrides{CurrentContext: name},
+		).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig context %s: %w", name, err)
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client for context %s: %w", name, err)
+		}
+		clusters[name] = clientset
 	}
+	return newMultiClusterClient(clusters, contexts[0]), nil
+}
 
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+// InClusterName is the cluster name used when running with the in-cluster config
+const InClusterName = "in-cluster"
+
+func newMultiClusterClient(clusters map[string]*kubernetes.Clientset, defaultCluster string) *KubernetesClient {
+	return &KubernetesClient{
+		clientset:      clusters[defaultCluster],
+		clusterName:    defaultCluster,
+		clusters:       clusters,
+		defaultCluster: defaultCluster,
+	}
+}
+
+// Clusters returns the names of all configured clusters
+func (k *KubernetesClient) Clusters() []string {
+	names := make([]string, 0, len(k.clusters))
+	for name := range k.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ClusterName returns the cluster this client is bound to
+func (k *KubernetesClient) ClusterName() string {
+	return k.clusterName
+}
+
+// InCluster returns a client bound to the named cluster. An empty name means the
+// default cluster.
+func (k *KubernetesClient) InCluster(name string) (*KubernetesClient, error) {
+	if name == "" || name == k.clusterName {
+		return k, nil
+	}
+	clientset, ok := k.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown kubernetes cluster %q", name)
 	}
+	return &KubernetesClient{
+		clientset:      clientset,
+		clusterName:    name,
+		clusters:       k.clusters,
+		defaultCluster: k.defaultCluster,
+	}, nil
+}
 
-	return &KubernetesClient{clientset: clientset}, nil
+func splitList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
 }
 
 // GetFailedPods returns all pods that are not in Running state
@@ -89,6 +165,7 @@ func (k *KubernetesClient) GetFailedPods(ctx context.Context, namespace string)
 			failedPods = append(failedPods, PodStatus{
 				Name:      pod.Name,
 				Namespace: pod.Namespace,
+				Cluster:   k.clusterName,
 				Status:    status,
 				Restarts:  totalRestarts,
 				Age:       formatAge(age),
@@ -131,13 +208,17 @@ func (k *KubernetesClient) GetRecentEvents(ctx context.Context, namespace string
 
 // GetPodsByLabel returns pods matching specific labels
 func (k *KubernetesClient) GetPodsByLabel(ctx context.Context, namespace string, labels map[string]string) ([]PodStatus, error) {
-	if namespace == "" {
-		namespace = corev1.NamespaceAll
-	}
-
 	labelSelector := metav1.FormatLabelSelector(&metav1.LabelSelector{
 		MatchLabels: labels,
 	})
+	return k.GetPodsBySelector(ctx, namespace, labelSelector)
+}
+
+// GetPodsBySelector returns pods matching a label selector such as "app=api,tier=web"
+func (k *KubernetesClient) GetPodsBySelector(ctx context.Context, namespace, labelSelector string) ([]PodStatus, error) {
+	if namespace == "" {
+		namespace = corev1.NamespaceAll
+	}
 
 	pods, err := k.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: labelSelector,
@@ -159,6 +240,7 @@ func (k *KubernetesClient) GetPodsByLabel(ctx context.Context, namespace string,
 		podStatuses = append(podStatuses, PodStatus{
 			Name:      pod.Name,
 			Namespace: pod.Namespace,
+			Cluster:   k.clusterName,
 			Status:    string(pod.Status.Phase),
 			Restarts:  totalRestarts,
 			Age:       formatAge(age),
@@ -169,6 +251,15 @@ func (k *KubernetesClient) GetPodsByLabel(ctx context.Context, namespace string,
 	return podStatuses, nil
 }
 
+// GetPodsInCluster returns pods matching a label selector in the given cluster and namespace
+func (k *KubernetesClient) GetPodsInCluster(ctx context.Context, cluster, namespace, labelSelector string) ([]PodStatus, error) {
+	client, err := k.InCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPodsBySelector(ctx, namespace, labelSelector)
+}
+
 // GetPodLogs returns logs from a specific pod
 func (k *KubernetesClient) GetPodLogs(ctx context.Context, namespace, podName string, tailLines int64) (string, error) {
 	podLogOpts := corev1.PodLogOptions{
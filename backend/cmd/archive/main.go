@@ -0,0 +1,102 @@
+// Command archive applies the retention policies, lists archived incidents
+// and restores them. It reads the same RETENTION_POLICIES,
+// INCIDENT_ARCHIVE_AFTER and ARCHIVE_DIR variables as the server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/database"
+	"github.com/sarika-03/Reliability-Studio/retention"
+)
+
+func main() {
+	limit := flag.Int("limit", 50, "number of archived incidents to list")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s run | list | archive <incident-id> | restore <incident-id>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	cmd := flag.Arg(0)
+	needsID := cmd == "archive" || cmd == "restore"
+	if flag.NArg() == 0 || (needsID && flag.NArg() != 2) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config, err := retention.LoadConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := database.Connect(database.LoadConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	store, err := retention.NewDirStore(config.ArchiveDir)
+	if err != nil {
+		log.Fatalf("Failed to open archive directory: %v", err)
+	}
+	archiver := retention.NewArchiver(db, store)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	switch cmd {
+	case "run":
+		result := retention.NewScheduler(db, archiver, config).RunOnce(ctx)
+		fmt.Printf("Archived %d incident(s)\n", result.Archived)
+		for _, p := range config.Policies {
+			fmt.Printf("Pruned %d row(s) from %s\n", result.Pruned[p.Table], p.Table)
+		}
+		if result.Failed > 0 {
+			log.Fatalf("%d step(s) failed", result.Failed)
+		}
+	case "list":
+		printArchives(ctx, archiver, *limit)
+	case "archive":
+		entry, err := archiver.Archive(ctx, flag.Arg(1))
+		if err != nil {
+			log.Fatalf("Failed to archive incident: %v", err)
+		}
+		fmt.Printf("Archived %d row(s) to %s\n", entry.RowCount, entry.Location)
+	case "restore":
+		n, err := archiver.Restore(ctx, flag.Arg(1))
+		if errors.Is(err, retention.ErrNotArchived) {
+			log.Fatalf("No archive for incident %s in %s", flag.Arg(1), config.ArchiveDir)
+		}
+		if err != nil {
+			log.Fatalf("Failed to restore incident: %v", err)
+		}
+		fmt.Printf("Restored %d row(s)\n", n)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func printArchives(ctx context.Context, archiver *retention.Archiver, limit int) {
+	archived, err := archiver.List(ctx, limit)
+	if err != nil {
+		log.Fatalf("Failed to list archived incidents: %v", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INCIDENT\tSEVERITY\tTITLE\tARCHIVED AT\tRESTORED AT")
+	for _, a := range archived {
+		restored := "-"
+		if a.RestoredAt != nil {
+			restored = a.RestoredAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.IncidentID, a.Severity, a.Title, a.ArchivedAt.Format(time.RFC3339), restored)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,76 @@
+// Command correlation-eval evaluates root cause candidates against responder
+// feedback and reports precision and recall per signal type. With -tune it also
+// stores per-service scoring weights derived from the feedback of each
+// service's organization.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/correlation"
+	"github.com/sarika-03/Reliability-Studio/database"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	tune := flag.Bool("tune", false, "store per-service scoring weights tuned from feedback")
+	minSamples := flag.Int("min-samples", 5, "minimum judged candidates per service and signal type before tuning")
+	flag.Parse()
+
+	db, err := database.Connect(database.LoadConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	engine := correlation.NewCorrelationEngine(db, nil, nil, nil)
+	samples, err := engine.LoadFeedbackSamples(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load feedback: %v", err)
+	}
+
+	report := correlation.Evaluate(samples)
+	var tuned []correlation.TunedWeight
+	if *tune {
+		tuned = correlation.TuneServiceWeights(samples, correlation.DefaultScoringWeights(), *minSamples)
+		if err := engine.SaveServiceWeights(ctx, tuned); err != nil {
+			log.Fatalf("Failed to save tuned weights: %v", err)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(map[string]interface{}{"report": report, "tuned_weights": tuned})
+		return
+	}
+
+	fmt.Printf("Evaluated %d incidents with feedback\n\n", report.Incidents)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SIGNAL TYPE\tTP\tFP\tRELEVANT\tDETECTED\tPRECISION\tRECALL")
+	for _, m := range append(report.BySignalType, report.Overall) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.3f\t%.3f\n",
+			m.SignalType, m.TruePositives, m.FalsePositives, m.Relevant, m.Detected, m.Precision, m.Recall)
+	}
+	tw.Flush()
+
+	if *tune {
+		fmt.Printf("\nStored %d tuned weights\n", len(tuned))
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ORG\tSERVICE\tSIGNAL TYPE\tWEIGHT\tSAMPLES\tPRECISION")
+		for _, t := range tuned {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.3f\t%d\t%.3f\n", t.OrgID, t.Service, t.SignalType, t.Weight, t.Samples, t.Precision)
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,87 @@
+// Command migrate shows and changes the applied database migrations. The
+// server applies pending migrations on start; this command is for checking the
+// state and for rolling back.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/database"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s status | up | down | to <version>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := database.Connect(database.LoadConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	var ran int
+	switch cmd := flag.Arg(0); cmd {
+	case "status":
+		printStatus(ctx, migrator)
+		return
+	case "up":
+		ran, err = migrator.Up(ctx)
+	case "down":
+		ran, err = migrator.Down(ctx)
+	case "to":
+		version, convErr := strconv.Atoi(flag.Arg(1))
+		if flag.NArg() != 2 || convErr != nil {
+			flag.Usage()
+			os.Exit(2)
+		}
+		ran, err = migrator.To(ctx, version)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed after %d migration(s): %v", ran, err)
+	}
+	fmt.Printf("Ran %d migration(s)\n", ran)
+}
+
+func printStatus(ctx context.Context, migrator *database.Migrator) {
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read migration status: %v", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range status {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	tw.Flush()
+}
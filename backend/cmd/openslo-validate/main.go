@@ -0,0 +1,87 @@
+// Command openslo-validate checks OpenSLO documents without a database so it can
+// run as a pre-merge check. Arguments are files or directories; directories are
+// searched recursively for *.yaml and *.yml files. All documents are validated
+// together so references across files resolve.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sarika-03/Reliability-Studio/openslo"
+)
+
+func main() {
+	quiet := flag.Bool("q", false, "only print errors")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-q] <file or directory>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := collectFiles(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to list files: %v", err)
+	}
+
+	var docs []openslo.Document
+	var errs []openslo.Error
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file, err)
+		}
+		fileDocs, fileErrs := openslo.Parse(file, data)
+		docs = append(docs, fileDocs...)
+		errs = append(errs, fileErrs...)
+	}
+	errs = append(errs, openslo.Validate(docs)...)
+
+	for _, e := range errs {
+		fmt.Fprintln(os.Stderr, e.Error())
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "%d error(s) in %d file(s)\n", len(errs), len(files))
+		os.Exit(1)
+	}
+	if !*quiet {
+		fmt.Printf("%d document(s) in %d file(s) are valid\n", len(docs), len(files))
+	}
+}
+
+func collectFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
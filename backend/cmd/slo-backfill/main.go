@@ -0,0 +1,71 @@
+// Command slo-backfill computes historical SLI values from Prometheus and
+// stores them as SLO history, for SLOs created after their metrics started.
+// Only the time before the first stored row of each SLO is backfilled.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/clients"
+	"github.com/sarika-03/Reliability-Studio/database"
+	"github.com/sarika-03/Reliability-Studio/services"
+	"go.uber.org/zap"
+)
+
+func main() {
+	sloID := flag.String("slo", "", "SLO ID to backfill (default: all SLOs)")
+	days := flag.Int("days", 0, "days to backfill (default: the SLO window)")
+	step := flag.Duration("step", 5*time.Minute, "resolution of the backfilled values")
+	flag.Parse()
+
+	db, err := database.Connect(database.LoadConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	promURL := os.Getenv("PROMETHEUS_URL")
+	if promURL == "" {
+		promURL = "http://prometheus:9090"
+	}
+	sloService := services.NewSLOService(db, zap.NewNop())
+	sloService.SetPrometheusClient(clients.NewPrometheusClient(promURL))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	var slos []services.SLO
+	if *sloID != "" {
+		slo, err := sloService.GetSLO(ctx, *sloID)
+		if err != nil {
+			log.Fatalf("Failed to load SLO %s: %v", *sloID, err)
+		}
+		slos = append(slos, *slo)
+	} else if slos, err = sloService.GetAllSLOs(ctx, ""); err != nil {
+		log.Fatalf("Failed to load SLOs: %v", err)
+	}
+
+	failed := 0
+	for _, slo := range slos {
+		window := *days
+		if window <= 0 {
+			window = slo.Window
+		}
+		from := time.Now().AddDate(0, 0, -window)
+		n, err := sloService.BackfillHistory(ctx, slo.ID, from, *step)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s/%s: %v\n", slo.Service, slo.Name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s/%s: stored %d points\n", slo.Service, slo.Name, n)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -22,6 +22,7 @@ type CorrelationEngine struct {
 	lokiClient      LokiClient
 	workerSemaphore chan struct{} // Bounded worker pool
 	mu              sync.RWMutex  // Protects correlations slice
+	similarity      similarityCache
 }
 
 type PrometheusClient interface {
@@ -31,7 +32,7 @@ type PrometheusClient interface {
 }
 
 type KubernetesClient interface {
-	GetPods(ctx context.Context, namespace, service string) ([]clients.PodStatus, error)
+	GetPodsInCluster(ctx context.Context, cluster, namespace, labelSelector string) ([]clients.PodStatus, error)
 }
 
 type LokiClient interface {
@@ -60,8 +61,11 @@ type RootCauseSummary struct {
 }
 
 type IncidentContext struct {
+	OrgID        string // organization of the incident, whose tuned weights apply
 	Service      string
 	Namespace    string
+	Cluster      string
+	Targets      []ServiceTarget // where each affected service runs
 	StartTime    time.Time
 	Severity     string
 	AffectedPods []clients.PodStatus
@@ -73,8 +77,10 @@ type IncidentContext struct {
 	IncidentConfidence float64            `json:"incident_confidence"`
 	RootCauseSummary   []RootCauseSummary `json:"root_cause_summary"`
 	Correlations       []Correlation      `json:"correlations"`
+	Run                *CorrelationRun    `json:"run,omitempty"`
 }
 
+// Default signal weights; see ScoringWeights for per-service overrides
 const (
 	metricWeight = 0.5
 	logWeight    = 0.3
@@ -94,6 +100,12 @@ func NewCorrelationEngine(db *sql.DB, promClient PrometheusClient, k8sClient Kub
 
 // CorrelateIncident performs comprehensive correlation for an incident with bounded concurrency
 func (e *CorrelationEngine) CorrelateIncident(ctx context.Context, incidentID, service, namespace string, startTime time.Time) (*IncidentContext, error) {
+	return e.CorrelateIncidentWithTrigger(ctx, incidentID, service, namespace, startTime, TriggerAuto)
+}
+
+// CorrelateIncidentWithTrigger runs correlation and records it as a new versioned
+// correlation run. Earlier runs and their correlations are kept for history.
+func (e *CorrelationEngine) CorrelateIncidentWithTrigger(ctx context.Context, incidentID, service, namespace string, startTime time.Time, trigger RunTrigger) (*IncidentContext, error) {
 	// Acquire worker slot (blocks if pool is full, enforcing max 10 concurrent correlations)
 	e.workerSemaphore <- struct{}{}
 	defer func() { <-e.workerSemaphore }()
@@ -102,29 +114,85 @@ func (e *CorrelationEngine) CorrelateIncident(ctx context.Context, incidentID, s
 		Namespace: namespace,
 		StartTime: startTime,
 	}
-
-	// Run correlations - FIXED: Now logging warnings instead of errors for optional components
-	if err := e.correlateK8sState(ctx, ic); err != nil {
-		fmt.Printf("Warning: Failed to correlate K8s state: %v\n", err)
+	ic.OrgID = e.incidentOrg(ctx, incidentID)
+	ic.Targets = e.resolveTargets(ctx, incidentID, service, namespace)
+	if len(ic.Targets) > 0 {
+		ic.Namespace = ic.Targets[0].Namespace
+		ic.Cluster = ic.Targets[0].Cluster
 	}
-	if err := e.correlateMetrics(ctx, ic); err != nil {
-		fmt.Printf("Warning: Failed to correlate metrics: %v\n", err)
-	}
-	if err := e.correlateLogs(ctx, ic); err != nil {
-		fmt.Printf("Warning: Failed to correlate logs: %v\n", err)
+
+	run, err := e.startRun(ctx, incidentID, trigger)
+	if err != nil {
+		return ic, err
 	}
+	ic.Run = run
+
+	// Run correlations - FIXED: Now logging warnings instead of errors for optional components
+	e.runSource(ic, "kubernetes", e.k8sClient == nil, func() error { return e.correlateK8sState(ctx, ic) })
+	e.runSource(ic, "prometheus", e.promClient == nil, func() error { return e.correlateMetrics(ctx, ic) })
+	e.runSource(ic, "loki", e.lokiClient == nil, func() error { return e.correlateLogs(ctx, ic) })
 	if err := e.analyzeRootCause(ctx, ic); err != nil {
 		fmt.Printf("Warning: Failed to analyze root cause: %v\n", err)
 	}
 
+	run.IncidentConfidence = ic.IncidentConfidence
+	run.RootCauseSummary = ic.RootCauseSummary
+	run.Correlations = ic.Correlations
+	run.Status = RunStatusCompleted
+
 	// Save correlations to database
-	if err := e.saveCorrelations(ctx, incidentID, ic); err != nil {
-		return ic, fmt.Errorf("failed to save correlations: %w", err)
+	if saveErr := e.saveCorrelations(ctx, incidentID, run, ic); saveErr != nil {
+		run.Status = RunStatusFailed
+		run.Error = saveErr.Error()
+		if err := e.finishRun(ctx, e.db, run); err != nil {
+			fmt.Printf("Warning: Failed to record correlation run %s: %v\n", run.ID, err)
+		}
+		return ic, fmt.Errorf("failed to save correlations: %w", saveErr)
 	}
 
 	return ic, nil
 }
 
+// RecorrelateIncident loads an incident's service and start time and runs a new
+// correlation pass for it
+func (e *CorrelationEngine) RecorrelateIncident(ctx context.Context, incidentID string, trigger RunTrigger) (*IncidentContext, error) {
+	var service string
+	var startedAt time.Time
+	err := e.db.QueryRowContext(ctx, `
+		SELECT COALESCE(s.name, ''), i.started_at
+		FROM incidents i
+		LEFT JOIN services s ON i.service_id = s.id
+		WHERE i.id = $1
+	`, incidentID).Scan(&service, &startedAt)
+	if err != nil {
+		return nil, err
+	}
+	return e.CorrelateIncidentWithTrigger(ctx, incidentID, service, "", startedAt, trigger)
+}
+
+// runSource executes one source correlator and records its status on the run
+func (e *CorrelationEngine) runSource(ic *IncidentContext, source string, unavailable bool, correlate func() error) {
+	before := len(ic.Correlations)
+	err := correlate()
+
+	status := SourceStatus{Status: SourceStatusOK}
+	if unavailable {
+		status.Status = SourceStatusSkipped
+	} else if err != nil {
+		fmt.Printf("Warning: Failed to correlate %s: %v\n", source, err)
+		status.Status = SourceStatusError
+		status.Error = err.Error()
+	}
+	for _, c := range ic.Correlations[before:] {
+		if c.Type != "status" {
+			status.Signals++
+		}
+	}
+	if ic.Run != nil {
+		ic.Run.SourceStatus[source] = status
+	}
+}
+
 func (e *CorrelationEngine) correlateK8sState(ctx context.Context, ic *IncidentContext) error {
 	// ✅ FIX: Check if k8sClient is available
 	if e.k8sClient == nil {
@@ -143,9 +211,17 @@ func (e *CorrelationEngine) correlateK8sState(ctx context.Context, ic *IncidentC
 		return nil
 	}
 
-	pods, err := e.k8sClient.GetPods(ctx, ic.Namespace, ic.Service)
-	if err == nil {
-		ic.AffectedPods = pods
+	// Query each affected service where it actually runs
+	var firstErr error
+	for _, target := range ic.Targets {
+		pods, err := e.k8sClient.GetPodsInCluster(ctx, target.Cluster, target.Namespace, target.LabelSelector)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", target.Service, err)
+			}
+			continue
+		}
+		ic.AffectedPods = append(ic.AffectedPods, pods...)
 
 		// Add K8s correlations if pods are unhealthy
 		for _, pod := range pods {
@@ -156,14 +232,17 @@ func (e *CorrelationEngine) correlateK8sState(ctx context.Context, ic *IncidentC
 					SourceID:        pod.Name,
 					ConfidenceScore: 0.95,
 					Details: map[string]interface{}{
-						"status": pod.Status,
-						"reason": "Pod unhealthy",
+						"status":    pod.Status,
+						"reason":    "Pod unhealthy",
+						"service":   target.Service,
+						"cluster":   pod.Cluster,
+						"namespace": pod.Namespace,
 					},
 				})
 			}
 		}
 	}
-	return nil
+	return firstErr
 }
 
 func (e *CorrelationEngine) correlateMetrics(ctx context.Context, ic *IncidentContext) error {
@@ -171,9 +250,12 @@ func (e *CorrelationEngine) correlateMetrics(ctx context.Context, ic *IncidentCo
 		return nil
 	}
 	ic.Metrics = make(map[string]float64)
+	var firstErr error
 
 	errorRate, err := e.promClient.GetErrorRate(ctx, ic.Service)
-	if err == nil {
+	if err != nil {
+		firstErr = err
+	} else {
 		ic.Metrics["error_rate"] = errorRate
 		// Correlation threshold tuned so the demo failure (30% errors) always
 		// appears as a high-confidence metric correlation.
@@ -190,7 +272,9 @@ func (e *CorrelationEngine) correlateMetrics(ctx context.Context, ic *IncidentCo
 	}
 
 	latency, err := e.promClient.GetLatencyP95(ctx, ic.Service)
-	if err == nil {
+	if err != nil && firstErr == nil {
+		firstErr = err
+	} else if err == nil {
 		ic.Metrics["latency_p95"] = latency
 		if latency > 1000 {
 			ic.RootCauses = append(ic.RootCauses, fmt.Sprintf("High latency: %.0fms", latency))
@@ -205,20 +289,26 @@ func (e *CorrelationEngine) correlateMetrics(ctx context.Context, ic *IncidentCo
 	}
 
 	reqRate, err := e.promClient.GetRequestRate(ctx, ic.Service)
-	if err == nil {
+	if err != nil && firstErr == nil {
+		firstErr = err
+	} else if err == nil {
 		ic.Metrics["request_rate"] = reqRate
 	}
 
-	return nil
+	return firstErr
 }
 
 func (e *CorrelationEngine) correlateLogs(ctx context.Context, ic *IncidentContext) error {
 	if e.lokiClient == nil {
 		return nil
 	}
+	var firstErr error
+
 	// Detect log patterns
 	patterns, err := e.lokiClient.DetectLogPatterns(ctx, ic.Service, ic.StartTime.Add(-10*time.Minute))
-	if err == nil {
+	if err != nil {
+		firstErr = err
+	} else {
 		ic.LogPatterns = patterns
 		for pattern, count := range patterns {
 			if count > 5 {
@@ -234,7 +324,9 @@ func (e *CorrelationEngine) correlateLogs(ctx context.Context, ic *IncidentConte
 	}
 
 	errorLogs, err := e.lokiClient.GetErrorLogs(ctx, ic.Service, ic.StartTime.Add(-5*time.Minute), 100)
-	if err == nil {
+	if err != nil && firstErr == nil {
+		firstErr = err
+	} else if err == nil {
 		ic.LogErrors = errorLogs
 		if len(errorLogs) > 0 {
 			ic.RootCauses = append(ic.RootCauses, fmt.Sprintf("Detected %d error logs", len(errorLogs)))
@@ -249,11 +341,12 @@ func (e *CorrelationEngine) correlateLogs(ctx context.Context, ic *IncidentConte
 			})
 		}
 	}
-	return nil
+	return firstErr
 }
 
 func (e *CorrelationEngine) analyzeRootCause(ctx context.Context, ic *IncidentContext) error {
 	var candidates []RootCauseSummary
+	weights := e.scoringWeightsFor(ctx, ic.OrgID, ic.Service)
 
 	// 1. Infrastructure issues (pods not running)
 	for _, pod := range ic.AffectedPods {
@@ -261,8 +354,8 @@ func (e *CorrelationEngine) analyzeRootCause(ctx context.Context, ic *IncidentCo
 			candidates = append(candidates, RootCauseSummary{
 				SignalType: "infrastructure",
 				Source:     "kubernetes",
-				Reason:     fmt.Sprintf("Pod %s is %s", pod.Name, pod.Status),
-				Score:      k8sWeight * 0.95,
+				Reason:     fmt.Sprintf("Pod %s/%s is %s", pod.Namespace, pod.Name, pod.Status),
+				Score:      weights.Weight("infrastructure") * 0.95,
 				SignalIDs:  []string{pod.Name},
 			})
 		}
@@ -274,7 +367,7 @@ func (e *CorrelationEngine) analyzeRootCause(ctx context.Context, ic *IncidentCo
 			SignalType: "metric",
 			Source:     "prometheus",
 			Reason:     fmt.Sprintf("High error rate: %.2f%%", er),
-			Score:      metricWeight * 0.9,
+			Score:      weights.Weight("metric") * 0.9,
 			SignalIDs:  []string{"error_rate"},
 		})
 	}
@@ -283,7 +376,7 @@ func (e *CorrelationEngine) analyzeRootCause(ctx context.Context, ic *IncidentCo
 			SignalType: "metric",
 			Source:     "prometheus",
 			Reason:     fmt.Sprintf("High latency: %.0fms", lat),
-			Score:      metricWeight * 0.7,
+			Score:      weights.Weight("metric") * 0.7,
 			SignalIDs:  []string{"latency_p95"},
 		})
 	}
@@ -296,7 +389,7 @@ func (e *CorrelationEngine) analyzeRootCause(ctx context.Context, ic *IncidentCo
 					SignalType: "log_pattern",
 					Source:     "loki",
 					Reason:     fmt.Sprintf("Log pattern spike: %s (%d hits)", pattern, count),
-					Score:      logWeight * 0.9,
+					Score:      weights.Weight("log_pattern") * 0.9,
 					SignalIDs:  []string{"log_pattern"},
 				})
 			}
@@ -360,22 +453,32 @@ func (e *CorrelationEngine) analyzeRootCause(ctx context.Context, ic *IncidentCo
 	return nil
 }
 
-func (e *CorrelationEngine) saveCorrelations(ctx context.Context, incidentID string, ic *IncidentContext) error {
-	// First, clear existing correlations for this incident to avoid duplicates on re-analysis
-	_, _ = e.db.ExecContext(ctx, "DELETE FROM correlations WHERE incident_id = $1", incidentID)
+// saveCorrelations stores the correlations of a run and finishes it in one
+// transaction, so a completed run never has only some of its correlations
+func (e *CorrelationEngine) saveCorrelations(ctx context.Context, incidentID string, run *CorrelationRun, ic *IncidentContext) error {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	// Correlations are attached to their run, so re-analysis never overwrites
+	// what responders saw in earlier runs.
 	for _, c := range ic.Correlations {
 		details, _ := json.Marshal(c.Details)
-		_, err := e.db.ExecContext(ctx, `
-			INSERT INTO correlations (incident_id, correlation_type, source_type, source_id, confidence_score, details)
-			VALUES ($1, $2, $3, $4, $5, $6)
-		`, incidentID, c.Type, c.SourceType, c.SourceID, c.ConfidenceScore, details)
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO correlations (incident_id, run_id, correlation_type, source_type, source_id, confidence_score, details)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, incidentID, run.ID, c.Type, c.SourceType, c.SourceID, c.ConfidenceScore, details)
 
 		if err != nil {
-			fmt.Printf("Error saving correlation: %v\n", err)
+			return err
 		}
 	}
-	return nil
+	if err := e.finishRun(ctx, tx, run); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // IncidentAnalysisResult is the high-level analysis contract for an incident.
@@ -383,30 +486,38 @@ type IncidentAnalysisResult struct {
 	IncidentID           string             `json:"incident_id"`
 	Service              string             `json:"service"`
 	Namespace            string             `json:"namespace"`
+	Cluster              string             `json:"cluster,omitempty"`
+	Targets              []ServiceTarget    `json:"targets"`
 	IncidentConfidence   float64            `json:"incident_confidence"`
 	RootCauseSummary     []RootCauseSummary `json:"root_cause_summary"`
 	RootCauseSummaryText string             `json:"root_cause_summary_text"`
 	Correlations         []Correlation      `json:"correlations"`
+	SimilarIncidents     []SimilarIncident  `json:"similar_incidents"`
 }
 
 // GetIncidentAnalysis returns a high-level analysis summary for an incident,
 // without triggering a full re-correlation. It relies on the correlations
 // previously saved by CorrelateIncident and reconstructs a lightweight
-// IncidentContext from the database.
-func (e *CorrelationEngine) GetIncidentAnalysis(ctx context.Context, incidentID string) (*IncidentAnalysisResult, error) {
-	// Fetch basic incident context (service, started_at). Namespace is left as
-	// empty for now and can be wired when we add explicit namespaces.
-	var service string
-	var namespace sql.NullString
+// IncidentContext from the database. Similar incidents come from the incident's
+// organization and the services visible accepts.
+func (e *CorrelationEngine) GetIncidentAnalysis(ctx context.Context, incidentID string, visible func(service string) bool) (*IncidentAnalysisResult, error) {
+	// Fetch basic incident context. Cluster and namespace come from the
+	// service catalog.
+	var orgID, service, title, rootCause, resolution string
 	row := e.db.QueryRowContext(ctx, `
-		SELECT COALESCE(s.name, ''), NULL::text
+		SELECT i.org_id, COALESCE(s.name, ''), i.title, COALESCE(i.root_cause, ''), COALESCE(i.resolution, '')
 		FROM incidents i
 		LEFT JOIN services s ON i.service_id = s.id
 		WHERE i.id = $1
 	`, incidentID)
-	if err := row.Scan(&service, &namespace); err != nil {
+	if err := row.Scan(&orgID, &service, &title, &rootCause, &resolution); err != nil {
 		return nil, err
 	}
+	targets := e.resolveTargets(ctx, incidentID, service, "")
+	var namespace, cluster string
+	if len(targets) > 0 {
+		namespace, cluster = targets[0].Namespace, targets[0].Cluster
+	}
 
 	correlations, err := e.GetCorrelations(ctx, incidentID)
 	if err != nil {
@@ -415,7 +526,9 @@ func (e *CorrelationEngine) GetIncidentAnalysis(ctx context.Context, incidentID
 
 	ic := &IncidentContext{
 		Service:      service,
-		Namespace:    namespace.String,
+		Namespace:    namespace,
+		Cluster:      cluster,
+		Targets:      targets,
 		Correlations: correlations,
 	}
 
@@ -433,6 +546,14 @@ func (e *CorrelationEngine) GetIncidentAnalysis(ctx context.Context, incidentID
 				ic.Metrics["latency_p95"] = v
 			}
 		}
+		if c.Type == "infrastructure" {
+			status, _ := c.Details["status"].(string)
+			podNamespace, _ := c.Details["namespace"].(string)
+			podCluster, _ := c.Details["cluster"].(string)
+			ic.AffectedPods = append(ic.AffectedPods, clients.PodStatus{
+				Name: c.SourceID, Namespace: podNamespace, Cluster: podCluster, Status: status,
+			})
+		}
 		if c.Type == "log_pattern" {
 			if p, ok := c.Details["pattern"].(string); ok {
 				if count, ok2 := c.Details["count"].(float64); ok2 {
@@ -464,35 +585,50 @@ func (e *CorrelationEngine) GetIncidentAnalysis(ctx context.Context, incidentID
 		}
 	}
 
+	// Rank resolved incidents that look like this one. A recorded root cause
+	// wins over the inferred one.
+	if rootCause == "" {
+		rootCause = rootText
+	}
+	similar, err := e.FindSimilarIncidents(ctx, orgID, fingerprintFor(incidentID, title, service, rootCause, resolution, correlations), similarIncidentsLimit, visible)
+	if err != nil {
+		fmt.Printf("Warning: Failed to find similar incidents for %s: %v\n", incidentID, err)
+		similar = []SimilarIncident{}
+	}
+
 	return &IncidentAnalysisResult{
 		IncidentID:           incidentID,
 		Service:              service,
-		Namespace:            namespace.String,
+		Namespace:            namespace,
+		Cluster:              cluster,
+		Targets:              targets,
 		IncidentConfidence:   conf,
 		RootCauseSummary:     ic.RootCauseSummary,
 		RootCauseSummaryText: rootText,
 		Correlations:         correlations,
+		SimilarIncidents:     similar,
 	}, nil
 }
 
+// GetCorrelations returns the correlations of the latest completed run. Incidents
+// correlated before runs existed fall back to their unversioned rows.
 func (e *CorrelationEngine) GetCorrelations(ctx context.Context, incidentID string) ([]Correlation, error) {
 	rows, err := e.db.QueryContext(ctx, `
 		SELECT id, incident_id, correlation_type, source_type, source_id, confidence_score, details, created_at
 		FROM correlations
 		WHERE incident_id = $1
+		  AND run_id IS NOT DISTINCT FROM (
+			SELECT id FROM correlation_runs
+			WHERE incident_id = $1 AND status = 'completed'
+			ORDER BY version DESC
+			LIMIT 1
+		  )
+		ORDER BY created_at
 	`, incidentID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var correlations []Correlation
-	for rows.Next() {
-		var c Correlation
-		var detailsJSON string
-		if err := rows.Scan(&c.ID, &c.IncidentID, &c.Type, &c.SourceType, &c.SourceID, &c.ConfidenceScore, &detailsJSON, &c.CreatedAt); err == nil {
-			correlations = append(correlations, c)
-		}
-	}
-	return correlations, nil
+	return scanCorrelations(rows)
 }
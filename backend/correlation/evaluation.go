@@ -0,0 +1,279 @@
+package correlation
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// FeedbackSample is everything known about one incident for evaluation: the
+// candidates the engine produced and what responders said about them
+type FeedbackSample struct {
+	IncidentID string
+	OrgID      string
+	Service    string
+	Candidates []RootCauseSummary
+	Verdicts   map[int]string // candidate index -> confirmed/rejected
+	Actual     *RootCauseSummary
+}
+
+// SignalTypeMetrics holds precision and recall of the engine for one signal type
+type SignalTypeMetrics struct {
+	SignalType     string  `json:"signal_type"`
+	TruePositives  int     `json:"true_positives"`  // candidates judged correct
+	FalsePositives int     `json:"false_positives"` // candidates judged wrong
+	Relevant       int     `json:"relevant"`        // incidents whose real root cause had this type
+	Detected       int     `json:"detected"`        // relevant incidents where the engine found it
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+}
+
+// EvaluationReport summarizes how well root cause candidates matched responder feedback
+type EvaluationReport struct {
+	GeneratedAt  time.Time           `json:"generated_at"`
+	Incidents    int                 `json:"incidents"`
+	Overall      SignalTypeMetrics   `json:"overall"`
+	BySignalType []SignalTypeMetrics `json:"by_signal_type"`
+}
+
+// TunedWeight is a per-service scoring weight derived from the feedback of the
+// service's organization
+type TunedWeight struct {
+	OrgID      string  `json:"org_id"`
+	Service    string  `json:"service"`
+	SignalType string  `json:"signal_type"`
+	Weight     float64 `json:"weight"`
+	Samples    int     `json:"samples"`
+	Precision  float64 `json:"precision"`
+}
+
+func matchesActual(c RootCauseSummary, actual *RootCauseSummary) bool {
+	if c.SignalType != actual.SignalType {
+		return false
+	}
+	return actual.Source == "" || c.Source == actual.Source
+}
+
+// labels returns, per candidate index, whether the candidate was correct. Explicit
+// verdicts win over the actual root cause; candidates with neither are left out.
+func (s FeedbackSample) labels() map[int]bool {
+	labels := make(map[int]bool)
+	for i, c := range s.Candidates {
+		switch s.Verdicts[i] {
+		case VerdictConfirmed:
+			labels[i] = true
+		case VerdictRejected:
+			labels[i] = false
+		default:
+			if s.Actual != nil {
+				labels[i] = matchesActual(c, s.Actual)
+			}
+		}
+	}
+	return labels
+}
+
+// relevantTypes returns the signal types that really caused the incident
+func (s FeedbackSample) relevantTypes(labels map[int]bool) map[string]bool {
+	relevant := make(map[string]bool)
+	if s.Actual != nil {
+		relevant[s.Actual.SignalType] = true
+	}
+	for i, ok := range labels {
+		if ok {
+			relevant[s.Candidates[i].SignalType] = true
+		}
+	}
+	return relevant
+}
+
+// Evaluate computes precision and recall per signal type. Precision is counted
+// per candidate, recall per incident.
+func Evaluate(samples []FeedbackSample) EvaluationReport {
+	byType := make(map[string]*SignalTypeMetrics)
+	get := func(t string) *SignalTypeMetrics {
+		if m, ok := byType[t]; ok {
+			return m
+		}
+		m := &SignalTypeMetrics{SignalType: t}
+		byType[t] = m
+		return m
+	}
+
+	report := EvaluationReport{GeneratedAt: time.Now(), Overall: SignalTypeMetrics{SignalType: "all"}}
+	for _, s := range samples {
+		labels := s.labels()
+		relevant := s.relevantTypes(labels)
+		if len(labels) == 0 && len(relevant) == 0 {
+			continue
+		}
+		report.Incidents++
+
+		detected := make(map[string]bool)
+		for i, ok := range labels {
+			m := get(s.Candidates[i].SignalType)
+			if ok {
+				m.TruePositives++
+				report.Overall.TruePositives++
+				detected[s.Candidates[i].SignalType] = true
+			} else {
+				m.FalsePositives++
+				report.Overall.FalsePositives++
+			}
+		}
+		for t := range relevant {
+			m := get(t)
+			m.Relevant++
+			report.Overall.Relevant++
+			if detected[t] {
+				m.Detected++
+				report.Overall.Detected++
+			}
+		}
+	}
+
+	report.Overall.finish()
+	report.BySignalType = make([]SignalTypeMetrics, 0, len(byType))
+	for _, m := range byType {
+		m.finish()
+		report.BySignalType = append(report.BySignalType, *m)
+	}
+	sort.Slice(report.BySignalType, func(i, j int) bool {
+		return report.BySignalType[i].SignalType < report.BySignalType[j].SignalType
+	})
+	return report
+}
+
+func (m *SignalTypeMetrics) finish() {
+	if judged := m.TruePositives + m.FalsePositives; judged > 0 {
+		m.Precision = round3(float64(m.TruePositives) / float64(judged))
+	}
+	if m.Relevant > 0 {
+		m.Recall = round3(float64(m.Detected) / float64(m.Relevant))
+	}
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// TuneServiceWeights scales the base weight of each signal type per service by
+// how often its candidates were judged correct. Services of different
+// organizations are tuned separately, even when they share a name. A Laplace-smoothed precision of
+// 0.5 keeps the base weight; the factor is clamped to [0.5, 1.5]. Pairs with
+// fewer than minSamples judged candidates are left untouched.
+func TuneServiceWeights(samples []FeedbackSample, base ScoringWeights, minSamples int) []TunedWeight {
+	type key struct{ org, service, signalType string }
+	counts := make(map[key][2]int) // [correct, judged]
+	for _, s := range samples {
+		for i, ok := range s.labels() {
+			k := key{s.OrgID, s.Service, s.Candidates[i].SignalType}
+			c := counts[k]
+			if ok {
+				c[0]++
+			}
+			c[1]++
+			counts[k] = c
+		}
+	}
+
+	tuned := make([]TunedWeight, 0)
+	for k, c := range counts {
+		if k.service == "" || c[1] < minSamples {
+			continue
+		}
+		precision := float64(c[0]+1) / float64(c[1]+2)
+		factor := math.Max(0.5, math.Min(1.5, 2*precision))
+		tuned = append(tuned, TunedWeight{
+			OrgID:      k.org,
+			Service:    k.service,
+			SignalType: k.signalType,
+			Weight:     round3(base.Weight(k.signalType) * factor),
+			Samples:    c[1],
+			Precision:  round3(float64(c[0]) / float64(c[1])),
+		})
+	}
+	sort.Slice(tuned, func(i, j int) bool {
+		if tuned[i].OrgID != tuned[j].OrgID {
+			return tuned[i].OrgID < tuned[j].OrgID
+		}
+		if tuned[i].Service != tuned[j].Service {
+			return tuned[i].Service < tuned[j].Service
+		}
+		return tuned[i].SignalType < tuned[j].SignalType
+	})
+	return tuned
+}
+
+// LoadFeedbackSamples builds one sample per incident that has feedback, with
+// the incident's organization. Candidate verdicts are evaluated against the run
+// they were given on; incidents with only an actual root cause are evaluated
+// against their latest completed run.
+func (e *CorrelationEngine) LoadFeedbackSamples(ctx context.Context) ([]FeedbackSample, error) {
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT `+feedbackColumns+`
+		FROM root_cause_feedback
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	feedback, err := scanFeedback(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	byIncident := make(map[string][]RootCauseFeedback)
+	var order []string
+	for _, fb := range feedback {
+		if _, ok := byIncident[fb.IncidentID]; !ok {
+			order = append(order, fb.IncidentID)
+		}
+		byIncident[fb.IncidentID] = append(byIncident[fb.IncidentID], fb)
+	}
+
+	samples := make([]FeedbackSample, 0, len(order))
+	for _, incidentID := range order {
+		sample := FeedbackSample{IncidentID: incidentID, Verdicts: make(map[int]string)}
+		_ = e.db.QueryRowContext(ctx, `
+			SELECT i.org_id, COALESCE(s.name, '')
+			FROM incidents i
+			LEFT JOIN services s ON i.service_id = s.id
+			WHERE i.id = $1
+		`, incidentID).Scan(&sample.OrgID, &sample.Service)
+
+		// The most recently judged run is the one responders looked at
+		var runID string
+		for _, fb := range byIncident[incidentID] {
+			if fb.Verdict == VerdictActual {
+				actual := RootCauseSummary{SignalType: fb.SignalType, Source: fb.Source, Reason: fb.Reason, SignalIDs: fb.SignalIDs}
+				sample.Actual = &actual
+			} else if fb.RunID != "" {
+				runID = fb.RunID
+			}
+		}
+
+		var run *CorrelationRun
+		if runID != "" {
+			run, err = scanRun(e.db.QueryRowContext(ctx, `
+				SELECT `+runColumns+` FROM correlation_runs WHERE id = $1
+			`, runID))
+		} else {
+			run, err = e.latestCompletedRun(ctx, incidentID)
+		}
+		if err != nil || run == nil {
+			continue
+		}
+		sample.Candidates = run.RootCauseSummary
+
+		for _, fb := range byIncident[incidentID] {
+			if fb.RunID == run.ID && fb.CandidateIndex != nil {
+				sample.Verdicts[*fb.CandidateIndex] = fb.Verdict
+			}
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
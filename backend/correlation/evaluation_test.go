@@ -0,0 +1,112 @@
+package correlation
+
+import (
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	samples := []FeedbackSample{
+		{
+			// Metric candidate confirmed, log candidate rejected
+			IncidentID: "inc-1",
+			Candidates: []RootCauseSummary{
+				{SignalType: "metric", Source: "prometheus"},
+				{SignalType: "log_pattern", Source: "loki"},
+			},
+			Verdicts: map[int]string{0: VerdictConfirmed, 1: VerdictRejected},
+		},
+		{
+			// Actual cause was infrastructure but the engine only suggested a metric
+			IncidentID: "inc-2",
+			Candidates: []RootCauseSummary{{SignalType: "metric", Source: "prometheus"}},
+			Verdicts:   map[int]string{},
+			Actual:     &RootCauseSummary{SignalType: "infrastructure", Source: "kubernetes"},
+		},
+		{
+			// No feedback at all is ignored
+			IncidentID: "inc-3",
+			Candidates: []RootCauseSummary{{SignalType: "metric"}},
+		},
+	}
+
+	report := Evaluate(samples)
+	if report.Incidents != 2 {
+		t.Errorf("expected 2 evaluated incidents, got %d", report.Incidents)
+	}
+
+	got := make(map[string]SignalTypeMetrics)
+	for _, m := range report.BySignalType {
+		got[m.SignalType] = m
+	}
+	if m := got["metric"]; m.TruePositives != 1 || m.FalsePositives != 1 || m.Precision != 0.5 || m.Recall != 1 {
+		t.Errorf("unexpected metric results: %+v", m)
+	}
+	if m := got["log_pattern"]; m.Precision != 0 || m.Relevant != 0 {
+		t.Errorf("unexpected log_pattern results: %+v", m)
+	}
+	if m := got["infrastructure"]; m.Relevant != 1 || m.Detected != 0 || m.Recall != 0 {
+		t.Errorf("unexpected infrastructure results: %+v", m)
+	}
+}
+
+func TestTuneServiceWeights(t *testing.T) {
+	var samples []FeedbackSample
+	for i := 0; i < 6; i++ {
+		samples = append(samples, FeedbackSample{
+			Service: "checkout",
+			Candidates: []RootCauseSummary{
+				{SignalType: "metric"},
+				{SignalType: "log_pattern"},
+			},
+			Verdicts: map[int]string{0: VerdictRejected, 1: VerdictConfirmed},
+		})
+	}
+
+	tuned := TuneServiceWeights(samples, DefaultScoringWeights(), 5)
+	if len(tuned) != 2 {
+		t.Fatalf("expected 2 tuned weights, got %d", len(tuned))
+	}
+	for _, w := range tuned {
+		base := DefaultScoringWeights().Weight(w.SignalType)
+		switch w.SignalType {
+		case "log_pattern":
+			if w.Weight <= base {
+				t.Errorf("expected confirmed signal weight to increase from %v, got %v", base, w.Weight)
+			}
+		case "metric":
+			if w.Weight >= base {
+				t.Errorf("expected rejected signal weight to decrease from %v, got %v", base, w.Weight)
+			}
+		}
+	}
+
+	if tuned := TuneServiceWeights(samples, DefaultScoringWeights(), 10); len(tuned) != 0 {
+		t.Errorf("expected no tuning below min samples, got %+v", tuned)
+	}
+
+	// Feedback of another organization's service with the same name is tuned
+	// on its own and doesn't add to this one's samples
+	for i := range samples {
+		samples[i].OrgID = "default"
+	}
+	for i := 0; i < 6; i++ {
+		samples = append(samples, FeedbackSample{
+			OrgID:      "acme",
+			Service:    "checkout",
+			Candidates: []RootCauseSummary{{SignalType: "metric"}},
+			Verdicts:   map[int]string{0: VerdictConfirmed},
+		})
+	}
+	tuned = TuneServiceWeights(samples, DefaultScoringWeights(), 5)
+	if len(tuned) != 3 {
+		t.Fatalf("expected 3 tuned weights, got %+v", tuned)
+	}
+	if w := tuned[0]; w.OrgID != "acme" || w.SignalType != "metric" || w.Samples != 6 || w.Weight <= DefaultScoringWeights().Weight("metric") {
+		t.Errorf("acme metric weight = %+v, want 6 confirmed samples", w)
+	}
+	for _, w := range tuned[1:] {
+		if w.OrgID != "default" || w.Samples != 6 {
+			t.Errorf("default weight = %+v, want 6 samples", w)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package correlation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Feedback verdicts
+const (
+	VerdictConfirmed = "confirmed" // candidate was a real root cause
+	VerdictRejected  = "rejected"  // candidate was not a root cause
+	VerdictActual    = "actual"    // the root cause recorded at resolution
+)
+
+// ErrInvalidCandidate is returned when feedback references a candidate the run does not have
+var ErrInvalidCandidate = errors.New("root cause candidate not found")
+
+// RootCauseFeedback is a responder's judgement on a root cause candidate, or the
+// actual root cause recorded when the incident was resolved
+type RootCauseFeedback struct {
+	ID             string    `json:"id"`
+	IncidentID     string    `json:"incident_id"`
+	RunID          string    `json:"run_id,omitempty"`
+	CandidateIndex *int      `json:"candidate_index,omitempty"`
+	SignalType     string    `json:"signal_type"`
+	Source         string    `json:"source"`
+	Reason         string    `json:"reason"`
+	SignalIDs      []string  `json:"signal_ids"`
+	Verdict        string    `json:"verdict"`
+	SubmittedBy    string    `json:"submitted_by"`
+	Comment        string    `json:"comment,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SubmitCandidateFeedback confirms or rejects one RootCauseSummary entry of a run.
+// An empty runID refers to the latest completed run. A responder's later verdict on
+// the same candidate replaces the earlier one.
+func (e *CorrelationEngine) SubmitCandidateFeedback(ctx context.Context, incidentID, runID string, index int, verdict, submittedBy, comment string) (*RootCauseFeedback, error) {
+	if verdict != VerdictConfirmed && verdict != VerdictRejected {
+		return nil, errors.New("verdict must be confirmed or rejected")
+	}
+
+	var run *CorrelationRun
+	var err error
+	if runID == "" {
+		run, err = e.latestCompletedRun(ctx, incidentID)
+		if err == nil && run == nil {
+			err = sql.ErrNoRows
+		}
+	} else {
+		run, err = scanRun(e.db.QueryRowContext(ctx, `
+			SELECT `+runColumns+`
+			FROM correlation_runs
+			WHERE incident_id = $1 AND id = $2
+		`, incidentID, runID))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(run.RootCauseSummary) {
+		return nil, ErrInvalidCandidate
+	}
+
+	candidate := run.RootCauseSummary[index]
+	fb := &RootCauseFeedback{
+		IncidentID:     incidentID,
+		RunID:          run.ID,
+		CandidateIndex: &index,
+		SignalType:     candidate.SignalType,
+		Source:         candidate.Source,
+		Reason:         candidate.Reason,
+		SignalIDs:      candidate.SignalIDs,
+		Verdict:        verdict,
+		SubmittedBy:    submittedBy,
+		Comment:        comment,
+	}
+	signalIDs, _ := json.Marshal(fb.SignalIDs)
+	err = e.db.QueryRowContext(ctx, `
+		INSERT INTO root_cause_feedback
+			(incident_id, run_id, candidate_index, signal_type, source, reason, signal_ids, verdict, submitted_by, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (run_id, candidate_index, submitted_by) DO UPDATE
+		SET verdict = EXCLUDED.verdict, comment = EXCLUDED.comment, created_at = NOW()
+		RETURNING id, created_at
+	`, incidentID, run.ID, index, fb.SignalType, fb.Source, fb.Reason, signalIDs, verdict, submittedBy, comment).Scan(&fb.ID, &fb.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return fb, nil
+}
+
+// RootCauseFilled is the incident's free-text root cause as filled in by
+// RecordActualRootCause, with the incident's new version
+type RootCauseFilled struct {
+	RootCause string
+	Version   int
+}
+
+// RecordActualRootCause stores the root cause signal identified at resolution,
+// replacing any earlier record. The incident's free-text root cause is filled in
+// if responders have not written one, which is an edit like any other: it
+// bumps the incident's version and is returned, or nil when nothing was filled.
+func (e *CorrelationEngine) RecordActualRootCause(ctx context.Context, incidentID string, actual RootCauseSummary, submittedBy, comment string) (*RootCauseFeedback, *RootCauseFilled, error) {
+	if actual.SignalType == "" {
+		return nil, nil, errors.New("signal_type is required")
+	}
+
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var rootCause string
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(root_cause, '') FROM incidents WHERE id = $1 FOR UPDATE
+	`, incidentID).Scan(&rootCause); err != nil {
+		return nil, nil, err
+	}
+	var filled *RootCauseFilled
+	if rootCause == "" && actual.Reason != "" {
+		filled = &RootCauseFilled{RootCause: actual.Reason}
+		if err := tx.QueryRowContext(ctx, `
+			UPDATE incidents
+			SET root_cause = $2, updated_at = NOW(), version = version + 1
+			WHERE id = $1
+			RETURNING version
+		`, incidentID, actual.Reason).Scan(&filled.Version); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM root_cause_feedback WHERE incident_id = $1 AND verdict = $2
+	`, incidentID, VerdictActual); err != nil {
+		return nil, nil, err
+	}
+
+	fb := &RootCauseFeedback{
+		IncidentID:  incidentID,
+		SignalType:  actual.SignalType,
+		Source:      actual.Source,
+		Reason:      actual.Reason,
+		SignalIDs:   actual.SignalIDs,
+		Verdict:     VerdictActual,
+		SubmittedBy: submittedBy,
+		Comment:     comment,
+	}
+	if fb.SignalIDs == nil {
+		fb.SignalIDs = []string{}
+	}
+	signalIDs, _ := json.Marshal(fb.SignalIDs)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO root_cause_feedback
+			(incident_id, signal_type, source, reason, signal_ids, verdict, submitted_by, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, incidentID, fb.SignalType, fb.Source, fb.Reason, signalIDs, VerdictActual, submittedBy, comment).Scan(&fb.ID, &fb.CreatedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return fb, filled, nil
+}
+
+// GetRootCauseFeedback lists all feedback recorded for an incident, oldest first
+func (e *CorrelationEngine) GetRootCauseFeedback(ctx context.Context, incidentID string) ([]RootCauseFeedback, error) {
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT `+feedbackColumns+`
+		FROM root_cause_feedback
+		WHERE incident_id = $1
+		ORDER BY created_at
+	`, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFeedback(rows)
+}
+
+const feedbackColumns = `id, incident_id, COALESCE(run_id::text, ''), candidate_index, signal_type,
+	COALESCE(source, ''), COALESCE(reason, ''), signal_ids, verdict, COALESCE(submitted_by, ''),
+	COALESCE(comment, ''), created_at`
+
+func scanFeedback(rows *sql.Rows) ([]RootCauseFeedback, error) {
+	feedback := make([]RootCauseFeedback, 0)
+	for rows.Next() {
+		var fb RootCauseFeedback
+		var index sql.NullInt64
+		var signalIDs []byte
+		if err := rows.Scan(&fb.ID, &fb.IncidentID, &fb.RunID, &index, &fb.SignalType, &fb.Source, &fb.Reason,
+			&signalIDs, &fb.Verdict, &fb.SubmittedBy, &fb.Comment, &fb.CreatedAt); err != nil {
+			continue
+		}
+		if index.Valid {
+			i := int(index.Int64)
+			fb.CandidateIndex = &i
+		}
+		if len(signalIDs) > 0 {
+			_ = json.Unmarshal(signalIDs, &fb.SignalIDs)
+		}
+		feedback = append(feedback, fb)
+	}
+	return feedback, rows.Err()
+}
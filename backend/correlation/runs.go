@@ -0,0 +1,358 @@
+package correlation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RunTrigger describes what caused a correlation run
+type RunTrigger string
+
+const (
+	TriggerAuto      RunTrigger = "auto"      // incident creation / detection
+	TriggerManual    RunTrigger = "manual"    // responder asked to re-correlate
+	TriggerScheduled RunTrigger = "scheduled" // periodic re-correlation
+)
+
+// Run status values
+const (
+	RunStatusRunning   = "running"
+	RunStatusCompleted = "completed"
+	RunStatusFailed    = "failed"
+)
+
+// Per-source status values
+const (
+	SourceStatusOK      = "ok"
+	SourceStatusError   = "error"
+	SourceStatusSkipped = "skipped"
+)
+
+// SourceStatus records how a single telemetry source behaved during a run
+type SourceStatus struct {
+	Status  string `json:"status"` // ok, error, skipped
+	Signals int    `json:"signals"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CorrelationRun is one versioned execution of CorrelateIncident for an incident
+type CorrelationRun struct {
+	ID                 string                  `json:"id"`
+	IncidentID         string                  `json:"incident_id"`
+	Version            int                     `json:"version"`
+	Trigger            RunTrigger              `json:"trigger"`
+	Status             string                  `json:"status"`
+	StartedAt          time.Time               `json:"started_at"`
+	CompletedAt        *time.Time              `json:"completed_at,omitempty"`
+	SourceStatus       map[string]SourceStatus `json:"source_status"`
+	IncidentConfidence float64                 `json:"incident_confidence"`
+	RootCauseSummary   []RootCauseSummary      `json:"root_cause_summary"`
+	Error              string                  `json:"error,omitempty"`
+	Correlations       []Correlation           `json:"correlations,omitempty"`
+}
+
+// RunDiff describes which signals appeared or disappeared between two runs
+type RunDiff struct {
+	IncidentID  string        `json:"incident_id"`
+	FromRunID   string        `json:"from_run_id,omitempty"`
+	FromVersion int           `json:"from_version"`
+	ToRunID     string        `json:"to_run_id"`
+	ToVersion   int           `json:"to_version"`
+	Appeared    []Correlation `json:"appeared"`
+	Disappeared []Correlation `json:"disappeared"`
+	Persisted   []Correlation `json:"persisted"`
+}
+
+// signalKey identifies a correlation signal independently of the run that found it.
+// Log patterns share a source ID, so the pattern text is part of the key.
+func signalKey(c Correlation) string {
+	key := fmt.Sprintf("%s/%s/%s", c.Type, c.SourceType, c.SourceID)
+	if p, ok := c.Details["pattern"].(string); ok {
+		key += "/" + p
+	}
+	return key
+}
+
+// DiffCorrelations compares the signals of two runs
+func DiffCorrelations(from, to []Correlation) RunDiff {
+	before := make(map[string]Correlation, len(from))
+	for _, c := range from {
+		before[signalKey(c)] = c
+	}
+	after := make(map[string]bool, len(to))
+
+	diff := RunDiff{
+		Appeared:    []Correlation{},
+		Disappeared: []Correlation{},
+		Persisted:   []Correlation{},
+	}
+	for _, c := range to {
+		key := signalKey(c)
+		after[key] = true
+		if _, ok := before[key]; ok {
+			diff.Persisted = append(diff.Persisted, c)
+		} else {
+			diff.Appeared = append(diff.Appeared, c)
+		}
+	}
+	for _, c := range from {
+		if !after[signalKey(c)] {
+			diff.Disappeared = append(diff.Disappeared, c)
+		}
+	}
+
+	sortBySignal := func(list []Correlation) {
+		sort.Slice(list, func(i, j int) bool { return signalKey(list[i]) < signalKey(list[j]) })
+	}
+	sortBySignal(diff.Appeared)
+	sortBySignal(diff.Disappeared)
+	sortBySignal(diff.Persisted)
+	return diff
+}
+
+// startRun records a new running correlation run with the next version number.
+// The incident row is locked so concurrent runs get distinct versions.
+func (e *CorrelationEngine) startRun(ctx context.Context, incidentID string, trigger RunTrigger) (*CorrelationRun, error) {
+	run := &CorrelationRun{
+		IncidentID:   incidentID,
+		Trigger:      trigger,
+		Status:       RunStatusRunning,
+		SourceStatus: make(map[string]SourceStatus),
+	}
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start correlation run: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked string
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM incidents WHERE id = $1 FOR UPDATE`, incidentID).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("failed to lock incident for correlation run: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO correlation_runs (incident_id, version, trigger, status, started_at)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, NOW()
+		FROM correlation_runs
+		WHERE incident_id = $1
+		RETURNING id, version, started_at
+	`, incidentID, string(trigger), RunStatusRunning).Scan(&run.ID, &run.Version, &run.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start correlation run: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to start correlation run: %w", err)
+	}
+	return run, nil
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// finishRun stores the outcome of a run with q, the database or a transaction
+func (e *CorrelationEngine) finishRun(ctx context.Context, q queryer, run *CorrelationRun) error {
+	sourceStatus, _ := json.Marshal(run.SourceStatus)
+	summary, _ := json.Marshal(run.RootCauseSummary)
+	var runErr sql.NullString
+	if run.Error != "" {
+		runErr = sql.NullString{String: run.Error, Valid: true}
+	}
+
+	return q.QueryRowContext(ctx, `
+		UPDATE correlation_runs
+		SET status = $1, completed_at = NOW(), source_status = $2,
+		    incident_confidence = $3, root_cause_summary = $4, error = $5
+		WHERE id = $6
+		RETURNING completed_at
+	`, run.Status, sourceStatus, run.IncidentConfidence, summary, runErr, run.ID).Scan(&run.CompletedAt)
+}
+
+const runColumns = `id, incident_id, version, trigger, status, started_at, completed_at,
+	source_status, COALESCE(incident_confidence, 0), root_cause_summary, COALESCE(error, '')`
+
+func scanRun(scanner interface{ Scan(...any) error }) (*CorrelationRun, error) {
+	var run CorrelationRun
+	var trigger string
+	var completedAt sql.NullTime
+	var sourceStatusJSON, summaryJSON []byte
+	if err := scanner.Scan(&run.ID, &run.IncidentID, &run.Version, &trigger, &run.Status, &run.StartedAt,
+		&completedAt, &sourceStatusJSON, &run.IncidentConfidence, &summaryJSON, &run.Error); err != nil {
+		return nil, err
+	}
+	run.Trigger = RunTrigger(trigger)
+	if completedAt.Valid {
+		run.CompletedAt = &completedAt.Time
+	}
+	run.SourceStatus = make(map[string]SourceStatus)
+	if len(sourceStatusJSON) > 0 {
+		_ = json.Unmarshal(sourceStatusJSON, &run.SourceStatus)
+	}
+	if len(summaryJSON) > 0 {
+		_ = json.Unmarshal(summaryJSON, &run.RootCauseSummary)
+	}
+	return &run, nil
+}
+
+// GetCorrelationRuns lists all runs for an incident, newest first
+func (e *CorrelationEngine) GetCorrelationRuns(ctx context.Context, incidentID string) ([]CorrelationRun, error) {
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT `+runColumns+`
+		FROM correlation_runs
+		WHERE incident_id = $1
+		ORDER BY version DESC
+	`, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]CorrelationRun, 0)
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			continue
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetCorrelationRun returns a single run together with the correlations it found
+func (e *CorrelationEngine) GetCorrelationRun(ctx context.Context, incidentID, runID string) (*CorrelationRun, error) {
+	run, err := scanRun(e.db.QueryRowContext(ctx, `
+		SELECT `+runColumns+`
+		FROM correlation_runs
+		WHERE incident_id = $1 AND id = $2
+	`, incidentID, runID))
+	if err != nil {
+		return nil, err
+	}
+
+	run.Correlations, err = e.getRunCorrelations(ctx, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// latestCompletedRun returns the newest completed run, or nil if there is none
+func (e *CorrelationEngine) latestCompletedRun(ctx context.Context, incidentID string) (*CorrelationRun, error) {
+	run, err := scanRun(e.db.QueryRowContext(ctx, `
+		SELECT `+runColumns+`
+		FROM correlation_runs
+		WHERE incident_id = $1 AND status = $2
+		ORDER BY version DESC
+		LIMIT 1
+	`, incidentID, RunStatusCompleted))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+// DiffRuns compares two runs of an incident. An empty toRunID means the latest
+// completed run, and an empty fromRunID means the completed run before it.
+func (e *CorrelationEngine) DiffRuns(ctx context.Context, incidentID, fromRunID, toRunID string) (*RunDiff, error) {
+	runs, err := e.GetCorrelationRuns(ctx, incidentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []CorrelationRun
+	for _, r := range runs {
+		if r.Status == RunStatusCompleted {
+			completed = append(completed, r)
+		}
+	}
+
+	var to, from *CorrelationRun
+	if toRunID == "" {
+		if len(completed) == 0 {
+			return nil, sql.ErrNoRows
+		}
+		to = &completed[0]
+	} else {
+		to = findRun(runs, toRunID)
+		if to == nil {
+			return nil, sql.ErrNoRows
+		}
+	}
+	if fromRunID == "" {
+		// Most recent completed run older than "to"
+		for i := range completed {
+			if completed[i].Version < to.Version {
+				from = &completed[i]
+				break
+			}
+		}
+	} else {
+		from = findRun(runs, fromRunID)
+		if from == nil {
+			return nil, sql.ErrNoRows
+		}
+	}
+
+	toCorrelations, err := e.getRunCorrelations(ctx, to.ID)
+	if err != nil {
+		return nil, err
+	}
+	var fromCorrelations []Correlation
+	if from != nil {
+		if fromCorrelations, err = e.getRunCorrelations(ctx, from.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	diff := DiffCorrelations(fromCorrelations, toCorrelations)
+	diff.IncidentID = incidentID
+	diff.ToRunID = to.ID
+	diff.ToVersion = to.Version
+	if from != nil {
+		diff.FromRunID = from.ID
+		diff.FromVersion = from.Version
+	}
+	return &diff, nil
+}
+
+func findRun(runs []CorrelationRun, id string) *CorrelationRun {
+	for i := range runs {
+		if runs[i].ID == id {
+			return &runs[i]
+		}
+	}
+	return nil
+}
+
+func (e *CorrelationEngine) getRunCorrelations(ctx context.Context, runID string) ([]Correlation, error) {
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT id, incident_id, correlation_type, source_type, source_id, confidence_score, details, created_at
+		FROM correlations
+		WHERE run_id = $1
+		ORDER BY created_at
+	`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanCorrelations(rows)
+}
+
+func scanCorrelations(rows *sql.Rows) ([]Correlation, error) {
+	correlations := make([]Correlation, 0)
+	for rows.Next() {
+		var c Correlation
+		var detailsJSON []byte
+		if err := rows.Scan(&c.ID, &c.IncidentID, &c.Type, &c.SourceType, &c.SourceID, &c.ConfidenceScore, &detailsJSON, &c.CreatedAt); err != nil {
+			continue
+		}
+		if len(detailsJSON) > 0 {
+			_ = json.Unmarshal(detailsJSON, &c.Details)
+		}
+		correlations = append(correlations, c)
+	}
+	return correlations, rows.Err()
+}
@@ -0,0 +1,37 @@
+package correlation
+
+import (
+	"testing"
+)
+
+func TestDiffCorrelations(t *testing.T) {
+	errorRate := Correlation{Type: "metric", SourceType: "prometheus", SourceID: "error_rate"}
+	latency := Correlation{Type: "metric", SourceType: "prometheus", SourceID: "latency_p95"}
+	timeoutPattern := Correlation{Type: "log_pattern", SourceType: "loki", SourceID: "pattern_detected",
+		Details: map[string]interface{}{"pattern": "timeout"}}
+	refusedPattern := Correlation{Type: "log_pattern", SourceType: "loki", SourceID: "pattern_detected",
+		Details: map[string]interface{}{"pattern": "connection refused"}}
+
+	diff := DiffCorrelations(
+		[]Correlation{errorRate, timeoutPattern},
+		[]Correlation{errorRate, latency, refusedPattern},
+	)
+
+	if len(diff.Persisted) != 1 || diff.Persisted[0].SourceID != "error_rate" {
+		t.Errorf("expected error_rate to persist, got %+v", diff.Persisted)
+	}
+	if len(diff.Appeared) != 2 {
+		t.Fatalf("expected 2 appeared signals, got %d", len(diff.Appeared))
+	}
+	if len(diff.Disappeared) != 1 || diff.Disappeared[0].Details["pattern"] != "timeout" {
+		t.Errorf("expected timeout pattern to disappear, got %+v", diff.Disappeared)
+	}
+}
+
+func TestDiffCorrelationsFirstRun(t *testing.T) {
+	diff := DiffCorrelations(nil, []Correlation{{Type: "logs", SourceType: "loki", SourceID: "error_logs"}})
+
+	if len(diff.Appeared) != 1 || len(diff.Disappeared) != 0 || len(diff.Persisted) != 0 {
+		t.Errorf("first run should only have appeared signals, got %+v", diff)
+	}
+}
@@ -0,0 +1,301 @@
+package correlation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchedulerConfig controls how often open incidents are re-correlated
+type SchedulerConfig struct {
+	BaseInterval time.Duration // cadence for freshly opened incidents
+	MaxInterval  time.Duration // upper bound once the incident has aged
+	BackoffAfter time.Duration // incident age after which the cadence doubles, repeatedly
+}
+
+// DefaultSchedulerConfig returns the default re-correlation cadence
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		BaseInterval: 1 * time.Minute,
+		MaxInterval:  30 * time.Minute,
+		BackoffAfter: 15 * time.Minute,
+	}
+}
+
+// withDefaults fills unset intervals with the defaults. MaxInterval is never
+// below BaseInterval.
+func (c SchedulerConfig) withDefaults() SchedulerConfig {
+	if c.BaseInterval <= 0 {
+		c.BaseInterval = DefaultSchedulerConfig().BaseInterval
+	}
+	if c.MaxInterval <= 0 {
+		c.MaxInterval = DefaultSchedulerConfig().MaxInterval
+	}
+	if c.MaxInterval < c.BaseInterval {
+		c.MaxInterval = c.BaseInterval
+	}
+	return c
+}
+
+// IntervalForAge returns the re-correlation interval for an incident of the given
+// age. The interval doubles for every BackoffAfter the incident has been open, up
+// to MaxInterval. Unset intervals take their defaults.
+func (c SchedulerConfig) IntervalForAge(age time.Duration) time.Duration {
+	c = c.withDefaults()
+	interval := c.BaseInterval
+	if c.BackoffAfter <= 0 {
+		return interval
+	}
+	for steps := age / c.BackoffAfter; steps > 0 && interval < c.MaxInterval; steps-- {
+		interval *= 2
+	}
+	if interval > c.MaxInterval {
+		interval = c.MaxInterval
+	}
+	return interval
+}
+
+// NewCorrelationsCallback receives correlations that were not present in the previous run
+type NewCorrelationsCallback func(incidentID, runID string, correlations []Correlation)
+
+// RecorrelationScheduler periodically re-runs correlation for every non-resolved incident
+type RecorrelationScheduler struct {
+	engine           *CorrelationEngine
+	config           SchedulerConfig
+	logger           *log.Logger
+	newCorrelations  NewCorrelationsCallback
+	timelineCallback func(incidentID string, event interface{})
+	stopChan         chan struct{}
+	stopOnce         sync.Once
+}
+
+// NewRecorrelationScheduler creates a scheduler for the given engine
+func NewRecorrelationScheduler(engine *CorrelationEngine, config SchedulerConfig) *RecorrelationScheduler {
+	return &RecorrelationScheduler{
+		engine:   engine,
+		config:   config.withDefaults(),
+		logger:   log.New(log.Writer(), "[Recorrelation] ", log.LstdFlags),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// SetNewCorrelationsCallback sets the callback for newly found correlations
+func (s *RecorrelationScheduler) SetNewCorrelationsCallback(callback NewCorrelationsCallback) {
+	s.newCorrelations = callback
+}
+
+// SetTimelineCallback sets the callback for timeline events
+func (s *RecorrelationScheduler) SetTimelineCallback(callback func(incidentID string, event interface{})) {
+	s.timelineCallback = callback
+}
+
+// Start begins periodic re-correlation. Due incidents are checked every BaseInterval.
+func (s *RecorrelationScheduler) Start(ctx context.Context) {
+	s.logger.Printf("Starting re-correlation every %v (backoff after %v, max %v)",
+		s.config.BaseInterval, s.config.BackoffAfter, s.config.MaxInterval)
+
+	go func() {
+		ticker := time.NewTicker(s.config.BaseInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.runCycle(ctx)
+			case <-s.stopChan:
+				s.logger.Println("Stopping re-correlation")
+				return
+			case <-ctx.Done():
+				s.logger.Println("Context cancelled, stopping re-correlation")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler
+func (s *RecorrelationScheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+}
+
+type openIncident struct {
+	id        string
+	startedAt time.Time
+	lastRunAt *time.Time
+}
+
+// dueIncidents returns open incidents whose last run is older than their current interval
+func (s *RecorrelationScheduler) dueIncidents(ctx context.Context, now time.Time) ([]openIncident, error) {
+	rows, err := s.engine.db.QueryContext(ctx, `
+		SELECT i.id, i.started_at, MAX(r.started_at)
+		FROM incidents i
+		LEFT JOIN correlation_runs r ON r.incident_id = i.id
+		WHERE i.status != 'resolved'
+		GROUP BY i.id, i.started_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []openIncident
+	for rows.Next() {
+		var inc openIncident
+		var lastRun sql.NullTime
+		if err := rows.Scan(&inc.id, &inc.startedAt, &lastRun); err != nil {
+			continue
+		}
+		if lastRun.Valid {
+			inc.lastRunAt = &lastRun.Time
+		}
+		interval := s.config.IntervalForAge(now.Sub(inc.startedAt))
+		if inc.lastRunAt == nil || now.Sub(*inc.lastRunAt) >= interval {
+			due = append(due, inc)
+		}
+	}
+	return due, rows.Err()
+}
+
+// schedulerLockKey is the Postgres advisory lock held during a cycle, so only
+// one replica re-correlates at a time
+const schedulerLockKey = 7_241_153_003
+
+func (s *RecorrelationScheduler) runCycle(ctx context.Context) {
+	conn, err := s.engine.db.Conn(ctx)
+	if err != nil {
+		s.logger.Printf("Failed to get connection for re-correlation: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, schedulerLockKey).Scan(&locked); err != nil {
+		s.logger.Printf("Failed to acquire re-correlation lock: %v", err)
+		return
+	}
+	if !locked {
+		// Another replica is running this cycle
+		return
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, schedulerLockKey)
+
+	due, err := s.dueIncidents(ctx, time.Now())
+	if err != nil {
+		s.logger.Printf("Failed to load open incidents: %v", err)
+		return
+	}
+
+	for _, inc := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.recorrelate(ctx, inc.id); err != nil {
+			s.logger.Printf("Re-correlation failed for incident %s: %v", inc.id, err)
+		}
+	}
+}
+
+// recorrelate runs a scheduled correlation pass and reports what changed since the previous run
+func (s *RecorrelationScheduler) recorrelate(ctx context.Context, incidentID string) error {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	previous, err := s.engine.latestCompletedRun(runCtx, incidentID)
+	if err != nil {
+		return err
+	}
+
+	ic, err := s.engine.RecorrelateIncident(runCtx, incidentID, TriggerScheduled)
+	if err != nil {
+		return err
+	}
+
+	var previousCorrelations []Correlation
+	var previousSummary []RootCauseSummary
+	if previous != nil {
+		previousSummary = previous.RootCauseSummary
+		if previousCorrelations, err = s.engine.getRunCorrelations(runCtx, previous.ID); err != nil {
+			return err
+		}
+	}
+
+	diff := DiffCorrelations(previousCorrelations, ic.Correlations)
+	if len(diff.Appeared) > 0 && s.newCorrelations != nil {
+		s.newCorrelations(incidentID, ic.Run.ID, diff.Appeared)
+	}
+
+	oldPrimary, newPrimary := primaryRootCause(previousSummary), primaryRootCause(ic.RootCauseSummary)
+	if newPrimary != nil && (oldPrimary == nil || oldPrimary.Reason != newPrimary.Reason) {
+		if err := s.recordRootCauseChange(runCtx, incidentID, ic.Run, oldPrimary, newPrimary); err != nil {
+			s.logger.Printf("Failed to record root cause change for incident %s: %v", incidentID, err)
+		}
+	}
+
+	s.logger.Printf("Incident %s re-correlated (run v%d): %d new, %d gone",
+		incidentID, ic.Run.Version, len(diff.Appeared), len(diff.Disappeared))
+	return nil
+}
+
+// recordRootCauseChange adds a timeline event when the primary root cause changes
+func (s *RecorrelationScheduler) recordRootCauseChange(ctx context.Context, incidentID string, run *CorrelationRun, oldPrimary, newPrimary *RootCauseSummary) error {
+	description := fmt.Sprintf("Primary root cause is now: %s", newPrimary.Reason)
+	metadata := map[string]interface{}{
+		"run_id":      run.ID,
+		"run_version": run.Version,
+		"signal_type": newPrimary.SignalType,
+		"source":      newPrimary.Source,
+	}
+	if oldPrimary != nil {
+		description = fmt.Sprintf("Primary root cause changed from %q to %q", oldPrimary.Reason, newPrimary.Reason)
+		metadata["previous_reason"] = oldPrimary.Reason
+	}
+
+	event, err := s.engine.addTimelineEvent(ctx, incidentID, "root_cause_changed", "Primary root cause changed", description, metadata)
+	if err != nil {
+		return err
+	}
+	if s.timelineCallback != nil {
+		s.timelineCallback(incidentID, event)
+	}
+	return nil
+}
+
+func primaryRootCause(summary []RootCauseSummary) *RootCauseSummary {
+	for i := range summary {
+		if summary[i].Primary {
+			return &summary[i]
+		}
+	}
+	return nil
+}
+
+// addTimelineEvent inserts a correlation timeline event and returns its broadcast payload
+func (e *CorrelationEngine) addTimelineEvent(ctx context.Context, incidentID, eventType, title, description string, metadata map[string]interface{}) (map[string]interface{}, error) {
+	id := uuid.New()
+	now := time.Now()
+	metadataJSON, _ := json.Marshal(metadata)
+	_, err := e.db.ExecContext(ctx, `
+		INSERT INTO timeline_events (id, incident_id, event_type, timestamp, source, title, description, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, id, incidentID, eventType, now, "correlation", title, description, metadataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"id":          id,
+		"incident_id": incidentID,
+		"event_type":  eventType,
+		"timestamp":   now,
+		"source":      "correlation",
+		"title":       title,
+		"description": description,
+		"metadata":    metadata,
+	}, nil
+}
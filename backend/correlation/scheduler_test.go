@@ -0,0 +1,56 @@
+package correlation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervalForAge(t *testing.T) {
+	config := SchedulerConfig{
+		BaseInterval: time.Minute,
+		MaxInterval:  10 * time.Minute,
+		BackoffAfter: 15 * time.Minute,
+	}
+
+	tests := []struct {
+		name string
+		age  time.Duration
+		want time.Duration
+	}{
+		{"new incident", 2 * time.Minute, time.Minute},
+		{"one backoff step", 20 * time.Minute, 2 * time.Minute},
+		{"two backoff steps", 31 * time.Minute, 4 * time.Minute},
+		{"capped at max", 24 * time.Hour, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.IntervalForAge(tt.age); got != tt.want {
+				t.Errorf("IntervalForAge(%v) = %v, want %v", tt.age, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntervalForAgeDefaults(t *testing.T) {
+	defaults := DefaultSchedulerConfig()
+	tests := []struct {
+		name   string
+		config SchedulerConfig
+		age    time.Duration
+		want   time.Duration
+	}{
+		{"unset max uses default cap", SchedulerConfig{BaseInterval: time.Minute, BackoffAfter: 15 * time.Minute}, 24 * time.Hour, defaults.MaxInterval},
+		{"unset max still backs off", SchedulerConfig{BaseInterval: time.Minute, BackoffAfter: 15 * time.Minute}, 20 * time.Minute, 2 * time.Minute},
+		{"max below base", SchedulerConfig{BaseInterval: 5 * time.Minute, MaxInterval: time.Minute, BackoffAfter: 15 * time.Minute}, 24 * time.Hour, 5 * time.Minute},
+		{"unset base", SchedulerConfig{MaxInterval: 10 * time.Minute}, time.Hour, defaults.BaseInterval},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.IntervalForAge(tt.age); got != tt.want {
+				t.Errorf("IntervalForAge(%v) = %v, want %v", tt.age, got, tt.want)
+			}
+		})
+	}
+}
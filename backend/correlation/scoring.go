@@ -0,0 +1,80 @@
+package correlation
+
+import (
+	"context"
+)
+
+// ScoringWeights maps a root cause signal type to its weight in candidate scoring
+type ScoringWeights map[string]float64
+
+// DefaultScoringWeights returns the built-in weights used when a service has no tuned weights
+func DefaultScoringWeights() ScoringWeights {
+	return ScoringWeights{
+		"metric":         metricWeight,
+		"log_pattern":    logWeight,
+		"infrastructure": k8sWeight,
+	}
+}
+
+// Weight returns the weight for a signal type, falling back to the default
+func (w ScoringWeights) Weight(signalType string) float64 {
+	if v, ok := w[signalType]; ok {
+		return v
+	}
+	return DefaultScoringWeights()[signalType]
+}
+
+// scoringWeightsFor loads the tuned weights for a service of an organization.
+// Missing or unreadable weights fall back to the defaults so scoring never
+// fails on them.
+func (e *CorrelationEngine) scoringWeightsFor(ctx context.Context, orgID, service string) ScoringWeights {
+	weights := DefaultScoringWeights()
+	if e.db == nil || service == "" {
+		return weights
+	}
+
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT signal_type, weight FROM service_scoring_weights WHERE org_id = $1 AND service_name = $2
+	`, orgID, service)
+	if err != nil {
+		return weights
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var signalType string
+		var weight float64
+		if err := rows.Scan(&signalType, &weight); err == nil {
+			weights[signalType] = weight
+		}
+	}
+	return weights
+}
+
+// incidentOrg returns the organization of an incident, or the default one when
+// it can't be read
+func (e *CorrelationEngine) incidentOrg(ctx context.Context, incidentID string) string {
+	orgID := "default"
+	if e.db != nil {
+		_ = e.db.QueryRowContext(ctx, `SELECT org_id FROM incidents WHERE id = $1`, incidentID).Scan(&orgID)
+	}
+	return orgID
+}
+
+// SaveServiceWeights upserts tuned weights per organization, service and
+// signal type
+func (e *CorrelationEngine) SaveServiceWeights(ctx context.Context, tuned []TunedWeight) error {
+	for _, t := range tuned {
+		_, err := e.db.ExecContext(ctx, `
+			INSERT INTO service_scoring_weights (org_id, service_name, signal_type, weight, samples, precision, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+			ON CONFLICT (org_id, service_name, signal_type) DO UPDATE
+			SET weight = EXCLUDED.weight, samples = EXCLUDED.samples,
+			    precision = EXCLUDED.precision, updated_at = NOW()
+		`, t.OrgID, t.Service, t.SignalType, t.Weight, t.Samples, t.Precision)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package correlation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	similarityIndexTTL    = 5 * time.Minute
+	similarityMinScore    = 0.1
+	similarIncidentsLimit = 5
+)
+
+// IncidentFingerprint is the text and signal profile of an incident used for similarity search
+type IncidentFingerprint struct {
+	IncidentID   string
+	Title        string
+	Service      string
+	SignalTypes  []string // e.g. metric/error_rate, log_pattern/pattern_detected
+	LogTemplates []string
+	RootCause    string
+	Resolution   string
+	ResolvedAt   *time.Time
+}
+
+// SimilarIncident is a resolved incident that resembles the one being analyzed
+type SimilarIncident struct {
+	IncidentID    string     `json:"incident_id"`
+	Title         string     `json:"title"`
+	Service       string     `json:"service"`
+	Score         float64    `json:"score"` // cosine similarity in [0,1]
+	RootCause     string     `json:"root_cause"`
+	Resolution    string     `json:"resolution"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	SharedSignals []string   `json:"shared_signals"`
+}
+
+var (
+	templateUUID   = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	templateIP     = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`)
+	templateHex    = regexp.MustCompile(`\b0x[0-9a-f]+\b|\b[0-9a-f]{12,}\b`)
+	templateNumber = regexp.MustCompile(`\b\d+(\.\d+)?(ms|s|m|h|kb|mb|gb|%)?\b`)
+	wordPattern    = regexp.MustCompile(`[a-z0-9_<>*]+`)
+)
+
+// LogTemplate reduces a log line to its template by masking variable parts
+// such as IDs, addresses and numbers.
+func LogTemplate(line string) string {
+	t := strings.ToLower(line)
+	t = templateUUID.ReplaceAllString(t, "<*>")
+	t = templateIP.ReplaceAllString(t, "<*>")
+	t = templateHex.ReplaceAllString(t, "<*>")
+	t = templateNumber.ReplaceAllString(t, "<*>")
+	return strings.Join(strings.Fields(t), " ")
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "to": true, "in": true,
+	"on": true, "for": true, "is": true, "was": true, "by": true, "with": true, "it": true,
+}
+
+func words(text string) []string {
+	var out []string
+	for _, w := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if len(w) > 1 && !stopWords[w] && w != "<*>" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// terms turns a fingerprint into prefixed terms so that the same word in
+// different fields (e.g. a service name and a log template) is kept apart.
+func (f IncidentFingerprint) terms() map[string]float64 {
+	tf := make(map[string]float64)
+	if f.Service != "" {
+		tf["svc:"+strings.ToLower(f.Service)] += 2
+	}
+	for _, s := range f.SignalTypes {
+		tf["sig:"+s]++
+	}
+	for _, tmpl := range f.LogTemplates {
+		tmpl = LogTemplate(tmpl)
+		tf["tpl:"+tmpl]++
+		for _, w := range words(tmpl) {
+			tf["log:"+w]++
+		}
+	}
+	for _, w := range words(f.RootCause) {
+		tf["txt:"+w]++
+	}
+	for _, w := range words(f.Resolution) {
+		tf["txt:"+w] += 0.5
+	}
+	return tf
+}
+
+type indexedIncident struct {
+	fingerprint IncidentFingerprint
+	vector      map[string]float64
+	norm        float64
+}
+
+// SimilarityIndex is an in-memory TF-IDF index over resolved incidents
+type SimilarityIndex struct {
+	docs []indexedIncident
+	idf  map[string]float64
+}
+
+// BuildSimilarityIndex indexes the given fingerprints
+func BuildSimilarityIndex(fingerprints []IncidentFingerprint) *SimilarityIndex {
+	idx := &SimilarityIndex{idf: make(map[string]float64)}
+
+	termSets := make([]map[string]float64, len(fingerprints))
+	df := make(map[string]int)
+	for i, f := range fingerprints {
+		termSets[i] = f.terms()
+		for term := range termSets[i] {
+			df[term]++
+		}
+	}
+	n := float64(len(fingerprints))
+	for term, count := range df {
+		// Smoothed IDF keeps terms shared by every incident slightly positive
+		idx.idf[term] = math.Log((1+n)/(1+float64(count))) + 1
+	}
+
+	for i, f := range fingerprints {
+		vector, norm := idx.weigh(termSets[i])
+		idx.docs = append(idx.docs, indexedIncident{fingerprint: f, vector: vector, norm: norm})
+	}
+	return idx
+}
+
+// weigh applies IDF weights to term frequencies. Terms that are not in the index
+// are dropped since they cannot match anything.
+func (idx *SimilarityIndex) weigh(tf map[string]float64) (map[string]float64, float64) {
+	vector := make(map[string]float64, len(tf))
+	var sum float64
+	for term, freq := range tf {
+		idf, ok := idx.idf[term]
+		if !ok {
+			continue
+		}
+		w := (1 + math.Log(freq)) * idf
+		vector[term] = w
+		sum += w * w
+	}
+	return vector, math.Sqrt(sum)
+}
+
+// Len returns the number of indexed incidents
+func (idx *SimilarityIndex) Len() int {
+	return len(idx.docs)
+}
+
+// Query returns up to limit indexed incidents most similar to f, best first.
+// The incident itself is never returned.
+func (idx *SimilarityIndex) Query(f IncidentFingerprint, limit int) []SimilarIncident {
+	vector, norm := idx.weigh(f.terms())
+	results := make([]SimilarIncident, 0)
+	if norm == 0 {
+		return results
+	}
+
+	for _, doc := range idx.docs {
+		if doc.fingerprint.IncidentID == f.IncidentID || doc.norm == 0 {
+			continue
+		}
+		var dot float64
+		var shared []string
+		for term, w := range vector {
+			if dw, ok := doc.vector[term]; ok {
+				dot += w * dw
+				if strings.HasPrefix(term, "sig:") || strings.HasPrefix(term, "tpl:") {
+					shared = append(shared, term[4:])
+				}
+			}
+		}
+		score := dot / (norm * doc.norm)
+		if score < similarityMinScore {
+			continue
+		}
+		sort.Strings(shared)
+		if shared == nil {
+			shared = []string{}
+		}
+		results = append(results, SimilarIncident{
+			IncidentID:    doc.fingerprint.IncidentID,
+			Title:         doc.fingerprint.Title,
+			Service:       doc.fingerprint.Service,
+			Score:         math.Round(score*1000) / 1000,
+			RootCause:     doc.fingerprint.RootCause,
+			Resolution:    doc.fingerprint.Resolution,
+			ResolvedAt:    doc.fingerprint.ResolvedAt,
+			SharedSignals: shared,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// similarityCache holds the lazily built index of resolved incidents of each
+// organization
+type similarityCache struct {
+	mu      sync.Mutex
+	indexes map[string]*cachedIndex // by organization ID
+}
+
+type cachedIndex struct {
+	index   *SimilarityIndex
+	builtAt time.Time
+}
+
+// InvalidateSimilarityIndex forces the next similarity searches to rebuild the
+// indexes, e.g. after an incident was resolved
+func (e *CorrelationEngine) InvalidateSimilarityIndex() {
+	e.similarity.mu.Lock()
+	defer e.similarity.mu.Unlock()
+	e.similarity.indexes = nil
+}
+
+func (e *CorrelationEngine) getSimilarityIndex(ctx context.Context, orgID string) (*SimilarityIndex, error) {
+	e.similarity.mu.Lock()
+	defer e.similarity.mu.Unlock()
+
+	if cached, ok := e.similarity.indexes[orgID]; ok && time.Since(cached.builtAt) < similarityIndexTTL {
+		return cached.index, nil
+	}
+	fingerprints, err := e.loadResolvedFingerprints(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if e.similarity.indexes == nil {
+		e.similarity.indexes = make(map[string]*cachedIndex)
+	}
+	cached := &cachedIndex{index: BuildSimilarityIndex(fingerprints), builtAt: time.Now()}
+	e.similarity.indexes[orgID] = cached
+	return cached.index, nil
+}
+
+// FindSimilarIncidents ranks the resolved incidents of an organization by
+// similarity to the given fingerprint. visible selects the services whose
+// incidents may be returned; nil allows all.
+func (e *CorrelationEngine) FindSimilarIncidents(ctx context.Context, orgID string, f IncidentFingerprint, limit int, visible func(service string) bool) ([]SimilarIncident, error) {
+	idx, err := e.getSimilarityIndex(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if visible == nil {
+		return idx.Query(f, limit), nil
+	}
+	results := make([]SimilarIncident, 0)
+	for _, similar := range idx.Query(f, 0) {
+		if visible(similar.Service) {
+			results = append(results, similar)
+		}
+		if limit > 0 && len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// loadResolvedFingerprints reads every resolved incident of an organization
+// together with the signals of its latest completed correlation run
+func (e *CorrelationEngine) loadResolvedFingerprints(ctx context.Context, orgID string) ([]IncidentFingerprint, error) {
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT i.id, i.title, COALESCE(s.name, ''), COALESCE(i.root_cause, ''), COALESCE(i.resolution, ''), i.resolved_at
+		FROM incidents i
+		LEFT JOIN services s ON i.service_id = s.id
+		WHERE i.status = 'resolved' AND i.org_id = $1
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[string]*IncidentFingerprint)
+	var order []string
+	for rows.Next() {
+		var f IncidentFingerprint
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&f.IncidentID, &f.Title, &f.Service, &f.RootCause, &f.Resolution, &resolvedAt); err != nil {
+			continue
+		}
+		if resolvedAt.Valid {
+			f.ResolvedAt = &resolvedAt.Time
+		}
+		byID[f.IncidentID] = &f
+		order = append(order, f.IncidentID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	signalRows, err := e.db.QueryContext(ctx, `
+		SELECT c.incident_id, c.correlation_type, c.source_id, c.details
+		FROM correlations c
+		JOIN incidents i ON i.id = c.incident_id
+		WHERE i.status = 'resolved' AND i.org_id = $1
+		  AND c.run_id IS NOT DISTINCT FROM (
+			SELECT r.id FROM correlation_runs r
+			WHERE r.incident_id = c.incident_id AND r.status = 'completed'
+			ORDER BY r.version DESC LIMIT 1
+		  )
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer signalRows.Close()
+
+	for signalRows.Next() {
+		var incidentID, corrType, sourceID string
+		var detailsJSON []byte
+		if err := signalRows.Scan(&incidentID, &corrType, &sourceID, &detailsJSON); err != nil {
+			continue
+		}
+		f, ok := byID[incidentID]
+		if !ok {
+			continue
+		}
+		var details map[string]interface{}
+		if len(detailsJSON) > 0 {
+			_ = json.Unmarshal(detailsJSON, &details)
+		}
+		f.addSignal(Correlation{Type: corrType, SourceID: sourceID, Details: details})
+	}
+	if err := signalRows.Err(); err != nil {
+		return nil, err
+	}
+
+	fingerprints := make([]IncidentFingerprint, 0, len(order))
+	for _, id := range order {
+		fingerprints = append(fingerprints, *byID[id])
+	}
+	return fingerprints, nil
+}
+
+// addSignal folds a correlation into the fingerprint
+func (f *IncidentFingerprint) addSignal(c Correlation) {
+	if c.Type == "status" {
+		return
+	}
+	if c.Type == "infrastructure" {
+		// Pod names are unique per rollout, so only the failure state is comparable
+		status, _ := c.Details["status"].(string)
+		f.SignalTypes = append(f.SignalTypes, "infrastructure/"+strings.ToLower(status))
+		return
+	}
+	f.SignalTypes = append(f.SignalTypes, c.Type+"/"+c.SourceID)
+	if pattern, ok := c.Details["pattern"].(string); ok && pattern != "" {
+		f.LogTemplates = append(f.LogTemplates, pattern)
+	}
+}
+
+// fingerprintFor builds the fingerprint of an incident from its current analysis
+func fingerprintFor(incidentID, title, service, rootCause, resolution string, correlations []Correlation) IncidentFingerprint {
+	f := IncidentFingerprint{
+		IncidentID: incidentID,
+		Title:      title,
+		Service:    service,
+		RootCause:  rootCause,
+		Resolution: resolution,
+	}
+	for _, c := range correlations {
+		f.addSignal(c)
+	}
+	return f
+}
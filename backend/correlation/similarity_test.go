@@ -0,0 +1,74 @@
+package correlation
+
+import (
+	"testing"
+)
+
+func TestLogTemplate(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"Timeout after 3000ms calling 10.0.0.12:5432", "timeout after <*> calling <*>"},
+		{"order 550e8400-e29b-41d4-a716-446655440000 failed", "order <*> failed"},
+		{"Connection   refused", "connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := LogTemplate(tt.line); got != tt.want {
+				t.Errorf("LogTemplate(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarityIndexQuery(t *testing.T) {
+	idx := BuildSimilarityIndex([]IncidentFingerprint{
+		{
+			IncidentID:   "inc-db",
+			Service:      "payment-service",
+			SignalTypes:  []string{"metric/error_rate", "log_pattern/pattern_detected"},
+			LogTemplates: []string{"connection refused to postgres"},
+			RootCause:    "Database connection pool exhausted",
+			Resolution:   "Rolled back deployment",
+		},
+		{
+			IncidentID:  "inc-latency",
+			Service:     "frontend",
+			SignalTypes: []string{"metric/latency_p95"},
+			RootCause:   "CDN cache misses",
+		},
+	})
+
+	results := idx.Query(IncidentFingerprint{
+		IncidentID:   "inc-new",
+		Service:      "payment-service",
+		SignalTypes:  []string{"metric/error_rate", "log_pattern/pattern_detected"},
+		LogTemplates: []string{"connection refused to postgres"},
+	}, 5)
+
+	if len(results) == 0 {
+		t.Fatal("expected at least one similar incident")
+	}
+	if results[0].IncidentID != "inc-db" {
+		t.Errorf("expected inc-db to rank first, got %s", results[0].IncidentID)
+	}
+	if results[0].Resolution != "Rolled back deployment" {
+		t.Errorf("expected resolution to be returned, got %q", results[0].Resolution)
+	}
+	for _, r := range results {
+		if r.IncidentID == "inc-latency" && r.Score >= results[0].Score {
+			t.Errorf("unrelated incident scored %v, expected less than %v", r.Score, results[0].Score)
+		}
+	}
+}
+
+func TestSimilarityIndexExcludesSelf(t *testing.T) {
+	f := IncidentFingerprint{IncidentID: "inc-1", Service: "api", SignalTypes: []string{"metric/error_rate"}}
+	idx := BuildSimilarityIndex([]IncidentFingerprint{f})
+
+	if results := idx.Query(f, 5); len(results) != 0 {
+		t.Errorf("expected incident not to match itself, got %+v", results)
+	}
+}
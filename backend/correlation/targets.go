@@ -0,0 +1,61 @@
+package correlation
+
+import (
+	"context"
+)
+
+const defaultNamespace = "default"
+
+// ServiceTarget says where an affected service runs in Kubernetes
+type ServiceTarget struct {
+	Service       string `json:"service"`
+	Cluster       string `json:"cluster,omitempty"` // empty means the default cluster
+	Namespace     string `json:"namespace"`
+	LabelSelector string `json:"label_selector"`
+}
+
+// withDefaults fills in the namespace and selector for services the catalog
+// does not describe
+func (t ServiceTarget) withDefaults(fallbackNamespace string) ServiceTarget {
+	if t.Namespace == "" {
+		t.Namespace = fallbackNamespace
+	}
+	if t.Namespace == "" {
+		t.Namespace = defaultNamespace
+	}
+	if t.LabelSelector == "" && t.Service != "" {
+		t.LabelSelector = "app=" + t.Service
+	}
+	return t
+}
+
+// resolveTargets looks up the catalog placement of every service affected by an
+// incident, primary service first. The namespace argument is only a fallback for
+// services without a declared namespace.
+func (e *CorrelationEngine) resolveTargets(ctx context.Context, incidentID, service, namespace string) []ServiceTarget {
+	var targets []ServiceTarget
+	if e.db != nil {
+		rows, err := e.db.QueryContext(ctx, `
+			SELECT s.name, COALESCE(s.cluster, ''), COALESCE(s.namespace, ''), COALESCE(s.label_selector, '')
+			FROM services s
+			WHERE s.id = (SELECT service_id FROM incidents WHERE id = $1)
+			   OR s.id IN (SELECT service_id FROM incident_services WHERE incident_id = $1)
+			   OR (s.name = $2 AND s.org_id = (SELECT org_id FROM incidents WHERE id = $1))
+			ORDER BY (s.name = $2) DESC, s.name
+		`, incidentID, service)
+		if err == nil {
+			defer rows.Close()
+			for rows.Next() {
+				var t ServiceTarget
+				if err := rows.Scan(&t.Service, &t.Cluster, &t.Namespace, &t.LabelSelector); err == nil {
+					targets = append(targets, t.withDefaults(namespace))
+				}
+			}
+		}
+	}
+
+	if len(targets) == 0 && service != "" {
+		targets = append(targets, ServiceTarget{Service: service}.withDefaults(namespace))
+	}
+	return targets
+}
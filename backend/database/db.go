@@ -8,14 +8,10 @@ import (
 	"os"
 	"time"
 
-	_ "embed"
 	_ "github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
-//go:embed schema.sql
-var schemaSQL string
-
 // DefaultQueryTimeout is the default timeout for database queries
 const DefaultQueryTimeout = 30 * time.Second
 
@@ -40,11 +36,16 @@ func LoadConfigFromEnv() *Config {
 	}
 }
 
-func Connect(config *Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
+// DSN returns the connection string of the database
+func (c *Config) DSN() string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode,
+		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode,
 	)
+}
+
+func Connect(config *Config) (*sql.DB, error) {
+	dsn := config.DSN()
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -80,13 +81,18 @@ func ContextWithTimeout(ctx context.Context) (context.Context, context.CancelFun
 	return context.WithTimeout(ctx, DefaultQueryTimeout)
 }
 
-// InitSchema initializes the database schema
+// InitSchema applies all pending migrations
 func InitSchema(db *sql.DB) error {
-	if _, err := db.Exec(schemaSQL); err != nil {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	log.Println("✅ Database schema initialized successfully")
+	log.Printf("✅ Database schema initialized successfully (%d migrations applied, version %d)", applied, migrator.Latest())
 	return nil
 }
 
@@ -110,7 +116,7 @@ func SeedDefaultData(db *sql.DB) error {
 		_, err = db.Exec(`
 			INSERT INTO services (name, description, owner_team, status)
 			VALUES ($1, $2, $3, 'healthy')
-			ON CONFLICT (name) DO NOTHING
+			ON CONFLICT (org_id, name) DO NOTHING
 		`, svc.name, svc.description, svc.team)
 
 		if err != nil {
@@ -139,7 +145,7 @@ func SeedDefaultData(db *sql.DB) error {
 	// and telemetry middleware: http_requests_total{service="<svc>",status="200"|"500"}.
 	for _, svcName := range []string{"frontend-web", "api-gateway", "payment-service"} {
 		var serviceID string
-		err := db.QueryRow("SELECT id FROM services WHERE name = $1", svcName).Scan(&serviceID)
+		err := db.QueryRow("SELECT id FROM services WHERE name = $1 AND org_id = 'default'", svcName).Scan(&serviceID)
 		if err == nil {
 			availabilityQuery := fmt.Sprintf(
 				"(1 - (rate(http_requests_total{service=\"%s\",status=~\"5..\"}[${WINDOW}]) / rate(http_requests_total{service=\"%s\"}[${WINDOW}]))) * 100",
@@ -187,7 +193,7 @@ func SeedDefaultData(db *sql.DB) error {
 		_, _ = db.Exec(`
 			INSERT INTO correlation_rules (name, description, enabled, rule_type, query, threshold_value, severity)
 			VALUES ($1, $2, true, $3, $4, $5, $6)
-			ON CONFLICT (name) DO UPDATE 
+			ON CONFLICT (org_id, name) DO UPDATE 
 			SET description = EXCLUDED.description,
 			    rule_type = EXCLUDED.rule_type,
 			    query = EXCLUDED.query,
@@ -0,0 +1,292 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the Postgres advisory lock held while migrating, so
+// replicas starting together don't run migrations concurrently
+const migrationLockKey = 7_241_153_002
+
+// Migration is one numbered schema change. Down may be empty for migrations
+// that can't be reverted.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration and when it was applied, if it was
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+var migrationPattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads NNNN_name.up.sql and NNNN_name.down.sql files from the
+// root of fsys, sorted by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := migrationPattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up migration", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// migrationStep applies or reverts one migration
+type migrationStep struct {
+	Migration
+	up bool
+}
+
+// planMigrations returns the steps that bring the applied set to target:
+// pending migrations up to target in ascending order, or applied migrations
+// above target in descending order
+func planMigrations(migrations []Migration, applied map[int]bool, target int) ([]migrationStep, error) {
+	if target != 0 {
+		known := false
+		for _, mig := range migrations {
+			known = known || mig.Version == target
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown migration version %d", target)
+		}
+	}
+
+	var steps []migrationStep
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if mig := migrations[i]; mig.Version > target && applied[mig.Version] {
+			if mig.Down == "" {
+				return nil, fmt.Errorf("migration %d_%s can't be reverted", mig.Version, mig.Name)
+			}
+			steps = append(steps, migrationStep{Migration: mig})
+		}
+	}
+	for _, mig := range migrations {
+		if mig.Version <= target && !applied[mig.Version] {
+			steps = append(steps, migrationStep{Migration: mig, up: true})
+		}
+	}
+	return steps, nil
+}
+
+// Migrator applies the embedded migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     *log.Logger
+}
+
+// NewMigrator creates a migrator for the embedded migrations
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		logger:     log.New(log.Writer(), "[Migrate] ", log.LstdFlags),
+	}, nil
+}
+
+// Latest returns the highest known migration version
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)
+`
+
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func appliedMigrations(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	if _, err := q.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// Status lists all known migrations with the time they were applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the most recently applied migration
+func (m *Migrator) Down(ctx context.Context) (int, error) {
+	return m.run(ctx, func(applied map[int]bool) ([]migrationStep, error) {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if mig := m.migrations[i]; applied[mig.Version] {
+				return planMigrations([]Migration{mig}, applied, 0)
+			}
+		}
+		return nil, nil
+	})
+}
+
+// To applies or reverts migrations until exactly the migrations up to version
+// are applied. It returns the number of migrations run.
+func (m *Migrator) To(ctx context.Context, version int) (int, error) {
+	return m.run(ctx, func(applied map[int]bool) ([]migrationStep, error) {
+		return planMigrations(m.migrations, applied, version)
+	})
+}
+
+// run plans and runs migrations while holding the migration lock
+func (m *Migrator) run(ctx context.Context, plan func(applied map[int]bool) ([]migrationStep, error)) (int, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return 0, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	// Read the applied set under the lock; another replica may just have migrated
+	appliedAt, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	applied := make(map[int]bool, len(appliedAt))
+	for v := range appliedAt {
+		applied[v] = true
+	}
+	steps, err := plan(applied)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, step := range steps {
+		if err := runStep(ctx, conn, step); err != nil {
+			return i, err
+		}
+		direction := "Applied"
+		if !step.up {
+			direction = "Reverted"
+		}
+		m.logger.Printf("%s %d_%s", direction, step.Version, step.Name)
+	}
+	return len(steps), nil
+}
+
+func runStep(ctx context.Context, conn *sql.Conn, step migrationStep) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, record, args := step.Down, `DELETE FROM schema_migrations WHERE version = $1`, []interface{}{step.Version}
+	if step.up {
+		script = step.Up
+		record = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+		args = append(args, step.Name)
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", step.Version, step.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", step.Version, step.Name, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        fstest.MapFS
+		wantVersions []int
+		wantErr      bool
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"0010_add_audit.up.sql":   {Data: []byte("CREATE TABLE audit ();")},
+				"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX i ON t(c);")},
+				"0002_add_index.down.sql": {Data: []byte("DROP INDEX i;")},
+				"README.md":               {Data: []byte("ignored")},
+			},
+			wantVersions: []int{2, 10},
+		},
+		{
+			name:    "down without up",
+			files:   fstest.MapFS{"0003_broken.down.sql": {Data: []byte("DROP TABLE t;")}},
+			wantErr: true,
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"0004_one.up.sql": {Data: []byte("SELECT 1;")},
+				"0004_two.up.sql": {Data: []byte("SELECT 2;")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(tt.files)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("LoadMigrations() = %v, want error", migrations)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMigrations() error = %v", err)
+			}
+			var versions []int
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			if !reflect.DeepEqual(versions, tt.wantVersions) {
+				t.Errorf("versions = %v, want %v", versions, tt.wantVersions)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "baseline" {
+		t.Fatalf("first migration = %+v, want 0001_baseline", migrations[0])
+	}
+	for _, m := range migrations {
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down migration", m.Version, m.Name)
+		}
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "baseline", Up: "up", Down: "down"},
+		{Version: 2, Name: "second", Up: "up", Down: "down"},
+		{Version: 3, Name: "third", Up: "up"},
+	}
+	type step struct {
+		version int
+		up      bool
+	}
+
+	tests := []struct {
+		name    string
+		applied map[int]bool
+		target  int
+		want    []step
+		wantErr bool
+	}{
+		{name: "fresh database", applied: map[int]bool{}, target: 3, want: []step{{1, true}, {2, true}, {3, true}}},
+		{name: "up to date", applied: map[int]bool{1: true, 2: true, 3: true}, target: 3},
+		{name: "fills gaps", applied: map[int]bool{1: true, 3: true}, target: 3, want: []step{{2, true}}},
+		{name: "partial up", applied: map[int]bool{1: true}, target: 2, want: []step{{2, true}}},
+		{name: "down to baseline", applied: map[int]bool{1: true, 2: true}, target: 1, want: []step{{2, false}}},
+		{name: "down to zero", applied: map[int]bool{1: true, 2: true}, target: 0, want: []step{{2, false}, {1, false}}},
+		{name: "irreversible", applied: map[int]bool{1: true, 2: true, 3: true}, target: 2, wantErr: true},
+		{name: "unknown version", applied: map[int]bool{}, target: 7, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := planMigrations(migrations, tt.applied, tt.target)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("planMigrations() = %v, want error", steps)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planMigrations() error = %v", err)
+			}
+			var got []step
+			for _, s := range steps {
+				got = append(got, step{s.Version, s.up})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planMigrations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+-- Drops everything created by the baseline migration
+DROP TABLE IF EXISTS slo_reports CASCADE;
+DROP TABLE IF EXISTS error_budget_policy_events CASCADE;
+DROP TABLE IF EXISTS error_budget_policies CASCADE;
+DROP TABLE IF EXISTS composite_slo_history CASCADE;
+DROP TABLE IF EXISTS composite_slo_components CASCADE;
+DROP TABLE IF EXISTS composite_slos CASCADE;
+DROP TABLE IF EXISTS service_scoring_weights CASCADE;
+DROP TABLE IF EXISTS root_cause_feedback CASCADE;
+DROP TABLE IF EXISTS investigation_steps CASCADE;
+DROP TABLE IF EXISTS investigation_hypotheses CASCADE;
+DROP TABLE IF EXISTS correlations CASCADE;
+DROP TABLE IF EXISTS correlation_runs CASCADE;
+DROP TABLE IF EXISTS metrics_cache CASCADE;
+DROP TABLE IF EXISTS alerts CASCADE;
+DROP TABLE IF EXISTS correlation_rules CASCADE;
+DROP TABLE IF EXISTS incident_tasks CASCADE;
+DROP TABLE IF EXISTS timeline_events CASCADE;
+DROP TABLE IF EXISTS incident_services CASCADE;
+DROP TABLE IF EXISTS incidents CASCADE;
+DROP TABLE IF EXISTS slo_history CASCADE;
+DROP TABLE IF EXISTS slos CASCADE;
+DROP TABLE IF EXISTS services CASCADE;
+
+DROP FUNCTION IF EXISTS calculate_incident_metrics();
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -17,6 +17,12 @@ CREATE TABLE IF NOT EXISTS services (
     updated_at TIMESTAMP DEFAULT NOW()
 );
 
+-- Where a service runs in Kubernetes. An empty cluster means the default cluster
+-- and an empty label selector means app=<name>.
+ALTER TABLE services ADD COLUMN IF NOT EXISTS cluster VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE services ADD COLUMN IF NOT EXISTS namespace VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE services ADD COLUMN IF NOT EXISTS label_selector TEXT NOT NULL DEFAULT '';
+
 -- SLOs
 CREATE TABLE IF NOT EXISTS slos (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -34,6 +40,24 @@ CREATE TABLE IF NOT EXISTS slos (
     UNIQUE(service_id, name)
 );
 
+-- SLI definition as imported from OpenSLO. sli_query stays the evaluated query;
+-- openslo keeps the source documents so exports are lossless.
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS indicator_type VARCHAR(20);
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS good_query TEXT;
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS bad_query TEXT;
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS total_query TEXT;
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS threshold_query TEXT;
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS threshold_operator VARCHAR(4);
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS threshold_value FLOAT;
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS budgeting_method VARCHAR(20);
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS source VARCHAR(50) NOT NULL DEFAULT 'api';
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS openslo JSONB;
+
+-- Latency SLOs: share of requests faster than latency_threshold (seconds)
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS latency_metric VARCHAR(255);
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS latency_threshold FLOAT;
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS latency_selector TEXT;
+
 -- SLO History (for tracking over time)
 CREATE TABLE IF NOT EXISTS slo_history (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -45,6 +69,10 @@ CREATE TABLE IF NOT EXISTS slo_history (
     created_at TIMESTAMP DEFAULT NOW()
 );
 
+-- Raw rows are rolled up into hourly and daily rows, see DownsampleHistory
+ALTER TABLE slo_history ADD COLUMN IF NOT EXISTS resolution VARCHAR(10) NOT NULL DEFAULT 'raw'
+    CHECK (resolution IN ('raw', 'hourly', 'daily'));
+
 -- Incidents
 CREATE TABLE IF NOT EXISTS incidents (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -149,6 +177,23 @@ CREATE TABLE IF NOT EXISTS metrics_cache (
     UNIQUE(metric_key, service_id, timestamp)
 );
 
+-- Correlation Runs (one versioned row per CorrelateIncident call)
+CREATE TABLE IF NOT EXISTS correlation_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    incident_id UUID REFERENCES incidents(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    trigger VARCHAR(50) NOT NULL CHECK (trigger IN ('auto', 'manual', 'scheduled')),
+    status VARCHAR(50) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP,
+    source_status JSONB DEFAULT '{}',
+    incident_confidence FLOAT,
+    root_cause_summary JSONB DEFAULT '[]',
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(incident_id, version)
+);
+
 -- Correlations (for incident correlation engine)
 CREATE TABLE IF NOT EXISTS correlations (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -160,6 +205,7 @@ CREATE TABLE IF NOT EXISTS correlations (
     details JSONB DEFAULT '{}',
     created_at TIMESTAMP DEFAULT NOW()
 );
+ALTER TABLE correlations ADD COLUMN IF NOT EXISTS run_id UUID REFERENCES correlation_runs(id) ON DELETE CASCADE;
 
 -- Indexes for performance
 CREATE INDEX IF NOT EXISTS idx_incidents_status ON incidents(status);
@@ -168,12 +214,16 @@ CREATE INDEX IF NOT EXISTS idx_incidents_started_at ON incidents(started_at DESC
 CREATE INDEX IF NOT EXISTS idx_timeline_incident_timestamp ON timeline_events(incident_id, timestamp DESC);
 CREATE INDEX IF NOT EXISTS idx_slos_service ON slos(service_id);
 CREATE INDEX IF NOT EXISTS idx_slo_history_slo_timestamp ON slo_history(slo_id, timestamp DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_slo_history_rollup ON slo_history(slo_id, resolution, timestamp) WHERE resolution != 'raw';
+CREATE INDEX IF NOT EXISTS idx_slo_history_resolution ON slo_history(resolution, timestamp);
 CREATE INDEX IF NOT EXISTS idx_alerts_fingerprint ON alerts(fingerprint);
 CREATE INDEX IF NOT EXISTS idx_alerts_status ON alerts(status);
 CREATE INDEX IF NOT EXISTS idx_incident_services_service ON incident_services(service_id);
 CREATE INDEX IF NOT EXISTS idx_metrics_cache_key_time ON metrics_cache(metric_key, timestamp DESC);
 CREATE INDEX IF NOT EXISTS idx_correlations_created_at ON correlations(created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_correlations_incident_id ON correlations(incident_id);
+CREATE INDEX IF NOT EXISTS idx_correlations_run_id ON correlations(run_id);
+CREATE INDEX IF NOT EXISTS idx_correlation_runs_incident_version ON correlation_runs(incident_id, version DESC);
 
 -- Trigger to update updated_at timestamp
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -261,4 +311,109 @@ CREATE TABLE IF NOT EXISTS investigation_steps (
 -- Create indexes for investigations
 CREATE INDEX IF NOT EXISTS idx_hypotheses_incident ON investigation_hypotheses(incident_id);
 CREATE INDEX IF NOT EXISTS idx_steps_incident ON investigation_steps(incident_id);
-CREATE INDEX IF NOT EXISTS idx_steps_status ON investigation_steps(status);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_steps_status ON investigation_steps(status);
+-- Responder feedback on root cause candidates
+CREATE TABLE IF NOT EXISTS root_cause_feedback (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    incident_id UUID NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+    run_id UUID REFERENCES correlation_runs(id) ON DELETE CASCADE, -- NULL for the actual root cause
+    candidate_index INT,                                            -- index into the run's root_cause_summary
+    signal_type VARCHAR(50) NOT NULL,
+    source VARCHAR(50),
+    reason TEXT,
+    signal_ids JSONB DEFAULT '[]',
+    verdict VARCHAR(20) NOT NULL CHECK (verdict IN ('confirmed', 'rejected', 'actual')),
+    submitted_by VARCHAR(255) NOT NULL DEFAULT '',
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(run_id, candidate_index, submitted_by)
+);
+
+-- Per-service scoring weights tuned from feedback
+CREATE TABLE IF NOT EXISTS service_scoring_weights (
+    service_name VARCHAR(255) NOT NULL,
+    signal_type VARCHAR(50) NOT NULL,
+    weight FLOAT NOT NULL,
+    samples INT NOT NULL DEFAULT 0,
+    precision FLOAT,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (service_name, signal_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_root_cause_feedback_incident ON root_cause_feedback(incident_id);
+
+-- Composite SLOs combine service SLOs into one user-journey SLO
+CREATE TABLE IF NOT EXISTS composite_slos (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT,
+    method VARCHAR(20) NOT NULL CHECK (method IN ('weighted', 'and')),
+    objective FLOAT NOT NULL CHECK (objective > 0 AND objective < 100),
+    window_days INT NOT NULL DEFAULT 30,
+    error_budget_remaining FLOAT,
+    burn_rate FLOAT,
+    status VARCHAR(50) NOT NULL DEFAULT 'no_data',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS composite_slo_components (
+    composite_id UUID NOT NULL REFERENCES composite_slos(id) ON DELETE CASCADE,
+    slo_id UUID NOT NULL REFERENCES slos(id) ON DELETE CASCADE,
+    weight FLOAT NOT NULL DEFAULT 1 CHECK (weight > 0),
+    PRIMARY KEY (composite_id, slo_id)
+);
+
+CREATE TABLE IF NOT EXISTS composite_slo_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    composite_id UUID NOT NULL REFERENCES composite_slos(id) ON DELETE CASCADE,
+    timestamp TIMESTAMP NOT NULL,
+    value FLOAT NOT NULL,
+    error_budget FLOAT NOT NULL,
+    burn_rate FLOAT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_composite_slo_history ON composite_slo_history(composite_id, timestamp DESC);
+
+-- Error budget policies act once an SLO's remaining budget (percent) drops to the threshold
+CREATE TABLE IF NOT EXISTS error_budget_policies (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slo_id UUID NOT NULL REFERENCES slos(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    threshold FLOAT NOT NULL CHECK (threshold >= 0 AND threshold <= 100),
+    action VARCHAR(50) NOT NULL CHECK (action IN ('freeze_deploys', 'notify')),
+    webhook_url TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    state VARCHAR(20) NOT NULL DEFAULT 'ok' CHECK (state IN ('ok', 'triggered')),
+    state_since TIMESTAMP DEFAULT NOW(),
+    budget_remaining FLOAT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(slo_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS error_budget_policy_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    policy_id UUID NOT NULL REFERENCES error_budget_policies(id) ON DELETE CASCADE,
+    slo_id UUID NOT NULL REFERENCES slos(id) ON DELETE CASCADE,
+    from_state VARCHAR(20) NOT NULL,
+    to_state VARCHAR(20) NOT NULL,
+    threshold FLOAT NOT NULL,
+    budget_remaining FLOAT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_budget_policies_slo ON error_budget_policies(slo_id);
+CREATE INDEX IF NOT EXISTS idx_error_budget_policy_events_slo ON error_budget_policy_events(slo_id, created_at DESC);
+
+-- Stored SLO compliance reports, one per period
+CREATE TABLE IF NOT EXISTS slo_reports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    period_label VARCHAR(50) NOT NULL,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    generated_at TIMESTAMP NOT NULL,
+    report JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(period_start, period_end)
+);
@@ -0,0 +1,14 @@
+-- Resolved incidents moved out of the hot tables into archive files
+CREATE TABLE IF NOT EXISTS incident_archives (
+    incident_id UUID PRIMARY KEY,
+    title VARCHAR(500) NOT NULL,
+    severity VARCHAR(50) NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+    location TEXT NOT NULL,
+    row_count INT NOT NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    restored_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_incident_archives_archived_at ON incident_archives(archived_at DESC);
@@ -0,0 +1,19 @@
+-- Users for local login, and users signed in through OIDC
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    username VARCHAR(255) NOT NULL UNIQUE,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL DEFAULT '', -- empty for users without a local password
+    roles JSONB NOT NULL DEFAULT '["viewer"]',
+    is_first_login BOOLEAN NOT NULL DEFAULT true,
+    auth_provider VARCHAR(50) NOT NULL DEFAULT 'local',
+    external_id VARCHAR(255),
+    last_login TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_external_id ON users(auth_provider, external_id) WHERE external_id IS NOT NULL;
+
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
+CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
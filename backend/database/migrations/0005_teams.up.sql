@@ -0,0 +1,47 @@
+-- Teams own the services whose services.team matches their name
+CREATE TABLE IF NOT EXISTS teams (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_teams_name ON teams(lower(name));
+
+-- A member's permissions on every service of the team. user_id is users.id,
+-- or grafana:<login> for users signed in through the Grafana proxy.
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    permissions JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_members_user ON team_members(user_id);
+
+-- Permissions on a single service, on top of team membership
+CREATE TABLE IF NOT EXISTS service_grants (
+    service_id UUID NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    permissions JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (service_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_grants_user ON service_grants(user_id);
+
+DROP TRIGGER IF EXISTS update_teams_updated_at ON teams;
+CREATE TRIGGER update_teams_updated_at BEFORE UPDATE ON teams FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+DROP TRIGGER IF EXISTS update_team_members_updated_at ON team_members;
+CREATE TRIGGER update_team_members_updated_at BEFORE UPDATE ON team_members FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+DROP TRIGGER IF EXISTS update_service_grants_updated_at ON service_grants;
+CREATE TRIGGER update_service_grants_updated_at BEFORE UPDATE ON service_grants FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Existing service teams become teams
+INSERT INTO teams (name)
+SELECT DISTINCT ON (lower(team)) team FROM services WHERE COALESCE(team, '') <> ''
+ON CONFLICT DO NOTHING;
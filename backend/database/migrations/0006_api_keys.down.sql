@@ -0,0 +1,3 @@
+ALTER TABLE audit_log DROP COLUMN IF EXISTS api_key_id;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS service_accounts;
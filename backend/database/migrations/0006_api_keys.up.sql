@@ -0,0 +1,36 @@
+-- Non-human users for CI pipelines and scripts. Their user ID in team
+-- memberships and service grants is sa:<id>.
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    roles JSONB NOT NULL DEFAULT '["viewer"]',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Only a hash of each key is kept; prefix identifies the key in lookups and logs
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    service_account_id UUID NOT NULL REFERENCES service_accounts(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    key_hash CHAR(64) NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    rotated_from UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_account ON api_keys(service_account_id);
+
+DROP TRIGGER IF EXISTS update_service_accounts_updated_at ON service_accounts;
+CREATE TRIGGER update_service_accounts_updated_at BEFORE UPDATE ON service_accounts FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- The API key behind each audited action. Existing rows keep an empty value,
+-- which the hash leaves out, so their hashes still verify.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS api_key_id VARCHAR(255) NOT NULL DEFAULT '';
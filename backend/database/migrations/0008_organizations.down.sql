@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_teams_org_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_teams_name ON teams(lower(name));
+
+ALTER TABLE service_accounts DROP COLUMN IF EXISTS org_id;
+ALTER TABLE teams DROP COLUMN IF EXISTS org_id;
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+ALTER TABLE correlation_rules DROP COLUMN IF EXISTS org_id;
+ALTER TABLE slos DROP COLUMN IF EXISTS org_id;
+ALTER TABLE incidents DROP COLUMN IF EXISTS org_id;
+ALTER TABLE services DROP COLUMN IF EXISTS org_id;
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,29 @@
+-- Organizations isolate services, incidents, SLOs, rules, teams and users.
+-- Existing rows move to the default organization. grafana_org_id maps the org
+-- of users signed in through the Grafana proxy.
+CREATE TABLE IF NOT EXISTS organizations (
+    id VARCHAR(64) PRIMARY KEY CHECK (id ~ '^[a-z0-9][a-z0-9-]*$'),
+    name VARCHAR(255) NOT NULL,
+    grafana_org_id VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO organizations (id, name, grafana_org_id) VALUES ('default', 'Default', '1')
+ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE services ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE slos ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE correlation_rules ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE service_accounts ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+
+CREATE INDEX IF NOT EXISTS idx_services_org ON services(org_id);
+CREATE INDEX IF NOT EXISTS idx_incidents_org ON incidents(org_id, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_slos_org ON slos(org_id);
+CREATE INDEX IF NOT EXISTS idx_correlation_rules_org ON correlation_rules(org_id);
+
+-- Team names are unique within an organization
+DROP INDEX IF EXISTS idx_teams_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_teams_org_name ON teams(org_id, lower(name));
@@ -0,0 +1,14 @@
+-- Realtime broadcasts shared by all replicas when REALTIME_BUS=postgres. The
+-- ID orders events and is the event ID clients resume from; NOTIFY carries
+-- the event, or only its ID when it is too large. Old rows are pruned.
+CREATE TABLE IF NOT EXISTS realtime_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    org_id VARCHAR(64) NOT NULL,
+    topics TEXT[] NOT NULL,
+    service VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_realtime_events_created_at ON realtime_events(created_at);
@@ -0,0 +1,3 @@
+-- Version of each incident for optimistic concurrency: updates carrying an
+-- older version (If-Match) are rejected with 409
+ALTER TABLE incidents ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_slo_reports_org_period;
+DELETE FROM slo_reports WHERE org_id <> 'default';
+ALTER TABLE slo_reports DROP COLUMN IF EXISTS org_id;
+ALTER TABLE slo_reports ADD CONSTRAINT slo_reports_period_start_period_end_key UNIQUE (period_start, period_end);
@@ -0,0 +1,4 @@
+-- Stored SLO reports belong to an organization, one per period in each
+ALTER TABLE slo_reports ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE slo_reports DROP CONSTRAINT IF EXISTS slo_reports_period_start_period_end_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_slo_reports_org_period ON slo_reports(org_id, period_start, period_end);
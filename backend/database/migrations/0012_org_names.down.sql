@@ -0,0 +1,10 @@
+-- Fails while two organizations use the same name
+DROP INDEX IF EXISTS idx_composite_slos_org_name;
+ALTER TABLE composite_slos DROP COLUMN IF EXISTS org_id;
+ALTER TABLE composite_slos ADD CONSTRAINT composite_slos_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS idx_correlation_rules_org_name;
+ALTER TABLE correlation_rules ADD CONSTRAINT correlation_rules_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS idx_services_org_name;
+ALTER TABLE services ADD CONSTRAINT services_name_key UNIQUE (name);
@@ -0,0 +1,11 @@
+-- Service, correlation rule and composite SLO names are unique within an
+-- organization instead of across all of them
+ALTER TABLE services DROP CONSTRAINT IF EXISTS services_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_services_org_name ON services(org_id, name);
+
+ALTER TABLE correlation_rules DROP CONSTRAINT IF EXISTS correlation_rules_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_correlation_rules_org_name ON correlation_rules(org_id, name);
+
+ALTER TABLE composite_slos ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE composite_slos DROP CONSTRAINT IF EXISTS composite_slos_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_composite_slos_org_name ON composite_slos(org_id, name);
@@ -0,0 +1,4 @@
+DELETE FROM service_scoring_weights WHERE org_id <> 'default';
+ALTER TABLE service_scoring_weights DROP CONSTRAINT IF EXISTS service_scoring_weights_pkey;
+ALTER TABLE service_scoring_weights DROP COLUMN IF EXISTS org_id;
+ALTER TABLE service_scoring_weights ADD PRIMARY KEY (service_name, signal_type);
@@ -0,0 +1,5 @@
+-- Tuned scoring weights belong to the organization whose feedback they come
+-- from, since service names are only unique within one
+ALTER TABLE service_scoring_weights ADD COLUMN IF NOT EXISTS org_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES organizations(id);
+ALTER TABLE service_scoring_weights DROP CONSTRAINT IF EXISTS service_scoring_weights_pkey;
+ALTER TABLE service_scoring_weights ADD PRIMARY KEY (org_id, service_name, signal_type);
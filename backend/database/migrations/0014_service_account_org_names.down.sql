@@ -0,0 +1,3 @@
+-- Fails while two organizations use the same name
+DROP INDEX IF EXISTS idx_service_accounts_org_name;
+ALTER TABLE service_accounts ADD CONSTRAINT service_accounts_name_key UNIQUE (name);
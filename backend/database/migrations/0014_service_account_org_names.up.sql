@@ -0,0 +1,4 @@
+-- Service account names are unique within an organization instead of across
+-- all of them, so names of other organizations cannot be probed
+ALTER TABLE service_accounts DROP CONSTRAINT IF EXISTS service_accounts_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_accounts_org_name ON service_accounts(org_id, name);
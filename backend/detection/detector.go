@@ -27,6 +27,7 @@ type DetectionRule struct {
 	ThresholdValue float64                `json:"threshold_value" db:"threshold_value"`
 	Severity       string                 `json:"severity" db:"severity"`
 	ServiceID      *uuid.UUID             `json:"service_id,omitempty" db:"service_id"`
+	OrgID          string                 `json:"org_id" db:"org_id"`
 	Metadata       json.RawMessage        `json:"metadata,omitempty" db:"metadata"`
 	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time              `json:"updated_at" db:"updated_at"`
@@ -37,6 +38,7 @@ type DetectionEvent struct {
 	RuleID     uuid.UUID
 	RuleName   string
 	ServiceID  string
+	OrgID      string // of the rule; incidents and services are created in it
 	Severity   string
 	Value      float64
 	Timestamp  time.Time
@@ -59,7 +61,7 @@ type IncidentDetector struct {
 	stopChan            chan struct{}
 	running             bool
 	correlationCallback CorrelationCallback // Callback to trigger correlation
-	timelineCallback    func(event interface{}) // Callback for timeline events
+	timelineCallback    func(incidentID string, event interface{}) // Callback for timeline events
 }
 
 // NewIncidentDetector creates a new incident detector
@@ -86,7 +88,7 @@ func (d *IncidentDetector) SetCorrelationCallback(callback CorrelationCallback)
 }
 
 // SetTimelineCallback sets the callback for timeline events
-func (d *IncidentDetector) SetTimelineCallback(callback func(event interface{})) {
+func (d *IncidentDetector) SetTimelineCallback(callback func(incidentID string, event interface{})) {
 	d.timelineCallback = callback
 }
 
@@ -151,6 +153,9 @@ func (d *IncidentDetector) runDetection(ctx context.Context) {
 			d.logger.Printf("Failed to evaluate rule %s: %v\n", rule.Name, err)
 			continue
 		}
+		for i := range events {
+			events[i].OrgID = rule.OrgID
+		}
 		detectedEvents = append(detectedEvents, events...)
 	}
 
@@ -169,7 +174,7 @@ func (d *IncidentDetector) loadEnabledRules(ctx context.Context) ([]DetectionRul
 	var rules []DetectionRule
 	err := d.db.SelectContext(ctx, &rules, `
 		SELECT id, name, description, enabled, rule_type, query, 
-		       threshold_value, severity, service_id, metadata, created_at, updated_at
+		       threshold_value, severity, service_id, org_id, metadata, created_at, updated_at
 		FROM correlation_rules
 		WHERE enabled = true
 	`)
@@ -327,7 +332,7 @@ func (d *IncidentDetector) evaluatePatternRule(ctx context.Context, rule Detecti
 // processDetectionEvent converts a detection event into an incident if needed
 func (d *IncidentDetector) processDetectionEvent(ctx context.Context, event DetectionEvent) error {
 	// Create a unique key for this alert
-	alertKey := fmt.Sprintf("%s:%s", event.RuleName, event.ServiceID)
+	alertKey := fmt.Sprintf("%s:%s:%s", event.OrgID, event.RuleName, event.ServiceID)
 
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -348,10 +353,10 @@ func (d *IncidentDetector) processDetectionEvent(ctx context.Context, event Dete
 	
 	var serviceID string
 	err := d.db.QueryRow(`
-		INSERT INTO services (name, status) VALUES ($1, 'degraded')
-		ON CONFLICT (name) DO UPDATE SET status = 'degraded', updated_at = NOW()
+		INSERT INTO services (name, status, org_id) VALUES ($1, 'degraded', $2)
+		ON CONFLICT (org_id, name) DO UPDATE SET status = 'degraded', updated_at = NOW()
 		RETURNING id
-	`, serviceName).Scan(&serviceID)
+	`, serviceName, event.OrgID).Scan(&serviceID)
 	if err != nil {
 		d.logger.Printf("Warning: Failed to get/create service: %v\n", err)
 		serviceID = "" // Will insert NULL
@@ -402,21 +407,21 @@ func (d *IncidentDetector) processDetectionEvent(ctx context.Context, event Dete
 	var args []interface{}
 	if serviceID != "" {
 		query = `
-			INSERT INTO incidents (id, title, description, severity, status, service_id, started_at, detected_at, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			INSERT INTO incidents (id, title, description, severity, status, service_id, started_at, detected_at, created_at, updated_at, org_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		`
 		args = []interface{}{
 			incidentID, incidentTitle, incidentDescription, event.Severity,
-			"open", serviceID, event.Timestamp, event.Timestamp, time.Now(), time.Now(),
+			"open", serviceID, event.Timestamp, event.Timestamp, time.Now(), time.Now(), event.OrgID,
 		}
 	} else {
 		query = `
-			INSERT INTO incidents (id, title, description, severity, status, started_at, detected_at, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			INSERT INTO incidents (id, title, description, severity, status, started_at, detected_at, created_at, updated_at, org_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		`
 		args = []interface{}{
 			incidentID, incidentTitle, incidentDescription, event.Severity,
-			"open", event.Timestamp, event.Timestamp, time.Now(), time.Now(),
+			"open", event.Timestamp, event.Timestamp, time.Now(), time.Now(), event.OrgID,
 		}
 	}
 	
@@ -450,7 +455,7 @@ func (d *IncidentDetector) processDetectionEvent(ctx context.Context, event Dete
 
 	// After successful commit, invoke callback
 	if d.timelineCallback != nil {
-		d.timelineCallback(map[string]interface{}{
+		d.timelineCallback(incidentID.String(), map[string]interface{}{
 			"id":          timelineID,
 			"incident_id": incidentID,
 			"event_type":  "metric_anomaly",
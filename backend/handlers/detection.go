@@ -13,14 +13,33 @@ func InitDetectionHandlers(detector *detection.IncidentDetector) {
 	detectionService = detector
 }
 
-// GetDetectionRules returns all active detection alerts
-func GetDetectionRules(w http.ResponseWriter, r *http.Request) {
+// visibleAlerts returns the active alerts of the caller's organization on
+// services the caller can see. It has responded when ok is false.
+func visibleAlerts(w http.ResponseWriter, r *http.Request) (map[string]*detection.DetectionEvent, bool) {
 	if detectionService == nil {
 		http.Error(w, "Detection service not initialized", http.StatusServiceUnavailable)
-		return
+		return nil, false
+	}
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return nil, false
 	}
 
-	activeAlerts := detectionService.GetActiveAlerts()
+	alerts := make(map[string]*detection.DetectionEvent)
+	for key, event := range detectionService.GetActiveAlerts() {
+		if event.OrgID == access.OrgID && access.VisibleName(event.ServiceID) {
+			alerts[key] = event
+		}
+	}
+	return alerts, true
+}
+
+// GetDetectionRules returns the active detection alerts the caller can see
+func GetDetectionRules(w http.ResponseWriter, r *http.Request) {
+	activeAlerts, ok := visibleAlerts(w, r)
+	if !ok {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -31,14 +50,14 @@ func GetDetectionRules(w http.ResponseWriter, r *http.Request) {
 
 // GetDetectionStatus returns current detection system status
 func GetDetectionStatus(w http.ResponseWriter, r *http.Request) {
-	if detectionService == nil {
-		http.Error(w, "Detection service not initialized", http.StatusServiceUnavailable)
+	activeAlerts, ok := visibleAlerts(w, r)
+	if !ok {
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "running",
-		"alerts": detectionService.GetActiveAlerts(),
+		"alerts": activeAlerts,
 	})
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/gorilla/mux"
 	"github.com/sarika-03/Reliability-Studio/models"
+	"github.com/sarika-03/Reliability-Studio/rbac"
 	"github.com/sarika-03/Reliability-Studio/services"
 	"go.uber.org/zap"
 	"net/http"
@@ -56,6 +57,11 @@ func CreateIncident(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	req.OrgID = access.OrgID
 
 	incident, err := incidentService.Create(r.Context(), req)
 	if err != nil {
@@ -152,7 +158,23 @@ func AddTimelineEvent(w http.ResponseWriter, r *http.Request) {
 
 // --- Service Handlers ---
 
+// requestAccess returns the caller's permissions. It has responded when ok
+// is false.
+func requestAccess(w http.ResponseWriter, r *http.Request) (*rbac.Access, bool) {
+	access, err := rbac.FromContext(r.Context())
+	if err != nil {
+		logger.Error("Failed to load permissions", zap.Error(err))
+		http.Error(w, "Failed to load permissions", http.StatusInternalServerError)
+		return nil, false
+	}
+	return access, true
+}
+
 func ListServices(w http.ResponseWriter, r *http.Request) {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
 	servs, err := serviceService.GetAll(r.Context())
 	if err != nil {
 		logger.Error("Failed to list services", zap.Error(err))
@@ -160,8 +182,15 @@ func ListServices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	visible := make([]models.Service, 0, len(servs))
+	for _, svc := range servs {
+		if access.Visible(svc.ID.String()) {
+			visible = append(visible, svc)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(servs)
+	json.NewEncoder(w).Encode(visible)
 }
 
 func CreateService(w http.ResponseWriter, r *http.Request) {
@@ -170,6 +199,15 @@ func CreateService(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	if !access.CanTeam(rbac.TeamAdmin, req.Team) {
+		http.Error(w, "Missing permission: team:admin", http.StatusForbidden)
+		return
+	}
+	req.OrgID = access.OrgID
 
 	service, err := serviceService.Create(r.Context(), req)
 	if err != nil {
@@ -198,6 +236,14 @@ func GetService(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Service not found", http.StatusNotFound)
 		return
 	}
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	if !access.Visible(service.ID.String()) {
+		http.Error(w, "Service not found", http.StatusNotFound)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(service)
@@ -212,6 +258,15 @@ func UpdateService(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	// Moving a service to another team needs team:admin on both teams
+	if !access.Can(rbac.TeamAdmin, id) || (req.Team != nil && !access.CanTeam(rbac.TeamAdmin, *req.Team)) {
+		http.Error(w, "Missing permission: team:admin", http.StatusForbidden)
+		return
+	}
 
 	service, err := serviceService.Update(r.Context(), id, req)
 	if err != nil {
@@ -242,7 +297,11 @@ func GetServiceIncidents(w http.ResponseWriter, r *http.Request) {
 // --- SLO Handlers ---
 
 func ListSLOs(w http.ResponseWriter, r *http.Request) {
-	slos, err := sloService.GetAllSLOs(r.Context())
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	slos, err := sloService.GetAllSLOs(r.Context(), access.OrgID)
 	if err != nil {
 		logger.Error("Failed to list SLOs", zap.Error(err))
 		http.Error(w, "Failed to retrieve SLOs", http.StatusInternalServerError)
@@ -260,7 +319,11 @@ func CreateSLO(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := sloService.CreateSLO(r.Context(), &slo); err != nil {
+	access, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	if err := sloService.CreateSLO(r.Context(), access.OrgID, &slo); err != nil {
 		logger.Error("Failed to create SLO", zap.Error(err))
 		http.Error(w, "Failed to create SLO", http.StatusInternalServerError)
 		return
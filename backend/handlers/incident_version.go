@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sarika-03/Reliability-Studio/services"
+)
+
+// IncidentVersion returns the version an incident update applies to, from the
+// If-Match header or else the version in the body. "If-Match: *" applies to
+// any version and returns 0. It writes 400 for invalid headers and 428 when
+// there is no version, and returns false.
+func IncidentVersion(w http.ResponseWriter, r *http.Request, bodyVersion int) (int, bool) {
+	ifMatch := r.Header.Get("If-Match")
+	version, err := services.ParseIfMatch(ifMatch)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return 0, false
+	}
+	if version == 0 {
+		version = bodyVersion
+	}
+	if version == 0 && strings.TrimSpace(ifMatch) != "*" {
+		writeJSON(w, http.StatusPreconditionRequired, map[string]interface{}{
+			"error": "If-Match header or version is required",
+		})
+		return 0, false
+	}
+	return version, true
+}
+
+// IncidentConflict writes 409 with the current state of the incident and its
+// version as the ETag
+func IncidentConflict(w http.ResponseWriter, current *services.IncidentState, err error) {
+	w.Header().Set("ETag", current.ETag())
+	writeJSON(w, http.StatusConflict, map[string]interface{}{
+		"error":   err.Error(),
+		"current": current,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
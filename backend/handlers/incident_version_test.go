@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sarika-03/Reliability-Studio/services"
+)
+
+func TestIncidentVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifMatch     string
+		bodyVersion int
+		want        int
+		wantCode    int // 0 when the version is accepted
+	}{
+		{"If-Match", `"3"`, 0, 3, 0},
+		{"If-Match wins over body", `"3"`, 2, 3, 0},
+		{"body", "", 2, 2, 0},
+		{"any version", "*", 0, 0, 0},
+		{"missing", "", 0, 0, http.StatusPreconditionRequired},
+		{"invalid", `"abc"`, 2, 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/api/incidents/1", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			got, ok := IncidentVersion(w, r, tt.bodyVersion)
+			if ok != (tt.wantCode == 0) || got != tt.want {
+				t.Fatalf("IncidentVersion = %d, %v; want %d", got, ok, tt.want)
+			}
+			if !ok && w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestIncidentConflict(t *testing.T) {
+	current := &services.IncidentState{ID: "1", Status: "mitigated", Version: 5}
+	w := httptest.NewRecorder()
+	IncidentConflict(w, current, services.ErrIncidentConflict)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409", w.Code)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"5"` {
+		t.Errorf("ETag = %s, want \"5\"", etag)
+	}
+	var body struct {
+		Error   string                 `json:"error"`
+		Current services.IncidentState `json:"current"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error != services.ErrIncidentConflict.Error() {
+		t.Errorf("error = %q", body.Error)
+	}
+	if body.Current.ID != "1" || body.Current.Status != "mitigated" || body.Current.Version != 5 {
+		t.Errorf("current = %+v", body.Current)
+	}
+}
@@ -4,28 +4,42 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 	_ "net/http/pprof"
 	"go.uber.org/zap"
 	
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/sarika-03/Reliability-Studio/apikeys"
+	"github.com/sarika-03/Reliability-Studio/audit"
+	"github.com/sarika-03/Reliability-Studio/auth"
 	"github.com/sarika-03/Reliability-Studio/clients"
 	"github.com/sarika-03/Reliability-Studio/correlation"
 	"github.com/sarika-03/Reliability-Studio/database"
 	"github.com/sarika-03/Reliability-Studio/detection"
 	"github.com/sarika-03/Reliability-Studio/handlers"
+	"github.com/sarika-03/Reliability-Studio/metrics"
 	"github.com/sarika-03/Reliability-Studio/middleware"
+	"github.com/sarika-03/Reliability-Studio/openslo"
+	"github.com/sarika-03/Reliability-Studio/ratelimit"
+	"github.com/sarika-03/Reliability-Studio/rbac"
+	"github.com/sarika-03/Reliability-Studio/reports"
+	"github.com/sarika-03/Reliability-Studio/retention"
 	"github.com/sarika-03/Reliability-Studio/services"
 	"github.com/sarika-03/Reliability-Studio/stability"
 	"github.com/sarika-03/Reliability-Studio/utils"
@@ -44,6 +58,11 @@ type Server struct {
 	k8sClient          *clients.KubernetesClient
 	lokiClient         *clients.LokiClient
 	sloService         *services.SLOService
+	incidentService    *services.IncidentService
+	reportGenerator    *reports.Generator
+	auditRecorder      *audit.Recorder
+	authorizer         *rbac.Authorizer
+	apiKeys            *apikeys.Store
 	timelineService    *services.TimelineService
 	correlationEngine  *correlation.CorrelationEngine
 	incidentDetector   *detection.IncidentDetector
@@ -97,6 +116,27 @@ func main() {
 		log.Fatalf("Failed to initialize schema: %v", err)
 	}
 
+	// Authentication modes (local JWT, OIDC, Grafana proxy headers)
+	authConfig, err := auth.LoadConfigFromEnv()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	middleware.ConfigureAuth(authConfig)
+	var oidcProvider *auth.OIDCProvider
+	if authConfig.Enabled(auth.ModeOIDC) {
+		discoveryCtx, cancelDiscovery := context.WithTimeout(context.Background(), 15*time.Second)
+		oidcProvider, err = auth.NewOIDCProvider(discoveryCtx, authConfig.OIDC, authConfig.Roles)
+		cancelDiscovery()
+		if err != nil {
+			log.Fatalf("Failed to set up OIDC: %v", err)
+		}
+	}
+	if authConfig.Enabled(auth.ModeNone) {
+		log.Println("⚠️  Authentication is disabled; every request is treated as an admin")
+	} else {
+		log.Printf("🔒 Authentication modes: %s", strings.Join(authConfig.Modes, ", "))
+	}
+
 	// Seed default data
 	if err := database.SeedDefaultData(db); err != nil {
 		log.Printf("Warning: Failed to seed data: %v", err)
@@ -132,8 +172,28 @@ func main() {
 	// Initialize services
 	log.Println("⚙️  Initializing services...")
 	sloService := services.NewSLOService(db, zapLogger)
+	sloService.SetPrometheusClient(promClient)
+	historyRetention := services.DefaultHistoryRetention()
+	historyRetention.Raw = getEnvDuration("SLO_HISTORY_RAW_RETENTION", historyRetention.Raw)
+	historyRetention.Hourly = getEnvDuration("SLO_HISTORY_HOURLY_RETENTION", historyRetention.Hourly)
+	sloService.SetHistoryRetention(historyRetention)
+	incidentService := services.NewIncidentService(db, zapLogger)
+
+	// SLO and incident state is exported on /metrics from a snapshot the
+	// services keep up to date
+	metricsSnapshot := metrics.NewSnapshot()
+	sloService.SetMetricsSnapshot(metricsSnapshot)
+	incidentService.SetMetricsSnapshot(metricsSnapshot)
+	prometheus.MustRegister(metrics.NewCollector(metricsSnapshot))
+	if err := sloService.RefreshMetrics(context.Background()); err != nil {
+		log.Printf("Warning: Failed to load SLO metrics: %v", err)
+	}
+	if err := incidentService.RefreshMetrics(context.Background()); err != nil {
+		log.Printf("Warning: Failed to load incident metrics: %v", err)
+	}
 	timelineService := services.NewTimelineService(db)
 	investigationService := services.NewInvestigationService(db, zapLogger)
+	serviceService := services.NewServiceService(db, zapLogger)
 	correlationEngine := correlation.NewCorrelationEngine(db, promClient, k8sInterface, lokiClient)
 
 	// Initialize stability systems
@@ -153,6 +213,11 @@ func main() {
 		k8sClient:         k8sClient,
 		lokiClient:        lokiClient,
 		sloService:        sloService,
+		incidentService:   incidentService,
+		reportGenerator:   reports.NewGenerator(db),
+		auditRecorder:     audit.NewRecorder(db),
+		authorizer:        rbac.NewAuthorizer(db),
+		apiKeys:           apikeys.NewStore(db),
 		timelineService:   timelineService,
 		correlationEngine: correlationEngine,
 		healthChecker:     healthChecker,
@@ -160,20 +225,50 @@ func main() {
 		circuitBreaker:    circuitBreaker,
 	}
 
+	middleware.SetAuditRecorder(server.auditRecorder)
+	middleware.SetAPIKeyStore(server.apiKeys)
+
 	// Initialize WebSocket server for real-time updates
 	log.Println("🔌 Initializing WebSocket server...")
 	realtimeServer := websocket.NewRealtimeServer()
-	realtimeServer.Start()
+	sharedRealtime, err := websocket.SharedFromEnv()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if sharedRealtime {
+		// Replicas deliver each other's broadcasts through Postgres
+		bus := websocket.NewPostgresBus(db, dbConfig.DSN())
+		if err := realtimeServer.StartShared(context.Background(), bus); err != nil {
+			log.Fatalf("Failed to start realtime bus: %v", err)
+		}
+	} else {
+		realtimeServer.Start()
+	}
 	server.realtimeServer = realtimeServer
 
+	// Error budget policy transitions are pushed to clients and to policy webhooks
+	sloService.SetPolicyCallback(func(t services.PolicyTransition) {
+		realtimeServer.BroadcastAlert(websocket.AlertTarget(t.OrgID, t.Service, websocket.TopicSLO), map[string]interface{}{
+			"type":       "error_budget_policy",
+			"transition": t,
+		})
+		if t.WebhookURL != "" {
+			go func() {
+				if err := services.NotifyWebhook(context.Background(), t.WebhookURL, t); err != nil {
+					log.Printf("Warning: error budget policy webhook for %s failed: %v", t.PolicyName, err)
+				}
+			}()
+		}
+	})
+
 	// Initialize incident detector
 	log.Println("🔍 Initializing incident detector...")
 	detector := detection.NewIncidentDetector(db, promClient, lokiClient, k8sClient)
 	server.incidentDetector = detector
 
 	// Set timeline callback for real-time updates
-	detector.SetTimelineCallback(func(event interface{}) {
-		realtimeServer.BroadcastTimelineEvent(event)
+	detector.SetTimelineCallback(func(incidentID string, event interface{}) {
+		realtimeServer.BroadcastTimelineEvent(server.incidentTarget(incidentID), event)
 	})
 
 	// Set correlation callback to trigger correlation when incidents are detected
@@ -184,12 +279,14 @@ func main() {
 		time.Sleep(100 * time.Millisecond)
 		var id, title, severity, status, serviceName string
 		var startedAt time.Time
+		var version int
+		target := server.incidentTarget(incidentID)
 		err := db.QueryRow(`
-			SELECT i.id, i.title, i.severity, i.status, COALESCE(s.name, 'unknown') as service, i.started_at
+			SELECT i.id, i.title, i.severity, i.status, COALESCE(s.name, 'unknown') as service, i.started_at, i.version
 			FROM incidents i
 			LEFT JOIN services s ON i.service_id = s.id
 			WHERE i.id = $1
-		`, incidentID).Scan(&id, &title, &severity, &status, &serviceName, &startedAt)
+		`, incidentID).Scan(&id, &title, &severity, &status, &serviceName, &startedAt, &version)
 		if err == nil {
 			incidentData := map[string]interface{}{
 				"id":         id,
@@ -198,22 +295,23 @@ func main() {
 				"status":     status,
 				"service":    serviceName,
 				"started_at": startedAt,
+				"version":    version,
 			}
 			log.Printf("📡 Broadcasting incident created: id=%s, title=%s", id, title)
-			realtimeServer.BroadcastIncidentCreated(incidentData)
+			realtimeServer.BroadcastIncidentCreated(target, incidentData)
 		} else {
 			log.Printf("⚠️  Failed to fetch incident for broadcast: %v", err)
 		}
 		
 		// Run correlation
-		ic, err := correlationEngine.CorrelateIncident(ctx, incidentID, service, "default", timestamp)
+		ic, err := correlationEngine.CorrelateIncident(ctx, incidentID, service, "", timestamp)
 		if err != nil {
 			log.Printf("Warning: Correlation failed for incident %s: %v", incidentID, err)
 		} else {
 			log.Printf("✅ Correlation completed for incident %s: %d correlations found", incidentID, len(ic.Correlations))
 			// Broadcast correlation results
 			if len(ic.Correlations) > 0 {
-				realtimeServer.BroadcastCorrelationFound(map[string]interface{}{
+				realtimeServer.BroadcastCorrelationFound(target, map[string]interface{}{
 					"incident_id": incidentID,
 					"correlations": ic.Correlations,
 				})
@@ -226,6 +324,67 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	detector.Start(ctx, 30*time.Second)
 
+	// Periodically re-correlate open incidents, backing off as they age
+	schedulerConfig := correlation.DefaultSchedulerConfig()
+	schedulerConfig.BaseInterval = getEnvDuration("RECORRELATION_INTERVAL", schedulerConfig.BaseInterval)
+	schedulerConfig.MaxInterval = getEnvDuration("RECORRELATION_MAX_INTERVAL", schedulerConfig.MaxInterval)
+	schedulerConfig.BackoffAfter = getEnvDuration("RECORRELATION_BACKOFF_AFTER", schedulerConfig.BackoffAfter)
+	recorrelationScheduler := correlation.NewRecorrelationScheduler(correlationEngine, schedulerConfig)
+	recorrelationScheduler.SetNewCorrelationsCallback(func(incidentID, runID string, correlations []correlation.Correlation) {
+		realtimeServer.BroadcastCorrelationFound(server.incidentTarget(incidentID), map[string]interface{}{
+			"incident_id":  incidentID,
+			"run_id":       runID,
+			"correlations": correlations,
+		})
+	})
+	recorrelationScheduler.SetTimelineCallback(func(incidentID string, event interface{}) {
+		realtimeServer.BroadcastTimelineEvent(server.incidentTarget(incidentID), event)
+	})
+	recorrelationScheduler.Start(ctx)
+
+	// Optionally store compliance reports for each completed month or quarter
+	reportSchedules, err := reports.ParseSchedule(getEnv("REPORT_SCHEDULE", ""))
+	if err != nil {
+		log.Fatalf("Invalid REPORT_SCHEDULE: %v", err)
+	}
+	var reportScheduler *reports.Scheduler
+	if len(reportSchedules) > 0 {
+		reportScheduler = reports.NewScheduler(server.reportGenerator, reportSchedules)
+		reportScheduler.Start(ctx)
+	}
+
+	// Optionally prune old rows and archive resolved incidents once a day
+	retentionConfig, err := retention.LoadConfigFromEnv()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	var retentionScheduler *retention.Scheduler
+	if retentionConfig.Enabled() {
+		var archiver *retention.Archiver
+		if retentionConfig.ArchiveAfter > 0 {
+			store, err := retention.NewDirStore(retentionConfig.ArchiveDir)
+			if err != nil {
+				log.Fatalf("Failed to open archive directory: %v", err)
+			}
+			archiver = retention.NewArchiver(db, store)
+		}
+		retentionScheduler = retention.NewScheduler(db, archiver, retentionConfig)
+		retentionScheduler.Start(ctx)
+	}
+
+	// Limit requests per API key, user or IP
+	rateLimitConfig, err := ratelimit.LoadConfigFromEnv()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if rateLimitConfig.Shared {
+		rateLimitStore = ratelimit.NewPostgresStore(db)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitConfig, rateLimitStore)
+	limiter.Start(ctx)
+	prometheus.MustRegister(limiter)
+
 	// Setup router
 	router := mux.NewRouter()
 
@@ -233,11 +392,12 @@ func main() {
 	router.Use(middleware.Recovery)
 	router.Use(middleware.Logging)
 	router.Use(middleware.SecurityHeadersMiddleware)
-	router.Use(middleware.RateLimitingMiddleware)
+	router.Use(middleware.RateLimit(limiter))
 	
 	// Initialize detection handlers
 	handlers.InitDetectionHandlers(detector)
 	handlers.InitInvestigationHandlers(investigationService)
+	handlers.InitHandlers(incidentService, serviceService, sloService, services.NewTaskService(db, zapLogger), zapLogger)
 
 	// Add telemetry middleware to capture all metrics and logs
 	telemetryMiddleware := middleware.NewTelemetryMiddleware(promClient, lokiClient, "reliability-studio")
@@ -248,36 +408,57 @@ func main() {
 	router.HandleFunc("/api/health", handlers.HandleHealthCheck(healthChecker)).Methods("GET")
 	// Prometheus metrics endpoint (public, no auth required)
 	router.Handle("/metrics", promhttp.Handler())
-	router.HandleFunc("/api/auth/login", middleware.LoginHandler(db)).Methods("POST")
-	router.HandleFunc("/api/auth/register", middleware.RegisterHandler(db)).Methods("POST")
-	router.HandleFunc("/api/auth/refresh", middleware.RefreshTokenHandler()).Methods("POST")
+	router.HandleFunc("/api/auth/config", authModesHandler(authConfig)).Methods("GET")
+	if authConfig.Enabled(auth.ModeLocal) {
+		router.HandleFunc("/api/auth/login", middleware.LoginHandler(db)).Methods("POST")
+		router.HandleFunc("/api/auth/register", middleware.RegisterHandler(db)).Methods("POST")
+	}
+	if oidcProvider != nil {
+		router.HandleFunc("/api/auth/oidc/login", middleware.OIDCLoginHandler(oidcProvider)).Methods("GET")
+		router.HandleFunc("/api/auth/oidc/callback", middleware.OIDCCallbackHandler(db, oidcProvider)).Methods("GET")
+	}
+	router.HandleFunc("/api/auth/refresh", middleware.RefreshTokenHandler(db)).Methods("POST")
 	
-	// WebSocket route (public for now, can add auth later)
-	router.HandleFunc("/api/realtime", realtimeServer.HandleWebSocket)
-
-	// Public API routes for Grafana plugin access
-	// These bypass auth for development - add auth in production
-	router.HandleFunc("/api/incidents", server.getIncidentsHandler).Methods("GET")
-	router.HandleFunc("/api/incidents/active", server.getActiveIncidentsHandler).Methods("GET")
-	router.HandleFunc("/api/incidents", server.createIncidentHandler).Methods("POST")
-	router.HandleFunc("/api/incidents/{id}", server.getIncidentHandler).Methods("GET")
-	router.HandleFunc("/api/incidents/{id}", server.updateIncidentHandler).Methods("PATCH")
-	router.HandleFunc("/api/incidents/{id}/timeline", server.getIncidentTimelineHandler).Methods("GET")
-	router.HandleFunc("/api/incidents/{id}/correlations", server.getIncidentCorrelationsHandler).Methods("GET")
-	router.HandleFunc("/api/incidents/{id}/analysis", server.getIncidentAnalysisHandler).Methods("GET")
-	router.HandleFunc("/api/services", server.getServicesHandler).Methods("GET")
+	// WebSocket route. Clients only receive the messages of their organization.
+	router.Handle("/api/realtime", server.protect(http.HandlerFunc(server.realtimeHandler)))
+	router.Handle("/api/realtime/events", server.protect(http.HandlerFunc(server.realtimeEventsHandler))).Methods("GET")
+
+	// Incident routes used by the Grafana plugin. Every caller needs a user so
+	// results can be limited to the services they may see.
+	incident := func(p rbac.Permission, h http.HandlerFunc) http.Handler {
+		return server.protect(server.forIncident(p, h))
+	}
+	router.Handle("/api/incidents", server.protect(http.HandlerFunc(server.getIncidentsHandler))).Methods("GET")
+	router.Handle("/api/incidents/active", server.protect(http.HandlerFunc(server.getActiveIncidentsHandler))).Methods("GET")
+	router.Handle("/api/incidents", server.protect(http.HandlerFunc(server.createIncidentHandler))).Methods("POST")
+	router.Handle("/api/incidents/{id}", incident(rbac.IncidentRead, server.getIncidentHandler)).Methods("GET")
+	router.Handle("/api/incidents/{id}", incident(rbac.IncidentWrite, server.updateIncidentHandler)).Methods("PATCH")
+	router.Handle("/api/incidents/{id}/timeline", incident(rbac.IncidentRead, server.getIncidentTimelineHandler)).Methods("GET")
+	router.Handle("/api/incidents/{id}/correlations", incident(rbac.IncidentRead, server.getIncidentCorrelationsHandler)).Methods("GET")
+	router.Handle("/api/incidents/{id}/analysis", incident(rbac.IncidentRead, server.getIncidentAnalysisHandler)).Methods("GET")
+	router.Handle("/api/incidents/{id}/correlate", incident(rbac.IncidentWrite, server.recorrelateIncidentHandler)).Methods("POST")
+	router.Handle("/api/incidents/{id}/correlation-runs", incident(rbac.IncidentRead, server.getCorrelationRunsHandler)).Methods("GET")
+	router.Handle("/api/incidents/{id}/correlation-runs/diff", incident(rbac.IncidentRead, server.getCorrelationRunDiffHandler)).Methods("GET")
+	router.Handle("/api/incidents/{id}/correlation-runs/{run_id}", incident(rbac.IncidentRead, server.getCorrelationRunHandler)).Methods("GET")
+	router.Handle("/api/incidents/{id}/root-causes/feedback", incident(rbac.IncidentRead, server.getRootCauseFeedbackHandler)).Methods("GET")
+	router.Handle("/api/incidents/{id}/root-causes/actual", incident(rbac.IncidentWrite, server.recordActualRootCauseHandler)).Methods("POST")
+	router.Handle("/api/incidents/{id}/root-causes/{index:[0-9]+}/confirm", incident(rbac.IncidentWrite, server.rootCauseVerdictHandler(correlation.VerdictConfirmed))).Methods("POST")
+	router.Handle("/api/incidents/{id}/root-causes/{index:[0-9]+}/reject", incident(rbac.IncidentWrite, server.rootCauseVerdictHandler(correlation.VerdictRejected))).Methods("POST")
+	router.Handle("/api/services", server.protect(http.HandlerFunc(server.getServicesHandler))).Methods("GET")
+	// CI pipelines query deploy gates with an API key scoped to slo:read
+	router.Handle("/api/services/{name}/deploy-gate", server.protect(http.HandlerFunc(server.getDeployGateHandler))).Methods("GET")
 
 	// Protected routes - requires authentication
 	api := router.PathPrefix("/api/admin").Subrouter()
-	api.Use(middleware.Auth)
+	api.Use(middleware.Auth, server.withAccess)
 
 	// Investigation routes (guided RCA workflows)
-	api.HandleFunc("/incidents/{id}/investigation/hypotheses", handlers.GetInvestigationHypotheses).Methods("GET")
-	api.HandleFunc("/incidents/{id}/investigation/hypotheses", handlers.CreateInvestigationHypothesis).Methods("POST")
-	api.HandleFunc("/incidents/{id}/investigation/steps", handlers.GetInvestigationSteps).Methods("GET")
-	api.HandleFunc("/incidents/{id}/investigation/steps", handlers.CreateInvestigationStep).Methods("POST")
-	api.HandleFunc("/incidents/{id}/investigation/rca", handlers.GetRootCauseAnalysis).Methods("GET")
-	api.HandleFunc("/incidents/{id}/investigation/recommended-actions", handlers.GetRecommendedActions).Methods("GET")
+	api.Handle("/incidents/{id}/investigation/hypotheses", server.forIncident(rbac.IncidentRead, handlers.GetInvestigationHypotheses)).Methods("GET")
+	api.Handle("/incidents/{id}/investigation/hypotheses", server.forIncident(rbac.IncidentWrite, handlers.CreateInvestigationHypothesis)).Methods("POST")
+	api.Handle("/incidents/{id}/investigation/steps", server.forIncident(rbac.IncidentRead, handlers.GetInvestigationSteps)).Methods("GET")
+	api.Handle("/incidents/{id}/investigation/steps", server.forIncident(rbac.IncidentWrite, handlers.CreateInvestigationStep)).Methods("POST")
+	api.Handle("/incidents/{id}/investigation/rca", server.forIncident(rbac.IncidentRead, handlers.GetRootCauseAnalysis)).Methods("GET")
+	api.Handle("/incidents/{id}/investigation/recommended-actions", server.forIncident(rbac.IncidentRead, handlers.GetRecommendedActions)).Methods("GET")
 
 	// Detection rules and alerts
 	api.HandleFunc("/detection/rules", handlers.GetDetectionRules).Methods("GET")
@@ -286,11 +467,35 @@ func main() {
 	// SLO routes
 	api.HandleFunc("/slos", server.getSLOsHandler).Methods("GET")
 	api.HandleFunc("/slos", server.createSLOHandler).Methods("POST")
-	api.HandleFunc("/slos/{id}", server.getSLOHandler).Methods("GET")
-	api.HandleFunc("/slos/{id}", server.updateSLOHandler).Methods("PATCH")
-	api.HandleFunc("/slos/{id}", server.deleteSLOHandler).Methods("DELETE")
-	api.HandleFunc("/slos/{id}/calculate", server.calculateSLOHandler).Methods("POST")
-	api.HandleFunc("/slos/{id}/history", server.getSLOHistoryHandler).Methods("GET")
+	api.HandleFunc("/slos/import", server.importOpenSLOHandler).Methods("POST")
+	api.HandleFunc("/slos/export", server.exportOpenSLOHandler).Methods("GET")
+	api.HandleFunc("/slos/rules", server.getSLORulesHandler).Methods("GET")
+	api.HandleFunc("/slos/composites", server.getCompositeSLOsHandler).Methods("GET")
+	api.HandleFunc("/slos/composites", server.createCompositeSLOHandler).Methods("POST")
+	api.Handle("/slos/composites/{id}", server.forComposite(rbac.SLORead, server.getCompositeSLOHandler)).Methods("GET")
+	api.Handle("/slos/composites/{id}", server.forComposite(rbac.SLOAdmin, server.deleteCompositeSLOHandler)).Methods("DELETE")
+	api.Handle("/slos/composites/{id}/calculate", server.forComposite(rbac.SLORead, server.calculateCompositeSLOHandler)).Methods("POST")
+	api.Handle("/slos/composites/{id}/history", server.forComposite(rbac.SLORead, server.getCompositeSLOHistoryHandler)).Methods("GET")
+	api.Handle("/slos/{id}", server.forSLO(rbac.SLORead, server.getSLOHandler)).Methods("GET")
+	api.Handle("/slos/{id}", server.forSLO(rbac.SLOWrite, server.updateSLOHandler)).Methods("PATCH")
+	api.Handle("/slos/{id}", server.forSLO(rbac.SLOAdmin, server.deleteSLOHandler)).Methods("DELETE")
+	api.Handle("/slos/{id}/calculate", server.forSLO(rbac.SLORead, server.calculateSLOHandler)).Methods("POST")
+	api.Handle("/slos/{id}/history", server.forSLO(rbac.SLORead, server.getSLOHistoryHandler)).Methods("GET")
+	api.Handle("/slos/{id}/policies", server.forSLO(rbac.SLORead, server.getPoliciesHandler)).Methods("GET")
+	api.Handle("/slos/{id}/policies", server.forSLO(rbac.SLOAdmin, server.createPolicyHandler)).Methods("POST")
+	api.Handle("/slos/{id}/policies/history", server.forSLO(rbac.SLORead, server.getPolicyHistoryHandler)).Methods("GET")
+	api.Handle("/slos/{id}/policies/{policy_id}", server.forSLO(rbac.SLOAdmin, server.deletePolicyHandler)).Methods("DELETE")
+
+	// SLO compliance reports
+	api.HandleFunc("/reports/slo", server.getSLOReportHandler).Methods("GET")
+	api.HandleFunc("/reports/slo/stored", server.getStoredSLOReportsHandler).Methods("GET")
+	api.HandleFunc("/reports/slo/stored/{id}", server.getStoredSLOReportHandler).Methods("GET")
+
+	// Service catalog routes (including Kubernetes placement: cluster, namespace, label selector)
+	api.HandleFunc("/services", handlers.ListServices).Methods("GET")
+	api.HandleFunc("/services", handlers.CreateService).Methods("POST")
+	api.HandleFunc("/services/{id}", handlers.GetService).Methods("GET")
+	api.HandleFunc("/services/{id}", handlers.UpdateService).Methods("PATCH")
 
 	// Metrics routes
 	api.HandleFunc("/metrics/availability/{service}", server.getServiceAvailabilityHandler).Methods("GET")
@@ -304,6 +509,16 @@ func main() {
 		api.HandleFunc("/kubernetes/events/{namespace}/{service}", server.getK8sEventsHandler).Methods("GET")
 	}
 
+	// Teams and per-service grants
+	api.HandleFunc("/me/permissions", server.getMyPermissionsHandler).Methods("GET")
+	api.HandleFunc("/teams", server.getTeamsHandler).Methods("GET")
+	api.HandleFunc("/teams/{id}/members", server.getTeamMembersHandler).Methods("GET")
+	api.HandleFunc("/teams/{id}/members/{user_id}", server.setTeamMemberHandler).Methods("PUT")
+	api.HandleFunc("/teams/{id}/members/{user_id}", server.removeTeamMemberHandler).Methods("DELETE")
+	api.HandleFunc("/services/{id}/grants", server.getServiceGrantsHandler).Methods("GET")
+	api.HandleFunc("/services/{id}/grants/{user_id}", server.setServiceGrantHandler).Methods("PUT")
+	api.HandleFunc("/services/{id}/grants/{user_id}", server.removeServiceGrantHandler).Methods("DELETE")
+
 	// Logs routes
 	api.HandleFunc("/logs/{service}/errors", server.getErrorLogsHandler).Methods("GET")
 	api.HandleFunc("/logs/{service}/search", server.searchLogsHandler).Methods("GET")
@@ -313,6 +528,20 @@ func main() {
 	admin.Use(middleware.RequireRole("admin"))
 	admin.HandleFunc("/users", server.getUsersHandler).Methods("GET")
 	admin.HandleFunc("/services", server.getServicesHandler).Methods("GET")
+	admin.HandleFunc("/teams", server.createTeamHandler).Methods("POST")
+	admin.HandleFunc("/teams/{id}", server.deleteTeamHandler).Methods("DELETE")
+	admin.HandleFunc("/audit", server.platform(server.getAuditLogHandler)).Methods("GET")
+	admin.HandleFunc("/audit/verify", server.platform(server.verifyAuditLogHandler)).Methods("GET")
+	admin.HandleFunc("/organizations", server.platform(server.getOrganizationsHandler)).Methods("GET")
+	admin.HandleFunc("/organizations", server.platform(server.createOrganizationHandler)).Methods("POST")
+	admin.HandleFunc("/organizations/{id}/users/{user_id}", server.platform(server.setUserOrganizationHandler)).Methods("PUT")
+	admin.HandleFunc("/service-accounts", server.getServiceAccountsHandler).Methods("GET")
+	admin.HandleFunc("/service-accounts", server.createServiceAccountHandler).Methods("POST")
+	admin.HandleFunc("/service-accounts/{id}", server.deleteServiceAccountHandler).Methods("DELETE")
+	admin.HandleFunc("/service-accounts/{id}/keys", server.getAPIKeysHandler).Methods("GET")
+	admin.HandleFunc("/service-accounts/{id}/keys", server.createAPIKeyHandler).Methods("POST")
+	admin.HandleFunc("/service-accounts/{id}/keys/{key_id}/rotate", server.rotateAPIKeyHandler).Methods("POST")
+	admin.HandleFunc("/service-accounts/{id}/keys/{key_id}", server.revokeAPIKeyHandler).Methods("DELETE")
 
 	// Test endpoints - for chaos engineering and verification
 	// Note: These are public endpoints for testing purposes
@@ -365,6 +594,14 @@ func main() {
 			log.Println("⛔ Stopping incident detector...")
 			detector.Stop()
 		}
+		recorrelationScheduler.Stop()
+		if reportScheduler != nil {
+			reportScheduler.Stop()
+		}
+		if retentionScheduler != nil {
+			retentionScheduler.Stop()
+		}
+		limiter.Stop()
 
 		// Cancel background jobs
 		cancelBackgroundJobs()
@@ -394,6 +631,14 @@ func (s *Server) startBackgroundJobs(ctx context.Context) {
 	telemetryTicker := time.NewTicker(30 * time.Second)
 	defer telemetryTicker.Stop()
 
+	// Roll up SLO history into hourly and daily rows and apply retention
+	historyTicker := time.NewTicker(1 * time.Hour)
+	defer historyTicker.Stop()
+
+	// Refresh exported incident metrics, which also picks up detected incidents
+	incidentMetricsTicker := time.NewTicker(30 * time.Second)
+	defer incidentMetricsTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -408,6 +653,17 @@ func (s *Server) startBackgroundJobs(ctx context.Context) {
 		case <-telemetryTicker.C:
 			// Generate sample telemetry for development
 			go s.generateSampleTelemetry(ctx)
+		case <-historyTicker.C:
+			result, err := s.sloService.DownsampleHistory(ctx, time.Now())
+			if err != nil {
+				log.Printf("Error downsampling SLO history: %v", err)
+				continue
+			}
+			log.Printf("SLO history downsampled: %d hourly, %d daily, %d deleted", result.Hourly, result.Daily, result.Deleted)
+		case <-incidentMetricsTicker.C:
+			if err := s.incidentService.RefreshMetrics(ctx); err != nil {
+				log.Printf("Error refreshing incident metrics: %v", err)
+			}
 		}
 	}
 }
@@ -534,17 +790,25 @@ func (s *Server) getIncidentsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	where, condArgs := access.Filter(rbac.IncidentRead, "i", 3)
+	args := append([]interface{}{limit, offset}, condArgs...)
+
 	// Query incidents from database with pagination and timeout
 	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 	defer cancel()
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT i.id, i.title, i.severity, i.status, s.name as service, i.started_at
+		SELECT i.id, i.title, i.severity, i.status, s.name as service, i.started_at, i.version
 		FROM incidents i
 		LEFT JOIN services s ON i.service_id = s.id
+		WHERE `+where+`
 		ORDER BY i.started_at DESC
 		LIMIT $1 OFFSET $2
-	`, limit, offset)
+	`, args...)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to query incidents")
 		return
@@ -555,8 +819,9 @@ func (s *Server) getIncidentsHandler(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var id, title, severity, status, service string
 		var startedAt time.Time
+		var version int
 
-		if err := rows.Scan(&id, &title, &severity, &status, &service, &startedAt); err != nil {
+		if err := rows.Scan(&id, &title, &severity, &status, &service, &startedAt, &version); err != nil {
 			continue
 		}
 
@@ -567,6 +832,7 @@ func (s *Server) getIncidentsHandler(w http.ResponseWriter, r *http.Request) {
 			"status":     status,
 			"service":    service,
 			"started_at": startedAt,
+			"version":    version,
 		})
 	}
 
@@ -581,16 +847,22 @@ func (s *Server) getIncidentsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) getActiveIncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	where, args := access.Filter(rbac.IncidentRead, "i", 1)
+
 	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 	defer cancel()
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT i.id, i.title, i.severity, i.status, s.name as service, i.started_at
+		SELECT i.id, i.title, i.severity, i.status, s.name as service, i.started_at, i.version
 		FROM incidents i
 		LEFT JOIN services s ON i.service_id = s.id
-		WHERE i.status != 'resolved'
+		WHERE i.status != 'resolved' AND `+where+`
 		ORDER BY i.started_at DESC
-	`)
+	`, args...)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to query incidents: %v", err))
 		return
@@ -601,8 +873,9 @@ func (s *Server) getActiveIncidentsHandler(w http.ResponseWriter, r *http.Reques
 	for rows.Next() {
 		var id, title, severity, status, service string
 		var startedAt time.Time
+		var version int
 
-		if err := rows.Scan(&id, &title, &severity, &status, &service, &startedAt); err != nil {
+		if err := rows.Scan(&id, &title, &severity, &status, &service, &startedAt, &version); err != nil {
 			continue
 		}
 
@@ -613,6 +886,7 @@ func (s *Server) getActiveIncidentsHandler(w http.ResponseWriter, r *http.Reques
 			"status":     status,
 			"service":    service,
 			"started_at": startedAt,
+			"version":    version,
 		})
 	}
 
@@ -650,14 +924,22 @@ func (s *Server) createIncidentHandler(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "Service is required")
 		return
 	}
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	if !access.CanName(rbac.IncidentWrite, req.Service) {
+		forbidden(w, rbac.IncidentWrite)
+		return
+	}
 
 	// Get or create service
 	var serviceID string
 	err := s.db.QueryRow(`
-		INSERT INTO services (name, status) VALUES ($1, 'degraded')
-		ON CONFLICT (name) DO UPDATE SET status = 'degraded'
+		INSERT INTO services (name, status, org_id) VALUES ($1, 'degraded', $2)
+		ON CONFLICT (org_id, name) DO UPDATE SET status = 'degraded'
 		RETURNING id
-	`, req.Service).Scan(&serviceID)
+	`, req.Service, access.OrgID).Scan(&serviceID)
 
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create service: %v", err))
@@ -666,22 +948,32 @@ func (s *Server) createIncidentHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Create incident
 	var incidentID string
+	var version int
 	err = s.db.QueryRow(`
-		INSERT INTO incidents (title, description, severity, status, service, service_id)
-		VALUES ($1, $2, $3, 'open', $4, $5)
-		RETURNING id
-	`, req.Title, req.Description, req.Severity, req.Service, serviceID).Scan(&incidentID)
+		INSERT INTO incidents (title, description, severity, status, service, service_id, org_id)
+		VALUES ($1, $2, $3, 'open', $4, $5, $6)
+		RETURNING id, version
+	`, req.Title, req.Description, req.Severity, req.Service, serviceID, access.OrgID).Scan(&incidentID, &version)
 
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create incident: %v", err))
 		return
 	}
 
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionIncidentCreate,
+		ResourceType: "incident",
+		ResourceID:   incidentID,
+		Success:      true,
+		After:        s.rowSnapshot(r.Context(), "incidents", incidentID),
+	})
+
 	// Start correlation
 	go func() {
 		ctx := context.Background()
-		_, _ = s.correlationEngine.CorrelateIncident(ctx, incidentID, req.Service, "default", time.Now())
+		_, _ = s.correlationEngine.CorrelateIncident(ctx, incidentID, req.Service, "", time.Now())
 	}()
+	go s.incidentService.RefreshMetrics(context.Background())
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -692,6 +984,7 @@ func (s *Server) createIncidentHandler(w http.ResponseWriter, r *http.Request) {
 		"status":     "open",
 		"service":    req.Service,
 		"created_at": time.Now(),
+		"version":    version,
 	})
 }
 
@@ -708,16 +1001,17 @@ func (s *Server) getIncidentHandler(w http.ResponseWriter, r *http.Request) {
 		Service     string     `json:"service"`
 		StartedAt   time.Time  `json:"started_at"`
 		ResolvedAt  *time.Time `json:"resolved_at"`
+		Version     int        `json:"version"` // send back in If-Match when updating
 	}
 
 	err := s.db.QueryRow(`
-		SELECT i.id, i.title, i.description, i.severity, i.status, s.name as service, i.started_at, i.resolved_at
+		SELECT i.id, i.title, i.description, i.severity, i.status, s.name as service, i.started_at, i.resolved_at, i.version
 		FROM incidents i
 		LEFT JOIN services s ON i.service_id = s.id
 		WHERE i.id = $1
 	`, incidentID).Scan(
 		&incident.ID, &incident.Title, &incident.Description, &incident.Severity,
-		&incident.Status, &incident.Service, &incident.StartedAt, &incident.ResolvedAt,
+		&incident.Status, &incident.Service, &incident.StartedAt, &incident.ResolvedAt, &incident.Version,
 	)
 
 	if err == sql.ErrNoRows {
@@ -729,65 +1023,89 @@ func (s *Server) getIncidentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", services.IncidentState{Version: incident.Version}.ETag())
 	respondJSON(w, http.StatusOK, incident)
 }
 
+// updateIncidentHandler updates an incident. The update only applies to the
+// version in the If-Match header, or in the body, and returns 409 with the
+// current state when the incident changed since, so edits aren't silently
+// overwritten. Without a version it returns 428; "If-Match: *" applies to
+// any version.
 func (s *Server) updateIncidentHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	incidentID := vars["id"]
 
 	var req struct {
-		Status   string `json:"status"`
-		Severity string `json:"severity"`
+		services.IncidentPatch
+		Version int `json:"version"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request")
 		return
 	}
+	version, ok := handlers.IncidentVersion(w, r, req.Version)
+	if !ok {
+		return
+	}
 
-	_, err := s.db.Exec(`
-		UPDATE incidents 
-		SET status = COALESCE(NULLIF($1, ''), status),
-		    severity = COALESCE(NULLIF($2, ''), severity),
-		    updated_at = NOW(),
-		    resolved_at = CASE WHEN $1 = 'resolved' AND resolved_at IS NULL THEN NOW() ELSE resolved_at END
-		WHERE id = $3
-	`, req.Status, req.Severity, incidentID)
-
-	if err != nil {
+	before := s.rowSnapshot(r.Context(), "incidents", incidentID)
+	incident, changes, err := s.incidentService.Patch(r.Context(), incidentID, req.IncidentPatch, version)
+	if errors.Is(err, services.ErrIncidentConflict) {
+		handlers.IncidentConflict(w, incident, err)
+		return
+	} else if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Incident not found")
+		return
+	} else if err != nil {
+		log.Printf("Error updating incident %s: %v", incidentID, err)
 		respondError(w, http.StatusInternalServerError, "Failed to update incident")
 		return
 	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionIncidentUpdate,
+		ResourceType: "incident",
+		ResourceID:   incidentID,
+		Success:      true,
+		Before:       before,
+		After:        s.rowSnapshot(r.Context(), "incidents", incidentID),
+	})
 
-	// Fetch updated incident for broadcast
-	var id, title, severity, status, serviceName string
-	var startedAt time.Time
-	var resolvedAt sql.NullTime
-	err = s.db.QueryRow(`
-		SELECT i.id, i.title, i.severity, i.status, COALESCE(s.name, 'unknown') as service, i.started_at, i.resolved_at
-		FROM incidents i
-		LEFT JOIN services s ON i.service_id = s.id
-		WHERE i.id = $1
-	`, incidentID).Scan(&id, &title, &severity, &status, &serviceName, &startedAt, &resolvedAt)
-	
-	if err == nil && s.realtimeServer != nil {
+	// Resolved incidents feed the similar-incident search
+	if req.Status == "resolved" || req.RootCause != "" || req.Resolution != "" {
+		s.correlationEngine.InvalidateSimilarityIndex()
+	}
+
+	if s.realtimeServer != nil {
+		target := s.incidentTarget(incidentID)
 		incidentData := map[string]interface{}{
-			"id":         id,
-			"title":      title,
-			"severity":   severity,
-			"status":     status,
-			"service":    serviceName,
-			"started_at": startedAt,
+			"id":         incident.ID,
+			"title":      incident.Title,
+			"severity":   incident.Severity,
+			"status":     incident.Status,
+			"service":    incident.Service,
+			"started_at": incident.StartedAt,
+			"version":    incident.Version,
 		}
-		if resolvedAt.Valid {
-			incidentData["resolved_at"] = resolvedAt.Time
+		if incident.ResolvedAt != nil {
+			incidentData["resolved_at"] = *incident.ResolvedAt
+		}
+		log.Printf("📡 Broadcasting incident update: id=%s, status=%s", incident.ID, incident.Status)
+		s.realtimeServer.BroadcastIncidentUpdated(target, incidentData)
+		if len(changes) > 0 {
+			s.realtimeServer.BroadcastIncidentFieldsChanged(target, map[string]interface{}{
+				"incident_id": incident.ID,
+				"version":     incident.Version,
+				"user":        displayUser(r),
+				"changes":     changes,
+			})
 		}
-		log.Printf("📡 Broadcasting incident update: id=%s, status=%s", id, status)
-		s.realtimeServer.BroadcastIncidentUpdated(incidentData)
 	}
+	go s.incidentService.RefreshMetrics(context.Background())
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+	w.Header().Set("ETag", incident.ETag())
+	respondJSON(w, http.StatusOK, map[string]interface{}{"status": "updated", "version": incident.Version, "incident": incident})
 }
 
 func (s *Server) getIncidentTimelineHandler(w http.ResponseWriter, r *http.Request) {
@@ -823,8 +1141,14 @@ func (s *Server) getIncidentCorrelationsHandler(w http.ResponseWriter, r *http.R
 func (s *Server) getIncidentAnalysisHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	incidentID := vars["id"]
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
 
-	analysis, err := s.correlationEngine.GetIncidentAnalysis(context.Background(), incidentID)
+	analysis, err := s.correlationEngine.GetIncidentAnalysis(context.Background(), incidentID, func(service string) bool {
+		return access.CanName(rbac.IncidentRead, service)
+	})
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to get incident analysis")
 		return
@@ -833,175 +1157,1831 @@ func (s *Server) getIncidentAnalysisHandler(w http.ResponseWriter, r *http.Reque
 	respondJSON(w, http.StatusOK, analysis)
 }
 
-func (s *Server) getSLOsHandler(w http.ResponseWriter, r *http.Request) {
-	slos, err := s.sloService.GetAllSLOs(context.Background())
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve SLOs: %v", err))
-		return
-	}
+// recorrelateIncidentHandler runs an on-demand correlation pass and stores it
+// as a new correlation run
+func (s *Server) recorrelateIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	incidentID := vars["id"]
 
-	respondJSON(w, http.StatusOK, slos)
-}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
 
-func (s *Server) createSLOHandler(w http.ResponseWriter, r *http.Request) {
-	var slo services.SLO
-	if err := json.NewDecoder(r.Body).Decode(&slo); err != nil {
-		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid SLO request body: %v", err))
+	ic, err := s.correlationEngine.RecorrelateIncident(ctx, incidentID, correlation.TriggerManual)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Incident not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to correlate incident: %v", err))
 		return
 	}
 
-	if err := s.sloService.CreateSLO(context.Background(), &slo); err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create SLO: %v", err))
-		return
+	if s.realtimeServer != nil && len(ic.Correlations) > 0 {
+		s.realtimeServer.BroadcastCorrelationFound(s.incidentTarget(incidentID), map[string]interface{}{
+			"incident_id":  incidentID,
+			"run_id":       ic.Run.ID,
+			"correlations": ic.Correlations,
+		})
 	}
 
-	respondJSON(w, http.StatusCreated, slo)
+	respondJSON(w, http.StatusCreated, ic.Run)
 }
 
-func (s *Server) getSLOHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	sloID := vars["id"]
+// rootCauseVerdictHandler confirms or rejects a RootCauseSummary candidate by its
+// index. The run defaults to the latest completed one.
+func (s *Server) rootCauseVerdictHandler(verdict string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		incidentID := vars["id"]
+		index, err := strconv.Atoi(vars["index"])
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid candidate index")
+			return
+		}
 
-	slo, err := s.sloService.GetSLO(context.Background(), sloID)
-	if err != nil {
-		respondError(w, http.StatusNotFound, fmt.Sprintf("SLO not found: %v", err))
-		return
+		var req struct {
+			RunID   string `json:"run_id"`
+			Comment string `json:"comment"`
+		}
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid request")
+				return
+			}
+		}
+
+		fb, err := s.correlationEngine.SubmitCandidateFeedback(r.Context(), incidentID, req.RunID, index, verdict, feedbackSubmitter(r), req.Comment)
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Correlation run not found")
+			return
+		} else if err == correlation.ErrInvalidCandidate {
+			respondError(w, http.StatusNotFound, err.Error())
+			return
+		} else if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to record feedback")
+			return
+		}
+		middleware.RecordAudit(r, audit.Entry{
+			Action:       audit.ActionRootCause,
+			ResourceType: "incident",
+			ResourceID:   incidentID,
+			Success:      true,
+			Details:      verdict,
+			After:        audit.Value(fb),
+		})
+
+		respondJSON(w, http.StatusCreated, fb)
 	}
+}
 
-	respondJSON(w, http.StatusOK, slo)
+// feedbackSubmitter identifies who submits root cause feedback: the API key,
+// or else the signed-in user
+func feedbackSubmitter(r *http.Request) string {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		return ""
+	}
+	if claims.APIKeyID != "" {
+		return "api_key:" + claims.APIKeyID
+	}
+	return claims.UserID
 }
 
-func (s *Server) updateSLOHandler(w http.ResponseWriter, r *http.Request) {
+// recordActualRootCauseHandler records the root cause signal identified at resolution
+func (s *Server) recordActualRootCauseHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	sloID := vars["id"]
+	incidentID := vars["id"]
 
-	var slo services.SLO
-	if err := json.NewDecoder(r.Body).Decode(&slo); err != nil {
-		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid SLO request body: %v", err))
+	var req struct {
+		SignalType string   `json:"signal_type"`
+		Source     string   `json:"source"`
+		Reason     string   `json:"reason"`
+		SignalIDs  []string `json:"signal_ids"`
+		Comment    string   `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if req.SignalType == "" {
+		respondError(w, http.StatusBadRequest, "signal_type is required")
 		return
 	}
-	slo.ID = sloID
 
-	if err := s.sloService.UpdateSLO(context.Background(), &slo); err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update SLO: %v", err))
+	actual := correlation.RootCauseSummary{
+		SignalType: req.SignalType,
+		Source:     req.Source,
+		Reason:     req.Reason,
+		SignalIDs:  req.SignalIDs,
+	}
+	fb, filled, err := s.correlationEngine.RecordActualRootCause(r.Context(), incidentID, actual, feedbackSubmitter(r), req.Comment)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Incident not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to record root cause")
 		return
 	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionRootCause,
+		ResourceType: "incident",
+		ResourceID:   incidentID,
+		Success:      true,
+		Details:      correlation.VerdictActual,
+		After:        audit.Value(fb),
+	})
+	s.correlationEngine.InvalidateSimilarityIndex()
+
+	// Filling in the root cause is an edit of the incident like a PATCH
+	if filled != nil && s.realtimeServer != nil {
+		s.realtimeServer.BroadcastIncidentFieldsChanged(s.incidentTarget(incidentID), map[string]interface{}{
+			"incident_id": incidentID,
+			"version":     filled.Version,
+			"user":        displayUser(r),
+			"changes":     []services.FieldChange{{Field: "root_cause", Old: "", New: filled.RootCause}},
+		})
+	}
 
-	respondJSON(w, http.StatusOK, slo)
+	respondJSON(w, http.StatusCreated, fb)
 }
 
-func (s *Server) deleteSLOHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getRootCauseFeedbackHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	sloID := vars["id"]
+	incidentID := vars["id"]
 
-	err := s.sloService.DeleteSLO(context.Background(), sloID)
+	feedback, err := s.correlationEngine.GetRootCauseFeedback(r.Context(), incidentID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete SLO: %v", err))
+		respondError(w, http.StatusInternalServerError, "Failed to get root cause feedback")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	respondJSON(w, http.StatusOK, feedback)
 }
 
-func (s *Server) calculateSLOHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getCorrelationRunsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	sloID := vars["id"]
+	incidentID := vars["id"]
 
-	analysis, err := s.sloService.CalculateSLO(context.Background(), sloID)
+	runs, err := s.correlationEngine.GetCorrelationRuns(r.Context(), incidentID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to calculate SLO: %v", err))
+		respondError(w, http.StatusInternalServerError, "Failed to get correlation runs")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, analysis)
+	respondJSON(w, http.StatusOK, runs)
 }
 
-func (s *Server) getSLOHistoryHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getCorrelationRunHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	sloID := vars["id"]
 
-	history, err := s.sloService.GetSLOHistory(context.Background(), sloID)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve SLO history: %v", err))
+	run, err := s.correlationEngine.GetCorrelationRun(r.Context(), vars["id"], vars["run_id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Correlation run not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get correlation run")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, history)
+	respondJSON(w, http.StatusOK, run)
 }
 
-func (s *Server) getServiceAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
-	// Implementation
-	respondJSON(w, http.StatusOK, map[string]interface{}{"availability": 99.9})
-}
+// getCorrelationRunDiffHandler compares two correlation runs. Without query
+// parameters it compares the latest completed run with the one before it.
+func (s *Server) getCorrelationRunDiffHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	incidentID := vars["id"]
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
 
-func (s *Server) getServiceErrorRateHandler(w http.ResponseWriter, r *http.Request) {
-	// Implementation
-	respondJSON(w, http.StatusOK, map[string]interface{}{"error_rate": 0.5})
-}
+	diff, err := s.correlationEngine.DiffRuns(r.Context(), incidentID, from, to)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Correlation run not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to diff correlation runs")
+		return
+	}
 
-func (s *Server) getServiceLatencyHandler(w http.ResponseWriter, r *http.Request) {
-	// Implementation
-	respondJSON(w, http.StatusOK, map[string]interface{}{"latency_p99": 250})
+	respondJSON(w, http.StatusOK, diff)
 }
 
-func (s *Server) getPodsHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	namespace := vars["namespace"]
-	service := vars["service"]
-
-	pods, err := s.k8sClient.GetPods(context.Background(), namespace, service)
+func (s *Server) getSLOsHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	slos, err := s.sloService.GetAllSLOs(context.Background(), access.OrgID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to get pods")
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve SLOs: %v", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, pods)
+	visible := make([]services.SLO, 0, len(slos))
+	for _, slo := range slos {
+		if access.CanName(rbac.SLORead, slo.Service) {
+			visible = append(visible, slo)
+		}
+	}
+	respondJSON(w, http.StatusOK, visible)
 }
 
-func (s *Server) getDeploymentsHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	namespace := vars["namespace"]
-	service := vars["service"]
+func (s *Server) createSLOHandler(w http.ResponseWriter, r *http.Request) {
+	var slo services.SLO
+	if err := json.NewDecoder(r.Body).Decode(&slo); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid SLO request body: %v", err))
+		return
+	}
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	if !access.CanName(rbac.SLOWrite, slo.Service) {
+		forbidden(w, rbac.SLOWrite)
+		return
+	}
 
-	deployments, err := s.k8sClient.GetDeployments(context.Background(), namespace, service)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to get deployments")
+	if err := s.sloService.CreateSLO(context.Background(), access.OrgID, &slo); errors.Is(err, services.ErrInvalidSLO) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create SLO: %v", err))
 		return
 	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionSLOCreate,
+		ResourceType: "slo",
+		ResourceID:   slo.ID,
+		Success:      true,
+		After:        s.rowSnapshot(r.Context(), "slos", slo.ID),
+	})
 
-	respondJSON(w, http.StatusOK, deployments)
-}
+	// Backfill the SLO window from Prometheus so charts don't start empty
+	go func(id string, days int) {
+		from := time.Now().AddDate(0, 0, -days)
+		if _, err := s.sloService.BackfillHistory(context.Background(), id, from, 5*time.Minute); err != nil {
+			log.Printf("Failed to backfill SLO %s: %v", id, err)
+		}
+	}(slo.ID, slo.Window)
 
-func (s *Server) getK8sEventsHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	namespace := vars["namespace"]
-	service := vars["service"]
+	respondJSON(w, http.StatusCreated, slo)
+}
 
-	events, err := s.k8sClient.GetEvents(context.Background(), namespace, service, time.Now().Add(-1*time.Hour))
+// importOpenSLOHandler upserts SLOs from an OpenSLO YAML body. With
+// ?validate_only=true nothing is written, so it can run as a pre-merge check.
+func (s *Server) importOpenSLOHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 5<<20))
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to get events")
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read request body: %v", err))
 		return
 	}
+	file := r.URL.Query().Get("file")
+	if file == "" {
+		file = "request"
+	}
+	validateOnly, _ := strconv.ParseBool(r.URL.Query().Get("validate_only"))
 
-	respondJSON(w, http.StatusOK, events)
-}
+	docs, errs := openslo.Parse(file, data)
+	var defs []openslo.Definition
+	if len(errs) == 0 {
+		defs, errs = openslo.Resolve(docs)
+	}
+	if len(errs) > 0 {
+		respondJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"valid":  false,
+			"errors": errs,
+		})
+		return
+	}
+	if validateOnly {
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"valid":  true,
+			"errors": []openslo.Error{},
+			"slos":   defs,
+		})
+		return
+	}
 
-func (s *Server) getErrorLogsHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	service := vars["service"]
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	for _, def := range defs {
+		if !access.CanName(rbac.SLOWrite, def.Service) {
+			respondError(w, http.StatusForbidden, fmt.Sprintf("Missing permission: %s on service %s", rbac.SLOWrite, def.Service))
+			return
+		}
+	}
 
-	logs, err := s.lokiClient.GetErrorLogs(context.Background(), service, time.Now().Add(-15*time.Minute), 100)
+	imported, err := s.sloService.ImportOpenSLO(r.Context(), access.OrgID, defs)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to get logs")
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to import SLOs: %v", err))
 		return
 	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionSLOImport,
+		ResourceType: "slo",
+		Success:      true,
+		Details:      file,
+		After:        audit.Value(imported),
+	})
 
-	respondJSON(w, http.StatusOK, logs)
-}
-
-func (s *Server) searchLogsHandler(w http.ResponseWriter, r *http.Request) {
-	// Implementation
-	respondJSON(w, http.StatusOK, []map[string]interface{}{})
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"valid":    true,
+		"errors":   []openslo.Error{},
+		"imported": imported,
+	})
+}
+
+func (s *Server) exportOpenSLOHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	defs, err := s.sloService.ExportOpenSLO(r.Context(), access.OrgID, r.URL.Query().Get("service"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to export SLOs: %v", err))
+		return
+	}
+
+	visible := make([]openslo.Definition, 0, len(defs))
+	for _, def := range defs {
+		if access.CanName(rbac.SLORead, def.Service) {
+			visible = append(visible, def)
+		}
+	}
+	data, err := openslo.Export(visible)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to export SLOs: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// getSLORulesHandler downloads the Prometheus rule file for a service's SLOs
+func (s *Server) getSLORulesHandler(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+	if service == "" {
+		respondError(w, http.StatusBadRequest, "service query parameter is required")
+		return
+	}
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	if !access.CanName(rbac.RuleRead, service) {
+		forbidden(w, rbac.RuleRead)
+		return
+	}
+
+	data, err := s.sloService.GenerateRules(r.Context(), access.OrgID, service)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("No SLOs found for service %s", service))
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to generate rules: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-slo-rules.yaml"`, openslo.Name(service)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (s *Server) getCompositeSLOsHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	composites, err := s.sloService.GetCompositeSLOs(r.Context(), access.OrgID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve composite SLOs: %v", err))
+		return
+	}
+
+	// A composite is listed when every component SLO is visible
+	visible := make([]services.CompositeSLO, 0, len(composites))
+	for _, composite := range composites {
+		readable := true
+		for _, c := range composite.Components {
+			if !access.CanName(rbac.SLORead, c.Service) {
+				readable = false
+				break
+			}
+		}
+		if readable {
+			visible = append(visible, composite)
+		}
+	}
+	respondJSON(w, http.StatusOK, visible)
+}
+
+func (s *Server) createCompositeSLOHandler(w http.ResponseWriter, r *http.Request) {
+	var composite services.CompositeSLO
+	if err := json.NewDecoder(r.Body).Decode(&composite); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid composite SLO request body: %v", err))
+		return
+	}
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	sloIDs := make([]string, 0, len(composite.Components))
+	for _, c := range composite.Components {
+		sloIDs = append(sloIDs, c.SLOID)
+	}
+	serviceIDs, err := s.sloServiceIDs(r.Context(), `SELECT DISTINCT service_id FROM slos WHERE id::text = ANY($1)`, pq.Array(sloIDs))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to check permissions")
+		return
+	}
+	for _, id := range serviceIDs {
+		if !access.Can(rbac.SLOWrite, id) {
+			forbidden(w, rbac.SLOWrite)
+			return
+		}
+	}
+
+	if err := s.sloService.CreateCompositeSLO(r.Context(), access.OrgID, &composite); errors.Is(err, services.ErrInvalidCompositeSLO) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create composite SLO: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionSLOCreate,
+		ResourceType: "composite_slo",
+		ResourceID:   composite.ID,
+		Success:      true,
+		After:        audit.Value(composite),
+	})
+
+	respondJSON(w, http.StatusCreated, composite)
+}
+
+func (s *Server) getCompositeSLOHandler(w http.ResponseWriter, r *http.Request) {
+	composite, err := s.sloService.GetCompositeSLO(r.Context(), mux.Vars(r)["id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Composite SLO not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve composite SLO: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, composite)
+}
+
+func (s *Server) deleteCompositeSLOHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	before := s.rowSnapshot(r.Context(), "composite_slos", id)
+	if err := s.sloService.DeleteCompositeSLO(r.Context(), id); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete composite SLO: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionSLODelete,
+		ResourceType: "composite_slo",
+		ResourceID:   id,
+		Success:      true,
+		Before:       before,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) calculateCompositeSLOHandler(w http.ResponseWriter, r *http.Request) {
+	analysis, err := s.sloService.CalculateCompositeSLO(r.Context(), mux.Vars(r)["id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Composite SLO not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to calculate composite SLO: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, analysis)
+}
+
+func (s *Server) getCompositeSLOHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	history, err := s.sloService.GetCompositeSLOHistory(r.Context(), mux.Vars(r)["id"], limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve composite SLO history: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, history)
+}
+
+func (s *Server) getPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	policies, err := s.sloService.GetPolicies(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve policies: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, policies)
+}
+
+func (s *Server) createPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	policy := services.ErrorBudgetPolicy{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid policy request body: %v", err))
+		return
+	}
+	policy.SLOID = mux.Vars(r)["id"]
+
+	if err := s.sloService.CreatePolicy(r.Context(), &policy); errors.Is(err, services.ErrInvalidPolicy) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create policy: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionPolicyCreate,
+		ResourceType: "slo_policy",
+		ResourceID:   policy.ID,
+		Success:      true,
+		After:        audit.Value(policy),
+	})
+
+	respondJSON(w, http.StatusCreated, policy)
+}
+
+func (s *Server) deletePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	before := s.rowSnapshot(r.Context(), "error_budget_policies", vars["policy_id"])
+	err := s.sloService.DeletePolicy(r.Context(), vars["id"], vars["policy_id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Policy not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete policy: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionPolicyDelete,
+		ResourceType: "slo_policy",
+		ResourceID:   vars["policy_id"],
+		Success:      true,
+		Before:       before,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) getPolicyHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	history, err := s.sloService.GetPolicyHistory(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve policy history: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, history)
+}
+
+// getDeployGateHandler lets CI pipelines check whether a service may deploy.
+// Blocked deploys are still answered with 200 so callers can read the reasons.
+func (s *Server) getDeployGateHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	service := mux.Vars(r)["name"]
+
+	gate, err := s.sloService.GetDeployGate(r.Context(), access.OrgID, service)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Service %s not found", service))
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to evaluate deploy gate: %v", err))
+		return
+	}
+	if !access.CanName(rbac.SLORead, service) {
+		forbidden(w, rbac.SLORead)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, gate)
+}
+
+// getSLOReportHandler generates a compliance report for a month, quarter or
+// date range in JSON, Markdown or CSV
+func (s *Server) getSLOReportHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	period, err := reports.ParsePeriod(q.Get("period"), q.Get("from"), q.Get("to"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.reportGenerator.Generate(r.Context(), access.OrgID, period, q.Get("service"), func(serviceID string) bool {
+		return access.Can(rbac.SLORead, serviceID)
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to generate report: %v", err))
+		return
+	}
+
+	writeReport(w, report, q.Get("format"))
+}
+
+func (s *Server) getStoredSLOReportsHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	stored, err := s.reportGenerator.ListStored(r.Context(), access.OrgID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve reports: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stored)
+}
+
+// getStoredSLOReportHandler returns a stored report without the services the
+// caller can't read SLOs of
+func (s *Server) getStoredSLOReportHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	report, err := s.reportGenerator.GetStored(r.Context(), access.OrgID, mux.Vars(r)["id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Report not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve report: %v", err))
+		return
+	}
+
+	report = report.Only(func(service string) bool {
+		return access.CanName(rbac.SLORead, service)
+	})
+	writeReport(w, report, r.URL.Query().Get("format"))
+}
+
+func writeReport(w http.ResponseWriter, report *reports.Report, format string) {
+	data, contentType, err := reports.Render(report, format)
+	if errors.Is(err, reports.ErrUnknownFormat) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to render report: %v", err))
+		return
+	}
+
+	filename := fmt.Sprintf("slo-report-%s.%s", strings.ReplaceAll(report.Period.Label, "..", "_"), reports.FileExtension(format))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (s *Server) getSLOHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sloID := vars["id"]
+
+	slo, err := s.sloService.GetSLO(context.Background(), sloID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("SLO not found: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, slo)
+}
+
+func (s *Server) updateSLOHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sloID := vars["id"]
+
+	var slo services.SLO
+	if err := json.NewDecoder(r.Body).Decode(&slo); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid SLO request body: %v", err))
+		return
+	}
+	slo.ID = sloID
+
+	before := s.rowSnapshot(r.Context(), "slos", sloID)
+	if err := s.sloService.UpdateSLO(context.Background(), &slo); errors.Is(err, services.ErrInvalidSLO) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update SLO: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionSLOUpdate,
+		ResourceType: "slo",
+		ResourceID:   sloID,
+		Success:      true,
+		Before:       before,
+		After:        s.rowSnapshot(r.Context(), "slos", sloID),
+	})
+
+	respondJSON(w, http.StatusOK, slo)
+}
+
+func (s *Server) deleteSLOHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sloID := vars["id"]
+
+	before := s.rowSnapshot(r.Context(), "slos", sloID)
+	err := s.sloService.DeleteSLO(context.Background(), sloID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete SLO: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionSLODelete,
+		ResourceType: "slo",
+		ResourceID:   sloID,
+		Success:      true,
+		Before:       before,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) calculateSLOHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sloID := vars["id"]
+
+	analysis, err := s.sloService.CalculateSLO(context.Background(), sloID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to calculate SLO: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, analysis)
+}
+
+func (s *Server) getSLOHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	historyRange, err := services.ParseHistoryRange(q.Get("from"), q.Get("to"), q.Get("step"), time.Now().UTC())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	history, err := s.sloService.GetSLOHistory(r.Context(), mux.Vars(r)["id"], historyRange)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve SLO history: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, history)
+}
+
+func (s *Server) getServiceAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	// Implementation
+	respondJSON(w, http.StatusOK, map[string]interface{}{"availability": 99.9})
+}
+
+func (s *Server) getServiceErrorRateHandler(w http.ResponseWriter, r *http.Request) {
+	// Implementation
+	respondJSON(w, http.StatusOK, map[string]interface{}{"error_rate": 0.5})
+}
+
+func (s *Server) getServiceLatencyHandler(w http.ResponseWriter, r *http.Request) {
+	// Implementation
+	respondJSON(w, http.StatusOK, map[string]interface{}{"latency_p99": 250})
+}
+
+// visibleService looks up a service of the caller's organization by name,
+// with where it runs. Services the caller can't read incidents of get 404, as
+// if they didn't exist.
+func (s *Server) visibleService(w http.ResponseWriter, r *http.Request, name string) (correlation.ServiceTarget, bool) {
+	target := correlation.ServiceTarget{Service: name}
+	access, ok := s.access(w, r)
+	if !ok {
+		return target, false
+	}
+	err := s.db.QueryRowContext(r.Context(), `
+		SELECT COALESCE(cluster, ''), COALESCE(namespace, ''), COALESCE(label_selector, '')
+		FROM services WHERE org_id = $1 AND name = $2
+	`, access.OrgID, name).Scan(&target.Cluster, &target.Namespace, &target.LabelSelector)
+	if err == sql.ErrNoRows || (err == nil && !access.CanName(rbac.IncidentRead, name)) {
+		respondError(w, http.StatusNotFound, fmt.Sprintf("Service %s not found", name))
+		return target, false
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load service")
+		return target, false
+	}
+	if target.Namespace == "" {
+		target.Namespace = "default"
+	}
+	return target, true
+}
+
+// serviceCluster returns the client of the cluster a service runs in. The
+// namespace in the path is ignored; the catalog says where the service runs.
+func (s *Server) serviceCluster(w http.ResponseWriter, r *http.Request) (*clients.KubernetesClient, correlation.ServiceTarget, bool) {
+	target, ok := s.visibleService(w, r, mux.Vars(r)["service"])
+	if !ok {
+		return nil, target, false
+	}
+	k8sClient, err := s.k8sClient.InCluster(target.Cluster)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return nil, target, false
+	}
+	return k8sClient, target, true
+}
+
+func (s *Server) getPodsHandler(w http.ResponseWriter, r *http.Request) {
+	k8sClient, target, ok := s.serviceCluster(w, r)
+	if !ok {
+		return
+	}
+
+	pods, err := k8sClient.GetPods(r.Context(), target.Namespace, target.Service)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get pods")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, pods)
+}
+
+func (s *Server) getDeploymentsHandler(w http.ResponseWriter, r *http.Request) {
+	k8sClient, target, ok := s.serviceCluster(w, r)
+	if !ok {
+		return
+	}
+
+	deployments, err := k8sClient.GetDeployments(r.Context(), target.Namespace, target.Service)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get deployments")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, deployments)
+}
+
+func (s *Server) getK8sEventsHandler(w http.ResponseWriter, r *http.Request) {
+	k8sClient, target, ok := s.serviceCluster(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := k8sClient.GetEvents(r.Context(), target.Namespace, target.Service, time.Now().Add(-1*time.Hour))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get events")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) getErrorLogsHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.visibleService(w, r, mux.Vars(r)["service"])
+	if !ok {
+		return
+	}
+
+	logs, err := s.lokiClient.GetErrorLogs(r.Context(), target.Service, time.Now().Add(-15*time.Minute), 100)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to get logs")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, logs)
+}
+
+func (s *Server) searchLogsHandler(w http.ResponseWriter, r *http.Request) {
+	// Implementation
+	respondJSON(w, http.StatusOK, []map[string]interface{}{})
+}
+
+// withAccess makes the caller's permissions available to handlers
+func (s *Server) withAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(middleware.UserContext).(*middleware.Claims)
+		if !ok {
+			respondError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		// API keys are limited to their scopes; unknown scopes allow nothing
+		var scopes []rbac.Permission
+		if claims.Scopes != nil {
+			scopes, _ = rbac.ParsePermissions(claims.Scopes)
+			if scopes == nil {
+				scopes = []rbac.Permission{}
+			}
+		}
+		ctx := s.authorizer.WithAccess(r.Context(), rbac.Identity{
+			UserID: claims.UserID,
+			OrgID:  claims.OrgID,
+			Roles:  claims.Roles,
+			Scopes: scopes,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// platform limits a handler to admins of the default organization, who manage
+// organizations and everything that spans them, like the audit log
+func (s *Server) platform(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		access, ok := s.access(w, r)
+		if !ok {
+			return
+		}
+		if access.OrgID != rbac.DefaultOrg {
+			respondError(w, http.StatusForbidden, "Only admins of the default organization may do this")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// realtimeHandler connects a websocket client to the messages of the caller's
+// organization
+func (s *Server) realtimeHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	s.realtimeServer.HandleWebSocket(w, r, access.OrgID, s.newRealtimeAccess(r, access))
+}
+
+// realtimeEventsHandler streams the same messages as server-sent events
+func (s *Server) realtimeEventsHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	s.realtimeServer.HandleSSE(w, r, access.OrgID, s.newRealtimeAccess(r, access))
+}
+
+// incidentTarget returns who receives messages about an incident. When the
+// incident can't be read the organization is "", which no client belongs to.
+func (s *Server) incidentTarget(incidentID string) websocket.Target {
+	var orgID, service string
+	err := s.db.QueryRow(`
+		SELECT i.org_id, COALESCE(s.name, '')
+		FROM incidents i
+		LEFT JOIN services s ON s.id = i.service_id
+		WHERE i.id::text = $1
+	`, incidentID).Scan(&orgID, &service)
+	if err != nil {
+		log.Printf("⚠️  Failed to read incident %s for broadcast: %v", incidentID, err)
+	}
+	return websocket.IncidentTarget(orgID, incidentID, service)
+}
+
+// realtimeAccess authorizes websocket and SSE subscriptions with the caller's
+// permissions
+type realtimeAccess struct {
+	db     *sql.DB
+	access *rbac.Access
+	user   string
+}
+
+// newRealtimeAccess returns the realtime access of a request's user
+func (s *Server) newRealtimeAccess(r *http.Request, access *rbac.Access) *realtimeAccess {
+	return &realtimeAccess{db: s.db, access: access, user: displayUser(r)}
+}
+
+// displayUser names the request's user to others: the username, or the user
+// ID when there is none
+func displayUser(r *http.Request) string {
+	claims, ok := middleware.GetClaims(r)
+	if !ok {
+		return ""
+	}
+	if claims.Username != "" {
+		return claims.Username
+	}
+	return claims.UserID
+}
+
+// CanSubscribe implements websocket.Access. Incident topics need incident:read
+// on the incident's service and service topics any permission on the service.
+func (a *realtimeAccess) CanSubscribe(topic string) error {
+	kind, arg, err := websocket.ParseTopic(topic)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "incident":
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var orgID, service string
+		err := a.db.QueryRowContext(ctx, `
+			SELECT i.org_id, COALESCE(s.name, '')
+			FROM incidents i
+			LEFT JOIN services s ON s.id = i.service_id
+			WHERE i.id::text = $1
+		`, arg).Scan(&orgID, &service)
+		if err == sql.ErrNoRows || (err == nil && orgID != a.access.OrgID) {
+			return fmt.Errorf("incident %s not found", arg)
+		} else if err != nil {
+			return fmt.Errorf("failed to check permissions")
+		}
+		if !a.access.CanName(rbac.IncidentRead, service) {
+			return fmt.Errorf("missing permission: %s", rbac.IncidentRead)
+		}
+	case "service":
+		if !a.access.VisibleName(arg) {
+			return fmt.Errorf("service %s not found", arg)
+		}
+	}
+	return nil
+}
+
+// CanSee implements websocket.Access
+func (a *realtimeAccess) CanSee(service string) bool {
+	return a.access.VisibleName(service)
+}
+
+// User implements websocket.Access
+func (a *realtimeAccess) User() string {
+	return a.user
+}
+
+// protect requires a signed-in user and loads their permissions
+func (s *Server) protect(next http.Handler) http.Handler {
+	return middleware.Auth(s.withAccess(next))
+}
+
+// access returns the caller's permissions. It has responded when ok is false.
+func (s *Server) access(w http.ResponseWriter, r *http.Request) (*rbac.Access, bool) {
+	access, err := rbac.FromContext(r.Context())
+	if errors.Is(err, rbac.ErrUnknownOrg) {
+		respondError(w, http.StatusForbidden, err.Error())
+		return nil, false
+	} else if err != nil {
+		log.Printf("Failed to load permissions: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to load permissions")
+		return nil, false
+	}
+	return access, true
+}
+
+func forbidden(w http.ResponseWriter, p rbac.Permission) {
+	respondError(w, http.StatusForbidden, fmt.Sprintf("Missing permission: %s", p))
+}
+
+// forIncident requires p on the service of the {id} incident. Incidents of
+// other organizations are not found.
+func (s *Server) forIncident(p rbac.Permission, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		access, ok := s.access(w, r)
+		if !ok {
+			return
+		}
+		var serviceID sql.NullString
+		err := s.db.QueryRowContext(r.Context(), `SELECT service_id FROM incidents WHERE id::text = $1 AND org_id = $2`,
+			mux.Vars(r)["id"], access.OrgID).Scan(&serviceID)
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Incident not found")
+			return
+		} else if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check permissions")
+			return
+		}
+		if !access.Can(p, serviceID.String) {
+			forbidden(w, p)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forSLO requires p on the service of the {id} SLO. SLOs of other
+// organizations are not found.
+func (s *Server) forSLO(p rbac.Permission, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		access, ok := s.access(w, r)
+		if !ok {
+			return
+		}
+		var serviceID sql.NullString
+		err := s.db.QueryRowContext(r.Context(), `SELECT service_id FROM slos WHERE id::text = $1 AND org_id = $2`,
+			mux.Vars(r)["id"], access.OrgID).Scan(&serviceID)
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "SLO not found")
+			return
+		} else if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check permissions")
+			return
+		}
+		if !access.Can(p, serviceID.String) {
+			forbidden(w, p)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forComposite requires p on the services of every component of the {id}
+// composite SLO. Composite SLOs of other organizations are not found.
+func (s *Server) forComposite(p rbac.Permission, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		access, ok := s.access(w, r)
+		if !ok {
+			return
+		}
+		var exists bool
+		if err := s.db.QueryRowContext(r.Context(), `SELECT EXISTS(SELECT 1 FROM composite_slos WHERE id::text = $1 AND org_id = $2)`,
+			mux.Vars(r)["id"], access.OrgID).Scan(&exists); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check permissions")
+			return
+		}
+		if !exists {
+			respondError(w, http.StatusNotFound, "Composite SLO not found")
+			return
+		}
+		serviceIDs, err := s.sloServiceIDs(r.Context(), `
+			SELECT DISTINCT s.service_id FROM composite_slo_components c
+			JOIN slos s ON s.id = c.slo_id
+			WHERE c.composite_id::text = $1
+		`, mux.Vars(r)["id"])
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check permissions")
+			return
+		}
+		for _, id := range serviceIDs {
+			if !access.Can(p, id) {
+				forbidden(w, p)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sloServiceIDs runs a query returning service IDs
+func (s *Server) sloServiceIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id sql.NullString
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id.String)
+	}
+	return ids, rows.Err()
+}
+
+func (s *Server) getMyPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, access.Summary())
+}
+
+func (s *Server) getTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	teams, err := s.authorizer.ListTeams(r.Context(), access.OrgID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve teams: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, teams)
+}
+
+func (s *Server) createTeamHandler(w http.ResponseWriter, r *http.Request) {
+	var team rbac.Team
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid team request body: %v", err))
+		return
+	}
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	team.OrgID = access.OrgID
+
+	err := s.authorizer.CreateTeam(r.Context(), &team)
+	if errors.Is(err, rbac.ErrInvalidTeam) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, rbac.ErrTeamExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create team: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionTeamCreate,
+		ResourceType: "team",
+		ResourceID:   team.ID,
+		Success:      true,
+		After:        audit.Value(team),
+	})
+
+	respondJSON(w, http.StatusCreated, team)
+}
+
+func (s *Server) deleteTeamHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	before := s.rowSnapshot(r.Context(), "teams", id)
+	err := s.authorizer.DeleteTeam(r.Context(), access.OrgID, id)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Team not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete team: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionTeamDelete,
+		ResourceType: "team",
+		ResourceID:   id,
+		Success:      true,
+		Before:       before,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) getOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	orgs, err := s.authorizer.ListOrgs(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve organizations: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, orgs)
+}
+
+func (s *Server) createOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	var org rbac.Organization
+	if err := json.NewDecoder(r.Body).Decode(&org); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid organization request body: %v", err))
+		return
+	}
+
+	err := s.authorizer.CreateOrg(r.Context(), &org)
+	if errors.Is(err, rbac.ErrInvalidOrg) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, rbac.ErrOrgExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create organization: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionOrgCreate,
+		ResourceType: "organization",
+		ResourceID:   org.ID,
+		Success:      true,
+		After:        audit.Value(org),
+	})
+
+	respondJSON(w, http.StatusCreated, org)
+}
+
+// setUserOrganizationHandler moves a local or OIDC user to the {id}
+// organization
+func (s *Server) setUserOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := s.authorizer.SetUserOrg(r.Context(), vars["user_id"], vars["id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "User or organization not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to move user: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionOrgAssign,
+		ResourceType: "user",
+		ResourceID:   vars["user_id"],
+		Success:      true,
+		Details:      "moved to organization " + vars["id"],
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// teamForAdmin loads the {id} team and requires team:admin on it
+func (s *Server) teamForAdmin(w http.ResponseWriter, r *http.Request) (*rbac.Team, bool) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return nil, false
+	}
+	team, err := s.authorizer.GetTeam(r.Context(), access.OrgID, mux.Vars(r)["id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Team not found")
+		return nil, false
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve team: %v", err))
+		return nil, false
+	}
+	if !access.CanTeam(rbac.TeamAdmin, team.Name) {
+		forbidden(w, rbac.TeamAdmin)
+		return nil, false
+	}
+	return team, true
+}
+
+// decodePermissions reads a {"permissions": [...]} body
+func decodePermissions(w http.ResponseWriter, r *http.Request) ([]rbac.Permission, bool) {
+	var req struct {
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return nil, false
+	}
+	perms, err := rbac.ParsePermissions(req.Permissions)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if len(perms) == 0 {
+		respondError(w, http.StatusBadRequest, "permissions must not be empty")
+		return nil, false
+	}
+	return perms, true
+}
+
+func (s *Server) getTeamMembersHandler(w http.ResponseWriter, r *http.Request) {
+	team, ok := s.teamForAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := s.authorizer.Members(r.Context(), team.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve members: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, members)
+}
+
+func (s *Server) setTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	team, ok := s.teamForAdmin(w, r)
+	if !ok {
+		return
+	}
+	perms, ok := decodePermissions(w, r)
+	if !ok {
+		return
+	}
+
+	member, err := s.authorizer.SetMember(r.Context(), team.ID, mux.Vars(r)["user_id"], perms)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update member: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionRoleChange,
+		ResourceType: "team_member",
+		ResourceID:   team.ID + "/" + member.UserID,
+		Success:      true,
+		Details:      "team " + team.Name,
+		After:        audit.Value(member),
+	})
+
+	respondJSON(w, http.StatusOK, member)
+}
+
+func (s *Server) removeTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	team, ok := s.teamForAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	userID := mux.Vars(r)["user_id"]
+	err := s.authorizer.RemoveMember(r.Context(), team.ID, userID)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Member not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to remove member: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionRoleChange,
+		ResourceType: "team_member",
+		ResourceID:   team.ID + "/" + userID,
+		Success:      true,
+		Details:      "removed from team " + team.Name,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
+
+// serviceForAdmin requires team:admin on the {id} service
+func (s *Server) serviceForAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return "", false
+	}
+	var serviceID string
+	err := s.db.QueryRowContext(r.Context(), `SELECT id FROM services WHERE id::text = $1 AND org_id = $2`,
+		mux.Vars(r)["id"], access.OrgID).Scan(&serviceID)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Service not found")
+		return "", false
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to check permissions")
+		return "", false
+	}
+	if !access.Can(rbac.TeamAdmin, serviceID) {
+		forbidden(w, rbac.TeamAdmin)
+		return "", false
+	}
+	return serviceID, true
+}
+
+func (s *Server) getServiceGrantsHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := s.serviceForAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	grants, err := s.authorizer.Grants(r.Context(), serviceID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve grants: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, grants)
+}
+
+func (s *Server) setServiceGrantHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := s.serviceForAdmin(w, r)
+	if !ok {
+		return
+	}
+	perms, ok := decodePermissions(w, r)
+	if !ok {
+		return
+	}
+
+	grant, err := s.authorizer.SetGrant(r.Context(), serviceID, mux.Vars(r)["user_id"], perms)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to update grant: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionRoleChange,
+		ResourceType: "service_grant",
+		ResourceID:   serviceID + "/" + grant.UserID,
+		Success:      true,
+		After:        audit.Value(grant),
+	})
+
+	respondJSON(w, http.StatusOK, grant)
+}
+
+func (s *Server) removeServiceGrantHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := s.serviceForAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	userID := mux.Vars(r)["user_id"]
+	err := s.authorizer.RemoveGrant(r.Context(), serviceID, userID)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Grant not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to remove grant: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionRoleChange,
+		ResourceType: "service_grant",
+		ResourceID:   serviceID + "/" + userID,
+		Success:      true,
+		Details:      "revoked",
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
+
+// serviceAccountRequest creates a service account
+type serviceAccountRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+// apiKeyRequest issues a key. TTL is like "30d" or "12h".
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	TTL    string   `json:"ttl"`
+}
+
+// apiKeyResponse carries a new key's secret, which is shown only once
+type apiKeyResponse struct {
+	*apikeys.Key
+	Secret string `json:"key"`
+}
+
+// actorName is the signed-in user's name, for created_by fields
+func actorName(r *http.Request) string {
+	if claims, ok := r.Context().Value(middleware.UserContext).(*middleware.Claims); ok {
+		return claims.Username
+	}
+	return ""
+}
+
+func (s *Server) getServiceAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	accounts, err := s.apiKeys.ListAccounts(r.Context(), access.OrgID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve service accounts: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, accounts)
+}
+
+func (s *Server) createServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var req serviceAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid service account request body: %v", err))
+		return
+	}
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+
+	account := apikeys.ServiceAccount{
+		OrgID:       access.OrgID,
+		Name:        req.Name,
+		Description: req.Description,
+		Roles:       req.Roles,
+		CreatedBy:   actorName(r),
+	}
+	err := s.apiKeys.CreateAccount(r.Context(), &account)
+	if errors.Is(err, apikeys.ErrInvalidAccount) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, apikeys.ErrAccountExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create service account: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionServiceAccountCreate,
+		ResourceType: "service_account",
+		ResourceID:   account.ID,
+		Success:      true,
+		After:        audit.Value(account),
+	})
+
+	respondJSON(w, http.StatusCreated, account)
+}
+
+func (s *Server) deleteServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	before := s.rowSnapshot(r.Context(), "service_accounts", id)
+	err := s.apiKeys.DeleteAccount(r.Context(), access.OrgID, id)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Service account not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete service account: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionServiceAccountDelete,
+		ResourceType: "service_account",
+		ResourceID:   id,
+		Success:      true,
+		Before:       before,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// serviceAccount loads the {id} service account of the caller's organization.
+// It has responded when ok is false.
+func (s *Server) serviceAccount(w http.ResponseWriter, r *http.Request) (*apikeys.ServiceAccount, bool) {
+	access, ok := s.access(w, r)
+	if !ok {
+		return nil, false
+	}
+	account, err := s.apiKeys.GetAccount(r.Context(), access.OrgID, mux.Vars(r)["id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Service account not found")
+		return nil, false
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve service account: %v", err))
+		return nil, false
+	}
+	return account, true
+}
+
+func (s *Server) getAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := s.serviceAccount(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := s.apiKeys.ListKeys(r.Context(), account.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve API keys: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, keys)
+}
+
+func (s *Server) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid API key request body: %v", err))
+		return
+	}
+	ttl, err := apikeys.ParseTTL(req.TTL)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	account, ok := s.serviceAccount(w, r)
+	if !ok {
+		return
+	}
+
+	key, secret, err := s.apiKeys.CreateKey(r.Context(), account.ID, req.Name, req.Scopes, ttl, actorName(r))
+	if errors.Is(err, apikeys.ErrInvalidKey) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create API key: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionAPIKeyCreate,
+		ResourceType: "api_key",
+		ResourceID:   key.ID,
+		Success:      true,
+		After:        audit.Value(key),
+	})
+
+	respondJSON(w, http.StatusCreated, apiKeyResponse{Key: key, Secret: secret})
+}
+
+// rotateAPIKeyHandler replaces a key. The old key keeps working for the
+// optional grace period, e.g. {"grace": "1h"}, so deployments can switch over.
+func (s *Server) rotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Grace string `json:"grace"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid rotate request body: %v", err))
+			return
+		}
+	}
+	var grace time.Duration
+	if req.Grace != "" {
+		var err error
+		if grace, err = apikeys.ParseTTL(req.Grace); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if _, ok := s.serviceAccount(w, r); !ok {
+		return
+	}
+	vars := mux.Vars(r)
+	key, secret, err := s.apiKeys.RotateKey(r.Context(), vars["id"], vars["key_id"], grace, actorName(r))
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	} else if errors.Is(err, apikeys.ErrInvalidKey) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to rotate API key: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionAPIKeyRotate,
+		ResourceType: "api_key",
+		ResourceID:   vars["key_id"],
+		Success:      true,
+		Details:      fmt.Sprintf("replaced by %s, grace %s", key.ID, grace),
+		After:        audit.Value(key),
+	})
+
+	respondJSON(w, http.StatusCreated, apiKeyResponse{Key: key, Secret: secret})
+}
+
+func (s *Server) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.serviceAccount(w, r); !ok {
+		return
+	}
+	vars := mux.Vars(r)
+	err := s.apiKeys.RevokeKey(r.Context(), vars["id"], vars["key_id"])
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	} else if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to revoke API key: %v", err))
+		return
+	}
+	middleware.RecordAudit(r, audit.Entry{
+		Action:       audit.ActionAPIKeyRevoke,
+		ResourceType: "api_key",
+		ResourceID:   vars["key_id"],
+		Success:      true,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+// authModesHandler tells the app which sign-in methods to offer
+func authModesHandler(config auth.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"modes":      config.Modes,
+			"oidc_login": config.Enabled(auth.ModeOIDC),
+		})
+	}
+}
+
+// rowSnapshot returns a row of table as JSON, for audit before and after
+// values. table must be a constant.
+func (s *Server) rowSnapshot(ctx context.Context, table, id string) json.RawMessage {
+	var row []byte
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT row_to_json(t) FROM %s t WHERE id = $1`, table), id).Scan(&row)
+	if err != nil {
+		return nil
+	}
+	return row
+}
+
+// getAuditLogHandler lists audit entries, newest first, filtered by actor,
+// action, resource_type, resource_id, api_key_id, from and to. Pass
+// next_cursor back as cursor for the next page.
+func (s *Server) getAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := audit.ParseFilter(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.auditRecorder.Query(r.Context(), filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to query audit log: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// verifyAuditLogHandler checks the audit log hash chain
+func (s *Server) verifyAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := s.auditRecorder.Verify(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to verify audit log: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
 }
 
 func (s *Server) getUsersHandler(w http.ResponseWriter, r *http.Request) {
@@ -1010,7 +2990,11 @@ func (s *Server) getUsersHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) getServicesHandler(w http.ResponseWriter, r *http.Request) {
-	rows, err := s.db.Query(`SELECT id, name, status FROM services ORDER BY name`)
+	access, ok := s.access(w, r)
+	if !ok {
+		return
+	}
+	rows, err := s.db.Query(`SELECT id, name, status FROM services WHERE org_id = $1 ORDER BY name`, access.OrgID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to get services")
 		return
@@ -1020,7 +3004,7 @@ func (s *Server) getServicesHandler(w http.ResponseWriter, r *http.Request) {
 	services := make([]map[string]interface{}, 0)
 	for rows.Next() {
 		var id, name, status string
-		if err := rows.Scan(&id, &name, &status); err != nil {
+		if err := rows.Scan(&id, &name, &status); err != nil || !access.Visible(id) {
 			continue
 		}
 		services = append(services, map[string]interface{}{
@@ -1057,4 +3041,14 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("Warning: invalid duration for %s: %q, using %v", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "reliability_studio"
+
+var (
+	sloLabels = []string{"slo_id", "slo", "service", "type"}
+
+	sloCurrentDesc = prometheus.NewDesc(namespace+"_slo_current_percent",
+		"Latest measured SLI value of the SLO in percent.", sloLabels, nil)
+	sloTargetDesc = prometheus.NewDesc(namespace+"_slo_target_percent",
+		"Objective of the SLO in percent.", sloLabels, nil)
+	sloBudgetDesc = prometheus.NewDesc(namespace+"_slo_error_budget_remaining_percent",
+		"Remaining error budget of the SLO in percent.", sloLabels, nil)
+	sloBurnRateDesc = prometheus.NewDesc(namespace+"_slo_burn_rate",
+		"Error budget burn rate of the SLO.", sloLabels, nil)
+	incidentsOpenDesc = prometheus.NewDesc(namespace+"_incidents_open",
+		"Non-resolved incidents by severity and service.", []string{"severity", "service"}, nil)
+	mttaDesc = prometheus.NewDesc(namespace+"_incident_mtta_seconds",
+		"Time from incident start to acknowledgement.", []string{"severity"}, nil)
+	mttrDesc = prometheus.NewDesc(namespace+"_incident_mttr_seconds",
+		"Time from incident start to resolution.", []string{"severity"}, nil)
+	updatedDesc = prometheus.NewDesc(namespace+"_snapshot_updated_timestamp_seconds",
+		"When the exported state was last refreshed.", []string{"source"}, nil)
+)
+
+// Collector exports the snapshot to Prometheus
+type Collector struct {
+	snapshot *Snapshot
+}
+
+// NewCollector creates a collector reading the given snapshot
+func NewCollector(snapshot *Snapshot) *Collector {
+	return &Collector{snapshot: snapshot}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		sloCurrentDesc, sloTargetDesc, sloBudgetDesc, sloBurnRateDesc,
+		incidentsOpenDesc, mttaDesc, mttrDesc, updatedDesc,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	slos, incidents, sloUpdatedAt, incidentUpdatedAt := c.snapshot.read()
+
+	for _, st := range slos {
+		labels := []string{st.ID, st.Name, st.Service, st.Type}
+		ch <- prometheus.MustNewConstMetric(sloTargetDesc, prometheus.GaugeValue, st.Target, labels...)
+		if st.Current != nil {
+			ch <- prometheus.MustNewConstMetric(sloCurrentDesc, prometheus.GaugeValue, *st.Current, labels...)
+		}
+		if st.BudgetRemaining != nil {
+			ch <- prometheus.MustNewConstMetric(sloBudgetDesc, prometheus.GaugeValue, *st.BudgetRemaining, labels...)
+		}
+		if st.BurnRate != nil {
+			ch <- prometheus.MustNewConstMetric(sloBurnRateDesc, prometheus.GaugeValue, *st.BurnRate, labels...)
+		}
+	}
+
+	for _, open := range incidents.Open {
+		ch <- prometheus.MustNewConstMetric(incidentsOpenDesc, prometheus.GaugeValue, float64(open.Count), open.Severity, open.Service)
+	}
+	for severity, h := range incidents.MTTA {
+		ch <- prometheus.MustNewConstHistogram(mttaDesc, h.Count, h.Sum, h.Buckets, severity)
+	}
+	for severity, h := range incidents.MTTR {
+		ch <- prometheus.MustNewConstHistogram(mttrDesc, h.Count, h.Sum, h.Buckets, severity)
+	}
+
+	for source, t := range map[string]time.Time{"slos": sloUpdatedAt, "incidents": incidentUpdatedAt} {
+		if !t.IsZero() {
+			ch <- prometheus.MustNewConstMetric(updatedDesc, prometheus.GaugeValue, float64(t.Unix()), source)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gather(t *testing.T, snapshot *Snapshot) map[string][]*dto.Metric {
+	t.Helper()
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(NewCollector(snapshot))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	byName := make(map[string][]*dto.Metric)
+	for _, f := range families {
+		byName[f.GetName()] = f.GetMetric()
+	}
+	return byName
+}
+
+func TestCollectorSLOs(t *testing.T) {
+	snapshot := NewSnapshot()
+	snapshot.SetSLOs([]SLOState{
+		{ID: "1", Service: "checkout", Name: "availability", Type: "availability", Target: 99.9},
+		{ID: "2", Service: "checkout", Name: "latency", Type: "latency", Target: 95},
+	})
+	snapshot.UpdateSLO("1", 99.95, 50, 0.5)
+	snapshot.UpdateSLO("unknown", 1, 2, 3)
+
+	got := gather(t, snapshot)
+
+	tests := []struct {
+		name  string
+		count int
+		value float64
+	}{
+		{"reliability_studio_slo_target_percent", 2, 0},
+		{"reliability_studio_slo_current_percent", 1, 99.95},
+		{"reliability_studio_slo_error_budget_remaining_percent", 1, 50},
+		{"reliability_studio_slo_burn_rate", 1, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := got[tt.name]
+			if len(metrics) != tt.count {
+				t.Fatalf("got %d series, want %d", len(metrics), tt.count)
+			}
+			if tt.count == 1 && metrics[0].GetGauge().GetValue() != tt.value {
+				t.Errorf("value = %v, want %v", metrics[0].GetGauge().GetValue(), tt.value)
+			}
+		})
+	}
+}
+
+func TestCollectorIncidents(t *testing.T) {
+	snapshot := NewSnapshot()
+	snapshot.SetIncidents(IncidentState{
+		Open: []OpenIncidents{
+			{Severity: "critical", Service: "checkout", Count: 2},
+			{Severity: "low", Service: "unknown", Count: 1},
+		},
+		MTTR: map[string]Histogram{
+			"critical": {Count: 3, Sum: 5400, Buckets: map[float64]uint64{900: 1, 3600: 3}},
+		},
+	})
+
+	got := gather(t, snapshot)
+
+	if n := len(got["reliability_studio_incidents_open"]); n != 2 {
+		t.Errorf("incidents_open series = %d, want 2", n)
+	}
+	mttr := got["reliability_studio_incident_mttr_seconds"]
+	if len(mttr) != 1 {
+		t.Fatalf("mttr series = %d, want 1", len(mttr))
+	}
+	if h := mttr[0].GetHistogram(); h.GetSampleCount() != 3 || h.GetSampleSum() != 5400 || len(h.GetBucket()) != 2 {
+		t.Errorf("mttr histogram = %v, want 3 samples summing to 5400 in 2 buckets", h)
+	}
+	if _, ok := got["reliability_studio_incident_mtta_seconds"]; ok {
+		t.Error("mtta exported without data")
+	}
+}
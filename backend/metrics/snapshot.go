@@ -0,0 +1,103 @@
+// Package metrics exposes SLO and incident state as Prometheus metrics. The
+// services refresh an in-memory snapshot; scrapes only read the snapshot.
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// DurationBuckets are the histogram buckets for MTTA and MTTR, in seconds
+var DurationBuckets = []float64{60, 300, 900, 1800, 3600, 7200, 14400, 28800, 86400, 259200}
+
+// SLOState is the latest known state of one SLO. Measured values are nil until
+// the SLO has been calculated.
+type SLOState struct {
+	ID              string
+	Service         string
+	Name            string
+	Type            string
+	Target          float64
+	Current         *float64
+	BudgetRemaining *float64
+	BurnRate        *float64
+}
+
+// OpenIncidents counts non-resolved incidents of one severity and service
+type OpenIncidents struct {
+	Severity string
+	Service  string
+	Count    int
+}
+
+// Histogram is a cumulative histogram over DurationBuckets
+type Histogram struct {
+	Count   uint64
+	Sum     float64
+	Buckets map[float64]uint64 // upper bound to cumulative count
+}
+
+// IncidentState is the aggregated incident state. MTTA and MTTR are keyed by
+// severity.
+type IncidentState struct {
+	Open []OpenIncidents
+	MTTA map[string]Histogram
+	MTTR map[string]Histogram
+}
+
+// Snapshot holds the state read by the collector
+type Snapshot struct {
+	mu                sync.RWMutex
+	slos              map[string]SLOState
+	incidents         IncidentState
+	sloUpdatedAt      time.Time
+	incidentUpdatedAt time.Time
+}
+
+// NewSnapshot creates an empty snapshot
+func NewSnapshot() *Snapshot {
+	return &Snapshot{slos: make(map[string]SLOState)}
+}
+
+// SetSLOs replaces the state of all SLOs
+func (s *Snapshot) SetSLOs(states []SLOState) {
+	slos := make(map[string]SLOState, len(states))
+	for _, st := range states {
+		slos[st.ID] = st
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slos = slos
+	s.sloUpdatedAt = time.Now()
+}
+
+// UpdateSLO records a new measurement of a known SLO
+func (s *Snapshot) UpdateSLO(id string, current, budgetRemaining, burnRate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.slos[id]
+	if !ok {
+		return
+	}
+	st.Current, st.BudgetRemaining, st.BurnRate = &current, &budgetRemaining, &burnRate
+	s.slos[id] = st
+	s.sloUpdatedAt = time.Now()
+}
+
+// SetIncidents replaces the incident state
+func (s *Snapshot) SetIncidents(state IncidentState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.incidents = state
+	s.incidentUpdatedAt = time.Now()
+}
+
+func (s *Snapshot) read() ([]SLOState, IncidentState, time.Time, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	slos := make([]SLOState, 0, len(s.slos))
+	for _, st := range s.slos {
+		slos = append(slos, st)
+	}
+	return slos, s.incidents, s.sloUpdatedAt, s.incidentUpdatedAt
+}
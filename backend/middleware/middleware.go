@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -14,6 +15,8 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/sarika-03/Reliability-Studio/apikeys"
+	"github.com/sarika-03/Reliability-Studio/auth"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -33,6 +36,9 @@ type Claims struct {
 	Roles        []string `json:"roles"`
 	TokenType    string   `json:"token_type"` // "access" or "refresh"
 	IsFirstLogin bool     `json:"is_first_login"`
+	OrgID        string   `json:"org_id,omitempty"`
+	APIKeyID     string   `json:"api_key_id,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"` // limit Roles when set, as for API keys
 	jwt.RegisteredClaims
 }
 
@@ -40,12 +46,64 @@ type UserContextKey string
 
 const UserContext UserContextKey = "user"
 
-// Auth middleware - HARDENED: Strict JWT validation with algorithm check
+// authConfig selects how Auth identifies users
+var authConfig = auth.Config{Modes: []string{auth.ModeLocal}}
+
+// ConfigureAuth sets the authentication modes used by Auth
+func ConfigureAuth(config auth.Config) {
+	authConfig = config
+}
+
+// apiKeys checks API keys. Keys are rejected while it is nil.
+var apiKeys *apikeys.Store
+
+// SetAPIKeyStore sets the store used to check API keys
+func SetAPIKeyStore(store *apikeys.Store) {
+	apiKeys = store
+}
+
+// GetClaims returns the claims of an authenticated request
+func GetClaims(r *http.Request) (*Claims, bool) {
+	claims, ok := r.Context().Value(UserContext).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// apiKeyAuth authenticates an API key given in X-API-Key or as a bearer token.
+// It returns false after responding when the key is rejected.
+func apiKeyAuth(w http.ResponseWriter, r *http.Request, key string) (*Claims, bool) {
+	if apiKeys == nil {
+		respondError(w, http.StatusUnauthorized, "API keys are not enabled")
+		return nil, false
+	}
+	principal, err := apiKeys.Authenticate(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, apikeys.ErrInvalidKey) {
+			log.Printf("🔒 AUTH: Rejected API key from %s", r.RemoteAddr)
+		} else {
+			log.Printf("🔒 AUTH: API key lookup failed: %v", err)
+		}
+		respondError(w, http.StatusUnauthorized, "Invalid or expired API key")
+		return nil, false
+	}
+	return &Claims{
+		UserID:    principal.Account.UserID(),
+		Username:  principal.Account.Name,
+		Roles:     principal.Account.Roles,
+		OrgID:     principal.Account.OrgID,
+		TokenType: "api_key",
+		APIKeyID:  principal.Key.ID,
+		Scopes:    principal.Key.ScopeNames(),
+	}, true
+}
+
+// Auth middleware - HARDENED: Strict JWT validation with algorithm check.
+// Grafana proxy headers are accepted when that mode is enabled; bearer tokens
+// are issued by local and OIDC logins. Service account API keys are accepted
+// in X-API-Key or as bearer tokens in every mode but none.
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// ✅ Support disabling auth for development - FORCED TO TRUE FOR NOW
-		if true { // os.Getenv("AUTH_ENABLED") == "false"
-			// Create dummy claims for context
+		// Development only: every request is an admin
+		if authConfig.Enabled(auth.ModeNone) {
 			claims := &Claims{
 				UserID:   "admin-id",
 				Username: "admin",
@@ -56,8 +114,51 @@ func Auth(next http.Handler) http.Handler {
 			return
 		}
 
-		// Get Authorization header
+		if authConfig.Enabled(auth.ModeGrafana) {
+			identity, ok, err := authConfig.Grafana.Identity(r, authConfig.Roles)
+			if err != nil {
+				log.Printf("🔒 AUTH: Rejected Grafana identity headers from %s: %v", r.RemoteAddr, err)
+				respondError(w, http.StatusUnauthorized, "Untrusted identity headers")
+				return
+			}
+			if ok {
+				claims := &Claims{
+					UserID:    "grafana:" + identity.Subject,
+					Username:  identity.Username,
+					Email:     identity.Email,
+					Roles:     identity.Roles,
+					OrgID:     identity.OrgID,
+					TokenType: "access",
+				}
+				ctx := context.WithValue(r.Context(), UserContext, claims)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		// API keys, which never look like JWTs
+		key := r.Header.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && apikeys.IsKey(bearer) {
+			key = bearer
+		}
+		if key != "" {
+			claims, ok := apiKeyAuth(w, r, key)
+			if !ok || !allowAPIKey(w, r, claims.APIKeyID) {
+				return
+			}
+			ctx := context.WithValue(r.Context(), UserContext, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// Get Authorization header. Browsers can't set it on websocket and
+		// EventSource connections, which pass the token as access_token instead.
 		authHeader := r.Header.Get("Authorization")
+		if token := r.URL.Query().Get("access_token"); authHeader == "" && token != "" &&
+			(strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+				strings.Contains(r.Header.Get("Accept"), "text/event-stream")) {
+			authHeader = "Bearer " + token
+		}
 		if authHeader == "" {
 			respondError(w, http.StatusUnauthorized, "Missing authorization token")
 			return
@@ -153,20 +254,10 @@ func Recovery(next http.Handler) http.Handler {
 	})
 }
 
-// RateLimit middleware - IMPROVED: Added basic rate limiting structure
-func RateLimit(requestsPerMinute int) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			next.ServeHTTP(w, r)
-		})
-	}
-}
 
 // LoginHandler - HARDENED with account lockout and audit logging
 func LoginHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		clientIP := GetClientIP(r)
-
 		var req struct {
 			Username string `json:"username"`
 			Password string `json:"password"`
@@ -185,7 +276,7 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 
 		// ✅ Check if account is locked
 		if accountLockout.IsLocked(req.Username) {
-			LogAuditEvent("login_attempt", "", req.Username, "LOGIN", "Account locked due to failed attempts", clientIP, false)
+			LogAuditEvent(r, "login_attempt", "", req.Username, "Account locked due to failed attempts", false)
 			respondError(w, http.StatusForbidden, "Account is temporarily locked. Try again in 15 minutes.")
 			return
 		}
@@ -198,24 +289,25 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 			RolesJSON    string
 			IsFirstLogin bool
 			LastLogin    *time.Time
+			OrgID        string
 		}
 
 		err := db.QueryRow(`
 			SELECT id, email, username, password_hash, roles::text, 
-			       (last_login IS NULL) as is_first_login, last_login
+			       (last_login IS NULL) as is_first_login, last_login, org_id
 			FROM users 
 			WHERE username = $1 OR email = $1
 		`, req.Username).Scan(&user.ID, &user.Email, &user.Username, &user.PasswordHash,
-			&user.RolesJSON, &user.IsFirstLogin, &user.LastLogin)
+			&user.RolesJSON, &user.IsFirstLogin, &user.LastLogin, &user.OrgID)
 
 		if err == sql.ErrNoRows {
 			accountLockout.RecordFailedAttempt(req.Username)
-			LogAuditEvent("login_attempt", "", req.Username, "LOGIN", "Invalid username", clientIP, false)
+			LogAuditEvent(r, "login_attempt", "", req.Username, "Invalid username", false)
 			respondError(w, http.StatusUnauthorized, "Invalid credentials")
 			return
 		} else if err != nil {
 			log.Printf("🔒 LOGIN: Database error: %v", err)
-			LogAuditEvent("login_attempt", "", req.Username, "LOGIN", fmt.Sprintf("DB error: %v", err), clientIP, false)
+			LogAuditEvent(r, "login_attempt", "", req.Username, fmt.Sprintf("DB error: %v", err), false)
 			respondError(w, http.StatusInternalServerError, "Login failed")
 			return
 		}
@@ -223,7 +315,7 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 		// ✅ Verify password with bcrypt
 		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
 			accountLockout.RecordFailedAttempt(req.Username)
-			LogAuditEvent("login_attempt", user.ID, user.Username, "LOGIN", "Invalid password", clientIP, false)
+			LogAuditEvent(r, "login_attempt", user.ID, user.Username, "Invalid password", false)
 			respondError(w, http.StatusUnauthorized, "Invalid credentials")
 			return
 		}
@@ -234,71 +326,27 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 		var roles []string
 		json.Unmarshal([]byte(user.RolesJSON), &roles)
 
-		// ✅ Generate ACCESS TOKEN (short-lived)
-		accessTokenTime := time.Now().Add(AccessTokenExpiration)
-		accessClaims := &Claims{
-			UserID:       user.ID,
+		// ✅ Update last_login timestamp
+		_, _ = db.Exec("UPDATE users SET last_login = NOW() WHERE id = $1", user.ID)
+
+		accessTokenString, err := issueTokens(w, tokenUser{
+			ID:           user.ID,
 			Username:     user.Username,
 			Email:        user.Email,
 			Roles:        roles,
-			TokenType:    "access",
 			IsFirstLogin: user.IsFirstLogin,
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(accessTokenTime),
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-				Subject:   user.ID,
-			},
-		}
-
-		accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-		accessTokenString, err := accessToken.SignedString(JWT_SECRET)
-		if err != nil {
-			log.Printf("🔒 LOGIN: Failed to generate access token: %v", err)
-			respondError(w, http.StatusInternalServerError, "Failed to generate token")
-			return
-		}
-
-		// ✅ Generate REFRESH TOKEN (long-lived, stored securely)
-		refreshTokenTime := time.Now().Add(RefreshTokenExpiration)
-		refreshClaims := &Claims{
-			UserID:    user.ID,
-			Username:  user.Username,
-			TokenType: "refresh",
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(refreshTokenTime),
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-				Subject:   user.ID,
-			},
-		}
-
-		refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-		refreshTokenString, err := refreshToken.SignedString(JWT_SECRET)
+			OrgID:        user.OrgID,
+		})
 		if err != nil {
-			log.Printf("🔒 LOGIN: Failed to generate refresh token: %v", err)
+			log.Printf("🔒 LOGIN: Failed to generate tokens: %v", err)
 			respondError(w, http.StatusInternalServerError, "Failed to generate token")
 			return
 		}
 
-		// ✅ Update last_login timestamp
-		_, _ = db.Exec("UPDATE users SET last_login = NOW() WHERE id = $1", user.ID)
-
-		LogAuditEvent("login", user.ID, user.Username, "LOGIN", "Successful login", clientIP, true)
+		LogAuditEvent(r, "login", user.ID, user.Username, "Successful login", true)
 
-		// ✅ Return tokens with secure cookie settings
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-
-		// Set refresh token as secure, httpOnly cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     "refresh_token",
-			Value:    refreshTokenString,
-			Path:     "/api/auth",
-			MaxAge:   int(RefreshTokenExpiration.Seconds()),
-			HttpOnly: true,
-			Secure:   os.Getenv("ENV") == "production",
-			SameSite: http.SameSiteLaxMode,
-		})
-
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"access_token": accessTokenString,
 			"token_type":   "Bearer",
@@ -314,15 +362,109 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// tokenUser is who tokens are issued for
+type tokenUser struct {
+	ID           string
+	Username     string
+	Email        string
+	Roles        []string
+	IsFirstLogin bool
+	OrgID        string
+}
+
+// issueTokens signs a short-lived access token and sets the long-lived
+// refresh token as a secure, httpOnly cookie
+func issueTokens(w http.ResponseWriter, user tokenUser) (string, error) {
+	now := time.Now()
+	accessClaims := &Claims{
+		UserID:       user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		Roles:        user.Roles,
+		TokenType:    "access",
+		IsFirstLogin: user.IsFirstLogin,
+		OrgID:        user.OrgID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenExpiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   user.ID,
+		},
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).SignedString(JWT_SECRET)
+	if err != nil {
+		return "", err
+	}
+
+	// Refreshing re-reads roles and org from users; these are only informative
+	refreshClaims := &Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Roles:     user.Roles,
+		OrgID:     user.OrgID,
+		TokenType: "refresh",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(RefreshTokenExpiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   user.ID,
+		},
+	}
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString(JWT_SECRET)
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     "/api/auth",
+		MaxAge:   int(RefreshTokenExpiration.Seconds()),
+		HttpOnly: true,
+		Secure:   os.Getenv("ENV") == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return accessToken, nil
+}
+
+// currentUser re-reads the user a refresh token was issued for, so refreshed
+// access tokens carry their current organization and roles. It returns
+// sql.ErrNoRows when the user no longer exists.
+func currentUser(ctx context.Context, db *sql.DB, userID string) (tokenUser, error) {
+	user := tokenUser{ID: userID}
+	var rolesJSON string
+	err := db.QueryRowContext(ctx, `
+		SELECT username, email, roles::text, org_id FROM users WHERE id::text = $1
+	`, userID).Scan(&user.Username, &user.Email, &rolesJSON, &user.OrgID)
+	if err != nil {
+		return user, err
+	}
+	json.Unmarshal([]byte(rolesJSON), &user.Roles)
+	return user, nil
+}
+
+// refreshUser is currentUser for a refresh request. It has responded when ok
+// is false.
+func refreshUser(w http.ResponseWriter, r *http.Request, db *sql.DB, claims *Claims) (tokenUser, bool) {
+	user, err := currentUser(r.Context(), db, claims.UserID)
+	if err == sql.ErrNoRows {
+		LogAuditEvent(r, "token_refresh", claims.UserID, claims.Username, "User no longer exists", false)
+		respondError(w, http.StatusUnauthorized, "User no longer exists")
+		return user, false
+	} else if err != nil {
+		log.Printf("🔒 REFRESH: Failed to load user %s: %v", claims.UserID, err)
+		respondError(w, http.StatusInternalServerError, "Failed to refresh token")
+		return user, false
+	}
+	return user, true
+}
+
 // RefreshTokenHandler - Exchange refresh token for new access token
-func RefreshTokenHandler() http.HandlerFunc {
+func RefreshTokenHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		clientIP := GetClientIP(r)
-
 		// Get refresh token from cookie
 		refreshCookie, err := r.Cookie("refresh_token")
 		if err != nil {
-			LogAuditEvent("token_refresh", "", "", "TOKEN_REFRESH", "Refresh token not found", clientIP, false)
+			LogAuditEvent(r, "token_refresh", "", "", "Refresh token not found", false)
 			respondError(w, http.StatusUnauthorized, "Refresh token not found")
 			return
 		}
@@ -337,14 +479,14 @@ func RefreshTokenHandler() http.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			LogAuditEvent("token_refresh", "", "", "TOKEN_REFRESH", fmt.Sprintf("Invalid refresh token: %v", err), clientIP, false)
+			LogAuditEvent(r, "token_refresh", "", "", fmt.Sprintf("Invalid refresh token: %v", err), false)
 			respondError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
 			return
 		}
 
 		claims, ok := token.Claims.(*Claims)
 		if !ok || claims.TokenType != "refresh" {
-			LogAuditEvent("token_refresh", "", "", "TOKEN_REFRESH", "Token type is not refresh", clientIP, false)
+			LogAuditEvent(r, "token_refresh", "", "", "Token type is not refresh", false)
 			respondError(w, http.StatusUnauthorized, "Invalid token type")
 			return
 		}
@@ -355,13 +497,20 @@ func RefreshTokenHandler() http.HandlerFunc {
 			return
 		}
 
+		// ✅ Users moved to another organization or demoted don't keep the old access
+		user, ok := refreshUser(w, r, db, claims)
+		if !ok {
+			return
+		}
+
 		// ✅ Generate new access token
 		accessTokenTime := time.Now().Add(AccessTokenExpiration)
 		newAccessClaims := &Claims{
-			UserID:    claims.UserID,
-			Username:  claims.Username,
-			Email:     claims.Email,
-			Roles:     claims.Roles,
+			UserID:    user.ID,
+			Username:  user.Username,
+			Email:     user.Email,
+			Roles:     user.Roles,
+			OrgID:     user.OrgID,
 			TokenType: "access",
 			RegisteredClaims: jwt.RegisteredClaims{
 				ExpiresAt: jwt.NewNumericDate(accessTokenTime),
@@ -378,7 +527,7 @@ func RefreshTokenHandler() http.HandlerFunc {
 			return
 		}
 
-		LogAuditEvent("token_refresh", claims.UserID, claims.Username, "TOKEN_REFRESH", "Access token refreshed", clientIP, true)
+		LogAuditEvent(r, "token_refresh", claims.UserID, claims.Username, "Access token refreshed", true)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -391,9 +540,8 @@ func RefreshTokenHandler() http.HandlerFunc {
 }
 
 // RefreshTokenMiddleware - Middleware version (for protected routes)
-func RefreshTokenMiddleware(next http.Handler) http.Handler {
+func RefreshTokenMiddleware(db *sql.DB, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		clientIP := GetClientIP(r)
 
 		// Get refresh token from cookie
 		refreshCookie, err := r.Cookie("refresh_token")
@@ -412,14 +560,14 @@ func RefreshTokenMiddleware(next http.Handler) http.Handler {
 		})
 
 		if err != nil || !token.Valid {
-			LogAuditEvent("token_refresh", "", "", "TOKEN_REFRESH", fmt.Sprintf("Invalid refresh token: %v", err), clientIP, false)
+			LogAuditEvent(r, "token_refresh", "", "", fmt.Sprintf("Invalid refresh token: %v", err), false)
 			respondError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
 			return
 		}
 
 		claims, ok := token.Claims.(*Claims)
 		if !ok || claims.TokenType != "refresh" {
-			LogAuditEvent("token_refresh", "", "", "TOKEN_REFRESH", "Token type is not refresh", clientIP, false)
+			LogAuditEvent(r, "token_refresh", "", "", "Token type is not refresh", false)
 			respondError(w, http.StatusUnauthorized, "Invalid token type")
 			return
 		}
@@ -430,13 +578,20 @@ func RefreshTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// ✅ Users moved to another organization or demoted don't keep the old access
+		user, ok := refreshUser(w, r, db, claims)
+		if !ok {
+			return
+		}
+
 		// ✅ Generate new access token
 		accessTokenTime := time.Now().Add(AccessTokenExpiration)
 		newAccessClaims := &Claims{
-			UserID:    claims.UserID,
-			Username:  claims.Username,
-			Email:     claims.Email,
-			Roles:     claims.Roles,
+			UserID:    user.ID,
+			Username:  user.Username,
+			Email:     user.Email,
+			Roles:     user.Roles,
+			OrgID:     user.OrgID,
 			TokenType: "access",
 			RegisteredClaims: jwt.RegisteredClaims{
 				ExpiresAt: jwt.NewNumericDate(accessTokenTime),
@@ -453,7 +608,7 @@ func RefreshTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		LogAuditEvent("token_refresh", claims.UserID, claims.Username, "TOKEN_REFRESH", "Access token refreshed", clientIP, true)
+		LogAuditEvent(r, "token_refresh", claims.UserID, claims.Username, "Access token refreshed", true)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -467,25 +622,15 @@ func RefreshTokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// GetClientIP - Extract client IP from request (handles proxies)
+// GetClientIP - Extract client IP from request; forwarded-for headers only
+// count from TRUSTED_PROXIES
 func GetClientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-	return strings.Split(r.RemoteAddr, ":")[0]
+	return auth.ClientIP(r, authConfig.TrustedProxies)
 }
 
 // RegisterHandler - HARDENED: Password strength validation, first login enforcement
 func RegisterHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		clientIP := GetClientIP(r)
-
 		var req struct {
 			Username string `json:"username"`
 			Email    string `json:"email"`
@@ -530,17 +675,17 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 
 		if err != nil {
 			if strings.Contains(err.Error(), "unique_violation") || strings.Contains(err.Error(), "duplicate key") {
-				LogAuditEvent("register", "", req.Username, "REGISTER", "Username or email already exists", clientIP, false)
+				LogAuditEvent(r, "register", "", req.Username, "Username or email already exists", false)
 				respondError(w, http.StatusConflict, "Username or email already exists")
 				return
 			}
 			log.Printf("🔒 REGISTER: Database error: %v", err)
-			LogAuditEvent("register", "", req.Username, "REGISTER", fmt.Sprintf("DB error: %v", err), clientIP, false)
+			LogAuditEvent(r, "register", "", req.Username, fmt.Sprintf("DB error: %v", err), false)
 			respondError(w, http.StatusInternalServerError, "Registration failed")
 			return
 		}
 
-		LogAuditEvent("register", userID, req.Username, "REGISTER", "User registered successfully", clientIP, true)
+		LogAuditEvent(r, "register", userID, req.Username, "User registered successfully", true)
 
 		respondJSON(w, http.StatusCreated, map[string]interface{}{
 			"status":   "user created",
@@ -658,3 +803,8 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	}
 	return nil, nil, fmt.Errorf("http.ResponseWriter does not support hijacking")
 }
+
+// Unwrap lets http.ResponseController flush the underlying writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
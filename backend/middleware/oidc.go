@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/auth"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// OIDCLoginHandler sends the user to the identity provider to sign in
+func OIDCLoginHandler(provider *auth.OIDCProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := auth.NewLoginState(oidcStateTTL, time.Now())
+		if err != nil {
+			log.Printf("🔒 OIDC: Failed to create login state: %v", err)
+			respondError(w, http.StatusInternalServerError, "Failed to start login")
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    state.Seal(JWT_SECRET),
+			Path:     "/api/auth/oidc",
+			MaxAge:   int(oidcStateTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   os.Getenv("ENV") == "production",
+			SameSite: http.SameSiteLaxMode, // sent on the provider's top-level redirect back
+		})
+		http.Redirect(w, r, provider.AuthCodeURL(state.State, state.Nonce, state.Verifier), http.StatusFound)
+	}
+}
+
+// OIDCCallbackHandler finishes the login: it redeems the code, creates or
+// updates the user and issues the same tokens as a local login. The browser
+// is sent on to the post-login URL, where the app gets an access token from
+// /api/auth/refresh.
+func OIDCCallbackHandler(db *sql.DB, provider *auth.OIDCProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			LogAuditEvent(r, "login_attempt", "", "", "OIDC provider error: "+e+" "+q.Get("error_description"), false)
+			respondError(w, http.StatusUnauthorized, "Sign-in was not completed")
+			return
+		}
+
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Login state not found; start the login again")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc", MaxAge: -1})
+
+		state, err := auth.OpenLoginState(JWT_SECRET, cookie.Value, time.Now())
+		if err != nil || q.Get("state") != state.State {
+			LogAuditEvent(r, "login_attempt", "", "", "OIDC state mismatch", false)
+			respondError(w, http.StatusBadRequest, "Invalid login state; start the login again")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+		identity, err := provider.Exchange(ctx, q.Get("code"), state.Verifier, state.Nonce)
+		if err != nil {
+			log.Printf("🔒 OIDC: Code exchange failed: %v", err)
+			LogAuditEvent(r, "login_attempt", "", "", "OIDC code exchange failed", false)
+			respondError(w, http.StatusUnauthorized, "Sign-in failed")
+			return
+		}
+
+		userID, orgID, err := upsertOIDCUser(ctx, db, identity)
+		if err != nil {
+			log.Printf("🔒 OIDC: Failed to store user %s: %v", identity.Username, err)
+			LogAuditEvent(r, "login_attempt", "", identity.Username, "Failed to store OIDC user", false)
+			if strings.Contains(err.Error(), "duplicate key") {
+				respondError(w, http.StatusConflict, "A different account already uses this username or email")
+				return
+			}
+			respondError(w, http.StatusInternalServerError, "Sign-in failed")
+			return
+		}
+
+		if _, err := issueTokens(w, tokenUser{
+			ID:       userID,
+			Username: identity.Username,
+			Email:    identity.Email,
+			Roles:    identity.Roles,
+			OrgID:    orgID,
+		}); err != nil {
+			log.Printf("🔒 OIDC: Failed to generate tokens: %v", err)
+			respondError(w, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+
+		LogAuditEvent(r, "login", userID, identity.Username, "Successful OIDC login", true)
+		http.Redirect(w, r, provider.Config().PostLoginURL, http.StatusFound)
+	}
+}
+
+// upsertOIDCUser creates the user on first sign-in and refreshes the name,
+// email and roles from the ID token on later ones. It returns the user's ID
+// and organization.
+func upsertOIDCUser(ctx context.Context, db *sql.DB, identity *auth.Identity) (string, string, error) {
+	roles, _ := json.Marshal(identity.Roles)
+	email := identity.Email
+	if email == "" {
+		// Email is unique and required; users without one get a placeholder
+		email = identity.Subject + "@oidc.invalid"
+	}
+
+	var userID, orgID string
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO users (username, email, roles, auth_provider, external_id, is_first_login, last_login)
+		VALUES ($1, $2, $3::jsonb, 'oidc', $4, false, NOW())
+		ON CONFLICT (auth_provider, external_id) WHERE external_id IS NOT NULL DO UPDATE
+		SET username = EXCLUDED.username, email = EXCLUDED.email, roles = EXCLUDED.roles, last_login = NOW()
+		RETURNING id, org_id
+	`, identity.Username, email, string(roles), identity.Subject).Scan(&userID, &orgID)
+	return userID, orgID, err
+}
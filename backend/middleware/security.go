@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
@@ -10,6 +11,8 @@ import (
 	"os"
 	"sync"
 	"time"
+
+	"github.com/sarika-03/Reliability-Studio/audit"
 )
 
 // ==================== CSRF PROTECTION ====================
@@ -86,78 +89,6 @@ func CSRFTokenHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ==================== RATE LIMITING ====================
-
-type RateLimiter struct {
-	mu       sync.RWMutex
-	requests map[string][]time.Time
-	limit    int
-	window   time.Duration
-}
-
-func NewRateLimiter(requestsPerMinute int) *RateLimiter {
-	return &RateLimiter{
-		requests: make(map[string][]time.Time),
-		limit:    requestsPerMinute,
-		window:   time.Minute,
-	}
-}
-
-func (rl *RateLimiter) Allow(identifier string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	now := time.Now()
-	key := identifier
-
-	// Clean old requests
-	if times, exists := rl.requests[key]; exists {
-		// Keep only requests within the window
-		var filtered []time.Time
-		for _, t := range times {
-			if now.Sub(t) < rl.window {
-				filtered = append(filtered, t)
-			}
-		}
-		rl.requests[key] = filtered
-
-		// Check if limit exceeded
-		if len(filtered) >= rl.limit {
-			return false
-		}
-	}
-
-	// Add current request
-	rl.requests[key] = append(rl.requests[key], now)
-	return true
-}
-
-// RateLimitingMiddleware enforces per-IP rate limiting
-var ipLimiter = NewRateLimiter(100) // 100 requests per minute per IP
-
-func RateLimitingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get client IP
-		clientIP := r.Header.Get("X-Forwarded-For")
-		if clientIP == "" {
-			clientIP = r.Header.Get("X-Real-IP")
-		}
-		if clientIP == "" {
-			clientIP = r.RemoteAddr
-		}
-
-		// Check rate limit
-		if !ipLimiter.Allow(clientIP) {
-			log.Printf("⚠️  Rate limit exceeded for IP: %s", clientIP)
-			w.Header().Set("Retry-After", "60")
-			respondError(w, http.StatusTooManyRequests, "Rate limit exceeded. Please try again later.")
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
 // ==================== SECURITY HEADERS ====================
 
 // SecurityHeadersMiddleware adds security headers to all responses
@@ -243,27 +174,71 @@ var accountLockout = NewAccountLockout(5, 15*time.Minute) // Lock after 5 attemp
 
 // ==================== AUDIT LOGGING ====================
 
-type AuditLog struct {
-	Timestamp string `json:"timestamp"`
-	EventType string `json:"event_type"` // login, logout, incident_edit, role_change
-	UserID    string `json:"user_id"`
-	Username  string `json:"username"`
-	Action    string `json:"action"`
-	Details   string `json:"details"`
-	IPAddress string `json:"ip_address"`
-	Success   bool   `json:"success"`
+var auditRecorder *audit.Recorder
+
+// SetAuditRecorder makes audit events persistent. Without a recorder they are
+// only logged.
+func SetAuditRecorder(recorder *audit.Recorder) {
+	auditRecorder = recorder
 }
 
-func LogAuditEvent(eventType, userID, username, action, details, ipAddress string, success bool) {
-	// Log to stdout with structured format
-	log.Printf("[AUDIT] %s | Event: %s | User: %s (%s) | Action: %s | IP: %s | Success: %v | Details: %s",
+// RecordAudit fills in the actor, client IP and request ID from the request
+// and writes the entry to the audit log. Failures are logged; they don't fail
+// the request.
+func RecordAudit(r *http.Request, e audit.Entry) {
+	if claims, ok := r.Context().Value(UserContext).(*Claims); ok && e.ActorID == "" && e.Actor == "" {
+		e.ActorID, e.Actor = claims.UserID, claims.Username
+	}
+	if claims, ok := r.Context().Value(UserContext).(*Claims); ok && e.APIKeyID == "" {
+		e.APIKeyID = claims.APIKeyID
+	}
+	e.IPAddress = GetClientIP(r)
+	e.RequestID = requestID(r)
+
+	log.Printf("[AUDIT] %s | Event: %s | User: %s (%s) | Resource: %s %s | IP: %s | Request: %s | Success: %v | Details: %s",
 		time.Now().Format(time.RFC3339),
-		eventType,
-		username,
-		userID,
-		action,
-		ipAddress,
-		success,
-		details,
+		e.Action,
+		e.Actor,
+		e.ActorID,
+		e.ResourceType,
+		e.ResourceID,
+		e.IPAddress,
+		e.RequestID,
+		e.Success,
+		e.Details,
 	)
+
+	if auditRecorder == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	defer cancel()
+	if err := auditRecorder.Record(ctx, &e); err != nil {
+		log.Printf("[AUDIT] Failed to store audit entry: %v", err)
+	}
+}
+
+// LogAuditEvent records an authentication event for a user
+func LogAuditEvent(r *http.Request, eventType, userID, username, details string, success bool) {
+	RecordAudit(r, audit.Entry{
+		ActorID:      userID,
+		Actor:        username,
+		Action:       eventType,
+		ResourceType: "user",
+		ResourceID:   userID,
+		Details:      details,
+		Success:      success,
+	})
+}
+
+// requestID is the client supplied X-Request-ID, or the trace ID assigned by
+// the telemetry middleware
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		return id
+	}
+	if id, ok := r.Context().Value("trace-id").(string); ok {
+		return id
+	}
+	return ""
 }
@@ -15,6 +15,9 @@ type Service struct {
 	OnCallSchedule   string    `json:"on_call_schedule,omitempty" db:"on_call_schedule"`
 	RepositoryURL    string    `json:"repository_url,omitempty" db:"repository_url"`
 	DocumentationURL string    `json:"documentation_url,omitempty" db:"documentation_url"`
+	Cluster          string    `json:"cluster" db:"cluster"`
+	Namespace        string    `json:"namespace" db:"namespace"`
+	LabelSelector    string    `json:"label_selector" db:"label_selector"`
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -86,6 +89,7 @@ type CreateIncidentRequest struct {
 	Description string   `json:"description"`
 	Severity    string   `json:"severity" validate:"required,oneof=critical high medium low"`
 	ServiceIDs  []string `json:"service_ids"`
+	OrgID       string   `json:"-"` // set from the caller's organization
 }
 
 type UpdateIncidentRequest struct {
@@ -0,0 +1,162 @@
+package openslo
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Indicator types
+const (
+	IndicatorRatio     = "ratio"
+	IndicatorThreshold = "threshold"
+)
+
+// Bundle holds the OpenSLO documents behind one stored objective so it can be
+// exported again without loss
+type Bundle struct {
+	SLO           SLO           `json:"slo"`
+	SLI           *SLI          `json:"sli,omitempty"`
+	AlertPolicies []AlertPolicy `json:"alert_policies,omitempty"`
+}
+
+// Definition is one SLO objective flattened into the shape of a slos row
+type Definition struct {
+	Name               string   `json:"name"`     // unique per service
+	SLOName            string   `json:"slo_name"` // metadata.name of the SLO document
+	Description        string   `json:"description"`
+	Service            string   `json:"service"`
+	ServiceDescription string   `json:"service_description,omitempty"`
+	IndicatorType      string   `json:"indicator_type"`
+	GoodQuery          string   `json:"good_query,omitempty"`
+	BadQuery           string   `json:"bad_query,omitempty"`
+	TotalQuery         string   `json:"total_query,omitempty"`
+	ThresholdQuery     string   `json:"threshold_query,omitempty"`
+	ThresholdOp        string   `json:"threshold_op,omitempty"`
+	ThresholdValue     *float64 `json:"threshold_value,omitempty"`
+	Objective          float64  `json:"objective"` // percent
+	Window             string   `json:"window"`
+	WindowDays         int      `json:"window_days"`
+	BudgetingMethod    string   `json:"budgeting_method"`
+	Documents          Bundle   `json:"-"`
+}
+
+// SLIQuery returns a PromQL expression for the SLI. Ratio indicators yield the
+// percentage of good events; threshold indicators yield the raw metric.
+func (d Definition) SLIQuery() string {
+	switch {
+	case d.IndicatorType == IndicatorThreshold:
+		return d.ThresholdQuery
+	case d.GoodQuery != "":
+		return fmt.Sprintf("100 * (%s) / (%s)", d.GoodQuery, d.TotalQuery)
+	default:
+		return fmt.Sprintf("100 * (1 - (%s) / (%s))", d.BadQuery, d.TotalQuery)
+	}
+}
+
+// Resolve validates the documents and turns every SLO objective into a
+// Definition. Nothing is returned unless all documents are valid.
+func Resolve(docs []Document) ([]Definition, []Error) {
+	if errs := Validate(docs); len(errs) > 0 {
+		return nil, errs
+	}
+
+	slis := make(map[string]*SLI)
+	policies := make(map[string]AlertPolicy)
+	services := make(map[string]string)
+	for _, d := range docs {
+		switch d.Kind {
+		case KindSLI:
+			slis[d.Name] = d.SLI
+		case KindAlertPolicy:
+			policies[d.Name] = *d.AlertPolicy
+		case KindService:
+			services[d.Name] = d.Service.Spec.Description
+		}
+	}
+
+	var defs []Definition
+	for _, d := range docs {
+		if d.Kind != KindSLO {
+			continue
+		}
+		slo := *d.SLO
+
+		// Normalize inline indicators into a standalone SLI so that exports
+		// always reference SLIs by name
+		sli := slis[slo.Spec.IndicatorRef]
+		if slo.Spec.Indicator != nil {
+			sli = &SLI{APIVersion: APIVersion, Kind: KindSLI, Metadata: slo.Spec.Indicator.Metadata, Spec: slo.Spec.Indicator.Spec}
+			if sli.Metadata.Name == "" {
+				sli.Metadata.Name = slo.Metadata.Name + "-sli"
+			}
+			slo.Spec.Indicator = nil
+			slo.Spec.IndicatorRef = sli.Metadata.Name
+		}
+
+		bundle := Bundle{SLO: slo, SLI: sli}
+		for _, ap := range slo.Spec.AlertPolicies {
+			if ap.AlertPolicyRef != "" {
+				bundle.AlertPolicies = append(bundle.AlertPolicies, policies[ap.AlertPolicyRef])
+			}
+		}
+
+		window := slo.Spec.TimeWindow[0].Duration
+		windowDuration, _ := ParseDuration(window)
+		windowDays := int(math.Ceil(windowDuration.Hours() / 24))
+
+		for i, o := range slo.Spec.Objectives {
+			def := Definition{
+				Name:               objectiveName(slo, i),
+				SLOName:            slo.Metadata.Name,
+				Description:        slo.Spec.Description,
+				Service:            slo.Spec.Service,
+				ServiceDescription: services[slo.Spec.Service],
+				Window:             window,
+				WindowDays:         windowDays,
+				BudgetingMethod:    slo.Spec.BudgetingMethod,
+				Documents:          bundle,
+			}
+			if o.Target != nil {
+				def.Objective = *o.Target * 100
+			} else {
+				def.Objective = *o.TargetPercent
+			}
+			def.Objective = math.Round(def.Objective*1e6) / 1e6
+
+			if sli.Spec.ThresholdMetric != nil {
+				def.IndicatorType = IndicatorThreshold
+				def.ThresholdQuery = sli.Spec.ThresholdMetric.Query()
+				def.ThresholdOp = o.Op
+				def.ThresholdValue = o.Value
+			} else {
+				def.IndicatorType = IndicatorRatio
+				def.GoodQuery = sli.Spec.RatioMetric.Good.Query()
+				def.BadQuery = sli.Spec.RatioMetric.Bad.Query()
+				def.TotalQuery = sli.Spec.RatioMetric.Total.Query()
+			}
+			defs = append(defs, def)
+		}
+	}
+	return defs, nil
+}
+
+// objectiveName names the stored row; SLOs with several objectives get one row each
+func objectiveName(slo SLO, i int) string {
+	if len(slo.Spec.Objectives) == 1 {
+		return slo.Metadata.Name
+	}
+	if dn := slo.Spec.Objectives[i].DisplayName; dn != "" {
+		return slo.Metadata.Name + "-" + Name(dn)
+	}
+	return fmt.Sprintf("%s-%d", slo.Metadata.Name, i+1)
+}
+
+var nameInvalid = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// Name converts free text into a valid OpenSLO metadata.name
+func Name(s string) string {
+	n := nameInvalid.ReplaceAllString(strings.ToLower(s), "-")
+	return strings.Trim(n, "-.")
+}
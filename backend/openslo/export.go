@@ -0,0 +1,120 @@
+package openslo
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Export writes definitions as a multi-document OpenSLO stream ordered Service,
+// SLI, AlertPolicy, SLO. Definitions that were not imported from OpenSLO are
+// described from their stored queries.
+func Export(defs []Definition) ([]byte, error) {
+	var services, slis, policies, slos []interface{}
+	seen := make(map[string]bool)
+	add := func(list *[]interface{}, kind, name string, doc interface{}) {
+		if seen[kind+"/"+name] {
+			return
+		}
+		seen[kind+"/"+name] = true
+		*list = append(*list, doc)
+	}
+
+	for _, d := range defs {
+		bundle := d.Documents
+		if bundle.SLO.Kind == "" {
+			bundle = d.synthesize()
+		}
+
+		add(&services, KindService, bundle.SLO.Spec.Service, Service{
+			APIVersion: APIVersion,
+			Kind:       KindService,
+			Metadata:   Metadata{Name: bundle.SLO.Spec.Service},
+			Spec:       ServiceSpec{Description: d.ServiceDescription},
+		})
+		if bundle.SLI != nil {
+			add(&slis, KindSLI, bundle.SLI.Metadata.Name, *bundle.SLI)
+		}
+		for _, ap := range bundle.AlertPolicies {
+			add(&policies, KindAlertPolicy, ap.Metadata.Name, ap)
+		}
+		add(&slos, KindSLO, bundle.SLO.Metadata.Name, bundle.SLO)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, group := range [][]interface{}{services, slis, policies, slos} {
+		for _, doc := range group {
+			if err := enc.Encode(doc); err != nil {
+				return nil, fmt.Errorf("failed to encode OpenSLO document: %w", err)
+			}
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// synthesize builds OpenSLO documents for a definition that has none
+func (d Definition) synthesize() Bundle {
+	name := d.SLOName
+	if name == "" {
+		name = Name(d.Service + "-" + d.Name)
+	}
+	window := d.Window
+	if window == "" {
+		window = fmt.Sprintf("%dd", d.WindowDays)
+	}
+	target := d.Objective / 100
+
+	sli := &SLI{
+		APIVersion: APIVersion,
+		Kind:       KindSLI,
+		Metadata:   Metadata{Name: name + "-sli"},
+	}
+	objective := Objective{DisplayName: d.Name, Target: &target}
+	if d.IndicatorType == IndicatorRatio {
+		ratio := &RatioMetric{Counter: true, Total: prometheusMetric(d.TotalQuery)}
+		if d.GoodQuery != "" {
+			ratio.Good = prometheusMetric(d.GoodQuery)
+		} else {
+			ratio.Bad = prometheusMetric(d.BadQuery)
+		}
+		sli.Spec.RatioMetric = ratio
+	} else {
+		sli.Spec.ThresholdMetric = prometheusMetric(d.ThresholdQuery)
+		objective.Op = d.ThresholdOp
+		objective.Value = d.ThresholdValue
+	}
+
+	method := d.BudgetingMethod
+	if method == "" {
+		method = "Occurrences"
+	}
+	return Bundle{
+		SLI: sli,
+		SLO: SLO{
+			APIVersion: APIVersion,
+			Kind:       KindSLO,
+			Metadata:   Metadata{Name: name, DisplayName: d.Name},
+			Spec: SLOSpec{
+				Description:     d.Description,
+				Service:         d.Service,
+				IndicatorRef:    sli.Metadata.Name,
+				TimeWindow:      []TimeWindow{{Duration: window, IsRolling: true}},
+				BudgetingMethod: method,
+				Objectives:      []Objective{objective},
+			},
+		},
+	}
+}
+
+func prometheusMetric(query string) *MetricSpec {
+	return &MetricSpec{MetricSource: MetricSource{
+		Type: "Prometheus",
+		Spec: map[string]interface{}{"query": query},
+	}}
+}
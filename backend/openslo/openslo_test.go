@@ -0,0 +1,153 @@
+package openslo
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	data, err := os.ReadFile("testdata/checkout.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, errs := Parse("checkout.yaml", data)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", errs)
+	}
+
+	defs, errs := Resolve(docs)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+	if len(defs) != 2 {
+		t.Fatalf("expected 2 definitions, got %d", len(defs))
+	}
+
+	availability := defs[0]
+	if availability.IndicatorType != IndicatorRatio || availability.Objective != 99.9 || availability.WindowDays != 28 {
+		t.Errorf("unexpected availability definition: %+v", availability)
+	}
+	if availability.ServiceDescription != "Checkout API" || len(availability.Documents.AlertPolicies) != 1 {
+		t.Errorf("expected service description and alert policy to be resolved: %+v", availability)
+	}
+	if !strings.HasPrefix(availability.SLIQuery(), "100 * (sum(rate(") {
+		t.Errorf("unexpected ratio query: %s", availability.SLIQuery())
+	}
+
+	latency := defs[1]
+	if latency.IndicatorType != IndicatorThreshold || latency.ThresholdOp != "lte" || *latency.ThresholdValue != 0.5 || latency.WindowDays != 7 {
+		t.Errorf("unexpected latency definition: %+v", latency)
+	}
+	if latency.Documents.SLO.Spec.IndicatorRef != "checkout-latency-p99" {
+		t.Errorf("expected inline indicator to become a reference, got %q", latency.Documents.SLO.Spec.IndicatorRef)
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	data, _ := os.ReadFile("testdata/checkout.yaml")
+	docs, _ := Parse("checkout.yaml", data)
+	defs, _ := Resolve(docs)
+
+	exported, err := Export(defs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, errs := Parse("export.yaml", exported)
+	if len(errs) > 0 {
+		t.Fatalf("exported documents do not parse: %v", errs)
+	}
+	again, errs := Resolve(docs)
+	if len(errs) > 0 {
+		t.Fatalf("exported documents are invalid: %v\n%s", errs, exported)
+	}
+	if len(again) != len(defs) {
+		t.Errorf("expected %d definitions after round trip, got %d", len(defs), len(again))
+	}
+}
+
+func TestExportSynthesized(t *testing.T) {
+	value := 99.0
+	exported, err := Export([]Definition{{
+		Name:           "Availability",
+		Service:        "payments",
+		IndicatorType:  IndicatorThreshold,
+		ThresholdQuery: `up{job="payments"} * 100`,
+		ThresholdOp:    "gte",
+		ThresholdValue: &value,
+		Objective:      99.5,
+		WindowDays:     30,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, errs := Parse("export.yaml", exported)
+	if len(errs) == 0 {
+		errs = Validate(docs)
+	}
+	if len(errs) > 0 {
+		t.Fatalf("synthesized export is invalid: %v\n%s", errs, exported)
+	}
+}
+
+func TestValidationErrorsHaveLines(t *testing.T) {
+	input := `apiVersion: openslo/v1
+kind: SLO
+metadata:
+  name: broken
+spec:
+  service: checkout
+  indicatorRef: missing-sli
+  timeWindow:
+    - duration: 28days
+      isRolling: true
+  budgetingMethod: Occurrences
+  objectives:
+    - target: 1.5
+  owner: team-a
+`
+	docs, errs := Parse("broken.yaml", []byte(input))
+	if len(errs) != 1 || errs[0].Line != 14 || !strings.Contains(errs[0].Message, "owner") {
+		t.Fatalf("expected unknown field error on line 14, got %v", errs)
+	}
+
+	docs, _ = Parse("broken.yaml", []byte(strings.Replace(input, "  owner: team-a\n", "", 1)))
+	errs = Validate(docs)
+	lines := make(map[int]bool)
+	for _, e := range errs {
+		lines[e.Line] = true
+		if e.File != "broken.yaml" {
+			t.Errorf("expected file name in error, got %q", e.File)
+		}
+	}
+	for _, want := range []int{7, 9, 13} {
+		if !lines[want] {
+			t.Errorf("expected an error on line %d, got %v", want, errs)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"5m", "5m0s", false},
+		{"28d", "672h0m0s", false},
+		{"1w", "168h0m0s", false},
+		{"28days", "", true},
+		{"0d", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := ParseDuration(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && d.String() != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, d, tt.want)
+			}
+		})
+	}
+}
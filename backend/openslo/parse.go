@@ -0,0 +1,236 @@
+package openslo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a schema or reference problem at a position in a file
+type Error struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	loc := e.File
+	if e.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	if e.Path != "" {
+		return fmt.Sprintf("%s: %s: %s", loc, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", loc, e.Message)
+}
+
+// Document is one parsed OpenSLO document. Exactly one of the kind fields is set.
+type Document struct {
+	File        string
+	Line        int
+	Kind        string
+	Name        string
+	Service     *Service
+	SLI         *SLI
+	SLO         *SLO
+	AlertPolicy *AlertPolicy
+
+	root *yaml.Node
+}
+
+// errorAt builds an Error for the deepest node found along path in the document
+func (d Document) errorAt(message string, path ...interface{}) Error {
+	node := find(d.root, path...)
+	line := d.Line
+	if node != nil {
+		line = node.Line
+	}
+	return Error{File: d.File, Line: line, Path: joinPath(d.Kind+"/"+d.Name, path), Message: message}
+}
+
+var yamlLine = regexp.MustCompile(`line (\d+)`)
+
+// Parse reads a multi-document YAML stream. Structural problems such as YAML
+// syntax errors, unknown kinds, unknown fields and wrong types are returned as
+// errors; semantic checks are done by Validate.
+func Parse(file string, data []byte) ([]Document, []Error) {
+	var docs []Document
+	var errs []Error
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errs = append(errs, Error{File: file, Line: lineFromMessage(err.Error()), Message: err.Error()})
+			break
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			continue
+		}
+
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			errs = append(errs, Error{File: file, Line: root.Line, Message: "document must be a mapping"})
+			continue
+		}
+
+		d := Document{File: file, Line: root.Line, root: root}
+		d.Kind = scalar(find(root, "kind"))
+		d.Name = scalar(find(root, "metadata", "name"))
+		if v := scalar(find(root, "apiVersion")); v != APIVersion {
+			errs = append(errs, d.errorAt(fmt.Sprintf("apiVersion must be %q, got %q", APIVersion, v), "apiVersion"))
+			continue
+		}
+
+		var target interface{}
+		switch d.Kind {
+		case KindService:
+			d.Service = &Service{}
+			target = d.Service
+		case KindSLI:
+			d.SLI = &SLI{}
+			target = d.SLI
+		case KindSLO:
+			d.SLO = &SLO{}
+			target = d.SLO
+		case KindAlertPolicy:
+			d.AlertPolicy = &AlertPolicy{}
+			target = d.AlertPolicy
+		default:
+			errs = append(errs, d.errorAt(fmt.Sprintf("unsupported kind %q (supported: Service, SLI, SLO, AlertPolicy)", d.Kind), "kind"))
+			continue
+		}
+
+		fieldErrs := checkFields(d, root, reflect.TypeOf(target), nil)
+		if len(fieldErrs) > 0 {
+			errs = append(errs, fieldErrs...)
+			continue
+		}
+		if err := root.Decode(target); err != nil {
+			errs = append(errs, decodeErrors(d, err)...)
+			continue
+		}
+		docs = append(docs, d)
+	}
+	return docs, errs
+}
+
+// checkFields reports mapping keys that the target type does not define
+func checkFields(d Document, node *yaml.Node, t reflect.Type, path []interface{}) []Error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var errs []Error
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			fields[name] = t.Field(i).Type
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			ft, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, Error{
+					File: d.File, Line: key.Line,
+					Path:    joinPath(d.Kind+"/"+d.Name, path),
+					Message: fmt.Sprintf("unknown field %q", key.Value),
+				})
+				continue
+			}
+			errs = append(errs, checkFields(d, value, ft, append(path, key.Value))...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			errs = append(errs, checkFields(d, item, t.Elem(), append(path, i))...)
+		}
+	}
+	return errs
+}
+
+// decodeErrors splits a yaml.TypeError into one Error per line
+func decodeErrors(d Document, err error) []Error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return []Error{{File: d.File, Line: d.Line, Message: err.Error()}}
+	}
+	errs := make([]Error, 0, len(typeErr.Errors))
+	for _, msg := range typeErr.Errors {
+		errs = append(errs, Error{File: d.File, Line: lineFromMessage(msg), Path: d.Kind + "/" + d.Name, Message: msg})
+	}
+	return errs
+}
+
+func lineFromMessage(msg string) int {
+	if m := yamlLine.FindStringSubmatch(msg); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+// find walks mapping keys (string) and sequence indexes (int) and returns the
+// deepest node reached, so errors on missing fields point at their parent
+func find(node *yaml.Node, path ...interface{}) *yaml.Node {
+	current := node
+	for _, p := range path {
+		if current == nil {
+			return nil
+		}
+		var next *yaml.Node
+		switch key := p.(type) {
+		case string:
+			if current.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(current.Content); i += 2 {
+					if current.Content[i].Value == key {
+						next = current.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if current.Kind == yaml.SequenceNode && key < len(current.Content) {
+				next = current.Content[key]
+			}
+		}
+		if next == nil {
+			return current
+		}
+		current = next
+	}
+	return current
+}
+
+func scalar(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+func joinPath(prefix string, path []interface{}) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, p := range path {
+		switch v := p.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", v)
+		default:
+			fmt.Fprintf(&b, ".%v", v)
+		}
+	}
+	return b.String()
+}
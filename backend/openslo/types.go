@@ -0,0 +1,166 @@
+// Package openslo reads, validates and writes OpenSLO v1 documents
+// (https://github.com/OpenSLO/OpenSLO). Only Prometheus metric sources are supported.
+package openslo
+
+// APIVersion is the only supported OpenSLO API version
+const APIVersion = "openslo/v1"
+
+// Supported document kinds
+const (
+	KindService     = "Service"
+	KindSLI         = "SLI"
+	KindSLO         = "SLO"
+	KindAlertPolicy = "AlertPolicy"
+)
+
+// Metadata is shared by all kinds
+type Metadata struct {
+	Name        string            `yaml:"name" json:"name"`
+	DisplayName string            `yaml:"displayName,omitempty" json:"displayName,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+}
+
+// Service groups SLOs
+type Service struct {
+	APIVersion string      `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string      `yaml:"kind" json:"kind"`
+	Metadata   Metadata    `yaml:"metadata" json:"metadata"`
+	Spec       ServiceSpec `yaml:"spec" json:"spec"`
+}
+
+type ServiceSpec struct {
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// SLI describes how the indicator is measured
+type SLI struct {
+	APIVersion string   `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string   `yaml:"kind" json:"kind"`
+	Metadata   Metadata `yaml:"metadata" json:"metadata"`
+	Spec       SLISpec  `yaml:"spec" json:"spec"`
+}
+
+type SLISpec struct {
+	Description     string       `yaml:"description,omitempty" json:"description,omitempty"`
+	ThresholdMetric *MetricSpec  `yaml:"thresholdMetric,omitempty" json:"thresholdMetric,omitempty"`
+	RatioMetric     *RatioMetric `yaml:"ratioMetric,omitempty" json:"ratioMetric,omitempty"`
+}
+
+type RatioMetric struct {
+	Counter bool        `yaml:"counter" json:"counter"`
+	Good    *MetricSpec `yaml:"good,omitempty" json:"good,omitempty"`
+	Bad     *MetricSpec `yaml:"bad,omitempty" json:"bad,omitempty"`
+	Total   *MetricSpec `yaml:"total,omitempty" json:"total,omitempty"`
+}
+
+type MetricSpec struct {
+	MetricSource MetricSource `yaml:"metricSource" json:"metricSource"`
+}
+
+type MetricSource struct {
+	MetricSourceRef string                 `yaml:"metricSourceRef,omitempty" json:"metricSourceRef,omitempty"`
+	Type            string                 `yaml:"type" json:"type"`
+	Spec            map[string]interface{} `yaml:"spec" json:"spec"`
+}
+
+// Query returns the Prometheus query of the metric source
+func (m *MetricSpec) Query() string {
+	if m == nil {
+		return ""
+	}
+	q, _ := m.MetricSource.Spec["query"].(string)
+	return q
+}
+
+// InlineIndicator is an SLI defined inside an SLO
+type InlineIndicator struct {
+	Metadata Metadata `yaml:"metadata" json:"metadata"`
+	Spec     SLISpec  `yaml:"spec" json:"spec"`
+}
+
+// SLO is a service level objective
+type SLO struct {
+	APIVersion string   `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string   `yaml:"kind" json:"kind"`
+	Metadata   Metadata `yaml:"metadata" json:"metadata"`
+	Spec       SLOSpec  `yaml:"spec" json:"spec"`
+}
+
+type SLOSpec struct {
+	Description     string           `yaml:"description,omitempty" json:"description,omitempty"`
+	Service         string           `yaml:"service" json:"service"`
+	Indicator       *InlineIndicator `yaml:"indicator,omitempty" json:"indicator,omitempty"`
+	IndicatorRef    string           `yaml:"indicatorRef,omitempty" json:"indicatorRef,omitempty"`
+	TimeWindow      []TimeWindow     `yaml:"timeWindow" json:"timeWindow"`
+	BudgetingMethod string           `yaml:"budgetingMethod" json:"budgetingMethod"`
+	Objectives      []Objective      `yaml:"objectives" json:"objectives"`
+	AlertPolicies   []AlertPolicyRef `yaml:"alertPolicies,omitempty" json:"alertPolicies,omitempty"`
+}
+
+type TimeWindow struct {
+	Duration  string    `yaml:"duration" json:"duration"`
+	IsRolling bool      `yaml:"isRolling" json:"isRolling"`
+	Calendar  *Calendar `yaml:"calendar,omitempty" json:"calendar,omitempty"`
+}
+
+type Calendar struct {
+	StartTime string `yaml:"startTime" json:"startTime"`
+	TimeZone  string `yaml:"timeZone" json:"timeZone"`
+}
+
+type Objective struct {
+	DisplayName     string   `yaml:"displayName,omitempty" json:"displayName,omitempty"`
+	Op              string   `yaml:"op,omitempty" json:"op,omitempty"`
+	Value           *float64 `yaml:"value,omitempty" json:"value,omitempty"`
+	Target          *float64 `yaml:"target,omitempty" json:"target,omitempty"`
+	TargetPercent   *float64 `yaml:"targetPercent,omitempty" json:"targetPercent,omitempty"`
+	TimeSliceTarget *float64 `yaml:"timeSliceTarget,omitempty" json:"timeSliceTarget,omitempty"`
+	TimeSliceWindow string   `yaml:"timeSliceWindow,omitempty" json:"timeSliceWindow,omitempty"`
+}
+
+// AlertPolicyRef is either a reference to an AlertPolicy document or an inline policy
+type AlertPolicyRef struct {
+	AlertPolicyRef string           `yaml:"alertPolicyRef,omitempty" json:"alertPolicyRef,omitempty"`
+	Kind           string           `yaml:"kind,omitempty" json:"kind,omitempty"`
+	Metadata       *Metadata        `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Spec           *AlertPolicySpec `yaml:"spec,omitempty" json:"spec,omitempty"`
+}
+
+// AlertPolicy describes when to alert on an SLO
+type AlertPolicy struct {
+	APIVersion string          `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string          `yaml:"kind" json:"kind"`
+	Metadata   Metadata        `yaml:"metadata" json:"metadata"`
+	Spec       AlertPolicySpec `yaml:"spec" json:"spec"`
+}
+
+type AlertPolicySpec struct {
+	Description         string              `yaml:"description,omitempty" json:"description,omitempty"`
+	AlertWhenNoData     bool                `yaml:"alertWhenNoData" json:"alertWhenNoData"`
+	AlertWhenResolved   bool                `yaml:"alertWhenResolved" json:"alertWhenResolved"`
+	AlertWhenBreaching  bool                `yaml:"alertWhenBreaching" json:"alertWhenBreaching"`
+	Conditions          []AlertConditionRef `yaml:"conditions" json:"conditions"`
+	NotificationTargets []map[string]any    `yaml:"notificationTargets,omitempty" json:"notificationTargets,omitempty"`
+}
+
+type AlertConditionRef struct {
+	ConditionRef string              `yaml:"conditionRef,omitempty" json:"conditionRef,omitempty"`
+	Kind         string              `yaml:"kind,omitempty" json:"kind,omitempty"`
+	Metadata     *Metadata           `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Spec         *AlertConditionSpec `yaml:"spec,omitempty" json:"spec,omitempty"`
+}
+
+type AlertConditionSpec struct {
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Severity    string            `yaml:"severity" json:"severity"`
+	Condition   BurnRateCondition `yaml:"condition" json:"condition"`
+}
+
+type BurnRateCondition struct {
+	Kind           string  `yaml:"kind" json:"kind"` // burnrate
+	Op             string  `yaml:"op" json:"op"`
+	Threshold      float64 `yaml:"threshold" json:"threshold"`
+	LookbackWindow string  `yaml:"lookbackWindow" json:"lookbackWindow"`
+	AlertAfter     string  `yaml:"alertAfter,omitempty" json:"alertAfter,omitempty"`
+}
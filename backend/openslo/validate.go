@@ -0,0 +1,258 @@
+package openslo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	namePattern     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	durationPattern = regexp.MustCompile(`^(\d+)([smhdwMQY])$`)
+	validOps        = map[string]bool{"lt": true, "lte": true, "gt": true, "gte": true}
+	budgetMethods   = map[string]bool{"Occurrences": true, "Timeslices": true, "RatioTimeslices": true}
+)
+
+// ParseDuration parses an OpenSLO duration shorthand such as 5m, 1h, 28d or 1M.
+// Months, quarters and years are treated as 30, 90 and 365 days.
+func ParseDuration(s string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q (expected e.g. 5m, 1h, 28d)", s)
+	}
+	n, _ := strconv.Atoi(m[1])
+	if n <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", s)
+	}
+	unit := map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+		"M": 30 * 24 * time.Hour,
+		"Q": 90 * 24 * time.Hour,
+		"Y": 365 * 24 * time.Hour,
+	}[m[2]]
+	return time.Duration(n) * unit, nil
+}
+
+// Validate checks documents against the OpenSLO v1 rules this importer relies on,
+// including references between documents. All documents of a change set should
+// be validated together so that references across files resolve.
+func Validate(docs []Document) []Error {
+	var errs []Error
+	seen := make(map[string]bool)
+	slis := make(map[string]bool)
+	policies := make(map[string]bool)
+	for _, d := range docs {
+		switch d.Kind {
+		case KindSLI:
+			slis[d.Name] = true
+		case KindAlertPolicy:
+			policies[d.Name] = true
+		}
+	}
+
+	for _, d := range docs {
+		if d.Name == "" {
+			errs = append(errs, d.errorAt("metadata.name is required", "metadata"))
+		} else if !namePattern.MatchString(d.Name) || len(d.Name) > 253 {
+			errs = append(errs, d.errorAt("metadata.name must be lowercase alphanumerics, '-' or '.'", "metadata", "name"))
+		}
+		key := d.Kind + "/" + d.Name
+		if seen[key] {
+			errs = append(errs, d.errorAt(fmt.Sprintf("duplicate %s %q", d.Kind, d.Name), "metadata", "name"))
+		}
+		seen[key] = true
+
+		switch d.Kind {
+		case KindSLI:
+			errs = append(errs, validateSLISpec(d, d.SLI.Spec, "spec")...)
+		case KindSLO:
+			errs = append(errs, validateSLO(d, slis, policies)...)
+		case KindAlertPolicy:
+			errs = append(errs, validateAlertPolicySpec(d, d.AlertPolicy.Spec, "spec")...)
+		}
+	}
+	return errs
+}
+
+func validateSLISpec(d Document, spec SLISpec, path ...interface{}) []Error {
+	var errs []Error
+	at := func(msg string, sub ...interface{}) {
+		errs = append(errs, d.errorAt(msg, append(append([]interface{}{}, path...), sub...)...))
+	}
+
+	switch {
+	case spec.ThresholdMetric == nil && spec.RatioMetric == nil:
+		at("one of thresholdMetric or ratioMetric is required")
+	case spec.ThresholdMetric != nil && spec.RatioMetric != nil:
+		at("thresholdMetric and ratioMetric are mutually exclusive")
+	case spec.ThresholdMetric != nil:
+		errs = append(errs, validateMetric(d, spec.ThresholdMetric, append(path, "thresholdMetric")...)...)
+	default:
+		r := spec.RatioMetric
+		if r.Total == nil {
+			at("ratioMetric.total is required", "ratioMetric")
+		} else {
+			errs = append(errs, validateMetric(d, r.Total, append(path, "ratioMetric", "total")...)...)
+		}
+		if (r.Good == nil) == (r.Bad == nil) {
+			at("ratioMetric needs exactly one of good or bad", "ratioMetric")
+		}
+		if r.Good != nil {
+			errs = append(errs, validateMetric(d, r.Good, append(path, "ratioMetric", "good")...)...)
+		}
+		if r.Bad != nil {
+			errs = append(errs, validateMetric(d, r.Bad, append(path, "ratioMetric", "bad")...)...)
+		}
+	}
+	return errs
+}
+
+func validateMetric(d Document, m *MetricSpec, path ...interface{}) []Error {
+	var errs []Error
+	if m.MetricSource.MetricSourceRef != "" {
+		errs = append(errs, d.errorAt("metricSourceRef is not supported; use an inline Prometheus metricSource", append(path, "metricSource", "metricSourceRef")...))
+	}
+	if !strings.EqualFold(m.MetricSource.Type, "Prometheus") {
+		errs = append(errs, d.errorAt(fmt.Sprintf("unsupported metricSource type %q (only Prometheus)", m.MetricSource.Type), append(path, "metricSource", "type")...))
+	}
+	if m.Query() == "" {
+		errs = append(errs, d.errorAt("metricSource.spec.query is required", append(path, "metricSource", "spec")...))
+	}
+	return errs
+}
+
+func validateSLO(d Document, slis, policies map[string]bool) []Error {
+	var errs []Error
+	spec := d.SLO.Spec
+	at := func(msg string, path ...interface{}) {
+		errs = append(errs, d.errorAt(msg, append([]interface{}{"spec"}, path...)...))
+	}
+
+	if spec.Service == "" {
+		at("service is required")
+	}
+
+	threshold := false
+	switch {
+	case spec.Indicator == nil && spec.IndicatorRef == "":
+		at("one of indicator or indicatorRef is required")
+	case spec.Indicator != nil && spec.IndicatorRef != "":
+		at("indicator and indicatorRef are mutually exclusive", "indicatorRef")
+	case spec.IndicatorRef != "":
+		if !slis[spec.IndicatorRef] {
+			at(fmt.Sprintf("indicatorRef %q does not match any SLI", spec.IndicatorRef), "indicatorRef")
+		}
+	default:
+		errs = append(errs, validateSLISpec(d, spec.Indicator.Spec, "spec", "indicator", "spec")...)
+		threshold = spec.Indicator.Spec.ThresholdMetric != nil
+	}
+
+	if len(spec.TimeWindow) != 1 {
+		at("exactly one timeWindow is required", "timeWindow")
+	} else {
+		tw := spec.TimeWindow[0]
+		if _, err := ParseDuration(tw.Duration); err != nil {
+			at(err.Error(), "timeWindow", 0, "duration")
+		}
+		if !tw.IsRolling && tw.Calendar == nil {
+			at("calendar is required when isRolling is false", "timeWindow", 0)
+		}
+	}
+
+	if !budgetMethods[spec.BudgetingMethod] {
+		at(fmt.Sprintf("budgetingMethod must be Occurrences, Timeslices or RatioTimeslices, got %q", spec.BudgetingMethod), "budgetingMethod")
+	}
+
+	if len(spec.Objectives) == 0 {
+		at("at least one objective is required", "objectives")
+	}
+	for i, o := range spec.Objectives {
+		switch {
+		case o.Target == nil && o.TargetPercent == nil:
+			at("target or targetPercent is required", "objectives", i)
+		case o.Target != nil && o.TargetPercent != nil:
+			at("target and targetPercent are mutually exclusive", "objectives", i)
+		case o.Target != nil && (*o.Target <= 0 || *o.Target >= 1):
+			at("target must be between 0 and 1 (exclusive)", "objectives", i, "target")
+		case o.TargetPercent != nil && (*o.TargetPercent <= 0 || *o.TargetPercent >= 100):
+			at("targetPercent must be between 0 and 100 (exclusive)", "objectives", i, "targetPercent")
+		}
+		if threshold && (o.Op == "" || o.Value == nil) {
+			at("op and value are required for a threshold indicator", "objectives", i)
+		}
+		if o.Op != "" && !validOps[o.Op] {
+			at(fmt.Sprintf("op must be one of lt, lte, gt, gte, got %q", o.Op), "objectives", i, "op")
+		}
+		if spec.BudgetingMethod == "Timeslices" {
+			if o.TimeSliceTarget == nil {
+				at("timeSliceTarget is required for Timeslices budgeting", "objectives", i)
+			}
+			if _, err := ParseDuration(o.TimeSliceWindow); err != nil {
+				at("timeSliceWindow: "+err.Error(), "objectives", i)
+			}
+		}
+	}
+
+	for i, ap := range spec.AlertPolicies {
+		switch {
+		case ap.AlertPolicyRef != "":
+			if !policies[ap.AlertPolicyRef] {
+				at(fmt.Sprintf("alertPolicyRef %q does not match any AlertPolicy", ap.AlertPolicyRef), "alertPolicies", i, "alertPolicyRef")
+			}
+		case ap.Kind != KindAlertPolicy || ap.Spec == nil:
+			at("inline alert policy needs kind AlertPolicy and a spec", "alertPolicies", i)
+		default:
+			errs = append(errs, validateAlertPolicySpec(d, *ap.Spec, "spec", "alertPolicies", i, "spec")...)
+		}
+	}
+	return errs
+}
+
+func validateAlertPolicySpec(d Document, spec AlertPolicySpec, path ...interface{}) []Error {
+	var errs []Error
+	at := func(msg string, sub ...interface{}) {
+		errs = append(errs, d.errorAt(msg, append(append([]interface{}{}, path...), sub...)...))
+	}
+
+	if len(spec.Conditions) == 0 {
+		at("at least one condition is required", "conditions")
+	}
+	for i, c := range spec.Conditions {
+		if c.ConditionRef != "" {
+			at("conditionRef is not supported; define the AlertCondition inline", "conditions", i, "conditionRef")
+			continue
+		}
+		if c.Kind != "AlertCondition" || c.Spec == nil {
+			at("inline condition needs kind AlertCondition and a spec", "conditions", i)
+			continue
+		}
+		cond := c.Spec.Condition
+		if c.Spec.Severity == "" {
+			at("severity is required", "conditions", i, "spec")
+		}
+		if cond.Kind != "burnrate" {
+			at(fmt.Sprintf("condition kind must be burnrate, got %q", cond.Kind), "conditions", i, "spec", "condition", "kind")
+		}
+		if !validOps[cond.Op] {
+			at(fmt.Sprintf("op must be one of lt, lte, gt, gte, got %q", cond.Op), "conditions", i, "spec", "condition", "op")
+		}
+		if cond.Threshold <= 0 {
+			at("threshold must be positive", "conditions", i, "spec", "condition", "threshold")
+		}
+		if _, err := ParseDuration(cond.LookbackWindow); err != nil {
+			at("lookbackWindow: "+err.Error(), "conditions", i, "spec", "condition", "lookbackWindow")
+		}
+		if cond.AlertAfter != "" {
+			if _, err := ParseDuration(cond.AlertAfter); err != nil {
+				at("alertAfter: "+err.Error(), "conditions", i, "spec", "condition", "alertAfter")
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pruneInterval is how often idle buckets are forgotten
+const pruneInterval = 5 * time.Minute
+
+// Limiter applies the policies of a config to identities
+type Limiter struct {
+	config   Config
+	store    Store
+	rejected *prometheus.CounterVec
+	errors   prometheus.Counter
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewLimiter creates a limiter keeping its buckets in store
+func NewLimiter(config Config, store Store) *Limiter {
+	return &Limiter{
+		config: config,
+		store:  store,
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "reliability_studio_rate_limit_rejected_total",
+			Help: "Requests rejected by rate limiting, by policy and identity kind.",
+		}, []string{"policy", "identity"}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reliability_studio_rate_limit_errors_total",
+			Help: "Requests let through because the rate limit store failed.",
+		}),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Allow takes a token for a request of the given policy. kind is "user", "key"
+// or "ip" and id identifies the caller within it. limited is false when no
+// limit applied: the policy is off, or the store failed, which lets the
+// request through so a database outage doesn't take the API down.
+func (l *Limiter) Allow(ctx context.Context, policy, kind, id string) (res Result, limited bool) {
+	limit, ok := l.config.Policies[policy]
+	if !ok {
+		limit = l.config.Policies[PolicyDefault]
+	}
+	if limit.Off() {
+		return Result{Allowed: true}, false
+	}
+
+	res, err := l.store.Take(ctx, policy+"|"+kind+":"+id, limit, time.Now())
+	if err != nil {
+		log.Printf("⚠️  Rate limit store failed, allowing request: %v", err)
+		l.errors.Inc()
+		return Result{Allowed: true}, false
+	}
+	if !res.Allowed {
+		l.rejected.WithLabelValues(policy, kind).Inc()
+	}
+	return res, true
+}
+
+// Start forgets idle buckets until ctx is done or Stop is called
+func (l *Limiter) Start(ctx context.Context) {
+	var window time.Duration
+	for _, limit := range l.config.Policies {
+		if w := limit.Window(); w > window {
+			window = w
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := l.store.Prune(ctx, time.Now().Add(-window)); err != nil {
+					log.Printf("⚠️  Failed to prune rate limit buckets: %v", err)
+				}
+			case <-l.stopChan:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops pruning
+func (l *Limiter) Stop() {
+	l.stopOnce.Do(func() { close(l.stopChan) })
+}
+
+// Describe implements prometheus.Collector
+func (l *Limiter) Describe(ch chan<- *prometheus.Desc) {
+	l.rejected.Describe(ch)
+	l.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (l *Limiter) Collect(ch chan<- prometheus.Metric) {
+	l.rejected.Collect(ch)
+	l.errors.Collect(ch)
+}
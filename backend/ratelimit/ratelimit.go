@@ -0,0 +1,218 @@
+// Package ratelimit limits requests with token buckets per identity (user, API
+// key or client IP) and route policy. Buckets live in memory or, to share them
+// across replicas, in Postgres.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policies
+const (
+	PolicyDefault   = "default"
+	PolicyLogin     = "login"     // sign-in, registration and token refresh
+	PolicyWebsocket = "websocket" // realtime connections
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per second.
+// The zero Limit allows everything.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Off reports whether the limit allows everything
+func (l Limit) Off() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// Window is how long an empty bucket takes to fill up again
+func (l Limit) Window() time.Duration {
+	if l.Off() {
+		return 0
+	}
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+func (l Limit) String() string {
+	if l.Off() {
+		return "off"
+	}
+	return fmt.Sprintf("%d;w=%d", l.Burst, int(math.Ceil(l.Window().Seconds())))
+}
+
+var units = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+
+// ParseLimit reads a limit like "100/m", or "100/m:20" for a burst of 20
+// instead of the whole amount. "off" disables the limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return Limit{}, nil
+	}
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	count, unit, ok := strings.Cut(rate, "/")
+	n, err := strconv.Atoi(count)
+	per, known := units[unit]
+	if !ok || err != nil || !known || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, use a count per s, m or h like 100/m", s)
+	}
+	l := Limit{Rate: float64(n) / per.Seconds(), Burst: n}
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid burst in rate limit %q", s)
+		}
+	}
+	return l, nil
+}
+
+// Config selects the limit of each policy and where buckets are kept
+type Config struct {
+	Policies map[string]Limit
+	Shared   bool // keep buckets in Postgres
+}
+
+// DefaultConfig limits each identity to 100 requests a minute, sign-ins to 10
+// and new realtime connections to 10
+func DefaultConfig() Config {
+	return Config{Policies: map[string]Limit{
+		PolicyDefault:   {Rate: 100.0 / 60, Burst: 100},
+		PolicyLogin:     {Rate: 10.0 / 60, Burst: 5},
+		PolicyWebsocket: {Rate: 10.0 / 60, Burst: 5},
+	}}
+}
+
+// LoadConfigFromEnv reads RATE_LIMIT_DEFAULT, RATE_LIMIT_LOGIN,
+// RATE_LIMIT_WEBSOCKET and RATE_LIMIT_STORE (memory or postgres)
+func LoadConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	for policy := range config.Policies {
+		value := os.Getenv("RATE_LIMIT_" + strings.ToUpper(policy))
+		if value == "" {
+			continue
+		}
+		l, err := ParseLimit(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("RATE_LIMIT_%s: %w", strings.ToUpper(policy), err)
+		}
+		config.Policies[policy] = l
+	}
+
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+	case "postgres":
+		config.Shared = true
+	default:
+		return Config{}, fmt.Errorf("RATE_LIMIT_STORE: unknown store %q, use memory or postgres", store)
+	}
+	return config, nil
+}
+
+// PolicyFor returns the policy of a request
+func PolicyFor(r *http.Request) string {
+	switch {
+	case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"), strings.HasPrefix(r.URL.Path, "/api/realtime"):
+		return PolicyWebsocket
+	case strings.HasPrefix(r.URL.Path, "/api/auth/") && r.URL.Path != "/api/auth/config":
+		return PolicyLogin
+	}
+	return PolicyDefault
+}
+
+// Result is the state of a bucket after a request
+type Result struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int
+	Reset      time.Duration // until the bucket is full
+	RetryAfter time.Duration // until the next request is allowed, when rejected
+}
+
+// result describes a bucket holding tokens after a request
+func result(l Limit, tokens float64, allowed bool) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     time.Duration((float64(l.Burst) - tokens) / l.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+	}
+	return res
+}
+
+// bucket is a token bucket as of updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b until now and takes a token if there is one
+func (l Limit) take(b *bucket, now time.Time) Result {
+	if b.updated.IsZero() {
+		b.tokens = float64(l.Burst)
+	} else if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(l.Burst), b.tokens+elapsed*l.Rate)
+	}
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(l, b.tokens, allowed)
+}
+
+// Store keeps buckets by key
+type Store interface {
+	// Take takes a token from the bucket of key
+	Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error)
+	// Prune forgets buckets unused since before, which are full again
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// MemoryStore keeps the buckets of one replica
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{}
+		s.buckets[key] = b
+	}
+	return l.take(b, now), nil
+}
+
+// Prune implements Store
+func (s *MemoryStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if b.updated.Before(before) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
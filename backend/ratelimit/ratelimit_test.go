@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "100/m", want: Limit{Rate: 100.0 / 60, Burst: 100}},
+		{value: "10/s:20", want: Limit{Rate: 10, Burst: 20}},
+		{value: "3600/h", want: Limit{Rate: 1, Burst: 3600}},
+		{value: "off", want: Limit{}},
+		{value: "100", wantErr: true},
+		{value: "100/d", wantErr: true},
+		{value: "0/m", wantErr: true},
+		{value: "10/m:0", wantErr: true},
+		{value: "10/m:x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLimit(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitString(t *testing.T) {
+	if got := (Limit{Rate: 10.0 / 60, Burst: 5}).String(); got != "5;w=30" {
+		t.Errorf("String() = %q, want 5;w=30", got)
+	}
+	if got := (Limit{}).String(); got != "off" {
+		t.Errorf("String() = %q, want off", got)
+	}
+}
+
+func TestTake(t *testing.T) {
+	l := Limit{Rate: 1, Burst: 2}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &bucket{}
+
+	steps := []struct {
+		after     time.Duration
+		allowed   bool
+		remaining int
+		retry     time.Duration
+	}{
+		{0, true, 1, 0},
+		{0, true, 0, 0},
+		{0, false, 0, time.Second},
+		{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{time.Second, true, 0, 0},
+		{time.Hour, true, 1, 0}, // refills up to the burst only
+	}
+	now := start
+	for i, step := range steps {
+		now = now.Add(step.after)
+		res := l.take(b, now)
+		if res.Allowed != step.allowed || res.Remaining != step.remaining || res.RetryAfter != step.retry {
+			t.Errorf("step %d: got allowed %v, remaining %d, retry %v; want %v, %d, %v",
+				i, res.Allowed, res.Remaining, res.RetryAfter, step.allowed, step.remaining, step.retry)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	l := Limit{Rate: 1, Burst: 1}
+	now := time.Now()
+
+	if res, _ := s.Take(ctx, "a", l, now); !res.Allowed {
+		t.Error("first request of a rejected")
+	}
+	if res, _ := s.Take(ctx, "a", l, now); res.Allowed {
+		t.Error("second request of a allowed")
+	}
+	if res, _ := s.Take(ctx, "b", l, now); !res.Allowed {
+		t.Error("b should have its own bucket")
+	}
+
+	s.Prune(ctx, now.Add(time.Second))
+	if len(s.buckets) != 0 {
+		t.Errorf("%d buckets left after pruning", len(s.buckets))
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	l := NewLimiter(Config{Policies: map[string]Limit{
+		PolicyDefault: {Rate: 1, Burst: 1},
+		PolicyLogin:   {},
+	}}, NewMemoryStore())
+	ctx := context.Background()
+
+	if _, limited := l.Allow(ctx, PolicyLogin, "ip", "10.0.0.1"); limited {
+		t.Error("policy that is off should not limit")
+	}
+	if res, limited := l.Allow(ctx, PolicyDefault, "user", "u1"); !limited || !res.Allowed {
+		t.Errorf("first request: allowed %v, limited %v", res.Allowed, limited)
+	}
+	if res, _ := l.Allow(ctx, PolicyWebsocket, "user", "u1"); !res.Allowed {
+		t.Error("unknown policies should use the default limit with their own bucket")
+	}
+	if res, _ := l.Allow(ctx, PolicyDefault, "user", "u1"); res.Allowed {
+		t.Error("second request allowed")
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	tests := []struct {
+		method, path, upgrade string
+		want                  string
+	}{
+		{"POST", "/api/auth/login", "", PolicyLogin},
+		{"POST", "/api/auth/refresh", "", PolicyLogin},
+		{"GET", "/api/auth/oidc/callback", "", PolicyLogin},
+		{"GET", "/api/auth/config", "", PolicyDefault},
+		{"GET", "/api/realtime", "websocket", PolicyWebsocket},
+		{"GET", "/api/realtime", "", PolicyWebsocket},
+		{"GET", "/api/realtime/events", "", PolicyWebsocket},
+		{"GET", "/api/incidents", "", PolicyDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.upgrade != "" {
+				r.Header.Set("Upgrade", tt.upgrade)
+			}
+			if got := PolicyFor(r); got != tt.want {
+				t.Errorf("PolicyFor(%s %s) = %s, want %s", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Setenv("RATE_LIMIT_LOGIN", "20/m:10")
+	t.Setenv("RATE_LIMIT_STORE", "postgres")
+	config, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Limit{Rate: 20.0 / 60, Burst: 10}); config.Policies[PolicyLogin] != want {
+		t.Errorf("login = %+v, want %+v", config.Policies[PolicyLogin], want)
+	}
+	if config.Policies[PolicyDefault] != DefaultConfig().Policies[PolicyDefault] || !config.Shared {
+		t.Errorf("config = %+v", config)
+	}
+
+	t.Setenv("RATE_LIMIT_STORE", "redis")
+	if _, err := LoadConfigFromEnv(); err == nil {
+		t.Error("unknown store accepted")
+	}
+}
@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PostgresStore keeps buckets in rate_limit_buckets so that all replicas share
+// them. It uses the database clock rather than the now passed in, as replica
+// clocks may differ.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a store
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// refill is the tokens of an existing bucket after refilling it until now.
+// $2 is the burst and $3 the rate.
+const refill = `LEAST($2, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3)`
+
+// Take implements Store. The bucket is updated in a single statement, so
+// concurrent requests from different replicas can't both take the last token.
+func (s *PostgresStore) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	var tokens float64
+	var allowed bool
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $2 - 1, TRUE, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE WHEN `+refill+` >= 1 THEN `+refill+` - 1 ELSE `+refill+` END,
+			allowed = `+refill+` >= 1,
+			updated_at = NOW()
+		RETURNING tokens, allowed
+	`, key, float64(l.Burst), l.Rate).Scan(&tokens, &allowed)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(l, tokens, allowed), nil
+}
+
+// Prune implements Store
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, before)
+	return err
+}
@@ -0,0 +1,380 @@
+// Package rbac decides what a user may do on each service. Users and services
+// belong to an organization, and nothing of other organizations is visible.
+// Within one, services belong to the team named in services.team; team members
+// get the permissions of their membership on every service of the team, and
+// service grants add permissions on single services. Global roles only apply
+// to services no team owns, except admin, which can do everything in the
+// organization.
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// Permission is an action on the services it is granted for
+type Permission string
+
+// Permissions
+const (
+	IncidentRead       Permission = "incident:read"
+	IncidentWrite      Permission = "incident:write"
+	SLORead            Permission = "slo:read"
+	SLOWrite           Permission = "slo:write"
+	SLOAdmin           Permission = "slo:admin" // delete SLOs and manage error budget policies
+	RuleRead           Permission = "rule:read"
+	RuleWrite          Permission = "rule:write"
+	RemediationExecute Permission = "remediation:execute"
+	TeamAdmin          Permission = "team:admin" // edit services, members and grants of the team
+)
+
+// All lists every permission
+var All = []Permission{
+	IncidentRead, IncidentWrite,
+	SLORead, SLOWrite, SLOAdmin,
+	RuleRead, RuleWrite,
+	RemediationExecute,
+	TeamAdmin,
+}
+
+// implied lists the permissions that come with another
+var implied = map[Permission][]Permission{
+	IncidentWrite: {IncidentRead},
+	SLOWrite:      {SLORead},
+	SLOAdmin:      {SLOWrite, SLORead},
+	RuleWrite:     {RuleRead},
+}
+
+// rolePermissions apply to services without an owning team
+var rolePermissions = map[string][]Permission{
+	"viewer": {IncidentRead, SLORead, RuleRead},
+	"editor": {IncidentWrite, SLOAdmin, RuleWrite, RemediationExecute, TeamAdmin},
+}
+
+// ErrUnknownPermission is returned for permission names that don't exist
+var ErrUnknownPermission = errors.New("unknown permission")
+
+// ParsePermissions validates permission names
+func ParsePermissions(names []string) ([]Permission, error) {
+	perms := make([]Permission, 0, len(names))
+	for _, name := range names {
+		p := Permission(strings.ToLower(strings.TrimSpace(name)))
+		known := false
+		for _, q := range All {
+			if p == q {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownPermission, name)
+		}
+		perms = append(perms, p)
+	}
+	return perms, nil
+}
+
+// Set is a set of permissions, including the implied ones
+type Set map[Permission]bool
+
+// NewSet returns a set with perms and the permissions they imply
+func NewSet(perms ...Permission) Set {
+	s := make(Set)
+	s.add(perms...)
+	return s
+}
+
+func (s Set) add(perms ...Permission) {
+	for _, p := range perms {
+		s[p] = true
+		for _, q := range implied[p] {
+			s[q] = true
+		}
+	}
+}
+
+// Has reports whether p is in the set
+func (s Set) Has(p Permission) bool {
+	return s[p]
+}
+
+// List returns the permissions in a stable order
+func (s Set) List() []Permission {
+	perms := make([]Permission, 0, len(s))
+	for p := range s {
+		perms = append(perms, p)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+// Service is the part of a catalog entry that decides ownership
+type Service struct {
+	ID    string
+	Name  string
+	Team  string
+	OrgID string
+}
+
+// DefaultOrg is the organization of users and rows that name none
+const DefaultOrg = "default"
+
+// Identity is who access is loaded for
+type Identity struct {
+	UserID string
+	OrgID  string // an organization ID or Grafana org ID; empty is DefaultOrg
+	Roles  []string
+	Scopes []Permission // limit everything when not nil, as for API keys
+}
+
+// Access is what one user may do. It is loaded once per request.
+type Access struct {
+	UserID string
+	OrgID  string // resolved organization ID
+	admin  bool
+	roles  Set            // on services no team owns
+	teams  map[string]Set // lower-cased team name -> membership permissions
+	grants map[string]Set // service ID -> granted permissions
+	scopes Set            // limits everything above when not nil (API keys)
+
+	services map[string]Service // by ID
+	byName   map[string]string  // service name -> ID
+	known    map[string]bool    // lower-cased names of existing teams
+}
+
+func newAccess(id Identity, orgID string) *Access {
+	a := &Access{
+		UserID:   id.UserID,
+		OrgID:    orgID,
+		roles:    make(Set),
+		teams:    make(map[string]Set),
+		grants:   make(map[string]Set),
+		services: make(map[string]Service),
+		byName:   make(map[string]string),
+		known:    make(map[string]bool),
+	}
+	for _, role := range id.Roles {
+		if role == "admin" {
+			a.admin = true
+		}
+		a.roles.add(rolePermissions[role]...)
+	}
+	if id.Scopes != nil {
+		a.scopes = NewSet(id.Scopes...)
+	}
+	return a
+}
+
+// addService adds a catalog entry. Only services of the organization can be
+// looked up by name, since other organizations may use the same names.
+func (a *Access) addService(svc Service) {
+	a.services[svc.ID] = svc
+	if svc.OrgID == a.OrgID {
+		a.byName[svc.Name] = svc.ID
+	}
+}
+
+// unlimited reports whether the access has every permission in its
+// organization, which needs no membership lookups
+func (a *Access) unlimited() bool {
+	return a.admin && a.scopes == nil
+}
+
+// scoped returns the permissions of set that are also in the scopes
+func (a *Access) scoped(set Set) Set {
+	if a.scopes == nil {
+		return set
+	}
+	limited := make(Set)
+	for p := range set {
+		if a.scopes[p] {
+			limited[p] = true
+		}
+	}
+	return limited
+}
+
+// IsAdmin reports whether the user has the admin role in their organization
+func (a *Access) IsAdmin() bool {
+	return a.admin
+}
+
+// foreign reports whether a service belongs to another organization
+func (a *Access) foreign(serviceID string) bool {
+	svc, ok := a.services[serviceID]
+	return ok && svc.OrgID != a.OrgID
+}
+
+// owned returns the lower-cased team name when an existing team owns it
+func (a *Access) owned(team string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(team))
+	return key, key != "" && a.known[key]
+}
+
+// teamSet is the user's permissions on the services of a team
+func (a *Access) teamSet(team string) Set {
+	if a.admin {
+		return a.scoped(NewSet(All...))
+	}
+	if key, ok := a.owned(team); ok {
+		return a.scoped(a.teams[key])
+	}
+	return a.scoped(a.roles)
+}
+
+// Permissions returns what the user may do on a service. Services that aren't
+// in the catalog are treated as having no team; services of other
+// organizations allow nothing.
+func (a *Access) Permissions(serviceID string) Set {
+	set := NewSet()
+	if a.foreign(serviceID) {
+		return set
+	}
+	for p := range a.teamSet(a.services[serviceID].Team) {
+		set[p] = true
+	}
+	for p := range a.scoped(a.grants[serviceID]) {
+		set[p] = true
+	}
+	return set
+}
+
+// Can reports whether the user has p on a service
+func (a *Access) Can(p Permission, serviceID string) bool {
+	return a.Permissions(serviceID).Has(p)
+}
+
+// CanName is Can for a service given by name
+func (a *Access) CanName(p Permission, service string) bool {
+	if id, ok := a.byName[service]; ok {
+		return a.Can(p, id)
+	}
+	return a.teamSet("").Has(p)
+}
+
+// CanTeam reports whether the user has p on every service of a team
+func (a *Access) CanTeam(p Permission, team string) bool {
+	return a.teamSet(team).Has(p)
+}
+
+// Visible reports whether the user has any permission on a service
+func (a *Access) Visible(serviceID string) bool {
+	return len(a.Permissions(serviceID)) > 0
+}
+
+// VisibleName is Visible for a service given by name
+func (a *Access) VisibleName(service string) bool {
+	if id, ok := a.byName[service]; ok {
+		return a.Visible(id)
+	}
+	return len(a.teamSet("")) > 0
+}
+
+// Filter returns a SQL condition limiting the rows of table, an alias of a
+// table with org_id and service_id columns, to the user's organization and
+// the services the user has p on. Rows without a service count as having no
+// team. Its arguments start at $arg.
+func (a *Access) Filter(p Permission, table string, arg int) (string, []interface{}) {
+	cond := fmt.Sprintf("%s.org_id = $%d", table, arg)
+	args := []interface{}{a.OrgID}
+	if a.unlimited() {
+		return cond, args
+	}
+
+	ids := make([]string, 0)
+	for id := range a.services {
+		if a.Can(p, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	column := table + ".service_id"
+	services := fmt.Sprintf("%s::text = ANY($%d)", column, arg+1)
+	if a.teamSet("").Has(p) {
+		services = fmt.Sprintf("(%s IS NULL OR %s)", column, services)
+	}
+	return cond + " AND " + services, append(args, pq.Array(ids))
+}
+
+// Summary is the user's access as returned by the API
+type Summary struct {
+	UserID   string                  `json:"user_id"`
+	OrgID    string                  `json:"org_id"`
+	Admin    bool                    `json:"admin"`
+	Scopes   []Permission            `json:"scopes,omitempty"`
+	Default  []Permission            `json:"default"` // on services without a team
+	Teams    map[string][]Permission `json:"teams"`
+	Services map[string][]Permission `json:"services"`
+}
+
+// Summary lists the user's permissions per team and per granted service
+func (a *Access) Summary() Summary {
+	s := Summary{
+		UserID:   a.UserID,
+		OrgID:    a.OrgID,
+		Admin:    a.admin,
+		Default:  a.teamSet("").List(),
+		Teams:    make(map[string][]Permission),
+		Services: make(map[string][]Permission),
+	}
+	if a.scopes != nil {
+		s.Scopes = a.scopes.List()
+	}
+	for team, set := range a.teams {
+		if set = a.scoped(set); len(set) > 0 {
+			s.Teams[team] = set.List()
+		}
+	}
+	for id, set := range a.grants {
+		if a.foreign(id) {
+			continue
+		}
+		name := a.services[id].Name
+		if name == "" {
+			name = id
+		}
+		if set = a.scoped(set); len(set) > 0 {
+			s.Services[name] = set.List()
+		}
+	}
+	return s
+}
+
+type contextKey struct{}
+
+// lazyAccess loads the access of a request the first time it is needed
+type lazyAccess struct {
+	once   sync.Once
+	load   func() (*Access, error)
+	access *Access
+	err    error
+}
+
+// ErrNoAccess is returned when a request carries no user
+var ErrNoAccess = errors.New("request has no authenticated user")
+
+// WithAccess returns a context that loads the user's access on first use
+func (az *Authorizer) WithAccess(ctx context.Context, id Identity) context.Context {
+	lazy := &lazyAccess{load: func() (*Access, error) {
+		return az.Load(ctx, id)
+	}}
+	return context.WithValue(ctx, contextKey{}, lazy)
+}
+
+// FromContext returns the access stored by WithAccess
+func FromContext(ctx context.Context) (*Access, error) {
+	lazy, ok := ctx.Value(contextKey{}).(*lazyAccess)
+	if !ok {
+		return nil, ErrNoAccess
+	}
+	lazy.once.Do(func() {
+		lazy.access, lazy.err = lazy.load()
+	})
+	return lazy.access, lazy.err
+}
@@ -0,0 +1,263 @@
+package rbac
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestParsePermissions(t *testing.T) {
+	got, err := ParsePermissions([]string{"incident:write", " SLO:Admin "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Permission{IncidentWrite, SLOAdmin}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePermissions = %v, want %v", got, want)
+	}
+	if _, err := ParsePermissions([]string{"incident:delete"}); !errors.Is(err, ErrUnknownPermission) {
+		t.Errorf("err = %v, want ErrUnknownPermission", err)
+	}
+}
+
+func TestSetImplied(t *testing.T) {
+	tests := []struct {
+		name  string
+		perms []Permission
+		want  []Permission
+	}{
+		{"write implies read", []Permission{IncidentWrite}, []Permission{IncidentRead, IncidentWrite}},
+		{"slo admin", []Permission{SLOAdmin}, []Permission{SLOAdmin, SLORead, SLOWrite}},
+		{"rule write", []Permission{RuleWrite}, []Permission{RuleRead, RuleWrite}},
+		{"nothing implied", []Permission{TeamAdmin}, []Permission{TeamAdmin}},
+		{"empty", nil, []Permission{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewSet(tt.perms...).List(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewSet(%v) = %v, want %v", tt.perms, got, tt.want)
+			}
+		})
+	}
+}
+
+// testAccess is a user in the Payments team with a grant on api-gateway,
+// which belongs to Platform. billing belongs to another organization.
+func testAccess(roles ...string) *Access {
+	a := newAccess(Identity{UserID: "user-1", Roles: roles}, DefaultOrg)
+	for _, svc := range []Service{
+		{ID: "svc-pay", Name: "payment-service", Team: "Payments", OrgID: DefaultOrg},
+		{ID: "svc-gw", Name: "api-gateway", Team: "Platform", OrgID: DefaultOrg},
+		{ID: "svc-auth", Name: "auth-service", Team: "platform", OrgID: DefaultOrg},
+		{ID: "svc-free", Name: "sandbox", Team: "", OrgID: DefaultOrg},
+		{ID: "svc-gone", Name: "legacy", Team: "Disbanded", OrgID: DefaultOrg},
+		{ID: "svc-acme", Name: "billing", Team: "", OrgID: "acme"},
+	} {
+		a.addService(svc)
+	}
+	a.known["payments"] = true
+	a.known["platform"] = true
+	a.teams["payments"] = NewSet(IncidentWrite, SLORead)
+	a.teams["platform"] = NewSet()
+	a.grants["svc-gw"] = NewSet(IncidentRead)
+	a.grants["svc-acme"] = NewSet(IncidentWrite)
+	return a
+}
+
+func TestAccessCan(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []string
+		perm    Permission
+		service string
+		want    bool
+	}{
+		{"team member writes incidents", []string{"viewer"}, IncidentWrite, "svc-pay", true},
+		{"team member reads slos", []string{"viewer"}, SLORead, "svc-pay", true},
+		{"team member can't edit slos", []string{"viewer"}, SLOWrite, "svc-pay", false},
+		{"grant on another team's service", []string{"viewer"}, IncidentRead, "svc-gw", true},
+		{"grant is limited", []string{"viewer"}, IncidentWrite, "svc-gw", false},
+		{"other team's service", []string{"editor"}, IncidentRead, "svc-auth", false},
+		{"role applies without team", []string{"viewer"}, IncidentRead, "svc-free", true},
+		{"viewer role can't write", []string{"viewer"}, IncidentWrite, "svc-free", false},
+		{"editor role writes without team", []string{"editor"}, SLOAdmin, "svc-free", true},
+		{"team that doesn't exist", []string{"editor"}, IncidentWrite, "svc-gone", true},
+		{"unknown service", []string{"viewer"}, IncidentRead, "svc-new", true},
+		{"no roles", nil, IncidentRead, "svc-free", false},
+		{"admin", []string{"admin"}, RemediationExecute, "svc-auth", true},
+		{"other organization", []string{"editor"}, IncidentRead, "svc-acme", false},
+		{"admin of other organization", []string{"admin"}, IncidentRead, "svc-acme", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testAccess(tt.roles...).Can(tt.perm, tt.service); got != tt.want {
+				t.Errorf("Can(%s, %s) = %v, want %v", tt.perm, tt.service, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessByNameAndTeam(t *testing.T) {
+	a := testAccess("viewer")
+	if !a.CanName(IncidentWrite, "payment-service") {
+		t.Error("CanName(payment-service) = false, want true")
+	}
+	if a.CanName(IncidentRead, "auth-service") {
+		t.Error("CanName(auth-service) = true, want false")
+	}
+	if !a.CanName(IncidentRead, "billing") || a.CanName(IncidentWrite, "billing") {
+		t.Error("CanName for a name used by another organization should use the roles, not its grants")
+	}
+	if !a.CanName(IncidentRead, "brand-new-service") {
+		t.Error("CanName for a service not in the catalog should use the roles")
+	}
+	if !a.CanTeam(IncidentWrite, "PAYMENTS") {
+		t.Error("team names should match case-insensitively")
+	}
+	if a.CanTeam(TeamAdmin, "Payments") {
+		t.Error("CanTeam(team:admin) = true, want false")
+	}
+	if !a.Visible("svc-gw") || a.Visible("svc-auth") {
+		t.Errorf("Visible(svc-gw) = %v, Visible(svc-auth) = %v", a.Visible("svc-gw"), a.Visible("svc-auth"))
+	}
+	if !a.VisibleName("api-gateway") || a.VisibleName("auth-service") {
+		t.Error("VisibleName should match Visible by ID")
+	}
+}
+
+func TestAccessFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    []string
+		perm     Permission
+		wantCond string
+		wantIDs  []string
+	}{
+		{
+			name:     "viewer sees services without team",
+			roles:    []string{"viewer"},
+			perm:     IncidentRead,
+			wantCond: "i.org_id = $3 AND (i.service_id IS NULL OR i.service_id::text = ANY($4))",
+			wantIDs:  []string{"svc-free", "svc-gone", "svc-gw", "svc-pay"},
+		},
+		{
+			name:     "no roles",
+			perm:     IncidentWrite,
+			wantCond: "i.org_id = $3 AND i.service_id::text = ANY($4)",
+			wantIDs:  []string{"svc-pay"},
+		},
+		{
+			name:     "admin",
+			roles:    []string{"admin"},
+			perm:     IncidentRead,
+			wantCond: "i.org_id = $3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, args := testAccess(tt.roles...).Filter(tt.perm, "i", 3)
+			if cond != tt.wantCond {
+				t.Errorf("cond = %q, want %q", cond, tt.wantCond)
+			}
+			if len(args) == 0 || args[0] != DefaultOrg {
+				t.Fatalf("args = %v, want the organization first", args)
+			}
+			if tt.wantIDs == nil {
+				if len(args) != 1 {
+					t.Errorf("args = %v, want the organization only", args)
+				}
+				return
+			}
+			if len(args) != 2 {
+				t.Fatalf("args = %v, want the organization and one array", args)
+			}
+			ids, ok := args[1].(driver.Valuer)
+			if !ok {
+				t.Fatalf("arg %T is not a driver value", args[0])
+			}
+			got, _ := ids.Value()
+			want, _ := pq.Array(tt.wantIDs).Value()
+			if got != want {
+				t.Errorf("ids = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	s := testAccess("viewer").Summary()
+	if s.Admin || s.UserID != "user-1" || s.OrgID != DefaultOrg {
+		t.Errorf("summary = %+v", s)
+	}
+	if want := []Permission{IncidentRead, IncidentWrite, SLORead}; !reflect.DeepEqual(s.Teams["payments"], want) {
+		t.Errorf("payments = %v, want %v", s.Teams["payments"], want)
+	}
+	if want := []Permission{IncidentRead}; !reflect.DeepEqual(s.Services["api-gateway"], want) {
+		t.Errorf("api-gateway = %v, want %v", s.Services["api-gateway"], want)
+	}
+	if _, ok := s.Services["billing"]; ok {
+		t.Error("grants on services of other organizations should be left out")
+	}
+}
+
+func TestScopedAccess(t *testing.T) {
+	a := testAccess("editor")
+	a.scopes = NewSet(IncidentRead)
+	if a.Can(IncidentWrite, "svc-pay") {
+		t.Error("scopes should limit team permissions")
+	}
+	if !a.Can(IncidentRead, "svc-pay") || !a.Can(IncidentRead, "svc-gw") {
+		t.Error("scoped read should still apply to the team and the grant")
+	}
+	if a.CanTeam(TeamAdmin, "") {
+		t.Error("scopes should limit role permissions")
+	}
+
+	admin := testAccess("admin")
+	admin.scopes = NewSet(SLORead)
+	if admin.Can(IncidentRead, "svc-auth") || !admin.Can(SLORead, "svc-auth") {
+		t.Error("scopes should limit admins too")
+	}
+	if cond, _ := admin.Filter(IncidentRead, "i", 1); cond == "i.org_id = $1" {
+		t.Error("scoped admins should be filtered")
+	}
+	if got := admin.Summary().Scopes; !reflect.DeepEqual(got, []Permission{SLORead}) {
+		t.Errorf("summary scopes = %v, want [slo:read]", got)
+	}
+}
+
+func TestOrganizationValidate(t *testing.T) {
+	empty := " "
+	tests := []struct {
+		name    string
+		org     Organization
+		want    Organization
+		wantErr bool
+	}{
+		{"name defaults to id", Organization{ID: " Acme "}, Organization{ID: "acme", Name: "acme"}, false},
+		{"empty grafana org", Organization{ID: "acme", Name: "Acme", GrafanaOrgID: &empty}, Organization{ID: "acme", Name: "Acme"}, false},
+		{"leading dash", Organization{ID: "-acme"}, Organization{}, true},
+		{"underscore", Organization{ID: "acme_eu"}, Organization{}, true},
+		{"empty", Organization{}, Organization{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := tt.org
+			err := org.validate()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidOrg) {
+					t.Errorf("err = %v, want ErrInvalidOrg", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(org, tt.want) {
+				t.Errorf("org = %+v, want %+v", org, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Errors returned for rejected team changes
+var (
+	ErrInvalidTeam = errors.New("invalid team")
+	ErrTeamExists  = errors.New("team already exists")
+)
+
+// Team owns the services whose team field matches its name
+type Team struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Services    []string  `json:"services"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Member is a user's permissions on the services of a team
+type Member struct {
+	TeamID      string       `json:"team_id"`
+	UserID      string       `json:"user_id"`
+	Username    string       `json:"username,omitempty"`
+	Permissions []Permission `json:"permissions"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Grant is a user's permissions on one service
+type Grant struct {
+	ServiceID   string       `json:"service_id"`
+	UserID      string       `json:"user_id"`
+	Username    string       `json:"username,omitempty"`
+	Permissions []Permission `json:"permissions"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Authorizer loads access and stores teams, members and grants
+type Authorizer struct {
+	db *sql.DB
+}
+
+// NewAuthorizer creates an authorizer
+func NewAuthorizer(db *sql.DB) *Authorizer {
+	return &Authorizer{db: db}
+}
+
+// Load reads what a user may do. Admins without scopes need no membership
+// lookups.
+func (az *Authorizer) Load(ctx context.Context, id Identity) (*Access, error) {
+	orgID, err := az.ResolveOrg(ctx, id.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	a := newAccess(id, orgID)
+
+	// Services of all organizations, so IDs of other organizations' services
+	// allow nothing. Names are looked up within the organization.
+	rows, err := az.db.QueryContext(ctx, `SELECT id, name, COALESCE(team, ''), org_id FROM services`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load services: %w", err)
+	}
+	for rows.Next() {
+		var svc Service
+		if err := rows.Scan(&svc.ID, &svc.Name, &svc.Team, &svc.OrgID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		a.addService(svc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if a.unlimited() {
+		return a, nil
+	}
+
+	rows, err = az.db.QueryContext(ctx, `
+		SELECT lower(t.name), COALESCE(m.permissions, '[]')
+		FROM teams t
+		LEFT JOIN team_members m ON m.team_id = t.id AND m.user_id = $1
+		WHERE t.org_id = $2
+	`, id.UserID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load team memberships: %w", err)
+	}
+	for rows.Next() {
+		var team string
+		var perms []byte
+		if err := rows.Scan(&team, &perms); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		a.known[team] = true
+		a.teams[team] = NewSet(decodePermissions(perms)...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = az.db.QueryContext(ctx, `SELECT service_id, permissions FROM service_grants WHERE user_id = $1`, id.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service grants: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var serviceID string
+		var perms []byte
+		if err := rows.Scan(&serviceID, &perms); err != nil {
+			return nil, err
+		}
+		a.grants[serviceID] = NewSet(decodePermissions(perms)...)
+	}
+	return a, rows.Err()
+}
+
+// decodePermissions reads a stored permission list, dropping names that are
+// no longer known
+func decodePermissions(data []byte) []Permission {
+	var names []string
+	json.Unmarshal(data, &names)
+	var perms []Permission
+	for _, name := range names {
+		if p, err := ParsePermissions([]string{name}); err == nil {
+			perms = append(perms, p...)
+		}
+	}
+	return perms
+}
+
+// ListTeams returns the teams of an organization with the names of their
+// services
+func (az *Authorizer) ListTeams(ctx context.Context, orgID string) ([]Team, error) {
+	return az.queryTeams(ctx, orgID, "")
+}
+
+// GetTeam returns one team of an organization, or sql.ErrNoRows
+func (az *Authorizer) GetTeam(ctx context.Context, orgID, id string) (*Team, error) {
+	teams, err := az.queryTeams(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &teams[0], nil
+}
+
+func (az *Authorizer) queryTeams(ctx context.Context, orgID, id string) ([]Team, error) {
+	rows, err := az.db.QueryContext(ctx, `
+		SELECT t.id, t.org_id, t.name, t.description, t.created_at,
+		       COALESCE(json_agg(s.name ORDER BY s.name) FILTER (WHERE s.id IS NOT NULL), '[]')
+		FROM teams t
+		LEFT JOIN services s ON lower(s.team) = lower(t.name) AND s.org_id = t.org_id
+		WHERE t.org_id = $1 AND ($2 = '' OR t.id::text = $2)
+		GROUP BY t.id
+		ORDER BY t.name
+	`, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := make([]Team, 0)
+	for rows.Next() {
+		var t Team
+		var services []byte
+		if err := rows.Scan(&t.ID, &t.OrgID, &t.Name, &t.Description, &t.CreatedAt, &services); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(services, &t.Services)
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+// CreateTeam stores a new team in t.OrgID
+func (az *Authorizer) CreateTeam(ctx context.Context, t *Team) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTeam)
+	}
+	err := az.db.QueryRowContext(ctx, `
+		INSERT INTO teams (org_id, name, description) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+	`, t.OrgID, t.Name, t.Description).Scan(&t.ID, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", ErrTeamExists, t.Name)
+	}
+	if t.Services == nil {
+		t.Services = []string{}
+	}
+	return err
+}
+
+// DeleteTeam deletes a team of an organization and its memberships. Its
+// services keep their team name and fall back to the global roles until the
+// team is created again.
+func (az *Authorizer) DeleteTeam(ctx context.Context, orgID, id string) error {
+	result, err := az.db.ExecContext(ctx, `DELETE FROM teams WHERE id::text = $1 AND org_id = $2`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Members lists the members of a team
+func (az *Authorizer) Members(ctx context.Context, teamID string) ([]Member, error) {
+	rows, err := az.db.QueryContext(ctx, `
+		SELECT m.team_id, m.user_id, COALESCE(u.username, ''), m.permissions, m.updated_at
+		FROM team_members m
+		LEFT JOIN users u ON u.id::text = m.user_id
+		WHERE m.team_id = $1
+		ORDER BY m.user_id
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]Member, 0)
+	for rows.Next() {
+		var m Member
+		var perms []byte
+		if err := rows.Scan(&m.TeamID, &m.UserID, &m.Username, &perms, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		m.Permissions = decodePermissions(perms)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SetMember adds a user to a team or replaces their permissions
+func (az *Authorizer) SetMember(ctx context.Context, teamID, userID string, perms []Permission) (*Member, error) {
+	data, _ := json.Marshal(perms)
+	m := Member{TeamID: teamID, UserID: userID, Permissions: perms}
+	err := az.db.QueryRowContext(ctx, `
+		INSERT INTO team_members (team_id, user_id, permissions) VALUES ($1, $2, $3::jsonb)
+		ON CONFLICT (team_id, user_id) DO UPDATE SET permissions = EXCLUDED.permissions
+		RETURNING updated_at
+	`, teamID, userID, string(data)).Scan(&m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// RemoveMember removes a user from a team, or returns sql.ErrNoRows
+func (az *Authorizer) RemoveMember(ctx context.Context, teamID, userID string) error {
+	result, err := az.db.ExecContext(ctx, `DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Grants lists the grants on a service
+func (az *Authorizer) Grants(ctx context.Context, serviceID string) ([]Grant, error) {
+	rows, err := az.db.QueryContext(ctx, `
+		SELECT g.service_id, g.user_id, COALESCE(u.username, ''), g.permissions, g.updated_at
+		FROM service_grants g
+		LEFT JOIN users u ON u.id::text = g.user_id
+		WHERE g.service_id = $1
+		ORDER BY g.user_id
+	`, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := make([]Grant, 0)
+	for rows.Next() {
+		var g Grant
+		var perms []byte
+		if err := rows.Scan(&g.ServiceID, &g.UserID, &g.Username, &perms, &g.UpdatedAt); err != nil {
+			return nil, err
+		}
+		g.Permissions = decodePermissions(perms)
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// SetGrant grants a user permissions on a service, replacing earlier ones
+func (az *Authorizer) SetGrant(ctx context.Context, serviceID, userID string, perms []Permission) (*Grant, error) {
+	data, _ := json.Marshal(perms)
+	g := Grant{ServiceID: serviceID, UserID: userID, Permissions: perms}
+	err := az.db.QueryRowContext(ctx, `
+		INSERT INTO service_grants (service_id, user_id, permissions) VALUES ($1, $2, $3::jsonb)
+		ON CONFLICT (service_id, user_id) DO UPDATE SET permissions = EXCLUDED.permissions
+		RETURNING updated_at
+	`, serviceID, userID, string(data)).Scan(&g.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// RemoveGrant revokes a user's grant on a service, or returns sql.ErrNoRows
+func (az *Authorizer) RemoveGrant(ctx context.Context, serviceID, userID string) error {
+	result, err := az.db.ExecContext(ctx, `DELETE FROM service_grants WHERE service_id = $1 AND user_id = $2`, serviceID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Generator loads SLO history and incidents and builds reports
+type Generator struct {
+	db *sql.DB
+}
+
+// NewGenerator creates a report generator
+func NewGenerator(db *sql.DB) *Generator {
+	return &Generator{db: db}
+}
+
+// StoredReport describes a stored report without its content
+type StoredReport struct {
+	ID          string    `json:"id"`
+	Period      Period    `json:"period"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Summary     Summary   `json:"summary"`
+}
+
+// Generate builds the report for the period of an organization, for all its
+// services or one service. include selects the services by ID; nil includes
+// them all.
+func (g *Generator) Generate(ctx context.Context, orgID string, p Period, service string, include func(serviceID string) bool) (*Report, error) {
+	rows, err := g.db.QueryContext(ctx, `
+		SELECT s.id, sv.id, sv.name, s.name, s.objective
+		FROM slos s
+		JOIN services sv ON sv.id = s.service_id
+		WHERE sv.org_id = $2 AND ($1 = '' OR sv.name = $1)
+		ORDER BY sv.name, s.name
+	`, service, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLOs: %w", err)
+	}
+	var inputs []SLOInput
+	serviceIDs := make(map[string]string)
+	for rows.Next() {
+		var in SLOInput
+		var serviceID string
+		if err := rows.Scan(&in.SLOID, &serviceID, &in.Service, &in.Name, &in.Target); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan SLO: %w", err)
+		}
+		if include != nil && !include(serviceID) {
+			continue
+		}
+		serviceIDs[in.SLOID] = serviceID
+		inputs = append(inputs, in)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	incidents := make(map[string][]IncidentWindow)
+	for i := range inputs {
+		in := &inputs[i]
+		if in.Samples, err = g.loadSamples(ctx, in.SLOID, p); err != nil {
+			return nil, err
+		}
+		serviceID := serviceIDs[in.SLOID]
+		if _, ok := incidents[serviceID]; !ok {
+			if incidents[serviceID], err = g.loadIncidents(ctx, orgID, serviceID, p); err != nil {
+				return nil, err
+			}
+		}
+		in.Incidents = incidents[serviceID]
+	}
+
+	return Build(p, inputs, time.Now().UTC()), nil
+}
+
+// resolutionSteps is how long a rolled up sample covers
+var resolutionSteps = map[string]time.Duration{"hourly": time.Hour, "daily": 24 * time.Hour}
+
+// loadSamples uses the finest resolution stored for each point in time and
+// includes samples from before the period so its start is covered
+func (g *Generator) loadSamples(ctx context.Context, sloID string, p Period) ([]Sample, error) {
+	rows, err := g.db.QueryContext(ctx, `
+		SELECT timestamp, value, resolution
+		FROM slo_history
+		WHERE slo_id = $1 AND timestamp >= $2 AND timestamp < $3
+		  AND (resolution = 'raw' OR timestamp < COALESCE(
+		          (SELECT MIN(timestamp) FROM slo_history WHERE slo_id = $1 AND resolution = 'raw'), 'infinity'))
+		  AND (resolution != 'daily' OR timestamp < COALESCE(
+		          (SELECT MIN(timestamp) FROM slo_history WHERE slo_id = $1 AND resolution = 'hourly'), 'infinity'))
+		ORDER BY timestamp
+	`, sloID, p.Start.Add(-24*time.Hour), p.End)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLO history: %w", err)
+	}
+	defer rows.Close()
+
+	var samples []Sample
+	for rows.Next() {
+		var s Sample
+		var resolution string
+		if err := rows.Scan(&s.Timestamp, &s.Value, &resolution); err != nil {
+			return nil, fmt.Errorf("failed to scan SLO history: %w", err)
+		}
+		s.Step = resolutionSteps[resolution]
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+func (g *Generator) loadIncidents(ctx context.Context, orgID, serviceID string, p Period) ([]IncidentWindow, error) {
+	rows, err := g.db.QueryContext(ctx, `
+		SELECT i.id, i.title, i.severity, i.started_at, i.resolved_at
+		FROM incidents i
+		WHERE i.org_id = $4
+		  AND (i.service_id = $1 OR EXISTS (
+		           SELECT 1 FROM incident_services isv WHERE isv.incident_id = i.id AND isv.service_id = $1))
+		  AND i.started_at < $3
+		  AND (i.resolved_at IS NULL OR i.resolved_at >= $2)
+		ORDER BY i.started_at
+	`, serviceID, p.Start, p.End, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incidents: %w", err)
+	}
+	defer rows.Close()
+
+	var incidents []IncidentWindow
+	for rows.Next() {
+		var inc IncidentWindow
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&inc.ID, &inc.Title, &inc.Severity, &inc.StartedAt, &resolvedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan incident: %w", err)
+		}
+		if resolvedAt.Valid {
+			inc.ResolvedAt = &resolvedAt.Time
+		}
+		incidents = append(incidents, inc)
+	}
+	return incidents, rows.Err()
+}
+
+// Store saves a report of an organization, replacing an earlier report for
+// the same period
+func (g *Generator) Store(ctx context.Context, orgID string, r *Report) error {
+	content, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return g.db.QueryRowContext(ctx, `
+		INSERT INTO slo_reports (org_id, period_label, period_start, period_end, generated_at, report)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (org_id, period_start, period_end) DO UPDATE
+		SET period_label = EXCLUDED.period_label, generated_at = EXCLUDED.generated_at, report = EXCLUDED.report
+		RETURNING id
+	`, orgID, r.Period.Label, r.Period.Start, r.Period.End, r.GeneratedAt, content).Scan(&r.ID)
+}
+
+// Stored reports whether an organization has a report for the period
+func (g *Generator) Stored(ctx context.Context, orgID string, p Period) (bool, error) {
+	var exists bool
+	err := g.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM slo_reports WHERE org_id = $1 AND period_start = $2 AND period_end = $3)
+	`, orgID, p.Start, p.End).Scan(&exists)
+	return exists, err
+}
+
+// orgs returns the IDs of all organizations
+func (g *Generator) orgs(ctx context.Context) ([]string, error) {
+	rows, err := g.db.QueryContext(ctx, `SELECT id FROM organizations ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ListStored returns the stored reports of an organization, newest period
+// first
+func (g *Generator) ListStored(ctx context.Context, orgID string) ([]StoredReport, error) {
+	rows, err := g.db.QueryContext(ctx, `
+		SELECT id, period_label, period_start, period_end, generated_at, report->'summary'
+		FROM slo_reports
+		WHERE org_id = $1
+		ORDER BY period_start DESC, period_end DESC
+	`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored reports: %w", err)
+	}
+	defer rows.Close()
+
+	stored := []StoredReport{}
+	for rows.Next() {
+		var s StoredReport
+		var summary []byte
+		if err := rows.Scan(&s.ID, &s.Period.Label, &s.Period.Start, &s.Period.End, &s.GeneratedAt, &summary); err != nil {
+			return nil, fmt.Errorf("failed to scan stored report: %w", err)
+		}
+		_ = json.Unmarshal(summary, &s.Summary)
+		stored = append(stored, s)
+	}
+	return stored, rows.Err()
+}
+
+// GetStored returns a stored report of an organization, or sql.ErrNoRows
+func (g *Generator) GetStored(ctx context.Context, orgID, id string) (*Report, error) {
+	var content []byte
+	if err := g.db.QueryRowContext(ctx, `SELECT report FROM slo_reports WHERE id::text = $1 AND org_id = $2`, id, orgID).Scan(&content); err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(content, &r); err != nil {
+		return nil, fmt.Errorf("failed to decode stored report: %w", err)
+	}
+	r.ID = id
+	return &r, nil
+}
@@ -0,0 +1,175 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Output formats
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+)
+
+// ErrUnknownFormat is returned for unsupported output formats
+var ErrUnknownFormat = errors.New("unknown report format")
+
+// Render encodes the report and returns the content type of the result. "md"
+// is accepted as an alias for markdown.
+func Render(r *Report, format string) ([]byte, string, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		data, err := json.MarshalIndent(r, "", "  ")
+		return data, "application/json", err
+	case FormatMarkdown, "md":
+		return Markdown(r), "text/markdown; charset=utf-8", nil
+	case FormatCSV:
+		data, err := CSV(r)
+		return data, "text/csv; charset=utf-8", err
+	default:
+		return nil, "", fmt.Errorf("%w %q (use json, markdown or csv)", ErrUnknownFormat, format)
+	}
+}
+
+// FileExtension returns the file extension for a format
+func FileExtension(format string) string {
+	switch strings.ToLower(format) {
+	case FormatMarkdown, "md":
+		return "md"
+	case FormatCSV:
+		return "csv"
+	default:
+		return "json"
+	}
+}
+
+func percent(v *float64) string {
+	if v == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f%%", *v)
+}
+
+func budget(v *float64) string {
+	if v == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", *v)
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws remaining budget (clamped to 0-100) as block characters
+func sparkline(points []BurnDownPoint) string {
+	var b strings.Builder
+	for _, p := range points {
+		v := p.BudgetRemaining
+		if v < 0 {
+			v = 0
+		} else if v > 100 {
+			v = 100
+		}
+		b.WriteRune(sparkBlocks[int(v/100*float64(len(sparkBlocks)-1)+0.5)])
+	}
+	return b.String()
+}
+
+// Markdown renders the report for humans
+func Markdown(r *Report) []byte {
+	var b bytes.Buffer
+	last := r.Period.End.AddDate(0, 0, -1)
+	fmt.Fprintf(&b, "# SLO Compliance Report: %s\n\n", r.Period.Label)
+	fmt.Fprintf(&b, "Period: %s to %s (UTC). Generated %s.\n\n",
+		r.Period.Start.Format("2006-01-02"), last.Format("2006-01-02"), r.GeneratedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "**%d SLOs:** %d met, %d missed, %d without data.\n",
+		r.Summary.SLOs, r.Summary.Met, r.Summary.Missed, r.Summary.NoData)
+
+	for _, svc := range r.Services {
+		fmt.Fprintf(&b, "\n## %s\n\n", svc.Service)
+		b.WriteString("| SLO | Target | Attainment | Budget consumed | Status | Coverage | Budget burn-down |\n")
+		b.WriteString("|-----|-------:|-----------:|----------------:|--------|---------:|------------------|\n")
+		for _, slo := range svc.SLOs {
+			status := map[string]string{StatusMet: "met", StatusMissed: "**missed**", StatusNoData: "no data"}[slo.Status]
+			fmt.Fprintf(&b, "| %s | %.3f%% | %s | %s | %s | %.0f%% | %s |\n",
+				slo.Name, slo.Target, percent(slo.Attainment), budget(slo.BudgetConsumed), status, slo.Coverage, sparkline(slo.BurnDown))
+		}
+
+		var rows []string
+		for _, slo := range svc.SLOs {
+			for _, inc := range slo.Incidents {
+				rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s | %.1f%% | %.1f%% |",
+					slo.Name, strings.ReplaceAll(inc.Title, "|", "\\|"), inc.Severity,
+					inc.StartedAt.UTC().Format("2006-01-02 15:04"), inc.BudgetConsumed, inc.Share))
+			}
+		}
+		if len(rows) > 0 {
+			b.WriteString("\n### Incidents that consumed budget\n\n")
+			b.WriteString("| SLO | Incident | Severity | Started (UTC) | Budget consumed | Share |\n")
+			b.WriteString("|-----|----------|----------|---------------|----------------:|------:|\n")
+			b.WriteString(strings.Join(rows, "\n"))
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes()
+}
+
+func optional(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.6f", *v)
+}
+
+// CSV renders one row per SLO, burn-down point and incident. The record column
+// tells the row types apart.
+func CSV(r *Report) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	header := []string{
+		"record", "period", "service", "slo", "target", "attainment", "budget_consumed", "budget_remaining",
+		"status", "coverage", "date", "incident_id", "incident_title", "severity", "started_at", "share",
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, svc := range r.Services {
+		for _, slo := range svc.SLOs {
+			row := func(record string) []string {
+				return []string{record, r.Period.Label, svc.Service, slo.Name, fmt.Sprintf("%g", slo.Target),
+					"", "", "", "", "", "", "", "", "", "", ""}
+			}
+
+			sloRow := row("slo")
+			sloRow[5], sloRow[6], sloRow[7] = optional(slo.Attainment), optional(slo.BudgetConsumed), optional(slo.BudgetRemaining)
+			sloRow[8], sloRow[9] = slo.Status, fmt.Sprintf("%.2f", slo.Coverage)
+			if err := w.Write(sloRow); err != nil {
+				return nil, err
+			}
+
+			for _, p := range slo.BurnDown {
+				bd := row("burn_down")
+				bd[7], bd[10] = fmt.Sprintf("%.6f", p.BudgetRemaining), p.Date.UTC().Format("2006-01-02")
+				if err := w.Write(bd); err != nil {
+					return nil, err
+				}
+			}
+
+			for _, inc := range slo.Incidents {
+				ir := row("incident")
+				ir[6], ir[11], ir[12], ir[13] = fmt.Sprintf("%.6f", inc.BudgetConsumed), inc.IncidentID, inc.Title, inc.Severity
+				ir[14], ir[15] = inc.StartedAt.UTC().Format(time.RFC3339), fmt.Sprintf("%.2f", inc.Share)
+				if err := w.Write(ir); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
@@ -0,0 +1,371 @@
+// Package reports builds SLO compliance reports for calendar periods from the
+// stored SLO history and incidents.
+package reports
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxSampleGap bounds how long one SLO history sample is assumed to hold. Time
+// not covered by samples lowers the coverage of the report.
+const maxSampleGap = 30 * time.Minute
+
+// Period is a half-open time range [Start, End)
+type Period struct {
+	Label string    `json:"label"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// MonthPeriod returns the calendar month in UTC
+func MonthPeriod(year int, month time.Month) Period {
+	start := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return Period{Label: start.Format("2006-01"), Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+// QuarterPeriod returns the calendar quarter (1-4) in UTC
+func QuarterPeriod(year, quarter int) Period {
+	start := time.Date(year, time.Month((quarter-1)*3+1), 1, 0, 0, 0, 0, time.UTC)
+	return Period{Label: fmt.Sprintf("%d-Q%d", year, quarter), Start: start, End: start.AddDate(0, 3, 0)}
+}
+
+// LastCompletedMonth returns the most recent month that ended before now
+func LastCompletedMonth(now time.Time) Period {
+	first := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	return MonthPeriod(first.Year(), first.Month())
+}
+
+// LastCompletedQuarter returns the most recent quarter that ended before now
+func LastCompletedQuarter(now time.Time) Period {
+	q := (int(now.UTC().Month())-1)/3 + 1
+	year := now.UTC().Year()
+	if q == 1 {
+		return QuarterPeriod(year-1, 4)
+	}
+	return QuarterPeriod(year, q-1)
+}
+
+var (
+	monthPattern   = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+	quarterPattern = regexp.MustCompile(`^(\d{4})-[Qq]([1-4])$`)
+)
+
+// ParsePeriod parses a month (2026-09), a quarter (2026-Q3) or a custom range
+// given as two dates (from and to, inclusive)
+func ParsePeriod(period, from, to string) (Period, error) {
+	if period != "" {
+		if m := monthPattern.FindStringSubmatch(period); m != nil {
+			year, _ := strconv.Atoi(m[1])
+			month, _ := strconv.Atoi(m[2])
+			if month < 1 || month > 12 {
+				return Period{}, fmt.Errorf("invalid month %q", period)
+			}
+			return MonthPeriod(year, time.Month(month)), nil
+		}
+		if m := quarterPattern.FindStringSubmatch(period); m != nil {
+			year, _ := strconv.Atoi(m[1])
+			quarter, _ := strconv.Atoi(m[2])
+			return QuarterPeriod(year, quarter), nil
+		}
+		return Period{}, fmt.Errorf("invalid period %q (expected e.g. 2026-09 or 2026-Q3)", period)
+	}
+
+	if from == "" || to == "" {
+		return Period{}, fmt.Errorf("period or from and to are required")
+	}
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid from date %q", from)
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid to date %q", to)
+	}
+	if end.Before(start) {
+		return Period{}, fmt.Errorf("to must not be before from")
+	}
+	return Period{Label: from + ".." + to, Start: start, End: end.AddDate(0, 0, 1)}, nil
+}
+
+// Sample is one stored SLI value (percent of good events). Rolled up samples
+// cover Step from their timestamp.
+type Sample struct {
+	Timestamp time.Time
+	Value     float64
+	Step      time.Duration
+}
+
+// IncidentWindow is an incident of the SLO's service
+type IncidentWindow struct {
+	ID         string
+	Title      string
+	Severity   string
+	StartedAt  time.Time
+	ResolvedAt *time.Time
+}
+
+// SLOInput is everything needed to report on one SLO
+type SLOInput struct {
+	SLOID     string
+	Service   string
+	Name      string
+	Target    float64
+	Samples   []Sample
+	Incidents []IncidentWindow
+}
+
+// Report is a compliance report for one period
+type Report struct {
+	ID          string          `json:"id,omitempty"`
+	Period      Period          `json:"period"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Summary     Summary         `json:"summary"`
+	Services    []ServiceReport `json:"services"`
+}
+
+// Summary counts SLOs by outcome
+type Summary struct {
+	SLOs   int `json:"slos"`
+	Met    int `json:"met"`
+	Missed int `json:"missed"`
+	NoData int `json:"no_data"`
+}
+
+type ServiceReport struct {
+	Service string      `json:"service"`
+	SLOs    []SLOReport `json:"slos"`
+}
+
+// SLO outcomes
+const (
+	StatusMet    = "met"
+	StatusMissed = "missed"
+	StatusNoData = "no_data"
+)
+
+// SLOReport is the outcome of one SLO over the period. Budget figures are
+// percentages of the period's error budget.
+type SLOReport struct {
+	SLOID           string           `json:"slo_id"`
+	Name            string           `json:"name"`
+	Target          float64          `json:"target"`
+	Attainment      *float64         `json:"attainment"`
+	BudgetConsumed  *float64         `json:"budget_consumed"`
+	BudgetRemaining *float64         `json:"budget_remaining"`
+	Status          string           `json:"status"`
+	Samples         int              `json:"samples"`
+	Coverage        float64          `json:"coverage"` // percent of the period covered by samples
+	BurnDown        []BurnDownPoint  `json:"burn_down"`
+	Incidents       []IncidentImpact `json:"incidents"`
+}
+
+// BurnDownPoint is the remaining budget at the end of a day
+type BurnDownPoint struct {
+	Date            time.Time `json:"date"`
+	BudgetRemaining float64   `json:"budget_remaining"`
+}
+
+// IncidentImpact is the budget consumed while an incident was open
+type IncidentImpact struct {
+	IncidentID     string     `json:"incident_id"`
+	Title          string     `json:"title"`
+	Severity       string     `json:"severity"`
+	StartedAt      time.Time  `json:"started_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	BudgetConsumed float64    `json:"budget_consumed"`
+	Share          float64    `json:"share"` // percent of the budget consumed in the period
+}
+
+// segment is the time a sample holds, clipped to the period
+type segment struct {
+	start, end time.Time
+	value      float64
+}
+
+func (s segment) errorWithin(from, to time.Time) float64 {
+	if from.Before(s.start) {
+		from = s.start
+	}
+	if to.After(s.end) {
+		to = s.end
+	}
+	if !to.After(from) {
+		return 0
+	}
+	errPct := 100 - s.value
+	if errPct < 0 {
+		errPct = 0
+	}
+	return errPct * to.Sub(from).Seconds()
+}
+
+func segments(samples []Sample, p Period) []segment {
+	sorted := append([]Sample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	var segs []segment
+	for i, s := range sorted {
+		hold := maxSampleGap
+		if s.Step > hold {
+			hold = s.Step
+		}
+		end := s.Timestamp.Add(hold)
+		if i+1 < len(sorted) && sorted[i+1].Timestamp.Before(end) {
+			end = sorted[i+1].Timestamp
+		}
+		start := s.Timestamp
+		if start.Before(p.Start) {
+			start = p.Start
+		}
+		if end.After(p.End) {
+			end = p.End
+		}
+		if end.After(start) {
+			segs = append(segs, segment{start: start, end: end, value: s.Value})
+		}
+	}
+	return segs
+}
+
+// BuildSLOReport computes attainment, budget, burn-down and incident impact.
+// Attainment is time weighted over the covered part of the period.
+func BuildSLOReport(in SLOInput, p Period) SLOReport {
+	r := SLOReport{
+		SLOID:     in.SLOID,
+		Name:      in.Name,
+		Target:    in.Target,
+		Status:    StatusNoData,
+		BurnDown:  []BurnDownPoint{},
+		Incidents: []IncidentImpact{},
+	}
+	segs := segments(in.Samples, p)
+	if len(segs) == 0 {
+		return r
+	}
+
+	var covered, weighted, errorTime float64
+	for _, s := range segs {
+		d := s.end.Sub(s.start).Seconds()
+		covered += d
+		weighted += s.value * d
+		errorTime += s.errorWithin(s.start, s.end)
+	}
+	for _, s := range in.Samples {
+		if !s.Timestamp.Before(p.Start) && s.Timestamp.Before(p.End) {
+			r.Samples++
+		}
+	}
+	attainment := weighted / covered
+	r.Attainment = &attainment
+	r.Coverage = covered / p.End.Sub(p.Start).Seconds() * 100
+	r.Status = StatusMissed
+	if attainment >= in.Target {
+		r.Status = StatusMet
+	}
+
+	// Budget of the covered time, in percent-seconds
+	budget := (100 - in.Target) * covered
+	if budget <= 0 {
+		return r
+	}
+	consumed := errorTime / budget * 100
+	remaining := 100 - consumed
+	r.BudgetConsumed = &consumed
+	r.BudgetRemaining = &remaining
+
+	errorBefore := func(t time.Time) float64 {
+		var sum float64
+		for _, s := range segs {
+			sum += s.errorWithin(p.Start, t)
+		}
+		return sum
+	}
+	for day := p.Start.AddDate(0, 0, 1); ; day = day.AddDate(0, 0, 1) {
+		if day.After(p.End) {
+			day = p.End
+		}
+		r.BurnDown = append(r.BurnDown, BurnDownPoint{Date: day, BudgetRemaining: 100 - errorBefore(day)/budget*100})
+		if !day.Before(p.End) {
+			break
+		}
+	}
+
+	for _, inc := range in.Incidents {
+		end := p.End
+		if inc.ResolvedAt != nil && inc.ResolvedAt.Before(end) {
+			end = *inc.ResolvedAt
+		}
+		var incError float64
+		for _, s := range segs {
+			incError += s.errorWithin(inc.StartedAt, end)
+		}
+		if incError <= 0 {
+			continue
+		}
+		r.Incidents = append(r.Incidents, IncidentImpact{
+			IncidentID:     inc.ID,
+			Title:          inc.Title,
+			Severity:       inc.Severity,
+			StartedAt:      inc.StartedAt,
+			ResolvedAt:     inc.ResolvedAt,
+			BudgetConsumed: incError / budget * 100,
+			Share:          incError / errorTime * 100,
+		})
+	}
+	sort.Slice(r.Incidents, func(i, j int) bool { return r.Incidents[i].Share > r.Incidents[j].Share })
+	return r
+}
+
+// Only returns a copy of the report limited to the services keep accepts,
+// with the summary counting their SLOs
+func (r *Report) Only(keep func(service string) bool) *Report {
+	limited := *r
+	limited.Summary = Summary{}
+	limited.Services = []ServiceReport{}
+	for _, svc := range r.Services {
+		if !keep(svc.Service) {
+			continue
+		}
+		limited.Services = append(limited.Services, svc)
+		for _, slo := range svc.SLOs {
+			limited.Summary.count(slo.Status)
+		}
+	}
+	return &limited
+}
+
+// count adds an SLO with the status to the summary
+func (s *Summary) count(status string) {
+	s.SLOs++
+	switch status {
+	case StatusMet:
+		s.Met++
+	case StatusMissed:
+		s.Missed++
+	default:
+		s.NoData++
+	}
+}
+
+// Build assembles a report from the inputs of all SLOs, grouped by service
+func Build(p Period, inputs []SLOInput, now time.Time) *Report {
+	report := &Report{Period: p, GeneratedAt: now, Services: []ServiceReport{}}
+	index := make(map[string]int)
+	for _, in := range inputs {
+		slo := BuildSLOReport(in, p)
+		i, ok := index[in.Service]
+		if !ok {
+			i = len(report.Services)
+			index[in.Service] = i
+			report.Services = append(report.Services, ServiceReport{Service: in.Service})
+		}
+		report.Services[i].SLOs = append(report.Services[i].SLOs, slo)
+		report.Summary.count(slo.Status)
+	}
+	sort.Slice(report.Services, func(i, j int) bool { return report.Services[i].Service < report.Services[j].Service })
+	return report
+}
@@ -0,0 +1,255 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		period    string
+		from, to  string
+		wantLabel string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{name: "month", period: "2026-09", wantLabel: "2026-09", wantStart: "2026-09-01", wantEnd: "2026-10-01"},
+		{name: "december", period: "2025-12", wantLabel: "2025-12", wantStart: "2025-12-01", wantEnd: "2026-01-01"},
+		{name: "quarter", period: "2026-q3", wantLabel: "2026-Q3", wantStart: "2026-07-01", wantEnd: "2026-10-01"},
+		{name: "range", from: "2026-09-10", to: "2026-09-12", wantLabel: "2026-09-10..2026-09-12", wantStart: "2026-09-10", wantEnd: "2026-09-13"},
+		{name: "invalid month", period: "2026-13", wantErr: true},
+		{name: "invalid quarter", period: "2026-Q5", wantErr: true},
+		{name: "missing to", from: "2026-09-10", wantErr: true},
+		{name: "reversed range", from: "2026-09-12", to: "2026-09-10", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePeriod(tt.period, tt.from, tt.to)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParsePeriod() = %+v, want error", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePeriod() error = %v", err)
+			}
+			if p.Label != tt.wantLabel || p.Start.Format("2006-01-02") != tt.wantStart || p.End.Format("2006-01-02") != tt.wantEnd {
+				t.Errorf("ParsePeriod() = %s [%s, %s), want %s [%s, %s)", p.Label,
+					p.Start.Format("2006-01-02"), p.End.Format("2006-01-02"), tt.wantLabel, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestLastCompletedPeriods(t *testing.T) {
+	tests := []struct {
+		now         time.Time
+		wantMonth   string
+		wantQuarter string
+	}{
+		{time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), "2026-09", "2026-Q3"},
+		{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), "2025-12", "2025-Q4"},
+		{time.Date(2026, 6, 30, 23, 0, 0, 0, time.UTC), "2026-05", "2026-Q1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.now.Format("2006-01-02"), func(t *testing.T) {
+			if got := LastCompletedMonth(tt.now).Label; got != tt.wantMonth {
+				t.Errorf("LastCompletedMonth() = %s, want %s", got, tt.wantMonth)
+			}
+			if got := LastCompletedQuarter(tt.now).Label; got != tt.wantQuarter {
+				t.Errorf("LastCompletedQuarter() = %s, want %s", got, tt.wantQuarter)
+			}
+		})
+	}
+}
+
+// samples returns samples every 30 minutes over the period, with value bad
+// during [badFrom, badTo) and good otherwise
+func samples(p Period, good, bad float64, badFrom, badTo time.Time) []Sample {
+	var out []Sample
+	for ts := p.Start; ts.Before(p.End); ts = ts.Add(30 * time.Minute) {
+		v := good
+		if !ts.Before(badFrom) && ts.Before(badTo) {
+			v = bad
+		}
+		out = append(out, Sample{Timestamp: ts, Value: v})
+	}
+	return out
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestBuildSLOReport(t *testing.T) {
+	p, _ := ParsePeriod("", "2026-09-01", "2026-09-10")
+	badFrom := time.Date(2026, 9, 5, 0, 0, 0, 0, time.UTC)
+	badTo := badFrom.Add(12 * time.Hour)
+	resolved := badFrom.Add(6 * time.Hour)
+
+	in := SLOInput{
+		SLOID:   "slo-1",
+		Service: "checkout",
+		Name:    "availability",
+		Target:  99,
+		// 12h at 90% out of 240h: 12*10 / (240*1) = 50% of the budget
+		Samples: samples(p, 100, 90, badFrom, badTo),
+		Incidents: []IncidentWindow{
+			{ID: "inc-1", Title: "Checkout errors", Severity: "high", StartedAt: badFrom, ResolvedAt: &resolved},
+			{ID: "inc-2", Title: "Unrelated", Severity: "low", StartedAt: p.Start, ResolvedAt: &badFrom},
+		},
+	}
+
+	r := BuildSLOReport(in, p)
+
+	if r.Status != StatusMet {
+		t.Errorf("Status = %s, want %s", r.Status, StatusMet)
+	}
+	if r.Attainment == nil || !approx(*r.Attainment, 99.5) {
+		t.Errorf("Attainment = %v, want 99.5", r.Attainment)
+	}
+	if r.BudgetConsumed == nil || !approx(*r.BudgetConsumed, 50) {
+		t.Errorf("BudgetConsumed = %v, want 50", r.BudgetConsumed)
+	}
+	if !approx(r.Coverage, 100) {
+		t.Errorf("Coverage = %v, want 100", r.Coverage)
+	}
+	if r.Samples != 480 {
+		t.Errorf("Samples = %d, want 480", r.Samples)
+	}
+	if len(r.BurnDown) != 10 {
+		t.Fatalf("len(BurnDown) = %d, want 10", len(r.BurnDown))
+	}
+	if got := r.BurnDown[3].BudgetRemaining; !approx(got, 100) {
+		t.Errorf("BurnDown[3] = %v, want 100", got)
+	}
+	if got := r.BurnDown[9].BudgetRemaining; !approx(got, 50) {
+		t.Errorf("BurnDown[9] = %v, want 50", got)
+	}
+	if len(r.Incidents) != 1 {
+		t.Fatalf("len(Incidents) = %d, want 1", len(r.Incidents))
+	}
+	if inc := r.Incidents[0]; inc.IncidentID != "inc-1" || !approx(inc.Share, 50) || !approx(inc.BudgetConsumed, 25) {
+		t.Errorf("Incidents[0] = %+v, want inc-1 with 50%% share and 25%% budget", inc)
+	}
+}
+
+func TestBuildSLOReportNoData(t *testing.T) {
+	p := MonthPeriod(2026, time.September)
+	r := BuildSLOReport(SLOInput{Name: "latency", Target: 99}, p)
+	if r.Status != StatusNoData || r.Attainment != nil || r.BudgetConsumed != nil {
+		t.Errorf("BuildSLOReport() = %+v, want no data", r)
+	}
+}
+
+func TestBuildSLOReportRolledUp(t *testing.T) {
+	p, _ := ParsePeriod("", "2026-09-01", "2026-09-02")
+	in := SLOInput{Name: "availability", Target: 99}
+	for ts := p.Start; ts.Before(p.End); ts = ts.Add(time.Hour) {
+		in.Samples = append(in.Samples, Sample{Timestamp: ts, Value: 99.5, Step: time.Hour})
+	}
+
+	r := BuildSLOReport(in, p)
+
+	if !approx(r.Coverage, 100) {
+		t.Errorf("Coverage = %v, want 100", r.Coverage)
+	}
+	if r.BudgetConsumed == nil || !approx(*r.BudgetConsumed, 50) {
+		t.Errorf("BudgetConsumed = %v, want 50", r.BudgetConsumed)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	p, _ := ParsePeriod("", "2026-09-01", "2026-09-01")
+	inputs := []SLOInput{
+		{Service: "payments", Name: "availability", Target: 99, Samples: samples(p, 98, 98, p.Start, p.Start)},
+		{Service: "checkout", Name: "availability", Target: 99, Samples: samples(p, 100, 100, p.Start, p.Start)},
+		{Service: "checkout", Name: "latency", Target: 95},
+	}
+
+	r := Build(p, inputs, p.End)
+
+	if want := (Summary{SLOs: 3, Met: 1, Missed: 1, NoData: 1}); r.Summary != want {
+		t.Errorf("Summary = %+v, want %+v", r.Summary, want)
+	}
+	if len(r.Services) != 2 || r.Services[0].Service != "checkout" || len(r.Services[0].SLOs) != 2 {
+		t.Errorf("Services = %+v, want checkout with 2 SLOs first", r.Services)
+	}
+
+	only := r.Only(func(service string) bool { return service == "payments" })
+	if want := (Summary{SLOs: 1, Missed: 1}); only.Summary != want || len(only.Services) != 1 {
+		t.Errorf("Only(payments) = %+v with %d services, want %+v with 1", only.Summary, len(only.Services), want)
+	}
+	if len(r.Services) != 2 {
+		t.Errorf("Only changed the report: %+v", r.Services)
+	}
+}
+
+func TestRender(t *testing.T) {
+	p, _ := ParsePeriod("", "2026-09-01", "2026-09-02")
+	resolved := p.Start.Add(2 * time.Hour)
+	r := Build(p, []SLOInput{{
+		Service:   "checkout",
+		Name:      "availability",
+		Target:    99,
+		Samples:   samples(p, 100, 95, p.Start, resolved),
+		Incidents: []IncidentWindow{{ID: "inc-1", Title: "Errors | 5xx", Severity: "high", StartedAt: p.Start, ResolvedAt: &resolved}},
+	}}, p.End)
+
+	tests := []struct {
+		format          string
+		wantContentType string
+		wantContains    string
+	}{
+		{"", "application/json", `"label": "2026-09-01..2026-09-02"`},
+		{"md", "text/markdown; charset=utf-8", `Errors \| 5xx`},
+		{"markdown", "text/markdown; charset=utf-8", "## checkout"},
+		{"csv", "text/csv; charset=utf-8", "incident,2026-09-01..2026-09-02,checkout,availability"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, contentType, err := Render(r, tt.format)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if contentType != tt.wantContentType {
+				t.Errorf("content type = %s, want %s", contentType, tt.wantContentType)
+			}
+			if !strings.Contains(string(data), tt.wantContains) {
+				t.Errorf("Render() output does not contain %q:\n%s", tt.wantContains, data)
+			}
+		})
+	}
+
+	if _, _, err := Render(r, "pdf"); err == nil {
+		t.Error("Render(pdf) error = nil, want error")
+	}
+}
+
+func TestCSVRecords(t *testing.T) {
+	p, _ := ParsePeriod("", "2026-09-01", "2026-09-03")
+	r := Build(p, []SLOInput{{Service: "checkout", Name: "availability", Target: 99, Samples: samples(p, 100, 100, p.Start, p.Start)}}, p.End)
+
+	data, err := CSV(r)
+	if err != nil {
+		t.Fatalf("CSV() error = %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV output is not valid: %v", err)
+	}
+	// header, one slo row and three burn-down rows
+	if len(records) != 5 {
+		t.Errorf("len(records) = %d, want 5", len(records))
+	}
+}
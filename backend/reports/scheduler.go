@@ -0,0 +1,127 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schedules
+const (
+	ScheduleMonthly   = "monthly"
+	ScheduleQuarterly = "quarterly"
+)
+
+// ParseSchedule parses a comma separated list of schedules. An empty list
+// disables scheduled reports.
+func ParseSchedule(value string) ([]string, error) {
+	var schedules []string
+	for _, s := range strings.Split(value, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case "":
+			continue
+		case ScheduleMonthly, ScheduleQuarterly:
+			schedules = append(schedules, s)
+		default:
+			return nil, fmt.Errorf("unknown report schedule %q (use monthly or quarterly)", s)
+		}
+	}
+	return schedules, nil
+}
+
+// Scheduler stores a report for every completed month or quarter that has not
+// been stored yet
+type Scheduler struct {
+	generator *Generator
+	schedules []string
+	interval  time.Duration
+	logger    *log.Logger
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewScheduler creates a scheduler for the given schedules
+func NewScheduler(generator *Generator, schedules []string) *Scheduler {
+	return &Scheduler{
+		generator: generator,
+		schedules: schedules,
+		interval:  1 * time.Hour,
+		logger:    log.New(log.Writer(), "[Reports] ", log.LstdFlags),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start checks for due reports immediately and then every hour
+func (s *Scheduler) Start(ctx context.Context) {
+	s.logger.Printf("Starting scheduled reports: %s", strings.Join(s.schedules, ", "))
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.runCycle(ctx)
+		for {
+			select {
+			case <-ticker.C:
+				s.runCycle(ctx)
+			case <-s.stopChan:
+				s.logger.Println("Stopping scheduled reports")
+				return
+			case <-ctx.Done():
+				s.logger.Println("Context cancelled, stopping scheduled reports")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+}
+
+func (s *Scheduler) runCycle(ctx context.Context) {
+	orgs, err := s.generator.orgs(ctx)
+	if err != nil {
+		s.logger.Printf("Failed to list organizations: %v", err)
+		return
+	}
+	now := time.Now().UTC()
+	for _, schedule := range s.schedules {
+		p := LastCompletedMonth(now)
+		if schedule == ScheduleQuarterly {
+			p = LastCompletedQuarter(now)
+		}
+		for _, org := range orgs {
+			s.storeReport(ctx, org, schedule, p)
+		}
+	}
+}
+
+// storeReport stores the report of an organization for the period unless it
+// has one
+func (s *Scheduler) storeReport(ctx context.Context, org, schedule string, p Period) {
+	stored, err := s.generator.Stored(ctx, org, p)
+	if err != nil {
+		s.logger.Printf("Failed to check stored report %s of %s: %v", p.Label, org, err)
+		return
+	}
+	if stored {
+		return
+	}
+
+	report, err := s.generator.Generate(ctx, org, p, "", nil)
+	if err != nil {
+		s.logger.Printf("Failed to generate report %s of %s: %v", p.Label, org, err)
+		return
+	}
+	if err := s.generator.Store(ctx, org, report); err != nil {
+		s.logger.Printf("Failed to store report %s of %s: %v", p.Label, org, err)
+		return
+	}
+	s.logger.Printf("Stored %s report %s of %s (%d SLOs, %d missed)", schedule, p.Label, org, report.Summary.SLOs, report.Summary.Missed)
+}
@@ -0,0 +1,367 @@
+package retention
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by the archiver
+var (
+	ErrNotResolved    = errors.New("incident is not resolved")
+	ErrIncidentExists = errors.New("incident already exists")
+)
+
+// archivedTable is a table whose rows are archived with their incident
+type archivedTable struct {
+	name   string
+	column string
+}
+
+// archivedTables are archived with each incident, in the order they are
+// restored so foreign keys are satisfied. Alerts outlive their incident and
+// are only linked back on restore.
+var archivedTables = []archivedTable{
+	{"incidents", "id"},
+	{"incident_services", "incident_id"},
+	{"correlation_runs", "incident_id"},
+	{"correlations", "incident_id"},
+	{"root_cause_feedback", "incident_id"},
+	{"timeline_events", "incident_id"},
+	{"incident_tasks", "incident_id"},
+	{"investigation_hypotheses", "incident_id"},
+	{"investigation_steps", "incident_id"},
+	{"alerts", "incident_id"},
+}
+
+// Record is one archived row, stored as one line of the archive file
+type Record struct {
+	Table string          `json:"table"`
+	Row   json.RawMessage `json:"row"`
+}
+
+// ArchivedIncident is an entry of the archive index
+type ArchivedIncident struct {
+	IncidentID string     `json:"incident_id"`
+	Title      string     `json:"title"`
+	Severity   string     `json:"severity"`
+	StartedAt  time.Time  `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Location   string     `json:"location"`
+	RowCount   int        `json:"row_count"`
+	ArchivedAt time.Time  `json:"archived_at"`
+	RestoredAt *time.Time `json:"restored_at,omitempty"`
+}
+
+// archiveName is the store name of an incident's archive file
+func archiveName(incidentID string) (string, error) {
+	id, err := uuid.Parse(incidentID)
+	if err != nil {
+		return "", fmt.Errorf("invalid incident ID %q", incidentID)
+	}
+	return "incidents/" + id.String() + ".jsonl.gz", nil
+}
+
+// encodeArchive writes records as gzip compressed JSON lines
+func encodeArchive(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeArchive reads the records of an archive file
+func decodeArchive(data []byte) ([]Record, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer zr.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("archive line %d: %w", line, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return records, nil
+}
+
+var identifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// insertStatement inserts a JSON row into table. Only the columns present in
+// the archive are set, so columns added later get their defaults.
+func insertStatement(table string, columns []string) (string, error) {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		if !identifierPattern.MatchString(c) {
+			return "", fmt.Errorf("invalid column name %q in archive", c)
+		}
+		quoted[i] = `"` + c + `"`
+	}
+	list := strings.Join(quoted, ", ")
+	return fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM json_populate_record(NULL::%s, $1)`,
+		table, list, list, table), nil
+}
+
+// Archiver moves resolved incidents and their related rows to archive files
+// and restores them
+type Archiver struct {
+	db    *sql.DB
+	store Store
+}
+
+// NewArchiver creates an archiver writing to store
+func NewArchiver(db *sql.DB, store Store) *Archiver {
+	return &Archiver{db: db, store: store}
+}
+
+// Candidates returns the IDs of incidents resolved before cutoff, oldest first
+func (a *Archiver) Candidates(ctx context.Context, cutoff time.Time) ([]string, error) {
+	rows, err := a.db.QueryContext(ctx, `
+		SELECT id FROM incidents
+		WHERE status = 'resolved' AND resolved_at < $1
+		ORDER BY resolved_at
+	`, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resolved incidents: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Archive writes a resolved incident with its timeline, correlations, tasks
+// and investigation to the store, then deletes it from the database. The
+// file is stored before the delete is committed; if the delete fails the
+// incident stays and is archived again on the next run.
+func (a *Archiver) Archive(ctx context.Context, incidentID string) (*ArchivedIncident, error) {
+	name, err := archiveName(incidentID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry := ArchivedIncident{IncidentID: incidentID, Location: a.store.Location(name)}
+	var status string
+	var resolvedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT title, severity, status, started_at, resolved_at
+		FROM incidents WHERE id = $1
+		FOR UPDATE
+	`, incidentID).Scan(&entry.Title, &entry.Severity, &status, &entry.StartedAt, &resolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	if status != "resolved" {
+		return nil, ErrNotResolved
+	}
+	if resolvedAt.Valid {
+		entry.ResolvedAt = &resolvedAt.Time
+	}
+
+	var records []Record
+	for _, t := range archivedTables {
+		rows, err := tx.QueryContext(ctx,
+			fmt.Sprintf(`SELECT row_to_json(t) FROM %s t WHERE %s = $1`, t.name, t.column), incidentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", t.name, err)
+		}
+		for rows.Next() {
+			var row []byte
+			if err := rows.Scan(&row); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			records = append(records, Record{Table: t.name, Row: row})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := encodeArchive(records)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.store.Write(name, data); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	entry.RowCount = len(records)
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO incident_archives (incident_id, title, severity, started_at, resolved_at, location, row_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (incident_id) DO UPDATE
+		SET title = EXCLUDED.title, severity = EXCLUDED.severity, started_at = EXCLUDED.started_at,
+		    resolved_at = EXCLUDED.resolved_at, location = EXCLUDED.location, row_count = EXCLUDED.row_count,
+		    archived_at = NOW(), restored_at = NULL
+		RETURNING archived_at
+	`, incidentID, entry.Title, entry.Severity, entry.StartedAt, entry.ResolvedAt, entry.Location, entry.RowCount).Scan(&entry.ArchivedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record archive: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM incidents WHERE id = $1`, incidentID); err != nil {
+		return nil, fmt.Errorf("failed to delete archived incident: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Restore brings an archived incident and its related rows back into the
+// database and returns the number of rows restored. The archive file is kept.
+func (a *Archiver) Restore(ctx context.Context, incidentID string) (int, error) {
+	name, err := archiveName(incidentID)
+	if err != nil {
+		return 0, err
+	}
+	data, err := a.store.Read(name)
+	if err != nil {
+		return 0, err
+	}
+	records, err := decodeArchive(data)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM incidents WHERE id = $1)`, incidentID).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, ErrIncidentExists
+	}
+
+	known := make(map[string]bool, len(archivedTables))
+	for _, t := range archivedTables {
+		known[t.name] = true
+	}
+	restored := 0
+	for i, r := range records {
+		if !known[r.Table] {
+			return 0, fmt.Errorf("archive record %d: unknown table %q", i+1, r.Table)
+		}
+		if r.Table == "alerts" {
+			// The alert row itself was never deleted, only unlinked
+			res, err := tx.ExecContext(ctx, `
+				UPDATE alerts SET incident_id = $2
+				WHERE id = ($1::json->>'id')::uuid AND incident_id IS NULL
+			`, string(r.Row), incidentID)
+			if err != nil {
+				return 0, fmt.Errorf("failed to relink alert: %w", err)
+			}
+			n, _ := res.RowsAffected()
+			restored += int(n)
+			continue
+		}
+
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(r.Row, &row); err != nil {
+			return 0, fmt.Errorf("archive record %d: %w", i+1, err)
+		}
+		columns := make([]string, 0, len(row))
+		for c := range row {
+			columns = append(columns, c)
+		}
+		sort.Strings(columns)
+		query, err := insertStatement(r.Table, columns)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, query, string(r.Row)); err != nil {
+			return 0, fmt.Errorf("failed to restore %s row: %w", r.Table, err)
+		}
+		restored++
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE incident_archives SET restored_at = NOW() WHERE incident_id = $1`, incidentID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return restored, nil
+}
+
+// List returns the archive index, most recently archived first
+func (a *Archiver) List(ctx context.Context, limit int) ([]ArchivedIncident, error) {
+	rows, err := a.db.QueryContext(ctx, `
+		SELECT incident_id, title, severity, started_at, resolved_at, location, row_count, archived_at, restored_at
+		FROM incident_archives
+		ORDER BY archived_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incident archives: %w", err)
+	}
+	defer rows.Close()
+
+	archived := []ArchivedIncident{}
+	for rows.Next() {
+		var e ArchivedIncident
+		var resolvedAt, restoredAt sql.NullTime
+		if err := rows.Scan(&e.IncidentID, &e.Title, &e.Severity, &e.StartedAt, &resolvedAt,
+			&e.Location, &e.RowCount, &e.ArchivedAt, &restoredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan incident archive: %w", err)
+		}
+		if resolvedAt.Valid {
+			e.ResolvedAt = &resolvedAt.Time
+		}
+		if restoredAt.Valid {
+			e.RestoredAt = &restoredAt.Time
+		}
+		archived = append(archived, e)
+	}
+	return archived, rows.Err()
+}
@@ -0,0 +1,122 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prunable maps the tables that can have a retention policy to the column
+// their age is measured by
+var prunable = map[string]string{
+	"timeline_events": "timestamp",
+	"correlations":    "created_at",
+	"metrics_cache":   "timestamp",
+	"slo_history":     "timestamp",
+}
+
+// incidentScoped tables keep rows of incidents that are not resolved yet, no
+// matter how old they are
+var incidentScoped = map[string]bool{
+	"timeline_events": true,
+	"correlations":    true,
+}
+
+// pruneBatch is how many rows are deleted per statement, so pruning a large
+// backlog doesn't hold long locks
+const pruneBatch = 10000
+
+// Policy removes rows of a table once they are older than MaxAge
+type Policy struct {
+	Table  string        `json:"table"`
+	MaxAge time.Duration `json:"max_age"`
+}
+
+// ParsePolicies parses a comma separated list of table=age pairs such as
+// "metrics_cache=7d,correlations=90d". Tables without a policy are kept
+// forever.
+func ParsePolicies(value string) ([]Policy, error) {
+	var policies []Policy
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		table, age, ok := strings.Cut(pair, "=")
+		table = strings.TrimSpace(table)
+		if !ok {
+			return nil, fmt.Errorf("invalid retention policy %q (use table=age)", pair)
+		}
+		if _, ok := prunable[table]; !ok {
+			return nil, fmt.Errorf("unknown retention table %q", table)
+		}
+		if seen[table] {
+			return nil, fmt.Errorf("duplicate retention policy for %s", table)
+		}
+		maxAge, err := ParseAge(age)
+		if err != nil {
+			return nil, fmt.Errorf("retention policy for %s: %w", table, err)
+		}
+		seen[table] = true
+		policies = append(policies, Policy{Table: table, MaxAge: maxAge})
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Table < policies[j].Table })
+	return policies, nil
+}
+
+// ParseAge parses a positive age in days ("30d") or as a Go duration ("12h")
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var age time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if age, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("age %q must be positive", value)
+	}
+	return age, nil
+}
+
+// pruneQuery deletes one batch of rows of the policy's table older than $1
+func pruneQuery(p Policy) string {
+	column := prunable[p.Table]
+	where := fmt.Sprintf("%s < $1", column)
+	if incidentScoped[p.Table] {
+		where += " AND (incident_id IS NULL OR incident_id NOT IN (SELECT id FROM incidents WHERE status != 'resolved'))"
+	}
+	return fmt.Sprintf(`DELETE FROM %s WHERE ctid IN (SELECT ctid FROM %s WHERE %s LIMIT %d)`,
+		p.Table, p.Table, where, pruneBatch)
+}
+
+// Prune deletes the rows older than the policy allows and returns how many
+func Prune(ctx context.Context, db *sql.DB, p Policy, now time.Time) (int64, error) {
+	query := pruneQuery(p)
+	cutoff := now.Add(-p.MaxAge)
+
+	var total int64
+	for {
+		res, err := db.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return total, fmt.Errorf("failed to prune %s: %w", p.Table, err)
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < pruneBatch {
+			return total, nil
+		}
+	}
+}
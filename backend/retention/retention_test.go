@@ -0,0 +1,142 @@
+package retention
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []Policy
+		wantErr bool
+	}{
+		{name: "empty", value: ""},
+		{
+			name:  "days and durations",
+			value: "metrics_cache=7d, correlations=36h",
+			want: []Policy{
+				{Table: "correlations", MaxAge: 36 * time.Hour},
+				{Table: "metrics_cache", MaxAge: 7 * 24 * time.Hour},
+			},
+		},
+		{name: "unknown table", value: "incidents=30d", wantErr: true},
+		{name: "missing age", value: "slo_history", wantErr: true},
+		{name: "zero age", value: "slo_history=0d", wantErr: true},
+		{name: "duplicate", value: "slo_history=30d,slo_history=60d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicies(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParsePolicies(%q) = %v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePolicies(%q) error = %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePolicies(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneQuery(t *testing.T) {
+	tests := []struct {
+		table         string
+		wantOpenCheck bool
+	}{
+		{table: "metrics_cache"},
+		{table: "slo_history"},
+		{table: "timeline_events", wantOpenCheck: true},
+		{table: "correlations", wantOpenCheck: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			query := pruneQuery(Policy{Table: tt.table, MaxAge: time.Hour})
+			if !strings.HasPrefix(query, "DELETE FROM "+tt.table+" ") {
+				t.Errorf("pruneQuery() = %q, want a delete from %s", query, tt.table)
+			}
+			if got := strings.Contains(query, "status != 'resolved'"); got != tt.wantOpenCheck {
+				t.Errorf("pruneQuery() keeps open incident rows = %v, want %v", got, tt.wantOpenCheck)
+			}
+		})
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	records := []Record{
+		{Table: "incidents", Row: json.RawMessage(`{"id":"3f0c2b9e-7d7a-4c1e-9d53-0a4f3c6b2e10","title":"API down"}`)},
+		{Table: "timeline_events", Row: json.RawMessage(`{"id":"5b1d","metadata":{"note":"line\nbreak"}}`)},
+	}
+	data, err := encodeArchive(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeArchive(data)
+	if err != nil {
+		t.Fatalf("decodeArchive() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Errorf("decodeArchive() = %s, want %s", got, records)
+	}
+
+	if _, err := decodeArchive([]byte("not gzip")); err == nil {
+		t.Error("decodeArchive() of plain text succeeded, want error")
+	}
+}
+
+func TestInsertStatement(t *testing.T) {
+	query, err := insertStatement("incident_tasks", []string{"id", "title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `INSERT INTO incident_tasks ("id", "title") SELECT "id", "title" FROM json_populate_record(NULL::incident_tasks, $1)`
+	if query != want {
+		t.Errorf("insertStatement() = %q, want %q", query, want)
+	}
+
+	if _, err := insertStatement("incident_tasks", []string{`id"; DROP TABLE incidents; --`}); err == nil {
+		t.Error("insertStatement() accepted an invalid column name")
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	store, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := archiveName("3F0C2B9E-7D7A-4C1E-9D53-0A4F3C6B2E10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "incidents/3f0c2b9e-7d7a-4c1e-9d53-0a4f3c6b2e10.jsonl.gz" {
+		t.Errorf("archiveName() = %q", name)
+	}
+
+	if _, err := store.Read(name); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("Read() of missing file error = %v, want ErrNotArchived", err)
+	}
+	if err := store.Write(name, []byte("data")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got, err := store.Read(name); err != nil || string(got) != "data" {
+		t.Errorf("Read() = %q, %v, want %q", got, err, "data")
+	}
+	if err := store.Write("../outside", []byte("data")); err == nil {
+		t.Error("Write() outside the archive directory succeeded")
+	}
+	if _, err := archiveName("../etc/passwd"); err == nil {
+		t.Error("archiveName() accepted a path")
+	}
+}
@@ -0,0 +1,143 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Config is what the scheduler enforces
+type Config struct {
+	Policies []Policy
+	// ArchiveAfter archives incidents resolved longer ago; zero disables archiving
+	ArchiveAfter time.Duration
+	ArchiveDir   string
+}
+
+// LoadConfigFromEnv reads RETENTION_POLICIES, INCIDENT_ARCHIVE_AFTER and
+// ARCHIVE_DIR
+func LoadConfigFromEnv() (Config, error) {
+	config := Config{ArchiveDir: os.Getenv("ARCHIVE_DIR")}
+	if config.ArchiveDir == "" {
+		config.ArchiveDir = "./archive"
+	}
+
+	var err error
+	if config.Policies, err = ParsePolicies(os.Getenv("RETENTION_POLICIES")); err != nil {
+		return config, fmt.Errorf("invalid RETENTION_POLICIES: %w", err)
+	}
+	if after := os.Getenv("INCIDENT_ARCHIVE_AFTER"); after != "" {
+		if config.ArchiveAfter, err = ParseAge(after); err != nil {
+			return config, fmt.Errorf("invalid INCIDENT_ARCHIVE_AFTER: %w", err)
+		}
+	}
+	return config, nil
+}
+
+// Enabled reports whether there is anything to enforce
+func (c Config) Enabled() bool {
+	return len(c.Policies) > 0 || c.ArchiveAfter > 0
+}
+
+// RunResult summarises one retention run
+type RunResult struct {
+	Pruned   map[string]int64 `json:"pruned"`
+	Archived int              `json:"archived"`
+	Failed   int              `json:"failed"`
+}
+
+// Scheduler prunes tables and archives resolved incidents once a day
+type Scheduler struct {
+	db       *sql.DB
+	archiver *Archiver
+	config   Config
+	interval time.Duration
+	logger   *log.Logger
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewScheduler creates a scheduler. archiver may be nil when archiving is
+// disabled.
+func NewScheduler(db *sql.DB, archiver *Archiver, config Config) *Scheduler {
+	return &Scheduler{
+		db:       db,
+		archiver: archiver,
+		config:   config,
+		interval: 24 * time.Hour,
+		logger:   log.New(log.Writer(), "[Retention] ", log.LstdFlags),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start runs retention immediately and then once a day
+func (s *Scheduler) Start(ctx context.Context) {
+	s.logger.Printf("Starting retention: %d table policies, archive after %s", len(s.config.Policies), s.config.ArchiveAfter)
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.RunOnce(ctx)
+		for {
+			select {
+			case <-ticker.C:
+				s.RunOnce(ctx)
+			case <-s.stopChan:
+				s.logger.Println("Stopping retention")
+				return
+			case <-ctx.Done():
+				s.logger.Println("Context cancelled, stopping retention")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+}
+
+// RunOnce archives due incidents and then prunes each table. Archiving runs
+// first so incident rows are archived before their table policies remove them.
+func (s *Scheduler) RunOnce(ctx context.Context) RunResult {
+	now := time.Now()
+	result := RunResult{Pruned: make(map[string]int64)}
+
+	if s.archiver != nil && s.config.ArchiveAfter > 0 {
+		ids, err := s.archiver.Candidates(ctx, now.Add(-s.config.ArchiveAfter))
+		if err != nil {
+			s.logger.Printf("Failed to find incidents to archive: %v", err)
+			result.Failed++
+		}
+		for _, id := range ids {
+			entry, err := s.archiver.Archive(ctx, id)
+			if err != nil {
+				s.logger.Printf("Failed to archive incident %s: %v", id, err)
+				result.Failed++
+				continue
+			}
+			s.logger.Printf("Archived incident %s (%d rows) to %s", id, entry.RowCount, entry.Location)
+			result.Archived++
+		}
+	}
+
+	for _, p := range s.config.Policies {
+		n, err := Prune(ctx, s.db, p, now)
+		result.Pruned[p.Table] = n
+		if err != nil {
+			s.logger.Printf("%v", err)
+			result.Failed++
+			continue
+		}
+		if n > 0 {
+			s.logger.Printf("Pruned %d rows from %s older than %s", n, p.Table, p.MaxAge)
+		}
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotArchived is returned when an archive file does not exist
+var ErrNotArchived = errors.New("archive not found")
+
+// Store keeps archive files. Names are slash separated relative paths.
+type Store interface {
+	Write(name string, data []byte) error
+	Read(name string) ([]byte, error)
+	// Location describes where a file is stored, for logs and the archive index
+	Location(name string) string
+}
+
+// DirStore stores archive files in a local directory. An object store bucket
+// mounted as a directory works the same way.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a store in dir, creating the directory if needed
+func NewDirStore(dir string) (*DirStore, error) {
+	if dir == "" {
+		return nil, errors.New("archive directory is not set")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+func (s *DirStore) path(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid archive name %q", name)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// Write stores data under name. The file is written to a temporary file and
+// synced before it is renamed into place, so a crash never leaves a partial
+// archive behind.
+func (s *DirStore) Write(name string, data []byte) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Read returns the file stored under name, or ErrNotArchived
+func (s *DirStore) Read(name string) ([]byte, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotArchived
+	}
+	return data, err
+}
+
+// Location returns the file path of name
+func (s *DirStore) Location(name string) string {
+	path, err := s.path(name)
+	if err != nil {
+		return name
+	}
+	return path
+}
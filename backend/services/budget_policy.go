@@ -0,0 +1,438 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Error budget policy actions
+const (
+	PolicyActionFreezeDeploys = "freeze_deploys"
+	PolicyActionNotify        = "notify"
+)
+
+// Error budget policy states
+const (
+	PolicyStateOK        = "ok"
+	PolicyStateTriggered = "triggered"
+)
+
+// ErrInvalidPolicy is returned when an error budget policy is rejected
+var ErrInvalidPolicy = errors.New("invalid error budget policy")
+
+// ErrInternalWebhook is returned for webhooks that resolve to an internal
+// address
+var ErrInternalWebhook = errors.New("webhook address is internal")
+
+// ErrorBudgetPolicy triggers its action once the remaining error budget of an
+// SLO drops to Threshold percent or below
+type ErrorBudgetPolicy struct {
+	ID              string     `json:"id"`
+	SLOID           string     `json:"slo_id"`
+	Name            string     `json:"name"`
+	Threshold       float64    `json:"threshold"`
+	Action          string     `json:"action"`
+	WebhookURL      string     `json:"webhook_url,omitempty"`
+	Enabled         bool       `json:"enabled"`
+	State           string     `json:"state"`
+	StateSince      *time.Time `json:"state_since,omitempty"`
+	BudgetRemaining *float64   `json:"budget_remaining,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// PolicyTransition records a policy changing state
+type PolicyTransition struct {
+	ID              string    `json:"id"`
+	PolicyID        string    `json:"policy_id"`
+	PolicyName      string    `json:"policy_name"`
+	Action          string    `json:"action"`
+	SLOID           string    `json:"slo_id"`
+	SLOName         string    `json:"slo_name"`
+	Service         string    `json:"service"`
+	Team            string    `json:"team,omitempty"`
+	FromState       string    `json:"from_state"`
+	ToState         string    `json:"to_state"`
+	Threshold       float64   `json:"threshold"`
+	BudgetRemaining float64   `json:"budget_remaining"`
+	CreatedAt       time.Time `json:"created_at"`
+	WebhookURL      string    `json:"-"`
+	OrgID           string    `json:"-"` // of the SLO's service
+}
+
+// DeployGate tells CI whether deploys of a service are allowed
+type DeployGate struct {
+	Service     string             `json:"service"`
+	Allowed     bool               `json:"allowed"`
+	Reasons     []DeployGateReason `json:"reasons"`
+	EvaluatedAt time.Time          `json:"evaluated_at"`
+}
+
+// DeployGateReason is a triggered freeze policy blocking deploys
+type DeployGateReason struct {
+	SLOID           string     `json:"slo_id"`
+	SLOName         string     `json:"slo_name"`
+	PolicyName      string     `json:"policy_name"`
+	Threshold       float64    `json:"threshold"`
+	BudgetRemaining *float64   `json:"budget_remaining,omitempty"`
+	Since           *time.Time `json:"since,omitempty"`
+	Message         string     `json:"message"`
+}
+
+// SetPolicyCallback sets the hook called for every policy state transition
+func (s *SLOService) SetPolicyCallback(fn func(PolicyTransition)) {
+	s.onPolicyTransition = fn
+}
+
+// policyState returns the state of a policy for the remaining budget
+func policyState(threshold, remaining float64) string {
+	if remaining <= threshold {
+		return PolicyStateTriggered
+	}
+	return PolicyStateOK
+}
+
+func validatePolicy(p *ErrorBudgetPolicy) error {
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPolicy)
+	}
+	if p.Threshold < 0 || p.Threshold > 100 {
+		return fmt.Errorf("%w: threshold must be between 0 and 100", ErrInvalidPolicy)
+	}
+	if p.Action != PolicyActionFreezeDeploys && p.Action != PolicyActionNotify {
+		return fmt.Errorf("%w: action must be freeze_deploys or notify", ErrInvalidPolicy)
+	}
+	if p.WebhookURL != "" {
+		if err := validateWebhookURL(p.WebhookURL); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+		}
+	}
+	return nil
+}
+
+// validateWebhookURL rejects webhooks that aren't http(s) or name an internal
+// host. Host names are checked again when the webhook is sent, against the
+// addresses they resolve to then.
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook_url must be an http(s) URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInternalWebhook
+	}
+	if ip := net.ParseIP(host); ip != nil && internalAddress(ip) {
+		return ErrInternalWebhook
+	}
+	return nil
+}
+
+// internalAddress reports whether ip is loopback, private, link-local,
+// multicast or unspecified
+func internalAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// dialPublic refuses connections to internal addresses. It runs after the
+// host is resolved, so names pointing at internal addresses are caught too.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || internalAddress(ip) {
+		return fmt.Errorf("%w: %s", ErrInternalWebhook, host)
+	}
+	return nil
+}
+
+// webhookClient sends webhooks, directly rather than through a proxy and only
+// to public addresses, redirects included
+var webhookClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: dialPublic}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// CreatePolicy attaches a policy to an SLO. The policy starts in the state
+// matching the SLO's current error budget.
+func (s *SLOService) CreatePolicy(ctx context.Context, p *ErrorBudgetPolicy) error {
+	if err := validatePolicy(p); err != nil {
+		return err
+	}
+
+	var remaining sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT (SELECT error_budget FROM slo_history WHERE slo_id = s.id ORDER BY timestamp DESC LIMIT 1)
+		FROM slos s WHERE s.id = $1
+	`, p.SLOID).Scan(&remaining)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: unknown SLO %s", ErrInvalidPolicy, p.SLOID)
+	} else if err != nil {
+		return fmt.Errorf("failed to look up SLO: %w", err)
+	}
+
+	p.State = PolicyStateOK
+	if remaining.Valid {
+		p.State = policyState(p.Threshold, remaining.Float64)
+		p.BudgetRemaining = &remaining.Float64
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO error_budget_policies (slo_id, name, threshold, action, webhook_url, enabled, state, budget_remaining)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+		RETURNING id, state_since, created_at
+	`, p.SLOID, p.Name, p.Threshold, p.Action, p.WebhookURL, p.Enabled, p.State, p.BudgetRemaining,
+	).Scan(&p.ID, &p.StateSince, &p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create policy: %w", err)
+	}
+	return nil
+}
+
+// GetPolicies returns the policies of an SLO
+func (s *SLOService) GetPolicies(ctx context.Context, sloID string) ([]ErrorBudgetPolicy, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, slo_id, name, threshold, action, COALESCE(webhook_url, ''), enabled,
+		       state, state_since, budget_remaining, created_at
+		FROM error_budget_policies
+		WHERE slo_id = $1
+		ORDER BY threshold DESC, name
+	`, sloID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []ErrorBudgetPolicy{}
+	for rows.Next() {
+		var p ErrorBudgetPolicy
+		var since sql.NullTime
+		var remaining sql.NullFloat64
+		if err := rows.Scan(&p.ID, &p.SLOID, &p.Name, &p.Threshold, &p.Action, &p.WebhookURL, &p.Enabled,
+			&p.State, &since, &remaining, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan policy: %w", err)
+		}
+		if since.Valid {
+			p.StateSince = &since.Time
+		}
+		if remaining.Valid {
+			p.BudgetRemaining = &remaining.Float64
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// DeletePolicy removes a policy from an SLO
+func (s *SLOService) DeletePolicy(ctx context.Context, sloID, policyID string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM error_budget_policies WHERE id = $1 AND slo_id = $2`, policyID, sloID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetPolicyHistory returns the state transitions of an SLO's policies, newest first
+func (s *SLOService) GetPolicyHistory(ctx context.Context, sloID string) ([]PolicyTransition, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT e.id, e.policy_id, p.name, p.action, e.slo_id, s.name, sv.name, COALESCE(sv.team, ''),
+		       e.from_state, e.to_state, e.threshold, e.budget_remaining, e.created_at
+		FROM error_budget_policy_events e
+		JOIN error_budget_policies p ON p.id = e.policy_id
+		JOIN slos s ON s.id = e.slo_id
+		JOIN services sv ON sv.id = s.service_id
+		WHERE e.slo_id = $1
+		ORDER BY e.created_at DESC
+		LIMIT 200
+	`, sloID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []PolicyTransition{}
+	for rows.Next() {
+		var t PolicyTransition
+		if err := rows.Scan(&t.ID, &t.PolicyID, &t.PolicyName, &t.Action, &t.SLOID, &t.SLOName, &t.Service, &t.Team,
+			&t.FromState, &t.ToState, &t.Threshold, &t.BudgetRemaining, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan policy transition: %w", err)
+		}
+		history = append(history, t)
+	}
+	return history, rows.Err()
+}
+
+// evaluatePolicies moves the enabled policies of an SLO to the state matching
+// the remaining budget, records every transition and calls the policy callback
+func (s *SLOService) evaluatePolicies(ctx context.Context, sloID string, remaining float64) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.id, p.name, p.action, p.threshold, COALESCE(p.webhook_url, ''), p.state,
+		       s.name, sv.name, COALESCE(sv.team, ''), sv.org_id
+		FROM error_budget_policies p
+		JOIN slos s ON s.id = p.slo_id
+		JOIN services sv ON sv.id = s.service_id
+		WHERE p.slo_id = $1 AND p.enabled
+	`, sloID)
+	if err != nil {
+		return fmt.Errorf("failed to query policies: %w", err)
+	}
+	var candidates []PolicyTransition
+	for rows.Next() {
+		t := PolicyTransition{SLOID: sloID, BudgetRemaining: remaining}
+		if err := rows.Scan(&t.PolicyID, &t.PolicyName, &t.Action, &t.Threshold, &t.WebhookURL, &t.FromState,
+			&t.SLOName, &t.Service, &t.Team, &t.OrgID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan policy: %w", err)
+		}
+		t.ToState = policyState(t.Threshold, remaining)
+		candidates = append(candidates, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var transitions []PolicyTransition
+	for _, t := range candidates {
+		if _, err := s.db.ExecContext(ctx, `
+			UPDATE error_budget_policies
+			SET budget_remaining = $1,
+			    state_since = CASE WHEN state <> $2 THEN NOW() ELSE state_since END,
+			    state = $2,
+			    updated_at = NOW()
+			WHERE id = $3
+		`, remaining, t.ToState, t.PolicyID); err != nil {
+			return fmt.Errorf("failed to update policy %s: %w", t.PolicyID, err)
+		}
+		if t.FromState == t.ToState {
+			continue
+		}
+		err := s.db.QueryRowContext(ctx, `
+			INSERT INTO error_budget_policy_events (policy_id, slo_id, from_state, to_state, threshold, budget_remaining)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at
+		`, t.PolicyID, sloID, t.FromState, t.ToState, t.Threshold, remaining).Scan(&t.ID, &t.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record policy transition: %w", err)
+		}
+		s.logger.Info("Error budget policy changed state",
+			zap.String("policy", t.PolicyName), zap.String("slo_id", sloID),
+			zap.String("from", t.FromState), zap.String("to", t.ToState), zap.Float64("budget_remaining", remaining))
+		transitions = append(transitions, t)
+	}
+
+	if s.onPolicyTransition != nil {
+		for _, t := range transitions {
+			s.onPolicyTransition(t)
+		}
+	}
+	return nil
+}
+
+// GetDeployGate reports whether deploys of a service of an organization are
+// allowed: any triggered freeze_deploys policy on one of its SLOs blocks them.
+// sql.ErrNoRows is returned for unknown services.
+func (s *SLOService) GetDeployGate(ctx context.Context, orgID, service string) (*DeployGate, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM services WHERE name = $1 AND org_id = $2)`, service, orgID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up service: %w", err)
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.id, s.name, p.name, p.threshold, p.budget_remaining, p.state_since
+		FROM error_budget_policies p
+		JOIN slos s ON s.id = p.slo_id
+		JOIN services sv ON sv.id = s.service_id
+		WHERE sv.name = $1 AND sv.org_id = $4 AND p.enabled AND p.action = $2 AND p.state = $3
+		ORDER BY s.name, p.name
+	`, service, PolicyActionFreezeDeploys, PolicyStateTriggered, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deploy freezes: %w", err)
+	}
+	defer rows.Close()
+
+	var reasons []DeployGateReason
+	for rows.Next() {
+		var r DeployGateReason
+		var remaining sql.NullFloat64
+		var since sql.NullTime
+		if err := rows.Scan(&r.SLOID, &r.SLOName, &r.PolicyName, &r.Threshold, &remaining, &since); err != nil {
+			return nil, fmt.Errorf("failed to scan deploy freeze: %w", err)
+		}
+		if remaining.Valid {
+			r.BudgetRemaining = &remaining.Float64
+		}
+		if since.Valid {
+			r.Since = &since.Time
+		}
+		reasons = append(reasons, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newDeployGate(service, reasons, time.Now()), nil
+}
+
+func newDeployGate(service string, reasons []DeployGateReason, now time.Time) *DeployGate {
+	gate := &DeployGate{Service: service, Allowed: len(reasons) == 0, Reasons: []DeployGateReason{}, EvaluatedAt: now}
+	for _, r := range reasons {
+		remaining := "unknown"
+		if r.BudgetRemaining != nil {
+			remaining = fmt.Sprintf("%.1f%%", *r.BudgetRemaining)
+		}
+		r.Message = fmt.Sprintf("SLO %s has %s error budget remaining, at or below the %.1f%% threshold of policy %s",
+			r.SLOName, remaining, r.Threshold, r.PolicyName)
+		gate.Reasons = append(gate.Reasons, r)
+	}
+	return gate
+}
+
+// NotifyWebhook posts a policy transition as JSON to a webhook URL. Webhooks
+// resolving to internal addresses are refused with ErrInternalWebhook.
+func NotifyWebhook(ctx context.Context, webhookURL string, t PolicyTransition) error {
+	body, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := validateWebhookURL(webhookURL); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
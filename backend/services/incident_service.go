@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/sarika-03/Reliability-Studio/metrics"
 	"github.com/sarika-03/Reliability-Studio/models"
 	"go.uber.org/zap"
 	"time"
@@ -13,8 +14,9 @@ import (
 )
 
 type IncidentService struct {
-	db     *sqlx.DB
-	logger *zap.Logger
+	db      *sqlx.DB
+	logger  *zap.Logger
+	metrics *metrics.Snapshot
 }
 
 func NewIncidentService(db *sql.DB, logger *zap.Logger) *IncidentService {
@@ -92,12 +94,12 @@ func (s *IncidentService) Create(ctx context.Context, req models.CreateIncidentR
 
 	// Insert incident
 	query := `
-        INSERT INTO incidents (id, title, description, severity, status, started_at, detected_at, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        INSERT INTO incidents (id, title, description, severity, status, started_at, detected_at, created_at, updated_at, org_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
     `
 	_, err = tx.ExecContext(ctx, query,
 		incident.ID, incident.Title, incident.Description, incident.Severity,
-		incident.Status, incident.StartedAt, incident.DetectedAt, incident.CreatedAt, incident.UpdatedAt,
+		incident.Status, incident.StartedAt, incident.DetectedAt, incident.CreatedAt, incident.UpdatedAt, req.OrgID,
 	)
 	if err != nil {
 		s.logger.Error("Failed to create incident", zap.Error(err))
@@ -126,6 +128,7 @@ func (s *IncidentService) Create(ctx context.Context, req models.CreateIncidentR
 	}
 
 	s.logger.Info("Created incident", zap.String("id", incident.ID.String()))
+	s.refreshMetrics(ctx)
 	return incident, nil
 }
 
@@ -200,6 +203,8 @@ func (s *IncidentService) Update(ctx context.Context, id string, req models.Upda
 		argNum++
 	}
 
+	// Clients holding the previous version get a conflict when they update
+	updates = append(updates, "version = version + 1")
 	updates = append(updates, "updated_at = $"+string(rune(argNum+'0')))
 	args = append(args, time.Now())
 	argNum++
@@ -213,6 +218,7 @@ func (s *IncidentService) Update(ctx context.Context, id string, req models.Upda
 		s.logger.Error("Failed to update incident", zap.Error(err))
 		return nil, err
 	}
+	s.refreshMetrics(ctx)
 
 	return s.GetByID(ctx, id)
 }
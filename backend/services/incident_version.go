@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrIncidentConflict is returned when an incident changed after the version
+// the client read
+var ErrIncidentConflict = errors.New("incident was changed by someone else")
+
+// ErrInvalidVersion is returned for If-Match headers that aren't a version
+var ErrInvalidVersion = errors.New("invalid incident version")
+
+// IncidentState is the editable state of an incident. Version increases with
+// every update.
+type IncidentState struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	Severity   string     `json:"severity"`
+	Status     string     `json:"status"`
+	Service    string     `json:"service"`
+	RootCause  string     `json:"root_cause"`
+	Resolution string     `json:"resolution"`
+	StartedAt  time.Time  `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Version    int        `json:"version"`
+}
+
+// ETag returns the version as an entity tag
+func (st IncidentState) ETag() string {
+	return strconv.Quote(strconv.Itoa(st.Version))
+}
+
+// ParseIfMatch returns the version of an If-Match header, or 0 when the header
+// is empty or "*", which match any version
+func ParseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidVersion, header)
+	}
+	return version, nil
+}
+
+// IncidentPatch is a partial update; empty fields are left unchanged
+type IncidentPatch struct {
+	Status     string `json:"status"`
+	Severity   string `json:"severity"`
+	RootCause  string `json:"root_cause"`
+	Resolution string `json:"resolution"`
+}
+
+// apply returns the state after the patch
+func (p IncidentPatch) apply(st IncidentState) IncidentState {
+	if p.Status != "" {
+		st.Status = p.Status
+	}
+	if p.Severity != "" {
+		st.Severity = p.Severity
+	}
+	if p.RootCause != "" {
+		st.RootCause = p.RootCause
+	}
+	if p.Resolution != "" {
+		st.Resolution = p.Resolution
+	}
+	return st
+}
+
+// FieldChange is a field an update changed
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// diffIncident lists the editable fields that differ
+func diffIncident(before, after IncidentState) []FieldChange {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"status", before.Status, after.Status},
+		{"severity", before.Severity, after.Severity},
+		{"root_cause", before.RootCause, after.RootCause},
+		{"resolution", before.Resolution, after.Resolution},
+	}
+	changes := make([]FieldChange, 0)
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, FieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	return changes
+}
+
+const incidentStateQuery = `
+	SELECT i.id, i.title, i.severity, i.status, COALESCE(s.name, 'unknown'),
+	       COALESCE(i.root_cause, ''), COALESCE(i.resolution, ''), i.started_at, i.resolved_at, i.version
+	FROM incidents i
+	LEFT JOIN services s ON i.service_id = s.id
+	WHERE i.id::text = $1`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanIncidentState(row rowScanner) (*IncidentState, error) {
+	var st IncidentState
+	var resolvedAt sql.NullTime
+	err := row.Scan(&st.ID, &st.Title, &st.Severity, &st.Status, &st.Service,
+		&st.RootCause, &st.Resolution, &st.StartedAt, &resolvedAt, &st.Version)
+	if err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		st.ResolvedAt = &resolvedAt.Time
+	}
+	return &st, nil
+}
+
+// GetState returns the editable state of an incident, or sql.ErrNoRows
+func (s *IncidentService) GetState(ctx context.Context, id string) (*IncidentState, error) {
+	return scanIncidentState(s.db.QueryRowContext(ctx, incidentStateQuery, id))
+}
+
+// Patch updates an incident if its version is still version, or whatever it
+// is when version is 0. It returns the new state and the changed fields. On
+// ErrIncidentConflict the state is the current one.
+func (s *IncidentService) Patch(ctx context.Context, id string, p IncidentPatch, version int) (*IncidentState, []FieldChange, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanIncidentState(tx.QueryRowContext(ctx, incidentStateQuery+` FOR UPDATE OF i`, id))
+	if err != nil {
+		return nil, nil, err
+	}
+	if version != 0 && before.Version != version {
+		return before, nil, ErrIncidentConflict
+	}
+
+	after := p.apply(*before)
+	var resolvedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		UPDATE incidents
+		SET status = $2, severity = $3,
+		    root_cause = NULLIF($4, ''), resolution = NULLIF($5, ''),
+		    updated_at = NOW(),
+		    resolved_at = CASE WHEN $2 = 'resolved' AND resolved_at IS NULL THEN NOW() ELSE resolved_at END,
+		    version = version + 1
+		WHERE id::text = $1
+		RETURNING resolved_at, version
+	`, id, after.Status, after.Severity, after.RootCause, after.Resolution).Scan(&resolvedAt, &after.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	after.ResolvedAt = nil
+	if resolvedAt.Valid {
+		after.ResolvedAt = &resolvedAt.Time
+	}
+	return &after, diffIncident(*before, after), nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"*", 0, false},
+		{`"3"`, 3, false},
+		{`W/"12"`, 12, false},
+		{"7", 7, false},
+		{`"0"`, 0, true},
+		{`"abc"`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, err := ParseIfMatch(tt.header)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVersion) {
+					t.Errorf("err = %v, want ErrInvalidVersion", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseIfMatch(%q) = %d, %v; want %d", tt.header, got, err, tt.want)
+			}
+		})
+	}
+	if etag := (IncidentState{Version: 4}).ETag(); etag != `"4"` {
+		t.Errorf("ETag = %s, want \"4\"", etag)
+	}
+}
+
+func TestIncidentPatchDiff(t *testing.T) {
+	before := IncidentState{Status: "open", Severity: "high", RootCause: "disk full"}
+	tests := []struct {
+		name  string
+		patch IncidentPatch
+		want  []FieldChange
+	}{
+		{"nothing", IncidentPatch{}, []FieldChange{}},
+		{"same value", IncidentPatch{Status: "open"}, []FieldChange{}},
+		{"root cause", IncidentPatch{RootCause: "bad deploy"}, []FieldChange{{"root_cause", "disk full", "bad deploy"}}},
+		{"resolve", IncidentPatch{Status: "resolved", Resolution: "rolled back"}, []FieldChange{
+			{"status", "open", "resolved"},
+			{"resolution", "", "rolled back"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffIncident(before, tt.patch.apply(before)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
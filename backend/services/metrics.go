@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/sarika-03/Reliability-Studio/metrics"
+	"go.uber.org/zap"
+)
+
+// SetMetricsSnapshot makes the service keep the exported SLO state up to date
+func (s *SLOService) SetMetricsSnapshot(snapshot *metrics.Snapshot) {
+	s.metrics = snapshot
+}
+
+// RefreshMetrics loads the state of all SLOs into the metrics snapshot
+func (s *SLOService) RefreshMetrics(ctx context.Context) error {
+	if s.metrics == nil {
+		return nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.id, sv.name, s.name, COALESCE(s.indicator_type, 'availability'), s.objective,
+		       h.value, h.error_budget, h.burn_rate
+		FROM slos s
+		JOIN services sv ON sv.id = s.service_id
+		LEFT JOIN LATERAL (
+			SELECT value, error_budget, burn_rate
+			FROM slo_history
+			WHERE slo_id = s.id
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) h ON true
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query SLO metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var states []metrics.SLOState
+	for rows.Next() {
+		var st metrics.SLOState
+		var current, budget, burnRate sql.NullFloat64
+		if err := rows.Scan(&st.ID, &st.Service, &st.Name, &st.Type, &st.Target, &current, &budget, &burnRate); err != nil {
+			return fmt.Errorf("failed to scan SLO metrics: %w", err)
+		}
+		if current.Valid {
+			st.Current = &current.Float64
+		}
+		if budget.Valid {
+			st.BudgetRemaining = &budget.Float64
+		}
+		if burnRate.Valid {
+			st.BurnRate = &burnRate.Float64
+		}
+		states = append(states, st)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.metrics.SetSLOs(states)
+	return nil
+}
+
+// refreshMetrics refreshes the snapshot after a change, logging failures
+func (s *SLOService) refreshMetrics(ctx context.Context) {
+	if err := s.RefreshMetrics(ctx); err != nil {
+		s.logger.Warn("Failed to refresh SLO metrics", zap.Error(err))
+	}
+}
+
+// SetMetricsSnapshot makes the service keep the exported incident state up to date
+func (s *IncidentService) SetMetricsSnapshot(snapshot *metrics.Snapshot) {
+	s.metrics = snapshot
+}
+
+// RefreshMetrics aggregates open incidents, MTTA and MTTR into the metrics snapshot
+func (s *IncidentService) RefreshMetrics(ctx context.Context) error {
+	if s.metrics == nil {
+		return nil
+	}
+
+	state := metrics.IncidentState{Open: []metrics.OpenIncidents{}}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT i.severity, COALESCE(s.name, 'unknown'), COUNT(*)
+		FROM incidents i
+		LEFT JOIN services s ON i.service_id = s.id
+		WHERE i.status != 'resolved'
+		GROUP BY 1, 2
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query open incidents: %w", err)
+	}
+	for rows.Next() {
+		var open metrics.OpenIncidents
+		if err := rows.Scan(&open.Severity, &open.Service, &open.Count); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan open incidents: %w", err)
+		}
+		state.Open = append(state.Open, open)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if state.MTTA, err = s.durationHistograms(ctx, "acknowledged_at"); err != nil {
+		return err
+	}
+	if state.MTTR, err = s.durationHistograms(ctx, "resolved_at"); err != nil {
+		return err
+	}
+
+	s.metrics.SetIncidents(state)
+	return nil
+}
+
+// refreshMetrics refreshes the snapshot after a change, logging failures
+func (s *IncidentService) refreshMetrics(ctx context.Context) {
+	if err := s.RefreshMetrics(ctx); err != nil {
+		s.logger.Warn("Failed to refresh incident metrics", zap.Error(err))
+	}
+}
+
+// durationHistograms buckets the time from start to the given timestamp
+// column per severity. Buckets are counted in Postgres so only the
+// aggregates are transferred.
+func (s *IncidentService) durationHistograms(ctx context.Context, column string) (map[string]metrics.Histogram, error) {
+	duration := fmt.Sprintf("GREATEST(EXTRACT(EPOCH FROM (%s - started_at)), 0)", column)
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT severity, b.le, COUNT(*) FILTER (WHERE %[1]s <= b.le), COUNT(*), COALESCE(SUM(%[1]s), 0)
+		FROM incidents
+		CROSS JOIN unnest($1::float8[]) AS b(le)
+		WHERE %[2]s IS NOT NULL
+		GROUP BY severity, b.le
+	`, duration, column), pq.Array(metrics.DurationBuckets))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incident durations: %w", err)
+	}
+	defer rows.Close()
+
+	histograms := make(map[string]metrics.Histogram)
+	for rows.Next() {
+		var severity string
+		var le, sum float64
+		var inBucket, count uint64
+		if err := rows.Scan(&severity, &le, &inBucket, &count, &sum); err != nil {
+			return nil, fmt.Errorf("failed to scan incident durations: %w", err)
+		}
+		h, ok := histograms[severity]
+		if !ok {
+			h = metrics.Histogram{Count: count, Sum: sum, Buckets: make(map[float64]uint64)}
+		}
+		h.Buckets[le] = inBucket
+		histograms[severity] = h
+	}
+	return histograms, rows.Err()
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/sarika-03/Reliability-Studio/models"
 	"go.uber.org/zap"
+	"strconv"
 	"time"
 )
 
@@ -29,6 +30,10 @@ type CreateServiceRequest struct {
 	OnCallSchedule   string `json:"on_call_schedule"`
 	RepositoryURL    string `json:"repository_url"`
 	DocumentationURL string `json:"documentation_url"`
+	Cluster          string `json:"cluster"`
+	Namespace        string `json:"namespace"`
+	LabelSelector    string `json:"label_selector"`
+	OrgID            string `json:"-"` // set from the caller's organization
 }
 
 type UpdateServiceRequest struct {
@@ -37,6 +42,9 @@ type UpdateServiceRequest struct {
 	OnCallSchedule   *string `json:"on_call_schedule,omitempty"`
 	RepositoryURL    *string `json:"repository_url,omitempty"`
 	DocumentationURL *string `json:"documentation_url,omitempty"`
+	Cluster          *string `json:"cluster,omitempty"`
+	Namespace        *string `json:"namespace,omitempty"`
+	LabelSelector    *string `json:"label_selector,omitempty"`
 }
 
 // GetAll retrieves all services
@@ -44,7 +52,8 @@ func (s *ServiceService) GetAll(ctx context.Context) ([]models.Service, error) {
 	var services []models.Service
 	query := `
         SELECT id, name, description, team, on_call_schedule, 
-               repository_url, documentation_url, created_at, updated_at
+               repository_url, documentation_url, cluster, namespace, label_selector,
+               created_at, updated_at
         FROM services
         ORDER BY name ASC
     `
@@ -66,19 +75,24 @@ func (s *ServiceService) Create(ctx context.Context, req CreateServiceRequest) (
 		OnCallSchedule:   req.OnCallSchedule,
 		RepositoryURL:    req.RepositoryURL,
 		DocumentationURL: req.DocumentationURL,
+		Cluster:          req.Cluster,
+		Namespace:        req.Namespace,
+		LabelSelector:    req.LabelSelector,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
 	}
 
 	query := `
         INSERT INTO services (id, name, description, team, on_call_schedule, 
-                            repository_url, documentation_url, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                            repository_url, documentation_url, cluster, namespace, label_selector,
+                            created_at, updated_at, org_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
     `
 	_, err := s.db.ExecContext(ctx, query,
 		service.ID, service.Name, service.Description, service.Team,
 		service.OnCallSchedule, service.RepositoryURL, service.DocumentationURL,
-		service.CreatedAt, service.UpdatedAt,
+		service.Cluster, service.Namespace, service.LabelSelector,
+		service.CreatedAt, service.UpdatedAt, req.OrgID,
 	)
 	if err != nil {
 		s.logger.Error("Failed to create service", zap.Error(err))
@@ -99,7 +113,8 @@ func (s *ServiceService) GetByID(ctx context.Context, id string) (*models.Servic
 	var service models.Service
 	query := `
         SELECT id, name, description, team, on_call_schedule,
-               repository_url, documentation_url, created_at, updated_at
+               repository_url, documentation_url, cluster, namespace, label_selector,
+               created_at, updated_at
         FROM services
         WHERE id = $1
     `
@@ -127,46 +142,64 @@ func (s *ServiceService) Update(ctx context.Context, id string, req UpdateServic
 	argNum := 1
 
 	if req.Description != nil {
-		updates = append(updates, "description = $"+string(rune(argNum+'0')))
+		updates = append(updates, "description = $"+strconv.Itoa(argNum))
 		args = append(args, *req.Description)
 		argNum++
 	}
 
 	if req.Team != nil {
-		updates = append(updates, "team = $"+string(rune(argNum+'0')))
+		updates = append(updates, "team = $"+strconv.Itoa(argNum))
 		args = append(args, *req.Team)
 		argNum++
 	}
 
 	if req.OnCallSchedule != nil {
-		updates = append(updates, "on_call_schedule = $"+string(rune(argNum+'0')))
+		updates = append(updates, "on_call_schedule = $"+strconv.Itoa(argNum))
 		args = append(args, *req.OnCallSchedule)
 		argNum++
 	}
 
 	if req.RepositoryURL != nil {
-		updates = append(updates, "repository_url = $"+string(rune(argNum+'0')))
+		updates = append(updates, "repository_url = $"+strconv.Itoa(argNum))
 		args = append(args, *req.RepositoryURL)
 		argNum++
 	}
 
 	if req.DocumentationURL != nil {
-		updates = append(updates, "documentation_url = $"+string(rune(argNum+'0')))
+		updates = append(updates, "documentation_url = $"+strconv.Itoa(argNum))
 		args = append(args, *req.DocumentationURL)
 		argNum++
 	}
 
+	if req.Cluster != nil {
+		updates = append(updates, "cluster = $"+strconv.Itoa(argNum))
+		args = append(args, *req.Cluster)
+		argNum++
+	}
+
+	if req.Namespace != nil {
+		updates = append(updates, "namespace = $"+strconv.Itoa(argNum))
+		args = append(args, *req.Namespace)
+		argNum++
+	}
+
+	if req.LabelSelector != nil {
+		updates = append(updates, "label_selector = $"+strconv.Itoa(argNum))
+		args = append(args, *req.LabelSelector)
+		argNum++
+	}
+
 	if len(updates) == 0 {
 		return s.GetByID(ctx, id)
 	}
 
-	updates = append(updates, "updated_at = $"+string(rune(argNum+'0')))
+	updates = append(updates, "updated_at = $"+strconv.Itoa(argNum))
 	args = append(args, time.Now())
 	argNum++
 
 	args = append(args, serviceUUID)
 
-	query := "UPDATE services SET " + joinStrings(updates, ", ") + " WHERE id = $" + string(rune(argNum+'0'))
+	query := "UPDATE services SET " + joinStrings(updates, ", ") + " WHERE id = $" + strconv.Itoa(argNum)
 
 	_, err = s.db.ExecContext(ctx, query, args...)
 	if err != nil {
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Composite SLO methods
+const (
+	// CompositeWeighted averages the component SLIs by weight
+	CompositeWeighted = "weighted"
+	// CompositeAnd treats a journey as good only when every component is good,
+	// so the component success ratios are multiplied
+	CompositeAnd = "and"
+)
+
+// ErrInvalidCompositeSLO is returned when a composite SLO definition is rejected
+var ErrInvalidCompositeSLO = errors.New("invalid composite SLO")
+
+// CompositeComponent is one service SLO in a composite SLO
+type CompositeComponent struct {
+	SLOID   string   `json:"slo_id"`
+	Name    string   `json:"name,omitempty"`
+	Service string   `json:"service,omitempty"`
+	Weight  float64  `json:"weight"`
+	Value   *float64 `json:"value,omitempty"`
+}
+
+// CompositeSLO is a user-journey SLO with its own objective and error budget
+type CompositeSLO struct {
+	ID                   string               `json:"id"`
+	Name                 string               `json:"name"`
+	Description          string               `json:"description"`
+	Method               string               `json:"method"`
+	Objective            float64              `json:"objective"`
+	WindowDays           int                  `json:"window_days"`
+	ErrorBudgetRemaining *float64             `json:"error_budget_remaining,omitempty"`
+	BurnRate             *float64             `json:"burn_rate,omitempty"`
+	Status               string               `json:"status"`
+	Components           []CompositeComponent `json:"components"`
+	CreatedAt            time.Time            `json:"created_at"`
+	UpdatedAt            time.Time            `json:"updated_at"`
+}
+
+// CompositeSLOAnalysis is the result of calculating a composite SLO
+type CompositeSLOAnalysis struct {
+	CompositeID  string               `json:"composite_id"`
+	Value        *float64             `json:"value"`
+	Target       float64              `json:"target"`
+	ErrorBudget  *float64             `json:"error_budget,omitempty"`
+	BurnRate     *float64             `json:"burn_rate,omitempty"`
+	Status       string               `json:"status"`
+	CalculatedAt time.Time            `json:"calculated_at"`
+	Components   []CompositeComponent `json:"components"`
+	Error        *SLOError            `json:"error,omitempty"`
+}
+
+// CompositeHistoryPoint is one stored composite SLO calculation
+type CompositeHistoryPoint struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Value       float64   `json:"value"`
+	ErrorBudget float64   `json:"error_budget"`
+	BurnRate    float64   `json:"burn_rate"`
+}
+
+// CompositeValue combines component SLI percentages. Every component needs a value.
+func CompositeValue(method string, components []CompositeComponent) (float64, error) {
+	if len(components) == 0 {
+		return 0, fmt.Errorf("no components")
+	}
+	switch method {
+	case CompositeWeighted:
+		var sum, weights float64
+		for _, c := range components {
+			if c.Value == nil {
+				return 0, fmt.Errorf("component %s has no value", c.SLOID)
+			}
+			sum += *c.Value * c.Weight
+			weights += c.Weight
+		}
+		if weights <= 0 {
+			return 0, fmt.Errorf("weights must be positive")
+		}
+		return sum / weights, nil
+	case CompositeAnd:
+		ratio := 1.0
+		for _, c := range components {
+			if c.Value == nil {
+				return 0, fmt.Errorf("component %s has no value", c.SLOID)
+			}
+			ratio *= *c.Value / 100
+		}
+		return ratio * 100, nil
+	default:
+		return 0, fmt.Errorf("unknown method %q", method)
+	}
+}
+
+func validateComposite(c *CompositeSLO) error {
+	if c.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCompositeSLO)
+	}
+	if c.Method != CompositeWeighted && c.Method != CompositeAnd {
+		return fmt.Errorf("%w: method must be weighted or and", ErrInvalidCompositeSLO)
+	}
+	if c.Objective <= 0 || c.Objective >= 100 {
+		return fmt.Errorf("%w: objective must be between 0 and 100 (exclusive)", ErrInvalidCompositeSLO)
+	}
+	if len(c.Components) == 0 {
+		return fmt.Errorf("%w: at least one component is required", ErrInvalidCompositeSLO)
+	}
+	seen := make(map[string]bool)
+	for i := range c.Components {
+		comp := &c.Components[i]
+		if comp.Weight == 0 {
+			comp.Weight = 1
+		}
+		if comp.Weight < 0 {
+			return fmt.Errorf("%w: component weights must be positive", ErrInvalidCompositeSLO)
+		}
+		if seen[comp.SLOID] {
+			return fmt.Errorf("%w: SLO %s is listed twice", ErrInvalidCompositeSLO, comp.SLOID)
+		}
+		seen[comp.SLOID] = true
+	}
+	return nil
+}
+
+// CreateCompositeSLO stores a composite SLO of an organization and its
+// components, which must be SLOs of the organization
+func (s *SLOService) CreateCompositeSLO(ctx context.Context, orgID string, c *CompositeSLO) error {
+	if c.WindowDays <= 0 {
+		c.WindowDays = 30
+	}
+	if err := validateComposite(c); err != nil {
+		return err
+	}
+
+	ids := make([]string, len(c.Components))
+	for i, comp := range c.Components {
+		ids[i] = comp.SLOID
+	}
+	var found int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM slos WHERE id::text = ANY($1) AND org_id = $2`, pq.Array(ids), orgID).Scan(&found); err != nil {
+		return fmt.Errorf("failed to check component SLOs: %w", err)
+	}
+	if found != len(ids) {
+		return fmt.Errorf("%w: unknown component SLO", ErrInvalidCompositeSLO)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO composite_slos (name, description, method, objective, window_days, org_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at, updated_at
+	`, c.Name, c.Description, c.Method, c.Objective, c.WindowDays, orgID).Scan(&c.ID, &c.Status, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create composite SLO: %w", err)
+	}
+	for _, comp := range c.Components {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO composite_slo_components (composite_id, slo_id, weight)
+			VALUES ($1, $2, $3)
+		`, c.ID, comp.SLOID, comp.Weight); err != nil {
+			return fmt.Errorf("failed to add component %s: %w", comp.SLOID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCompositeSLOs returns the composite SLOs of an organization, or of all of
+// them when orgID is empty, with their components
+func (s *SLOService) GetCompositeSLOs(ctx context.Context, orgID string) ([]CompositeSLO, error) {
+	return s.queryComposites(ctx, orgID, "")
+}
+
+// GetCompositeSLO returns one composite SLO, or sql.ErrNoRows
+func (s *SLOService) GetCompositeSLO(ctx context.Context, id string) (*CompositeSLO, error) {
+	composites, err := s.queryComposites(ctx, "", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(composites) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &composites[0], nil
+}
+
+// DeleteCompositeSLO deletes a composite SLO; its component SLOs are kept
+func (s *SLOService) DeleteCompositeSLO(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM composite_slos WHERE id = $1`, id)
+	return err
+}
+
+func (s *SLOService) queryComposites(ctx context.Context, orgID, id string) ([]CompositeSLO, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, COALESCE(description, ''), method, objective, window_days,
+		       error_budget_remaining, burn_rate, status, created_at, updated_at
+		FROM composite_slos
+		WHERE ($1 = '' OR id::text = $1) AND ($2 = '' OR org_id = $2)
+		ORDER BY name
+	`, id, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query composite SLOs: %w", err)
+	}
+	defer rows.Close()
+
+	var composites []CompositeSLO
+	index := make(map[string]int)
+	for rows.Next() {
+		var c CompositeSLO
+		var budget, burnRate sql.NullFloat64
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Method, &c.Objective, &c.WindowDays,
+			&budget, &burnRate, &c.Status, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan composite SLO: %w", err)
+		}
+		if budget.Valid {
+			c.ErrorBudgetRemaining = &budget.Float64
+		}
+		if burnRate.Valid {
+			c.BurnRate = &burnRate.Float64
+		}
+		c.Components = []CompositeComponent{}
+		index[c.ID] = len(composites)
+		composites = append(composites, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(composites) == 0 {
+		return composites, nil
+	}
+
+	ids := make([]string, 0, len(index))
+	for cid := range index {
+		ids = append(ids, cid)
+	}
+	compRows, err := s.db.QueryContext(ctx, `
+		SELECT c.composite_id, c.slo_id, s.name, sv.name, c.weight
+		FROM composite_slo_components c
+		JOIN slos s ON s.id = c.slo_id
+		JOIN services sv ON sv.id = s.service_id
+		WHERE c.composite_id::text = ANY($1)
+		ORDER BY sv.name, s.name
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query composite components: %w", err)
+	}
+	defer compRows.Close()
+	for compRows.Next() {
+		var cid string
+		var comp CompositeComponent
+		if err := compRows.Scan(&cid, &comp.SLOID, &comp.Name, &comp.Service, &comp.Weight); err != nil {
+			return nil, fmt.Errorf("failed to scan composite component: %w", err)
+		}
+		i := index[cid]
+		composites[i].Components = append(composites[i].Components, comp)
+	}
+	return composites, compRows.Err()
+}
+
+// CalculateCompositeSLO calculates the components of a composite SLO and
+// combines them
+func (s *SLOService) CalculateCompositeSLO(ctx context.Context, id string) (*CompositeSLOAnalysis, error) {
+	c, err := s.GetCompositeSLO(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.calculateComposite(ctx, c, nil)
+}
+
+// calculateComposite reuses component analyses from measured when present
+func (s *SLOService) calculateComposite(ctx context.Context, c *CompositeSLO, measured map[string]*SLOAnalysis) (*CompositeSLOAnalysis, error) {
+	analysis := &CompositeSLOAnalysis{
+		CompositeID:  c.ID,
+		Target:       c.Objective,
+		CalculatedAt: time.Now(),
+		Components:   c.Components,
+	}
+
+	var missing []string
+	for i := range analysis.Components {
+		comp := &analysis.Components[i]
+		result, ok := measured[comp.SLOID]
+		if !ok {
+			var err error
+			if result, err = s.CalculateSLO(ctx, comp.SLOID); err != nil {
+				return nil, fmt.Errorf("failed to calculate component %s: %w", comp.SLOID, err)
+			}
+		}
+		comp.Value = result.Value
+		if comp.Value == nil {
+			missing = append(missing, comp.Service+"/"+comp.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		analysis.Status = "no_data"
+		analysis.Error = &SLOError{
+			Type:        "component_no_data",
+			Message:     "Some component SLOs have no data",
+			Details:     fmt.Sprintf("%v", missing),
+			Suggestions: []string{"Calculate the component SLOs and check their SLI queries"},
+		}
+		return analysis, nil
+	}
+
+	value, err := CompositeValue(c.Method, analysis.Components)
+	if err != nil {
+		return nil, err
+	}
+	budget, burnRate, status := errorBudget(c.Objective, value)
+	analysis.Value = &value
+	analysis.ErrorBudget = &budget
+	analysis.BurnRate = &burnRate
+	analysis.Status = status
+
+	if err := s.saveCompositeAnalysis(ctx, analysis); err != nil {
+		s.logger.Warn("Failed to save composite SLO analysis", zap.String("composite_id", c.ID), zap.Error(err))
+	}
+	return analysis, nil
+}
+
+func (s *SLOService) saveCompositeAnalysis(ctx context.Context, a *CompositeSLOAnalysis) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO composite_slo_history (composite_id, timestamp, value, error_budget, burn_rate)
+		VALUES ($1, $2, $3, $4, $5)
+	`, a.CompositeID, a.CalculatedAt, *a.Value, *a.ErrorBudget, *a.BurnRate); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE composite_slos
+		SET error_budget_remaining = $1, burn_rate = $2, status = $3, updated_at = NOW()
+		WHERE id = $4
+	`, *a.ErrorBudget, *a.BurnRate, a.Status, a.CompositeID)
+	return err
+}
+
+// GetCompositeSLOHistory returns the latest stored calculations, newest first
+func (s *SLOService) GetCompositeSLOHistory(ctx context.Context, id string, limit int) ([]CompositeHistoryPoint, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT timestamp, value, error_budget, burn_rate
+		FROM composite_slo_history
+		WHERE composite_id = $1
+		ORDER BY timestamp DESC
+		LIMIT $2
+	`, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query composite SLO history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []CompositeHistoryPoint{}
+	for rows.Next() {
+		var p CompositeHistoryPoint
+		if err := rows.Scan(&p.Timestamp, &p.Value, &p.ErrorBudget, &p.BurnRate); err != nil {
+			return nil, fmt.Errorf("failed to scan composite SLO history: %w", err)
+		}
+		history = append(history, p)
+	}
+	return history, rows.Err()
+}
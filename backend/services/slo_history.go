@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarika-03/Reliability-Studio/clients"
+	"github.com/sarika-03/Reliability-Studio/slorules"
+	"go.uber.org/zap"
+)
+
+// SLO history resolutions. Raw rows are written by every calculation and
+// rolled up into hourly and daily rows.
+const (
+	ResolutionRaw    = "raw"
+	ResolutionHourly = "hourly"
+	ResolutionDaily  = "daily"
+)
+
+// HistoryRetention controls how long each resolution is kept. Daily rows are
+// kept forever.
+type HistoryRetention struct {
+	Raw    time.Duration
+	Hourly time.Duration
+}
+
+// DefaultHistoryRetention keeps raw rows for 7 days and hourly rows for 90 days
+func DefaultHistoryRetention() HistoryRetention {
+	return HistoryRetention{
+		Raw:    7 * 24 * time.Hour,
+		Hourly: 90 * 24 * time.Hour,
+	}
+}
+
+// ErrInvalidHistoryRange is returned for unusable history ranges
+var ErrInvalidHistoryRange = errors.New("invalid history range")
+
+// maxHistoryPoints bounds the points returned for one history request
+const maxHistoryPoints = 10000
+
+// HistoryRange is a time range and the step its points are aggregated to
+type HistoryRange struct {
+	From time.Time
+	To   time.Time
+	Step time.Duration
+}
+
+// SLOHistoryPoint is the SLO state aggregated over one step
+type SLOHistoryPoint struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Value       float64   `json:"value"`
+	ErrorBudget float64   `json:"error_budget"`
+	BurnRate    float64   `json:"burn_rate"`
+}
+
+// parseStep accepts Go durations and whole days such as "1d"
+func parseStep(step string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(step, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid step %q", step)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(step)
+	if err != nil {
+		return 0, fmt.Errorf("invalid step %q", step)
+	}
+	return d, nil
+}
+
+// defaultStep picks a step matching the stored resolution for the range length
+func defaultStep(span time.Duration) time.Duration {
+	switch {
+	case span <= 2*24*time.Hour:
+		return 5 * time.Minute
+	case span <= 31*24*time.Hour:
+		return time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// ParseHistoryRange parses RFC 3339 from and to times and a step. The range
+// defaults to the last 7 days and the step to one matching its length.
+func ParseHistoryRange(from, to, step string, now time.Time) (HistoryRange, error) {
+	r := HistoryRange{To: now}
+	var err error
+	if to != "" {
+		if r.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return r, fmt.Errorf("%w: invalid to %q", ErrInvalidHistoryRange, to)
+		}
+	}
+	r.From = r.To.Add(-7 * 24 * time.Hour)
+	if from != "" {
+		if r.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return r, fmt.Errorf("%w: invalid from %q", ErrInvalidHistoryRange, from)
+		}
+	}
+	if !r.From.Before(r.To) {
+		return r, fmt.Errorf("%w: from must be before to", ErrInvalidHistoryRange)
+	}
+
+	r.Step = defaultStep(r.To.Sub(r.From))
+	if step != "" {
+		if r.Step, err = parseStep(step); err != nil {
+			return r, fmt.Errorf("%w: %v", ErrInvalidHistoryRange, err)
+		}
+	}
+	if r.Step < time.Minute {
+		return r, fmt.Errorf("%w: step must be at least 1m", ErrInvalidHistoryRange)
+	}
+	if r.To.Sub(r.From)/r.Step > maxHistoryPoints {
+		return r, fmt.Errorf("%w: more than %d points, use a larger step", ErrInvalidHistoryRange, maxHistoryPoints)
+	}
+	return r, nil
+}
+
+// historyRows selects the finest resolution stored for each point in time of
+// SLO $1: coarser rows are only used before the first row of the next finer
+// resolution.
+const historyRows = `
+	SELECT timestamp, value, error_budget, burn_rate
+	FROM slo_history
+	WHERE slo_id = $1
+	  AND (resolution = 'raw' OR timestamp < COALESCE(
+	          (SELECT MIN(timestamp) FROM slo_history WHERE slo_id = $1 AND resolution = 'raw'), 'infinity'))
+	  AND (resolution != 'daily' OR timestamp < COALESCE(
+	          (SELECT MIN(timestamp) FROM slo_history WHERE slo_id = $1 AND resolution = 'hourly'), 'infinity'))
+`
+
+// GetSLOHistory returns the SLO history in the range, averaged per step. The
+// error budget of a step is the last one recorded in it.
+func (s *SLOService) GetSLOHistory(ctx context.Context, sloID string, r HistoryRange) ([]SLOHistoryPoint, error) {
+	query := `
+		WITH h AS (` + historyRows + `)
+		SELECT to_timestamp(floor(extract(epoch FROM timestamp) / $4) * $4) AT TIME ZONE 'UTC' AS bucket,
+		       AVG(value), (array_agg(error_budget ORDER BY timestamp DESC))[1], AVG(burn_rate)
+		FROM h
+		WHERE timestamp >= $2 AND timestamp < $3
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+	rows, err := s.db.QueryContext(ctx, query, sloID, r.From.UTC(), r.To.UTC(), r.Step.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLO history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []SLOHistoryPoint{}
+	for rows.Next() {
+		var p SLOHistoryPoint
+		if err := rows.Scan(&p.Timestamp, &p.Value, &p.ErrorBudget, &p.BurnRate); err != nil {
+			return nil, fmt.Errorf("failed to scan SLO history: %w", err)
+		}
+		history = append(history, p)
+	}
+	return history, rows.Err()
+}
+
+// SetHistoryRetention overrides the default history retention
+func (s *SLOService) SetHistoryRetention(retention HistoryRetention) {
+	s.retention = retention
+}
+
+// DownsampleResult counts the rows written and deleted by one downsampling run
+type DownsampleResult struct {
+	Hourly  int64 `json:"hourly"`
+	Daily   int64 `json:"daily"`
+	Deleted int64 `json:"deleted"`
+}
+
+// rollupQuery aggregates buckets of one resolution into the next. Only
+// buckets in [$2, $1) are recomputed, so incomplete buckets and buckets that
+// lost rows to retention are left alone. $3 limits the rollup to one SLO.
+const rollupQuery = `
+	INSERT INTO slo_history (slo_id, timestamp, value, error_budget, burn_rate, resolution)
+	SELECT h.slo_id, date_trunc('%[1]s', h.timestamp) AS bucket, AVG(h.value),
+	       (array_agg(h.error_budget ORDER BY h.timestamp DESC))[1], AVG(h.burn_rate), '%[3]s'
+	FROM slo_history h
+	WHERE h.resolution = '%[2]s'
+	  AND date_trunc('%[1]s', h.timestamp) < date_trunc('%[1]s', $1::timestamp)
+	  AND date_trunc('%[1]s', h.timestamp) >= $2
+	  AND ($3::text = '' OR h.slo_id::text = $3)
+	GROUP BY h.slo_id, bucket
+	ON CONFLICT (slo_id, resolution, timestamp) WHERE resolution != 'raw'
+	DO UPDATE SET value = EXCLUDED.value, error_budget = EXCLUDED.error_budget, burn_rate = EXCLUDED.burn_rate
+`
+
+// DownsampleHistory rolls raw rows up into hourly rows and hourly rows into
+// daily rows, then deletes rows past their retention
+func (s *SLOService) DownsampleHistory(ctx context.Context, now time.Time) (DownsampleResult, error) {
+	now = now.UTC()
+	result, err := s.rollup(ctx, "", now, now.Add(-s.retention.Raw), now.Add(-s.retention.Hourly))
+	if err != nil {
+		return result, err
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM slo_history
+		WHERE (resolution = 'raw' AND timestamp < $1) OR (resolution = 'hourly' AND timestamp < $2)
+	`, now.Add(-s.retention.Raw), now.Add(-s.retention.Hourly))
+	if err != nil {
+		return result, fmt.Errorf("failed to delete expired history: %w", err)
+	}
+	result.Deleted, _ = res.RowsAffected()
+
+	return result, nil
+}
+
+// rollup recomputes hourly buckets from rawSince and daily buckets from
+// hourlySince up to until, for one SLO or all SLOs
+func (s *SLOService) rollup(ctx context.Context, sloID string, until, rawSince, hourlySince time.Time) (DownsampleResult, error) {
+	var result DownsampleResult
+
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(rollupQuery, "hour", ResolutionRaw, ResolutionHourly), until, rawSince, sloID)
+	if err != nil {
+		return result, fmt.Errorf("failed to roll up hourly history: %w", err)
+	}
+	result.Hourly, _ = res.RowsAffected()
+
+	res, err = s.db.ExecContext(ctx, fmt.Sprintf(rollupQuery, "day", ResolutionHourly, ResolutionDaily), until, hourlySince, sloID)
+	if err != nil {
+		return result, fmt.Errorf("failed to roll up daily history: %w", err)
+	}
+	result.Daily, _ = res.RowsAffected()
+
+	return result, nil
+}
+
+// maxRangePoints keeps each Prometheus range query below its 11,000 point limit
+const maxRangePoints = 10000
+
+// BackfillHistory computes SLI values for the SLO from Prometheus between from
+// and the first stored row (or now) and stores them as raw history, then
+// downsamples. It returns the number of points stored.
+func (s *SLOService) BackfillHistory(ctx context.Context, sloID string, from time.Time, step time.Duration) (int, error) {
+	if s.prometheus == nil {
+		return 0, fmt.Errorf("prometheus is not configured")
+	}
+	if step < time.Minute {
+		return 0, fmt.Errorf("step must be at least 1m")
+	}
+	slo, err := s.GetSLO(ctx, sloID)
+	if err != nil {
+		return 0, err
+	}
+
+	to := time.Now().UTC()
+	var first sql.NullTime
+	if err := s.db.QueryRowContext(ctx, `SELECT MIN(timestamp) FROM slo_history WHERE slo_id = $1`, sloID).Scan(&first); err != nil {
+		return 0, err
+	}
+	if first.Valid && first.Time.Before(to) {
+		to = first.Time
+	}
+
+	stored := 0
+	for start := from.UTC(); start.Before(to); {
+		end := start.Add(step * maxRangePoints)
+		if end.After(to) {
+			end = to
+		}
+		resp, err := s.prometheus.QueryRange(ctx, slo.SLIQuery, start, end, step)
+		if err != nil {
+			return stored, fmt.Errorf("failed to query Prometheus: %w", err)
+		}
+		n, err := s.storeBackfill(ctx, slo, resp.Data.Result, to)
+		stored += n
+		if err != nil {
+			return stored, err
+		}
+		start = end.Add(step)
+	}
+
+	if stored > 0 {
+		// The backfilled range may be older than the retention, so roll it
+		// up before the next downsampling run deletes it
+		since := from.UTC().Truncate(24 * time.Hour)
+		if _, err := s.rollup(ctx, sloID, to, since, since); err != nil {
+			return stored, err
+		}
+		s.refreshMetrics(ctx)
+	}
+	s.logger.Info("Backfilled SLO history", zap.String("slo_id", sloID), zap.Int("points", stored))
+	return stored, nil
+}
+
+func (s *SLOService) storeBackfill(ctx context.Context, slo *SLO, result []clients.PrometheusResult, before time.Time) (int, error) {
+	if len(result) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stored := 0
+	for _, pair := range result[0].Values {
+		if len(pair) < 2 {
+			continue
+		}
+		ts, ok := pair[0].(float64)
+		raw, isString := pair[1].(string)
+		if !ok || !isString {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		if slo.Type == slorules.IndicatorErrorRate {
+			value = 100 - value
+		}
+		at := time.Unix(0, int64(ts*float64(time.Second))).UTC()
+		if !at.Before(before) {
+			continue
+		}
+
+		budget, burnRate, _ := errorBudget(slo.Target, value)
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO slo_history (slo_id, timestamp, value, error_budget, burn_rate)
+			VALUES ($1, $2, $3, $4, $5)
+		`, slo.ID, at, value, budget, burnRate); err != nil {
+			return 0, fmt.Errorf("failed to store backfilled history: %w", err)
+		}
+		stored++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return stored, nil
+}
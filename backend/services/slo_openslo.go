@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sarika-03/Reliability-Studio/openslo"
+	"go.uber.org/zap"
+)
+
+// OpenSLOImportResult reports one upserted SLO row
+type OpenSLOImportResult struct {
+	ID      string `json:"id"`
+	Service string `json:"service"`
+	Name    string `json:"name"`
+	Created bool   `json:"created"`
+}
+
+// ImportOpenSLO upserts the services and slos rows described by resolved OpenSLO
+// definitions into an organization. All rows are written in one transaction.
+func (s *SLOService) ImportOpenSLO(ctx context.Context, orgID string, defs []openslo.Definition) ([]OpenSLOImportResult, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	serviceIDs := make(map[string]string)
+	results := make([]OpenSLOImportResult, 0, len(defs))
+	for _, def := range defs {
+		serviceID, ok := serviceIDs[def.Service]
+		if !ok {
+			err := tx.QueryRowContext(ctx, `
+				INSERT INTO services (name, description, org_id)
+				VALUES ($1, NULLIF($2, ''), $3)
+				ON CONFLICT (org_id, name) DO UPDATE
+				SET description = COALESCE(NULLIF(EXCLUDED.description, ''), services.description), updated_at = NOW()
+				RETURNING id
+			`, def.Service, def.ServiceDescription, orgID).Scan(&serviceID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to upsert service %s: %w", def.Service, err)
+			}
+			serviceIDs[def.Service] = serviceID
+		}
+
+		bundle, err := json.Marshal(def.Documents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode OpenSLO documents for %s: %w", def.Name, err)
+		}
+
+		result := OpenSLOImportResult{Service: def.Service, Name: def.Name}
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO slos (
+				service_id, name, description, objective, window_days, sli_query,
+				indicator_type, good_query, bad_query, total_query,
+				threshold_query, threshold_operator, threshold_value,
+				budgeting_method, source, openslo, org_id
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''),
+			        NULLIF($11, ''), NULLIF($12, ''), $13, $14, 'openslo', $15, $16)
+			ON CONFLICT (service_id, name) DO UPDATE SET
+				description = EXCLUDED.description,
+				objective = EXCLUDED.objective,
+				window_days = EXCLUDED.window_days,
+				sli_query = EXCLUDED.sli_query,
+				indicator_type = EXCLUDED.indicator_type,
+				good_query = EXCLUDED.good_query,
+				bad_query = EXCLUDED.bad_query,
+				total_query = EXCLUDED.total_query,
+				threshold_query = EXCLUDED.threshold_query,
+				threshold_operator = EXCLUDED.threshold_operator,
+				threshold_value = EXCLUDED.threshold_value,
+				budgeting_method = EXCLUDED.budgeting_method,
+				source = EXCLUDED.source,
+				openslo = EXCLUDED.openslo,
+				updated_at = NOW()
+			RETURNING id, (xmax = 0)
+		`,
+			serviceID, def.Name, def.Description, def.Objective, def.WindowDays, def.SLIQuery(),
+			def.IndicatorType, def.GoodQuery, def.BadQuery, def.TotalQuery,
+			def.ThresholdQuery, def.ThresholdOp, def.ThresholdValue,
+			def.BudgetingMethod, bundle, orgID,
+		).Scan(&result.ID, &result.Created)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upsert SLO %s/%s: %w", def.Service, def.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit OpenSLO import: %w", err)
+	}
+	s.logger.Info("Imported OpenSLO definitions", zap.Int("slos", len(results)))
+	return results, nil
+}
+
+// ExportOpenSLO returns the definitions of all SLOs of an organization, or of
+// one service when service is set. SLOs that were not imported from OpenSLO
+// are exported as threshold indicators on their sli_query.
+func (s *SLOService) ExportOpenSLO(ctx context.Context, orgID, service string) ([]openslo.Definition, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sv.name, COALESCE(sv.description, ''), s.name, COALESCE(s.description, ''),
+		       s.objective, s.window_days, s.sli_query, COALESCE(s.budgeting_method, ''), s.openslo,
+		       COALESCE(s.indicator_type, ''), COALESCE(s.good_query, ''), COALESCE(s.total_query, '')
+		FROM slos s
+		JOIN services sv ON sv.id = s.service_id
+		WHERE sv.org_id = $2 AND ($1 = '' OR sv.name = $1)
+		ORDER BY sv.name, s.name
+	`, service, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLOs: %w", err)
+	}
+	defer rows.Close()
+
+	var defs []openslo.Definition
+	for rows.Next() {
+		var def openslo.Definition
+		var sliQuery, indicatorType, goodQuery, totalQuery string
+		var bundle []byte
+		if err := rows.Scan(
+			&def.Service, &def.ServiceDescription, &def.Name, &def.Description,
+			&def.Objective, &def.WindowDays, &sliQuery, &def.BudgetingMethod, &bundle,
+			&indicatorType, &goodQuery, &totalQuery,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan SLO: %w", err)
+		}
+
+		if len(bundle) > 0 {
+			if err := json.Unmarshal(bundle, &def.Documents); err != nil {
+				s.logger.Warn("Ignoring invalid stored OpenSLO documents", zap.String("slo", def.Name), zap.Error(err))
+				def.Documents = openslo.Bundle{}
+			}
+		}
+		switch {
+		case def.Documents.SLO.Kind != "":
+			// Imported documents are exported as they were
+		case indicatorType == "latency":
+			// Latency SLOs are a ratio of fast requests over all requests
+			def.IndicatorType = openslo.IndicatorRatio
+			def.GoodQuery = goodQuery
+			def.TotalQuery = totalQuery
+		default:
+			// Legacy rows have no indicator details: the SLI query is expected to
+			// return a percentage that must stay at or above the objective
+			value := def.Objective
+			def.IndicatorType = openslo.IndicatorThreshold
+			def.ThresholdQuery = sliQuery
+			def.ThresholdOp = "gte"
+			def.ThresholdValue = &value
+		}
+		defs = append(defs, def)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/sarika-03/Reliability-Studio/slorules"
+)
+
+// GenerateRules returns the Prometheus recording and alerting rule file for the
+// SLOs of a service of an organization. sql.ErrNoRows is returned when the
+// service has no SLOs.
+func (s *SLOService) GenerateRules(ctx context.Context, orgID, service string) ([]byte, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sv.name, s.name, s.objective, s.window_days, s.sli_query,
+		       COALESCE(s.indicator_type, ''), COALESCE(s.good_query, ''), COALESCE(s.bad_query, ''),
+		       COALESCE(s.total_query, ''), COALESCE(s.threshold_query, ''),
+		       COALESCE(s.threshold_operator, ''), COALESCE(s.threshold_value, 0)
+		FROM slos s
+		JOIN services sv ON sv.id = s.service_id
+		WHERE sv.name = $1 AND sv.org_id = $2
+		ORDER BY s.name
+	`, service, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLOs: %w", err)
+	}
+	defer rows.Close()
+
+	var slos []slorules.SLO
+	for rows.Next() {
+		var slo slorules.SLO
+		if err := rows.Scan(
+			&slo.Service, &slo.Name, &slo.Objective, &slo.WindowDays, &slo.SLIQuery,
+			&slo.IndicatorType, &slo.GoodQuery, &slo.BadQuery,
+			&slo.TotalQuery, &slo.ThresholdQuery,
+			&slo.ThresholdOp, &slo.ThresholdValue,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan SLO: %w", err)
+		}
+		slos = append(slos, slo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(slos) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return slorules.Generate(slos).YAML()
+}
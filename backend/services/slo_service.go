@@ -4,26 +4,43 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"time"
 	"github.com/jmoiron/sqlx"
+	"github.com/sarika-03/Reliability-Studio/clients"
+	"github.com/sarika-03/Reliability-Studio/metrics"
+	"github.com/sarika-03/Reliability-Studio/slorules"
 	"go.uber.org/zap"
 )
 
 // SLOService handles SLO calculations and management
 type SLOService struct {
-	db     *sqlx.DB
-	logger *zap.Logger
+	db         *sqlx.DB
+	logger     *zap.Logger
+	prometheus *clients.PrometheusClient
+
+	onPolicyTransition func(PolicyTransition)
+	metrics            *metrics.Snapshot
+	retention          HistoryRetention
 }
 
 // NewSLOService creates a new SLOService instance
 func NewSLOService(db *sql.DB, logger *zap.Logger) *SLOService {
 	return &SLOService{
-		db:     sqlx.NewDb(db, "postgres"),
-		logger: logger,
+		db:        sqlx.NewDb(db, "postgres"),
+		logger:    logger,
+		retention: DefaultHistoryRetention(),
 	}
 }
 
+// SetPrometheusClient enables SLO calculations against Prometheus
+func (s *SLOService) SetPrometheusClient(client *clients.PrometheusClient) {
+	s.prometheus = client
+}
+
 // SLO represents a local SLO type with all required fields
 type SLO struct {
 	ID              string     `db:"id"`
@@ -36,8 +53,47 @@ type SLO struct {
 	Current         *float64   `db:"current"`
 	Status          string     `db:"status"`
 	LastCalculated  *time.Time `db:"last_calculated"`
+	SLIQuery        string     `db:"sli_query"`
+
+	// Latency SLOs count the share of requests faster than LatencyThreshold
+	// (seconds) from the buckets of the LatencyMetric histogram
+	LatencyMetric    string   `db:"latency_metric"`
+	LatencyThreshold *float64 `db:"latency_threshold"`
+	LatencySelector  string   `db:"latency_selector"`
+
+	// Ratio and threshold SLOs measure their SLI with these queries
+	GoodQuery      string  `db:"good_query"`
+	BadQuery       string  `db:"bad_query"`
+	TotalQuery     string  `db:"total_query"`
+	ThresholdQuery string  `db:"threshold_query"`
+	ThresholdOp    string  `db:"threshold_operator"`
+	ThresholdValue float64 `db:"threshold_value"`
 }
 
+// rule returns the indicator of the SLO as used for the generated rules
+func (slo *SLO) rule() slorules.SLO {
+	return slorules.SLO{
+		Service:        slo.Service,
+		Name:           slo.Name,
+		Objective:      slo.Target,
+		WindowDays:     slo.Window,
+		IndicatorType:  slo.Type,
+		SLIQuery:       slo.SLIQuery,
+		GoodQuery:      slo.GoodQuery,
+		BadQuery:       slo.BadQuery,
+		TotalQuery:     slo.TotalQuery,
+		ThresholdQuery: slo.ThresholdQuery,
+		ThresholdOp:    slo.ThresholdOp,
+		ThresholdValue: slo.ThresholdValue,
+	}
+}
+
+// ErrInvalidSLO is returned when an SLO definition is rejected
+var ErrInvalidSLO = errors.New("invalid SLO")
+
+// DefaultLatencyMetric is the histogram used by latency SLOs without a metric
+const DefaultLatencyMetric = "http_request_duration_seconds"
+
 // SLOError represents a detailed SLO calculation error
 type SLOError struct {
 	Type        string   `json:"type"`
@@ -57,15 +113,24 @@ type SLOAnalysis struct {
 	Error        *SLOError  `json:"error,omitempty"`
 	Service      string     `json:"service"`
 	MetricType   string     `json:"metric_type"`
+	BurnRate     *float64   `json:"burn_rate,omitempty"`
+	Source       string     `json:"source,omitempty"` // recording_rule or live_query
 }
 
 // GetSLO retrieves an SLO by ID
 func (s *SLOService) GetSLO(ctx context.Context, sloID string) (*SLO, error) {
 	query := `
-		SELECT id, name, description, service, type, target, window, 
-		       COALESCE(current_value, NULL) as current, status
-		FROM slos
-		WHERE id = $1
+		SELECT s.id, s.name, COALESCE(s.description, '') AS description, sv.name AS service,
+		       COALESCE(s.indicator_type, 'availability') AS type, s.objective AS target,
+		       s.window_days AS "window", NULL::float AS current, COALESCE(s.status, 'healthy') AS status,
+		       s.sli_query, COALESCE(s.latency_metric, '') AS latency_metric, s.latency_threshold,
+		       COALESCE(s.latency_selector, '') AS latency_selector,
+		       COALESCE(s.good_query, '') AS good_query, COALESCE(s.bad_query, '') AS bad_query,
+		       COALESCE(s.total_query, '') AS total_query, COALESCE(s.threshold_query, '') AS threshold_query,
+		       COALESCE(s.threshold_operator, '') AS threshold_operator, COALESCE(s.threshold_value, 0) AS threshold_value
+		FROM slos s
+		JOIN services sv ON sv.id = s.service_id
+		WHERE s.id = $1
 	`
 	
 	var slo SLO
@@ -112,20 +177,48 @@ func (s *SLOService) CalculateSLO(ctx context.Context, sloID string) (*SLOAnalys
 		return analysis, nil
 	}
 
-	// For now, return a placeholder analysis
-	// TODO: Integrate with Prometheus for actual metric queries
-	analysis.Status = "no_data"
-	analysis.Error = &SLOError{
-		Type:    "prometheus_not_configured",
-		Message: "Prometheus integration not yet configured",
-		Details: "SLO calculation requires Prometheus integration",
-		Suggestions: []string{
-			"Configure Prometheus connection in the backend",
-			"Ensure Prometheus is running and accessible",
-			"Verify metrics are being scraped for this service",
-		},
+	if s.prometheus == nil {
+		analysis.Status = "no_data"
+		analysis.Error = &SLOError{
+			Type:    "prometheus_not_configured",
+			Message: "Prometheus integration not yet configured",
+			Details: "SLO calculation requires Prometheus integration",
+			Suggestions: []string{
+				"Configure Prometheus connection in the backend",
+				"Ensure Prometheus is running and accessible",
+				"Verify metrics are being scraped for this service",
+			},
+		}
+		return analysis, nil
 	}
 
+	value, source, err := s.measureSLI(ctx, slo)
+	if err != nil {
+		analysis.Status = "error"
+		analysis.Error = categorizePrometheusError(err, slo.Service)
+		return analysis, nil
+	}
+	if value == nil {
+		analysis.Status = "no_data"
+		analysis.Error = &SLOError{
+			Type:    "no_data",
+			Message: "The SLI query returned no data",
+			Details: slo.SLIQuery,
+			Suggestions: []string{
+				fmt.Sprintf("Verify '%s' is exporting metrics", slo.Service),
+				"Load the generated recording rules into Prometheus",
+			},
+		}
+		return analysis, nil
+	}
+
+	analysis.Value = value
+	analysis.Source = source
+	budget, burnRate, status := errorBudget(slo.Target, *value)
+	analysis.ErrorBudget = &budget
+	analysis.BurnRate = &burnRate
+	analysis.Status = status
+
 	// Save to database
 	if err := s.saveSLOAnalysis(ctx, analysis); err != nil {
 		// Log but don't fail the request
@@ -135,6 +228,71 @@ func (s *SLOService) CalculateSLO(ctx context.Context, sloID string) (*SLOAnalys
 	return analysis, nil
 }
 
+// measureSLI returns the SLI as a percentage over the SLO window. The series
+// recorded by the generated rules are used when Prometheus has them, otherwise
+// the error ratio those rules record is evaluated directly.
+func (s *SLOService) measureSLI(ctx context.Context, slo *SLO) (*float64, string, error) {
+	window := slorules.PeriodWindow(slo.Window)
+	recorded := slorules.ErrorRatioMetric(window) + slorules.Selector(slo.Service, slo.Name)
+	if ratio, ok, err := s.queryScalar(ctx, recorded); err == nil && ok {
+		value := (1 - ratio) * 100
+		return &value, "recording_rule", nil
+	} else if err != nil {
+		s.logger.Debug("Recorded SLI series unavailable", zap.String("slo_id", slo.ID), zap.Error(err))
+	}
+
+	ratio, ok, err := s.queryScalar(ctx, slo.rule().ErrorRatioExpr(window))
+	if err != nil || !ok {
+		return nil, "", err
+	}
+	value := (1 - ratio) * 100
+	return &value, "live_query", nil
+}
+
+// queryScalar evaluates query and returns the value of its first sample
+func (s *SLOService) queryScalar(ctx context.Context, query string) (float64, bool, error) {
+	resp, err := s.prometheus.Query(ctx, query, time.Now())
+	if err != nil {
+		return 0, false, err
+	}
+	if len(resp.Data.Result) == 0 || len(resp.Data.Result[0].Value) < 2 {
+		return 0, false, nil
+	}
+	raw, ok := resp.Data.Result[0].Value[1].(string)
+	if !ok {
+		return 0, false, fmt.Errorf("unexpected value type %T", resp.Data.Result[0].Value[1])
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) {
+		return 0, false, nil
+	}
+	return value, true, nil
+}
+
+// errorBudget returns the remaining error budget in percent, the burn rate over
+// the window and the resulting status for an SLI value against its target
+func errorBudget(target, value float64) (float64, float64, string) {
+	allowed := 100.0 - target
+	observed := 100.0 - value
+	if allowed <= 0 {
+		// A 100% target has no budget; report the raw error percentage as burn rate
+		if observed > 0 {
+			return -100, observed, "critical"
+		}
+		return 100, 0, "healthy"
+	}
+	remaining := ((allowed - observed) / allowed) * 100
+	burnRate := observed / allowed
+
+	status := "healthy"
+	if remaining < 25 {
+		status = "critical"
+	} else if remaining < 50 {
+		status = "warning"
+	}
+	return remaining, burnRate, status
+}
+
 // validateSLOConfig ensures SLO configuration is valid
 func (s *SLOService) validateSLOConfig(slo *SLO) error {
 	if slo.Target < 0 || slo.Target > 100 {
@@ -149,10 +307,16 @@ func (s *SLOService) validateSLOConfig(slo *SLO) error {
 		"availability": true,
 		"latency":      true,
 		"error_rate":   true,
+		"ratio":        true,
+		"threshold":    true,
 	}
 
 	if !validTypes[slo.Type] {
-		return fmt.Errorf("invalid metric type '%s', must be one of: availability, latency, error_rate", slo.Type)
+		return fmt.Errorf("invalid metric type '%s', must be one of: availability, latency, error_rate, ratio, threshold", slo.Type)
+	}
+
+	if slo.Type == "latency" && (slo.LatencyThreshold == nil || *slo.LatencyThreshold <= 0) {
+		return fmt.Errorf("latency SLOs need a positive LatencyThreshold in seconds")
 	}
 
 	return nil
@@ -215,11 +379,12 @@ func categorizePrometheusError(err error, service string) *SLOError {
 	}
 }
 
-// buildPrometheusQuery creates the appropriate query based on SLO type
+// buildPrometheusQuery creates the SLI query, as a percentage of good events
+// over the SLO window, based on SLO type
 func (s *SLOService) buildPrometheusQuery(slo *SLO) (string, error) {
-	timeWindow := fmt.Sprintf("%dm", slo.Window)
+	timeWindow := fmt.Sprintf("%dd", slo.Window)
 	if slo.Window <= 0 {
-		timeWindow = "5m"
+		timeWindow = "30d"
 	}
 
 	switch slo.Type {
@@ -230,10 +395,11 @@ func (s *SLOService) buildPrometheusQuery(slo *SLO) (string, error) {
 		), nil
 
 	case "latency":
-		return fmt.Sprintf(
-			`histogram_quantile(0.99, sum(rate(http_request_duration_seconds_bucket{service="%s"}[%s])) by (le)) * 1000`,
-			slo.Service, timeWindow,
-		), nil
+		if slo.LatencyThreshold == nil {
+			return "", fmt.Errorf("latency SLOs need a threshold")
+		}
+		good, total := latencyQueries(slo, timeWindow)
+		return fmt.Sprintf("100 * (%s) / (%s)", good, total), nil
 
 	case "error_rate":
 		return fmt.Sprintf(
@@ -246,6 +412,24 @@ func (s *SLOService) buildPrometheusQuery(slo *SLO) (string, error) {
 	}
 }
 
+// latencyQueries returns the rate of requests within the latency threshold and
+// the rate of all requests, from the buckets of the latency histogram
+func latencyQueries(slo *SLO, window string) (good, total string) {
+	metric := slo.LatencyMetric
+	if metric == "" {
+		metric = DefaultLatencyMetric
+	}
+	selector := slo.LatencySelector
+	if selector == "" {
+		selector = fmt.Sprintf(`service="%s"`, slo.Service)
+	}
+	le := strconv.FormatFloat(*slo.LatencyThreshold, 'f', -1, 64)
+
+	good = fmt.Sprintf(`sum(rate(%s_bucket{%s,le="%s"}[%s]))`, metric, selector, le, window)
+	total = fmt.Sprintf(`sum(rate(%s_count{%s}[%s]))`, metric, selector, window)
+	return good, total
+}
+
 // parseMetricValue extracts numeric value from Prometheus result
 func parseMetricValue(result interface{}) (float64, error) {
 	// Implementation depends on your Prometheus client library
@@ -268,51 +452,61 @@ func parseMetricValue(result interface{}) (float64, error) {
 
 // saveSLOAnalysis persists the analysis to database
 func (s *SLOService) saveSLOAnalysis(ctx context.Context, analysis *SLOAnalysis) error {
-	query := `
-		INSERT INTO slo_history (slo_id, value, target, error_budget, status, calculated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`
-
-	var value sql.NullFloat64
-	if analysis.Value != nil {
-		value = sql.NullFloat64{Float64: *analysis.Value, Valid: true}
-	}
-
-	var errorBudget sql.NullFloat64
-	if analysis.ErrorBudget != nil {
-		errorBudget = sql.NullFloat64{Float64: *analysis.ErrorBudget, Valid: true}
+	if analysis.Value == nil || analysis.ErrorBudget == nil || analysis.BurnRate == nil {
+		return nil
 	}
 
+	query := `
+		INSERT INTO slo_history (slo_id, timestamp, value, error_budget, burn_rate)
+		VALUES ($1, $2, $3, $4, $5)
+	`
 	_, err := s.db.ExecContext(ctx, query,
 		analysis.SLOID,
-		value,
-		analysis.Target,
-		errorBudget,
-		analysis.Status,
 		analysis.CalculatedAt,
+		*analysis.Value,
+		*analysis.ErrorBudget,
+		*analysis.BurnRate,
 	)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE slos SET error_budget_remaining = $1, burn_rate = $2, status = $3
+		WHERE id = $4
+	`, *analysis.ErrorBudget, *analysis.BurnRate, analysis.Status, analysis.SLOID)
+	if err != nil {
+		return err
+	}
+	if s.metrics != nil {
+		s.metrics.UpdateSLO(analysis.SLOID, *analysis.Value, *analysis.ErrorBudget, *analysis.BurnRate)
+	}
 
-	return err
+	return s.evaluatePolicies(ctx, analysis.SLOID, *analysis.ErrorBudget)
 }
 
-// GetAllSLOs returns all SLOs with their latest status
-func (s *SLOService) GetAllSLOs(ctx context.Context) ([]SLO, error) {
+// GetAllSLOs returns the SLOs of an organization, or of all of them when orgID
+// is empty, with their latest status
+func (s *SLOService) GetAllSLOs(ctx context.Context, orgID string) ([]SLO, error) {
 	query := `
 		SELECT 
-			s.id, s.name, s.description, s.service, s.type, s.target, s.window,
-			h.value, h.status, h.calculated_at
+			s.id, s.name, COALESCE(s.description, ''), sv.name, COALESCE(s.indicator_type, 'availability'),
+			s.objective, s.window_days, s.sli_query, COALESCE(s.latency_metric, ''), s.latency_threshold,
+			COALESCE(s.latency_selector, ''), h.value, s.status, h.timestamp
 		FROM slos s
+		JOIN services sv ON sv.id = s.service_id
 		LEFT JOIN LATERAL (
-			SELECT value, status, calculated_at
+			SELECT value, timestamp
 			FROM slo_history
 			WHERE slo_id = s.id
-			ORDER BY calculated_at DESC
+			ORDER BY timestamp DESC
 			LIMIT 1
 		) h ON true
-		ORDER BY s.name
+		WHERE $1 = '' OR s.org_id = $1
+		ORDER BY sv.name, s.name
 	`
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query, orgID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query SLOs: %w", err)
 	}
@@ -321,23 +515,27 @@ func (s *SLOService) GetAllSLOs(ctx context.Context) ([]SLO, error) {
 	var slos []SLO
 	for rows.Next() {
 		var slo SLO
-		var value sql.NullFloat64
+		var value, latencyThreshold sql.NullFloat64
 		var status sql.NullString
 		var calculatedAt sql.NullTime
 
 		err := rows.Scan(
 			&slo.ID, &slo.Name, &slo.Description, &slo.Service,
-			&slo.Type, &slo.Target, &slo.Window,
+			&slo.Type, &slo.Target, &slo.Window, &slo.SLIQuery,
+			&slo.LatencyMetric, &latencyThreshold, &slo.LatencySelector,
 			&value, &status, &calculatedAt,
 		)
 		if err != nil {
 			continue
 		}
 
+		if latencyThreshold.Valid {
+			slo.LatencyThreshold = &latencyThreshold.Float64
+		}
 		if value.Valid {
 			slo.Current = &value.Float64
 		}
-		if status.Valid {
+		if value.Valid && status.Valid {
 			slo.Status = status.String
 		} else {
 			slo.Status = "no_data"
@@ -352,53 +550,121 @@ func (s *SLOService) GetAllSLOs(ctx context.Context) ([]SLO, error) {
 	return slos, nil
 }
 
-// CreateSLO creates a new SLO
-func (s *SLOService) CreateSLO(ctx context.Context, slo *SLO) error {
+// CreateSLO creates a new SLO for an existing service of an organization. The
+// SLI query is built from the SLO type unless one is given.
+func (s *SLOService) CreateSLO(ctx context.Context, orgID string, slo *SLO) error {
+	if slo.Window <= 0 {
+		slo.Window = 30
+	}
+	if err := s.validateSLOConfig(slo); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSLO, err)
+	}
+	goodQuery, totalQuery, err := s.prepareQueries(slo)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO slos (id, name, description, service, type, target, window, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		INSERT INTO slos (
+			service_id, org_id, name, description, objective, window_days, sli_query, indicator_type,
+			good_query, total_query, latency_metric, latency_threshold, latency_selector
+		)
+		SELECT id, org_id, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, NULLIF($12, '')
+		FROM services
+		WHERE name = $1 AND org_id = $13
+		RETURNING id
 	`
-	
-	if slo.ID == "" {
-		slo.ID = fmt.Sprintf("slo_%d", time.Now().UnixNano())
+	err = s.db.QueryRowContext(ctx, query,
+		slo.Service, slo.Name, slo.Description, slo.Target, slo.Window, slo.SLIQuery, slo.Type,
+		goodQuery, totalQuery, slo.LatencyMetric, slo.LatencyThreshold, slo.LatencySelector, orgID,
+	).Scan(&slo.ID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: unknown service %s", ErrInvalidSLO, slo.Service)
+	} else if err != nil {
+		return err
 	}
-	
-	_, err := s.db.ExecContext(ctx, query,
-		slo.ID, slo.Name, slo.Description, slo.Service,
-		slo.Type, slo.Target, slo.Window, slo.Status,
-	)
-	
-	return err
+	s.refreshMetrics(ctx)
+	return nil
+}
+
+// prepareQueries fills in the SLI query and, for latency SLOs, returns the
+// good and total queries used to generate recording rules
+func (s *SLOService) prepareQueries(slo *SLO) (good, total string, err error) {
+	if slo.SLIQuery == "" {
+		if slo.SLIQuery, err = s.buildPrometheusQuery(slo); err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrInvalidSLO, err)
+		}
+	}
+	if slo.Type == "latency" {
+		good, total = latencyQueries(slo, "5m")
+	}
+	return good, total, nil
 }
 
 // UpdateSLO updates an existing SLO
 func (s *SLOService) UpdateSLO(ctx context.Context, slo *SLO) error {
+	if slo.Window <= 0 {
+		slo.Window = 30
+	}
+	if err := s.validateSLOConfig(slo); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSLO, err)
+	}
+	goodQuery, totalQuery, err := s.prepareQueries(slo)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE slos 
-		SET name = $1, description = $2, service = $3, type = $4, target = $5, window = $6, status = $7, updated_at = NOW()
-		WHERE id = $8
+		SET name = $1, description = $2, objective = $3, window_days = $4, sli_query = $5,
+		    indicator_type = $6,
+		    good_query = CASE WHEN $6 = 'latency' THEN NULLIF($7, '') ELSE good_query END,
+		    total_query = CASE WHEN $6 = 'latency' THEN NULLIF($8, '') ELSE total_query END,
+		    latency_metric = NULLIF($9, ''), latency_threshold = $10, latency_selector = NULLIF($11, ''),
+		    updated_at = NOW()
+		WHERE id = $12
 	`
 	
-	_, err := s.db.ExecContext(ctx, query,
-		slo.Name, slo.Description, slo.Service, slo.Type, slo.Target, slo.Window, slo.Status, slo.ID,
+	_, err = s.db.ExecContext(ctx, query,
+		slo.Name, slo.Description, slo.Target, slo.Window, slo.SLIQuery,
+		slo.Type, goodQuery, totalQuery,
+		slo.LatencyMetric, slo.LatencyThreshold, slo.LatencySelector, slo.ID,
 	)
-	
-	return err
+	if err != nil {
+		return err
+	}
+	s.refreshMetrics(ctx)
+	return nil
 }
 
 // CalculateAllSLOs calculates all SLOs
 func (s *SLOService) CalculateAllSLOs(ctx context.Context) error {
-	slos, err := s.GetAllSLOs(ctx)
+	slos, err := s.GetAllSLOs(ctx, "")
 	if err != nil {
 		return err
 	}
 	
+	measured := make(map[string]*SLOAnalysis, len(slos))
 	for _, slo := range slos {
-		_, err := s.CalculateSLO(ctx, slo.ID)
+		analysis, err := s.CalculateSLO(ctx, slo.ID)
 		if err != nil {
 			s.logger.Error("Failed to calculate SLO", zap.String("slo_id", slo.ID), zap.Error(err))
+			continue
 		}
+		measured[slo.ID] = analysis
 	}
+
+	// Composite SLOs reuse the component results calculated above
+	composites, err := s.GetCompositeSLOs(ctx, "")
+	if err != nil {
+		return err
+	}
+	for i := range composites {
+		if _, err := s.calculateComposite(ctx, &composites[i], measured); err != nil {
+			s.logger.Error("Failed to calculate composite SLO", zap.String("composite_id", composites[i].ID), zap.Error(err))
+		}
+	}
+	s.refreshMetrics(ctx)
 	
 	return nil
 }
@@ -406,47 +672,11 @@ func (s *SLOService) CalculateAllSLOs(ctx context.Context) error {
 // DeleteSLO deletes an SLO
 func (s *SLOService) DeleteSLO(ctx context.Context, sloID string) error {
 	query := `DELETE FROM slos WHERE id = $1`
-	_, err := s.db.ExecContext(ctx, query, sloID)
-	return err
-}
-
-// GetSLOHistory retrieves the history of an SLO
-func (s *SLOService) GetSLOHistory(ctx context.Context, sloID string) ([]SLOAnalysis, error) {
-	query := `
-		SELECT slo_id, value, target, error_budget, status, calculated_at
-		FROM slo_history
-		WHERE slo_id = $1
-		ORDER BY calculated_at DESC
-		LIMIT 100
-	`
-	
-	rows, err := s.db.QueryContext(ctx, query, sloID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	var history []SLOAnalysis
-	for rows.Next() {
-		var analysis SLOAnalysis
-		var value, errorBudget sql.NullFloat64
-		
-		err := rows.Scan(&analysis.SLOID, &value, &analysis.Target, &errorBudget, &analysis.Status, &analysis.CalculatedAt)
-		if err != nil {
-			continue
-		}
-		
-		if value.Valid {
-			analysis.Value = &value.Float64
-		}
-		if errorBudget.Valid {
-			analysis.ErrorBudget = &errorBudget.Float64
-		}
-		
-		history = append(history, analysis)
+	if _, err := s.db.ExecContext(ctx, query, sloID); err != nil {
+		return err
 	}
-	
-	return history, nil
+	s.refreshMetrics(ctx)
+	return nil
 }
 
 // Helper function
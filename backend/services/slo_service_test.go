@@ -2,13 +2,24 @@ package services
 
 import (
 	"context"
+	"errors"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/sarika-03/Reliability-Studio/clients"
+	"go.uber.org/zap"
 )
 
+// PrometheusQueryClient is the subset of the Prometheus client used for SLO calculations
+type PrometheusQueryClient interface {
+	Query(ctx context.Context, query string, timestamp time.Time) (*clients.PrometheusResponse, error)
+	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*clients.PrometheusResponse, error)
+}
+
 // MockPrometheusClient implements PrometheusQueryClient
 type MockPrometheusClient struct {
 	QueryFunc func(ctx context.Context, query string, timestamp time.Time) (*clients.PrometheusResponse, error)
@@ -66,3 +77,254 @@ func TestCalculateSLOLogic(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    float64
+		value     float64
+		remaining float64
+		burnRate  float64
+		status    string
+	}{
+		{"half of budget used", 99.9, 99.95, 50, 0.5, "healthy"},
+		{"budget exhausted", 99.9, 99.9, 0, 1, "critical"},
+		{"warning", 99, 99.4, 40, 0.6, "warning"},
+		{"no budget without errors", 100, 100, 100, 0, "healthy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining, burnRate, status := errorBudget(tt.target, tt.value)
+			if math.Abs(remaining-tt.remaining) > 0.0001 || math.Abs(burnRate-tt.burnRate) > 0.0001 || status != tt.status {
+				t.Errorf("errorBudget(%v, %v) = %v, %v, %s; want %v, %v, %s",
+					tt.target, tt.value, remaining, burnRate, status, tt.remaining, tt.burnRate, tt.status)
+			}
+		})
+	}
+}
+
+func TestBuildLatencyQuery(t *testing.T) {
+	threshold := 0.25
+	s := &SLOService{}
+	query, err := s.buildPrometheusQuery(&SLO{Service: "checkout", Type: "latency", Window: 28, LatencyThreshold: &threshold})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `100 * (sum(rate(http_request_duration_seconds_bucket{service="checkout",le="0.25"}[28d]))) / (sum(rate(http_request_duration_seconds_count{service="checkout"}[28d])))`
+	if query != want {
+		t.Errorf("buildPrometheusQuery() = %s, want %s", query, want)
+	}
+
+	if err := s.validateSLOConfig(&SLO{Service: "checkout", Type: "latency", Target: 99}); err == nil {
+		t.Error("expected a latency SLO without threshold to be rejected")
+	}
+}
+
+func TestMeasureSLIThresholdFallback(t *testing.T) {
+	var queries []string
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		queries = append(queries, query)
+		if strings.HasPrefix(query, "slo:") {
+			w.Write([]byte(`{"status": "success", "data": {"result": []}}`)) // no recording rules loaded
+			return
+		}
+		w.Write([]byte(`{"status": "success", "data": {"result": [{"value": [1, "0.25"]}]}}`))
+	}))
+	defer prom.Close()
+	s := &SLOService{logger: zap.NewNop(), prometheus: clients.NewPrometheusClient(prom.URL)}
+
+	value, source, err := s.measureSLI(context.Background(), &SLO{
+		Service: "checkout", Name: "p99", Type: "threshold", Window: 7,
+		SLIQuery: "p99_latency", ThresholdQuery: "p99_latency", ThresholdOp: "lt", ThresholdValue: 0.5,
+	})
+	if err != nil || value == nil {
+		t.Fatalf("measureSLI = %v, %v", value, err)
+	}
+	if *value != 75 || source != "live_query" {
+		t.Errorf("measureSLI = %v from %s, want 75 from live_query", *value, source)
+	}
+	want := "1 - avg_over_time(((p99_latency) < bool 0.5)[7d:1m])"
+	if len(queries) != 2 || queries[1] != want {
+		t.Errorf("queries = %q, want the threshold error ratio %q", queries, want)
+	}
+}
+
+func TestCompositeValue(t *testing.T) {
+	v := func(f float64) *float64 { return &f }
+	tests := []struct {
+		name       string
+		method     string
+		components []CompositeComponent
+		want       float64
+		wantErr    bool
+	}{
+		{
+			name:       "weighted",
+			method:     CompositeWeighted,
+			components: []CompositeComponent{{Weight: 3, Value: v(99)}, {Weight: 1, Value: v(95)}},
+			want:       98,
+		},
+		{
+			name:       "and multiplies success ratios",
+			method:     CompositeAnd,
+			components: []CompositeComponent{{Weight: 1, Value: v(99)}, {Weight: 1, Value: v(90)}},
+			want:       89.1,
+		},
+		{
+			name:       "missing component value",
+			method:     CompositeAnd,
+			components: []CompositeComponent{{Weight: 1, Value: v(99)}, {Weight: 1}},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown method",
+			method:     "or",
+			components: []CompositeComponent{{Weight: 1, Value: v(99)}},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompositeValue(tt.method, tt.components)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompositeValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && math.Abs(got-tt.want) > 0.0001 {
+				t.Errorf("CompositeValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateComposite(t *testing.T) {
+	c := &CompositeSLO{Name: "checkout-journey", Method: CompositeAnd, Objective: 99, Components: []CompositeComponent{{SLOID: "a"}, {SLOID: "b", Weight: 2}}}
+	if err := validateComposite(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Components[0].Weight != 1 {
+		t.Errorf("expected default weight 1, got %v", c.Components[0].Weight)
+	}
+
+	c.Components = append(c.Components, CompositeComponent{SLOID: "a"})
+	if err := validateComposite(c); !errors.Is(err, ErrInvalidCompositeSLO) {
+		t.Errorf("expected duplicate component to be rejected, got %v", err)
+	}
+}
+
+func TestPolicyState(t *testing.T) {
+	tests := []struct {
+		threshold float64
+		remaining float64
+		want      string
+	}{
+		{0, 10, PolicyStateOK},
+		{0, 0, PolicyStateTriggered},
+		{0, -50, PolicyStateTriggered},
+		{25, 25.1, PolicyStateOK},
+		{25, 24.9, PolicyStateTriggered},
+	}
+	for _, tt := range tests {
+		if got := policyState(tt.threshold, tt.remaining); got != tt.want {
+			t.Errorf("policyState(%v, %v) = %s, want %s", tt.threshold, tt.remaining, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ErrorBudgetPolicy
+		wantErr bool
+	}{
+		{"freeze", ErrorBudgetPolicy{Name: "freeze", Threshold: 0, Action: PolicyActionFreezeDeploys}, false},
+		{"notify with webhook", ErrorBudgetPolicy{Name: "notify", Threshold: 25, Action: PolicyActionNotify, WebhookURL: "https://hooks.example.com/x"}, false},
+		{"unknown action", ErrorBudgetPolicy{Name: "page", Threshold: 10, Action: "page"}, true},
+		{"threshold out of range", ErrorBudgetPolicy{Name: "x", Threshold: 120, Action: PolicyActionNotify}, true},
+		{"bad webhook", ErrorBudgetPolicy{Name: "x", Action: PolicyActionNotify, WebhookURL: "ftp://example.com"}, true},
+		{"loopback webhook", ErrorBudgetPolicy{Name: "x", Action: PolicyActionNotify, WebhookURL: "http://127.0.0.1:8080/x"}, true},
+		{"metadata webhook", ErrorBudgetPolicy{Name: "x", Action: PolicyActionNotify, WebhookURL: "http://169.254.169.254/latest"}, true},
+		{"private webhook", ErrorBudgetPolicy{Name: "x", Action: PolicyActionNotify, WebhookURL: "http://[fd00::1]/x"}, true},
+		{"localhost webhook", ErrorBudgetPolicy{Name: "x", Action: PolicyActionNotify, WebhookURL: "http://localhost/x"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePolicy(&tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotifyWebhookRefusesInternalAddresses(t *testing.T) {
+	// Only the dial-time check stands between a host name and its address;
+	// call it as the dialer would after resolving one to loopback
+	if err := dialPublic("tcp", "127.0.0.1:80", nil); !errors.Is(err, ErrInternalWebhook) {
+		t.Errorf("dialPublic(loopback) = %v, want ErrInternalWebhook", err)
+	}
+	if err := dialPublic("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("dialPublic(public) = %v", err)
+	}
+	if err := NotifyWebhook(context.Background(), "http://10.0.0.1/hook", PolicyTransition{}); !errors.Is(err, ErrInternalWebhook) {
+		t.Errorf("NotifyWebhook(private) = %v, want ErrInternalWebhook", err)
+	}
+}
+
+func TestNewDeployGate(t *testing.T) {
+	now := time.Now()
+	if gate := newDeployGate("checkout", nil, now); !gate.Allowed || len(gate.Reasons) != 0 {
+		t.Errorf("expected deploys to be allowed without triggered freezes, got %+v", gate)
+	}
+
+	remaining := -3.5
+	gate := newDeployGate("checkout", []DeployGateReason{{SLOName: "availability", PolicyName: "freeze", BudgetRemaining: &remaining}}, now)
+	if gate.Allowed {
+		t.Fatal("expected deploys to be blocked")
+	}
+	if want := "SLO availability has -3.5% error budget remaining, at or below the 0.0% threshold of policy freeze"; gate.Reasons[0].Message != want {
+		t.Errorf("unexpected reason %q", gate.Reasons[0].Message)
+	}
+}
+
+func TestParseHistoryRange(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		step     string
+		wantFrom time.Time
+		wantStep time.Duration
+		wantErr  bool
+	}{
+		{name: "defaults to last 7 days hourly", wantFrom: now.AddDate(0, 0, -7), wantStep: time.Hour},
+		{name: "short range uses raw step", from: "2026-10-19T00:00:00Z", wantFrom: now.Add(-12 * time.Hour), wantStep: 5 * time.Minute},
+		{name: "long range uses daily step", from: "2026-01-01T00:00:00Z", wantFrom: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), wantStep: 24 * time.Hour},
+		{name: "step in days", step: "2d", wantFrom: now.AddDate(0, 0, -7), wantStep: 48 * time.Hour},
+		{name: "explicit step", step: "15m", wantFrom: now.AddDate(0, 0, -7), wantStep: 15 * time.Minute},
+		{name: "invalid from", from: "yesterday", wantErr: true},
+		{name: "from after to", from: "2026-10-20T00:00:00Z", wantErr: true},
+		{name: "invalid step", step: "often", wantErr: true},
+		{name: "step too small", step: "10s", wantErr: true},
+		{name: "too many points", from: "2020-01-01T00:00:00Z", step: "1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseHistoryRange(tt.from, tt.to, tt.step, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidHistoryRange) {
+					t.Errorf("ParseHistoryRange() error = %v, want ErrInvalidHistoryRange", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseHistoryRange() error = %v", err)
+			}
+			if !r.From.Equal(tt.wantFrom) || !r.To.Equal(now) || r.Step != tt.wantStep {
+				t.Errorf("ParseHistoryRange() = %v..%v step %v, want %v..%v step %v", r.From, r.To, r.Step, tt.wantFrom, now, tt.wantStep)
+			}
+		})
+	}
+}
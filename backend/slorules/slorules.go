@@ -0,0 +1,292 @@
+// Package slorules generates Prometheus recording and alerting rules for SLOs,
+// following the layout used by Sloth (https://sloth.dev): SLI error ratios are
+// recorded over a fixed set of windows, the full SLO window is derived from the
+// 5m series, and alerts use multiple windows and burn rates.
+package slorules
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Label names attached to every generated series
+const (
+	LabelService = "slo_service"
+	LabelSLO     = "slo_name"
+	LabelWindow  = "slo_window"
+)
+
+// Windows are the short windows recorded for every SLO
+var Windows = []string{"5m", "30m", "1h", "2h", "6h", "1d", "3d"}
+
+// Indicator types, matching the indicator_type column of slos
+const (
+	IndicatorRatio     = "ratio"
+	IndicatorThreshold = "threshold"
+	IndicatorLatency   = "latency"    // good and total queries over histogram buckets
+	IndicatorErrorRate = "error_rate" // SLIQuery returns the percentage of bad events
+)
+
+// SLO is the subset of an SLO needed to generate rules
+type SLO struct {
+	Service    string
+	Name       string
+	Objective  float64 // percent
+	WindowDays int
+
+	// IndicatorType is empty (or availability) for SLOs whose SLIQuery returns
+	// the percentage of good events; range selectors in that query are
+	// rewritten per window
+	IndicatorType  string
+	SLIQuery       string
+	GoodQuery      string
+	BadQuery       string
+	TotalQuery     string
+	ThresholdQuery string
+	ThresholdOp    string
+	ThresholdValue float64
+}
+
+// RuleFile is a Prometheus rule file
+type RuleFile struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// burnAlert is one multi-window condition: the error ratio over both windows
+// must exceed factor times the error budget
+type burnAlert struct {
+	long, short   string
+	budgetPercent float64 // share of the budget consumed within the long window
+	longHours     float64
+}
+
+var (
+	pageAlerts = []burnAlert{
+		{long: "1h", short: "5m", budgetPercent: 2, longHours: 1},
+		{long: "6h", short: "30m", budgetPercent: 5, longHours: 6},
+	}
+	ticketAlerts = []burnAlert{
+		{long: "1d", short: "2h", budgetPercent: 10, longHours: 24},
+		{long: "3d", short: "6h", budgetPercent: 10, longHours: 72},
+	}
+)
+
+// factor returns the burn rate that consumes budgetPercent of the budget of a
+// windowDays SLO within the long window, e.g. 14.4 for 2% in 1h of 30d
+func (a burnAlert) factor(windowDays int) float64 {
+	return a.budgetPercent / 100 * float64(windowDays) * 24 / a.longHours
+}
+
+// ErrorRatioMetric is the name of the recorded SLI error ratio over window
+func ErrorRatioMetric(window string) string {
+	return "slo:sli_error:ratio_rate" + window
+}
+
+// PeriodWindow is the window label of the full SLO window
+func PeriodWindow(windowDays int) string {
+	if windowDays <= 0 {
+		windowDays = 30
+	}
+	return fmt.Sprintf("%dd", windowDays)
+}
+
+// Selector matches the generated series of one SLO
+func Selector(service, name string) string {
+	return fmt.Sprintf(`{%s=%s,%s=%s}`, LabelService, strconv.Quote(service), LabelSLO, strconv.Quote(name))
+}
+
+var rangeSelector = regexp.MustCompile(`\[[0-9]+[smhdwy]\]`)
+
+// ErrorRatioExpr returns the PromQL expression of the SLI error ratio (0-1)
+// over window
+func (s SLO) ErrorRatioExpr(window string) string {
+	rewrite := func(q string) string {
+		return rangeSelector.ReplaceAllString(q, "["+window+"]")
+	}
+	switch s.IndicatorType {
+	case IndicatorRatio, IndicatorLatency:
+		if s.BadQuery != "" {
+			return fmt.Sprintf("(%s) / (%s)", rewrite(s.BadQuery), rewrite(s.TotalQuery))
+		}
+		return fmt.Sprintf("1 - ((%s) / (%s))", rewrite(s.GoodQuery), rewrite(s.TotalQuery))
+	case IndicatorThreshold:
+		// Share of one minute slices in which the threshold was met
+		return fmt.Sprintf("1 - avg_over_time(((%s) %s bool %s)[%s:1m])",
+			s.ThresholdQuery, comparison(s.ThresholdOp), formatFloat(s.ThresholdValue), window)
+	case IndicatorErrorRate:
+		return fmt.Sprintf("(%s) / 100", rewrite(s.SLIQuery))
+	default:
+		return fmt.Sprintf("1 - ((%s) / 100)", rewrite(s.SLIQuery))
+	}
+}
+
+func comparison(op string) string {
+	switch op {
+	case "lt":
+		return "<"
+	case "lte":
+		return "<="
+	case "gt":
+		return ">"
+	default:
+		return ">="
+	}
+}
+
+// Generate builds the rule groups for the SLOs: SLI recordings, metadata
+// recordings and alerts for each SLO
+func Generate(slos []SLO) RuleFile {
+	sorted := append([]SLO(nil), slos...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Service != sorted[j].Service {
+			return sorted[i].Service < sorted[j].Service
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var file RuleFile
+	for _, s := range sorted {
+		if s.WindowDays <= 0 {
+			s.WindowDays = 30
+		}
+		prefix := fmt.Sprintf("slo-%s-%s", s.Service, s.Name)
+		file.Groups = append(file.Groups,
+			RuleGroup{Name: prefix + "-sli-recordings", Rules: s.sliRecordings()},
+			RuleGroup{Name: prefix + "-meta-recordings", Rules: s.metaRecordings()},
+			RuleGroup{Name: prefix + "-alerts", Rules: s.alerts()},
+		)
+	}
+	return file
+}
+
+func (s SLO) labels(extra ...string) map[string]string {
+	l := map[string]string{LabelService: s.Service, LabelSLO: s.Name}
+	for i := 0; i+1 < len(extra); i += 2 {
+		l[extra[i]] = extra[i+1]
+	}
+	return l
+}
+
+func (s SLO) sliRecordings() []Rule {
+	var rules []Rule
+	period := PeriodWindow(s.WindowDays)
+	for _, w := range Windows {
+		rules = append(rules, Rule{
+			Record: ErrorRatioMetric(w),
+			Expr:   s.ErrorRatioExpr(w),
+			Labels: s.labels(LabelWindow, w),
+		})
+	}
+	for _, w := range Windows {
+		if w == period {
+			return rules
+		}
+	}
+
+	// The full window is averaged from the 5m series instead of querying the
+	// raw metrics over weeks of data
+	short := ErrorRatioMetric(Windows[0]) + Selector(s.Service, s.Name)
+	rules = append(rules, Rule{
+		Record: ErrorRatioMetric(period),
+		Expr: fmt.Sprintf("sum_over_time(%s[%s]) / ignoring(%s) count_over_time(%s[%s])",
+			short, period, LabelWindow, short, period),
+		Labels: s.labels(LabelWindow, period),
+	})
+	return rules
+}
+
+func (s SLO) metaRecordings() []Rule {
+	sel := Selector(s.Service, s.Name)
+	objective := s.Objective / 100
+	on := fmt.Sprintf("on(%s, %s) group_left", LabelService, LabelSLO)
+	return []Rule{
+		{Record: "slo:objective:ratio", Expr: fmt.Sprintf("vector(%s)", formatFloat(objective)), Labels: s.labels()},
+		{Record: "slo:error_budget:ratio", Expr: fmt.Sprintf("vector(%s)", formatFloat(1-objective)), Labels: s.labels()},
+		{Record: "slo:time_period:days", Expr: fmt.Sprintf("vector(%d)", s.WindowDays), Labels: s.labels()},
+		{
+			Record: "slo:current_burn_rate:ratio",
+			Expr:   fmt.Sprintf("%s%s / %s slo:error_budget:ratio%s", ErrorRatioMetric(Windows[0]), sel, on, sel),
+			Labels: s.labels(),
+		},
+		{
+			Record: "slo:period_burn_rate:ratio",
+			Expr:   fmt.Sprintf("%s%s / %s slo:error_budget:ratio%s", ErrorRatioMetric(PeriodWindow(s.WindowDays)), sel, on, sel),
+			Labels: s.labels(),
+		},
+		{
+			Record: "slo:period_error_budget_remaining:ratio",
+			Expr:   fmt.Sprintf("1 - slo:period_burn_rate:ratio%s", sel),
+			Labels: s.labels(),
+		},
+	}
+}
+
+func (s SLO) alerts() []Rule {
+	budget := 1 - s.Objective/100
+	return []Rule{
+		s.burnRateAlert("SLOErrorBudgetFastBurn", "page", budget, pageAlerts),
+		s.burnRateAlert("SLOErrorBudgetSlowBurn", "ticket", budget, ticketAlerts),
+	}
+}
+
+func (s SLO) burnRateAlert(name, severity string, budget float64, conditions []burnAlert) Rule {
+	sel := Selector(s.Service, s.Name)
+	over := func(window string, factor float64) string {
+		return fmt.Sprintf("max(%s%s > (%s * %s)) without (%s)",
+			ErrorRatioMetric(window), sel, formatFloat(factor), formatFloat(budget), LabelWindow)
+	}
+	var parts []string
+	for _, c := range conditions {
+		f := c.factor(s.WindowDays)
+		parts = append(parts, fmt.Sprintf("(%s and %s)", over(c.short, f), over(c.long, f)))
+	}
+	return Rule{
+		Alert:  name,
+		Expr:   strings.Join(parts, " or "),
+		Labels: s.labels("severity", severity),
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("%s SLO %s is burning its error budget too fast", s.Service, s.Name),
+			"description": fmt.Sprintf("The error budget of the %s%% objective over %s is being consumed at a rate that will exhaust it early.", formatFloat(s.Objective), PeriodWindow(s.WindowDays)),
+		},
+	}
+}
+
+// YAML encodes the rule file as expected by Prometheus rule_files
+func (f RuleFile) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return nil, fmt.Errorf("failed to encode rule file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formatFloat rounds away float noise such as 1 - 0.999 = 0.0010000000000000009
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1e9)/1e9, 'g', -1, 64)
+}
@@ -0,0 +1,125 @@
+package slorules
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestErrorRatioExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		slo  SLO
+		want string
+	}{
+		{
+			name: "ratio with good events",
+			slo: SLO{
+				IndicatorType: IndicatorRatio,
+				GoodQuery:     `sum(rate(http_requests_total{status!~"5.."}[5m]))`,
+				TotalQuery:    `sum(rate(http_requests_total[5m]))`,
+			},
+			want: `1 - ((sum(rate(http_requests_total{status!~"5.."}[1h]))) / (sum(rate(http_requests_total[1h]))))`,
+		},
+		{
+			name: "ratio with bad events",
+			slo: SLO{
+				IndicatorType: IndicatorRatio,
+				BadQuery:      `sum(rate(errors_total[5m]))`,
+				TotalQuery:    `sum(rate(requests_total[5m]))`,
+			},
+			want: `(sum(rate(errors_total[1h]))) / (sum(rate(requests_total[1h])))`,
+		},
+		{
+			name: "threshold",
+			slo: SLO{
+				IndicatorType:  IndicatorThreshold,
+				ThresholdQuery: `histogram_quantile(0.99, sum(rate(latency_bucket[5m])) by (le))`,
+				ThresholdOp:    "lte",
+				ThresholdValue: 0.5,
+			},
+			want: `1 - avg_over_time(((histogram_quantile(0.99, sum(rate(latency_bucket[5m])) by (le))) <= bool 0.5)[1h:1m])`,
+		},
+		{
+			name: "percentage query",
+			slo:  SLO{SLIQuery: `100 * sum(rate(ok_total[30d])) / sum(rate(all_total[30d]))`},
+			want: `1 - ((100 * sum(rate(ok_total[1h])) / sum(rate(all_total[1h]))) / 100)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.slo.ErrorRatioExpr("1h"); got != tt.want {
+				t.Errorf("ErrorRatioExpr() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBurnRateFactor(t *testing.T) {
+	tests := []struct {
+		alert      burnAlert
+		windowDays int
+		want       string
+	}{
+		{pageAlerts[0], 30, "14.4"},
+		{pageAlerts[1], 30, "6"},
+		{ticketAlerts[0], 30, "3"},
+		{ticketAlerts[1], 30, "1"},
+		{pageAlerts[0], 28, "13.44"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.alert.factor(tt.windowDays)); got != tt.want {
+			t.Errorf("factor(%s, %dd) = %s, want %s", tt.alert.long, tt.windowDays, got, tt.want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	file := Generate([]SLO{{
+		Service:       "checkout",
+		Name:          "availability",
+		Objective:     99.9,
+		WindowDays:    30,
+		IndicatorType: IndicatorRatio,
+		BadQuery:      `sum(rate(errors_total[5m]))`,
+		TotalQuery:    `sum(rate(requests_total[5m]))`,
+	}})
+	if len(file.Groups) != 3 {
+		t.Fatalf("expected 3 rule groups, got %d", len(file.Groups))
+	}
+
+	recorded := make(map[string]string)
+	for _, r := range file.Groups[0].Rules {
+		recorded[r.Labels[LabelWindow]] = r.Record
+	}
+	for _, w := range append(Windows, "30d") {
+		if recorded[w] != ErrorRatioMetric(w) {
+			t.Errorf("expected a recording rule for window %s", w)
+		}
+	}
+
+	page := file.Groups[2].Rules[0]
+	if page.Labels["severity"] != "page" || !strings.Contains(page.Expr, "> (14.4 * 0.001)") {
+		t.Errorf("unexpected page alert: %+v", page)
+	}
+
+	data, err := file.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded RuleFile
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("generated rule file does not parse: %v", err)
+	}
+	if len(decoded.Groups) != 3 {
+		t.Errorf("expected 3 groups after decoding, got %d", len(decoded.Groups))
+	}
+}
+
+func TestGenerateWindowMatchesShortWindow(t *testing.T) {
+	file := Generate([]SLO{{Service: "api", Name: "daily", Objective: 99, WindowDays: 1, SLIQuery: "up * 100"}})
+	if n := len(file.Groups[0].Rules); n != len(Windows) {
+		t.Errorf("expected %d recording rules when the SLO window is already recorded, got %d", len(Windows), n)
+	}
+}
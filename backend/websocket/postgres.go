@@ -0,0 +1,281 @@
+package websocket
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// notifyChannel is the Postgres channel of realtime events
+const notifyChannel = "realtime_events"
+
+// maxNotifySize is the largest event sent inline; Postgres limits NOTIFY
+// payloads to 8000 bytes. For larger events only the ID is notified and
+// listeners read them from the table.
+const maxNotifySize = 7000
+
+// publishLockKey serialises publishers so events commit, and are notified,
+// in ID order
+const publishLockKey = 7_241_153_041
+
+// eventRetention is how long events are kept for catching up
+const eventRetention = time.Hour
+
+// SharedFromEnv reports whether REALTIME_BUS selects the Postgres bus. It is
+// memory, the default, or postgres.
+func SharedFromEnv() (bool, error) {
+	switch bus := os.Getenv("REALTIME_BUS"); bus {
+	case "", "memory":
+		return false, nil
+	case "postgres":
+		return true, nil
+	default:
+		return false, fmt.Errorf("REALTIME_BUS: unknown bus %q, use memory or postgres", bus)
+	}
+}
+
+// event is a message as stored and notified
+type event struct {
+	ID        uint64          `json:"id"`
+	Type      string          `json:"type,omitempty"`
+	Org       string          `json:"org,omitempty"`
+	Topics    []string        `json:"topics,omitempty"`
+	Service   string          `json:"service,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Timestamp int64           `json:"timestamp,omitempty"`
+}
+
+func (e event) message() *Message {
+	return &Message{
+		Type:      e.Type,
+		ID:        e.ID,
+		Payload:   e.Payload,
+		Timestamp: e.Timestamp,
+		target:    Target{Org: e.Org, Topics: e.Topics, Service: e.Service},
+	}
+}
+
+// PostgresBus carries broadcasts between replicas through a table and
+// LISTEN/NOTIFY
+type PostgresBus struct {
+	db     *sql.DB
+	dsn    string
+	logger *log.Logger
+}
+
+// NewPostgresBus creates a bus; dsn is used for the listener's own connection
+func NewPostgresBus(db *sql.DB, dsn string) *PostgresBus {
+	return &PostgresBus{
+		db:     db,
+		dsn:    dsn,
+		logger: log.New(log.Writer(), "[WebSocket] ", log.LstdFlags),
+	}
+}
+
+// lastID returns the ID of the newest event
+func (b *PostgresBus) lastID(ctx context.Context) (uint64, error) {
+	var id int64
+	if err := b.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM realtime_events`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read last realtime event: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// Publish stores m, sets its ID and notifies every replica
+func (b *PostgresBus) Publish(ctx context.Context, m *Message) error {
+	payload, err := json.Marshal(m.Payload)
+	if err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, publishLockKey); err != nil {
+		return fmt.Errorf("failed to lock realtime events: %w", err)
+	}
+	var id int64
+	var created time.Time
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO realtime_events (type, org_id, topics, service, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, m.Type, m.target.Org, pq.Array(m.target.Topics), m.target.Service, payload).Scan(&id, &created)
+	if err != nil {
+		return fmt.Errorf("failed to store realtime event: %w", err)
+	}
+
+	e := event{
+		ID:        uint64(id),
+		Type:      m.Type,
+		Org:       m.target.Org,
+		Topics:    m.target.Topics,
+		Service:   m.target.Service,
+		Payload:   payload,
+		Timestamp: created.Unix(),
+	}
+	notification, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(notification) > maxNotifySize {
+		notification, _ = json.Marshal(event{ID: e.ID})
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, string(notification)); err != nil {
+		return fmt.Errorf("failed to notify realtime event: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.ID = e.ID
+	return nil
+}
+
+// since returns the events with IDs after lastID in ID order
+func (b *PostgresBus) since(ctx context.Context, lastID uint64) ([]event, error) {
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT id, type, org_id, topics, service, payload, created_at
+		FROM realtime_events
+		WHERE id > $1
+		ORDER BY id
+	`, int64(lastID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []event
+	for rows.Next() {
+		var e event
+		var id int64
+		var payload []byte
+		var created time.Time
+		if err := rows.Scan(&id, &e.Type, &e.Org, pq.Array(&e.Topics), &e.Service, &payload, &created); err != nil {
+			return nil, err
+		}
+		e.ID, e.Payload, e.Timestamp = uint64(id), payload, created.Unix()
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// presence returns the presence messages of an incident topic that are still
+// kept, oldest first
+func (b *PostgresBus) presence(ctx context.Context, org, topic string) ([]sharedPresence, error) {
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT payload, created_at
+		FROM realtime_events
+		WHERE type = $1 AND org_id = $2 AND $3 = ANY(topics)
+		ORDER BY id
+	`, MessagePresence, org, topic)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []sharedPresence
+	for rows.Next() {
+		var payload []byte
+		var e sharedPresence
+		if err := rows.Scan(&payload, &e.at); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &e.PresenceEvent); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// listen delivers the events of every replica after lastID in ID order until
+// ctx is done. After the listener connection drops and comes back, it catches
+// up from the table on what it missed.
+func (b *PostgresBus) listen(ctx context.Context, lastID uint64, deliver func(*Message)) {
+	listener := pq.NewListener(b.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			b.logger.Printf("Realtime listener: %v", err)
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(notifyChannel); err != nil {
+		b.logger.Printf("Failed to listen for realtime events: %v", err)
+		return
+	}
+
+	// behind is set while events may be missing after lastID, so they are read
+	// from the table instead of taken from notifications
+	behind := false
+	catchUp := func() {
+		qctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		events, err := b.since(qctx, lastID)
+		behind = err != nil
+		if err != nil {
+			b.logger.Printf("Failed to catch up on realtime events: %v", err)
+			return
+		}
+		for _, e := range events {
+			deliver(e.message())
+			lastID = e.ID
+		}
+	}
+	// Events published before LISTEN took effect
+	catchUp()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			if n == nil {
+				// Reconnected; notifications may have been lost
+				catchUp()
+				continue
+			}
+			var e event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				b.logger.Printf("Invalid realtime event %q: %v", n.Extra, err)
+				behind = true
+				continue
+			}
+			if e.ID <= lastID {
+				continue
+			}
+			if e.Type == "" || behind {
+				// Too large to notify, or events before it are missing
+				catchUp()
+				continue
+			}
+			deliver(e.message())
+			lastID = e.ID
+		case <-ticker.C:
+			if err := listener.Ping(); err != nil {
+				b.logger.Printf("Realtime listener ping failed: %v", err)
+			}
+			if behind {
+				catchUp()
+			}
+			b.prune(ctx)
+		}
+	}
+}
+
+// prune deletes the events too old to catch up on
+func (b *PostgresBus) prune(ctx context.Context) {
+	_, err := b.db.ExecContext(ctx, `DELETE FROM realtime_events WHERE created_at < $1`, time.Now().Add(-eventRetention))
+	if err != nil {
+		b.logger.Printf("Failed to prune realtime events: %v", err)
+	}
+}
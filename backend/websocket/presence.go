@@ -0,0 +1,225 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// typingTimeout is how long a client counts as typing after its last typing
+// command; clients repeat the command while the user types
+const typingTimeout = 10 * time.Second
+
+// Presence actions
+const (
+	PresenceJoined = "joined" // started viewing the incident
+	PresenceLeft   = "left"
+	PresenceTyping = "typing" // editing Field
+	PresenceIdle   = "idle"   // stopped editing
+)
+
+// Presence messages
+const (
+	MessagePresence      = "presence"       // broadcast to the incident's topic
+	MessagePresenceState = "presence_state" // to a client that starts viewing
+)
+
+// PresenceEvent is the payload of presence messages
+type PresenceEvent struct {
+	IncidentID string `json:"incident_id"`
+	User       string `json:"user"`
+	Action     string `json:"action"`
+	Field      string `json:"field,omitempty"`
+}
+
+// Viewer is a user viewing an incident
+type Viewer struct {
+	User   string `json:"user"`
+	Typing string `json:"typing,omitempty"` // field being edited
+}
+
+// PresenceState is the payload of presence_state messages
+type PresenceState struct {
+	IncidentID string   `json:"incident_id"`
+	Viewers    []Viewer `json:"viewers"`
+}
+
+// typing is the field a client edits in an incident
+type typing struct {
+	field     string
+	announced time.Time // when it was last broadcast
+}
+
+// viewedIncidents returns the incident IDs among topics
+func viewedIncidents(topics []string) []string {
+	var ids []string
+	for _, topic := range topics {
+		if id, ok := strings.CutPrefix(topic, incidentPrefix); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// viewing reports whether the client views an incident. c.mu must be held.
+func (c *Client) viewing(incidentID string) bool {
+	return c.topics[IncidentTopic(incidentID)]
+}
+
+// setTyping records the field the client edits, "" when it stopped, and
+// reports whether others should be told
+func (c *Client) setTyping(incidentID, field string, now time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.viewing(incidentID) {
+		return false, fmt.Errorf("not subscribed to %s", IncidentTopic(incidentID))
+	}
+	current, ok := c.typing[incidentID]
+	if field == "" {
+		delete(c.typing, incidentID)
+		return ok, nil
+	}
+	if ok && current.field == field && now.Sub(current.announced) < typingTimeout/2 {
+		return false, nil
+	}
+	c.typing[incidentID] = typing{field: field, announced: now}
+	return true, nil
+}
+
+// sharedPresence is a presence message of any replica, replayed from the bus
+type sharedPresence struct {
+	PresenceEvent
+	at time.Time
+}
+
+// foldPresence returns the users viewing an incident, with the field each
+// edits, from the presence messages of every replica, oldest first. Users
+// view it while they joined more often than they left, as every tab joins on
+// its own, and edit a field until they go idle, leave or typingTimeout passes.
+func foldPresence(events []sharedPresence, now time.Time) map[string]string {
+	joins := make(map[string]int)
+	typed := make(map[string]sharedPresence)
+	for _, e := range events {
+		switch e.Action {
+		case PresenceJoined:
+			joins[e.User]++
+		case PresenceLeft:
+			joins[e.User]--
+			delete(typed, e.User)
+		case PresenceTyping:
+			typed[e.User] = e
+		case PresenceIdle:
+			delete(typed, e.User)
+		}
+	}
+	fields := make(map[string]string)
+	for user, n := range joins {
+		if n > 0 {
+			fields[user] = ""
+		}
+	}
+	for user, e := range typed {
+		if now.Sub(e.at) < typingTimeout {
+			fields[user] = e.Field
+		}
+	}
+	return fields
+}
+
+// sharedViewers returns the viewers of an incident on every replica according
+// to the bus, or nil without one. It must not be called with s.mu held.
+func (s *RealtimeServer) sharedViewers(org, incidentID string) map[string]string {
+	if s.bus == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := s.bus.presence(ctx, org, IncidentTopic(incidentID))
+	if err != nil {
+		s.logger.Printf("Failed to load presence of incident %s, listing local viewers only: %v", incidentID, err)
+		return nil
+	}
+	return foldPresence(events, time.Now())
+}
+
+// viewers lists the users of org viewing an incident on this server and, from
+// shared, on other replicas, with the field each edits. s.mu must be held.
+func (s *RealtimeServer) viewers(org, incidentID string, now time.Time, shared map[string]string) []Viewer {
+	fields := make(map[string]string)
+	for user, field := range shared {
+		fields[user] = field
+	}
+	for client := range s.clients {
+		if client.orgID != org {
+			continue
+		}
+		client.mu.Lock()
+		if client.viewing(incidentID) {
+			t, ok := client.typing[incidentID]
+			if ok && now.Sub(t.announced) < typingTimeout {
+				fields[client.user] = t.field
+			} else if _, seen := fields[client.user]; !seen {
+				fields[client.user] = ""
+			}
+		}
+		client.mu.Unlock()
+	}
+	viewers := make([]Viewer, 0, len(fields))
+	for user, field := range fields {
+		viewers = append(viewers, Viewer{User: user, Typing: field})
+	}
+	sort.Slice(viewers, func(i, j int) bool { return viewers[i].User < viewers[j].User })
+	return viewers
+}
+
+// presenceState is the message telling a client who views an incident, with
+// the viewers on other replicas in shared. s.mu must be held.
+func (s *RealtimeServer) presenceState(c *Client, incidentID string, shared map[string]string) *Message {
+	return &Message{
+		Type:      MessagePresenceState,
+		Payload:   PresenceState{IncidentID: incidentID, Viewers: s.viewers(c.orgID, incidentID, time.Now(), shared)},
+		Timestamp: getCurrentTimestamp(),
+	}
+}
+
+// announce broadcasts a presence change of the client to the incident's
+// viewers. It must not be called with s.mu held.
+func (s *RealtimeServer) announce(c *Client, incidentID, action, field string) {
+	s.publish(MessagePresence, Target{Org: c.orgID, Topics: []string{IncidentTopic(incidentID)}}, PresenceEvent{
+		IncidentID: incidentID,
+		User:       c.user,
+		Action:     action,
+		Field:      field,
+	})
+}
+
+// joined announces the incidents a client started viewing and tells it who
+// else views them
+func (s *RealtimeServer) joined(c *Client, incidentIDs []string) {
+	for _, id := range incidentIDs {
+		s.announce(c, id, PresenceJoined, "")
+		shared := s.sharedViewers(c.orgID, id)
+		s.mu.Lock()
+		if s.clients[c] {
+			s.deliver(c, s.presenceState(c, id, shared))
+		}
+		s.mu.Unlock()
+	}
+}
+
+// left announces the incidents a client stopped viewing
+func (s *RealtimeServer) left(c *Client, incidentIDs []string) {
+	for _, id := range incidentIDs {
+		s.announce(c, id, PresenceLeft, "")
+	}
+}
+
+// leave announces that a disconnecting client stopped viewing everything
+func (c *Client) leave() {
+	c.mu.Lock()
+	ids := viewedIncidents(c.topics.list())
+	c.mu.Unlock()
+	c.server.left(c, ids)
+}
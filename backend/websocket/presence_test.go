@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestClient(s *RealtimeServer, org, user string, topics ...string) *Client {
+	c := &Client{server: s, orgID: org, user: user, access: fakeAccess{}, topics: make(subscriptions), typing: make(map[string]typing)}
+	c.subscribe(topics)
+	return c
+}
+
+func TestClientSetTyping(t *testing.T) {
+	c := newTestClient(nil, "acme", "alice", "incident:1")
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		incidentID string
+		field      string
+		at         time.Duration
+		announce   bool
+		wantErr    bool
+	}{
+		{"starts typing", "1", "root_cause", 0, true, false},
+		{"keeps typing", "1", "root_cause", 2 * time.Second, false, false},
+		{"still typing later", "1", "root_cause", typingTimeout / 2, true, false},
+		{"other field", "1", "resolution", typingTimeout / 2, true, false},
+		{"stops", "1", "", typingTimeout, true, false},
+		{"stops again", "1", "", typingTimeout, false, false},
+		{"not viewing", "2", "root_cause", 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announce, err := c.setTyping(tt.incidentID, tt.field, now.Add(tt.at))
+			if announce != tt.announce || (err != nil) != tt.wantErr {
+				t.Errorf("setTyping = %v, %v; want %v, error %v", announce, err, tt.announce, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerViewers(t *testing.T) {
+	s := NewRealtimeServer()
+	now := time.Now()
+	alice := newTestClient(s, "acme", "alice", "incident:1")
+	aliceTab := newTestClient(s, "acme", "alice", "incident:1")
+	bob := newTestClient(s, "acme", "bob", "incident:1", "incident:2")
+	carol := newTestClient(s, "acme", "carol", "incident:1")
+	other := newTestClient(s, "other", "dave", "incident:1")
+	for _, c := range []*Client{alice, aliceTab, bob, carol, other} {
+		s.addClient(c)
+	}
+	aliceTab.setTyping("1", "root_cause", now)
+	carol.setTyping("1", "resolution", now.Add(-typingTimeout)) // expired
+
+	want := []Viewer{{User: "alice", Typing: "root_cause"}, {User: "bob"}, {User: "carol"}}
+	if got := s.viewers("acme", "1", now, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("viewers of 1 = %v, want %v", got, want)
+	}
+	if got := s.viewers("acme", "2", now, nil); !reflect.DeepEqual(got, []Viewer{{User: "bob"}}) {
+		t.Errorf("viewers of 2 = %v, want bob", got)
+	}
+
+	// Viewers on other replicas join the local ones, whose typing wins
+	shared := map[string]string{"alice": "", "bob": "summary", "erin": "resolution"}
+	want = []Viewer{{User: "alice", Typing: "root_cause"}, {User: "bob", Typing: "summary"}, {User: "carol"}, {User: "erin", Typing: "resolution"}}
+	if got := s.viewers("acme", "1", now, shared); !reflect.DeepEqual(got, want) {
+		t.Errorf("viewers of 1 with other replicas = %v, want %v", got, want)
+	}
+}
+
+func TestFoldPresence(t *testing.T) {
+	now := time.Now()
+	event := func(user, action, field string, ago time.Duration) sharedPresence {
+		return sharedPresence{PresenceEvent{IncidentID: "1", User: user, Action: action, Field: field}, now.Add(-ago)}
+	}
+	events := []sharedPresence{
+		event("alice", PresenceJoined, "", time.Minute),
+		event("alice", PresenceJoined, "", time.Minute), // second tab
+		event("alice", PresenceLeft, "", 30*time.Second),
+		event("bob", PresenceJoined, "", time.Minute),
+		event("bob", PresenceTyping, "root_cause", typingTimeout/2),
+		event("carol", PresenceJoined, "", time.Minute),
+		event("carol", PresenceTyping, "resolution", typingTimeout), // expired
+		event("dave", PresenceJoined, "", time.Minute),
+		event("dave", PresenceTyping, "summary", time.Second),
+		event("dave", PresenceLeft, "", 0),
+		event("erin", PresenceLeft, "", 0), // joined before the events kept
+		event("frank", PresenceJoined, "", time.Minute),
+		event("frank", PresenceTyping, "summary", 2*time.Second),
+		event("frank", PresenceIdle, "", time.Second),
+	}
+	want := map[string]string{"alice": "", "bob": "root_cause", "carol": "", "frank": ""}
+	if got := foldPresence(events, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("foldPresence = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,240 @@
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Commands clients send
+const (
+	CommandSubscribe   = "subscribe"
+	CommandUnsubscribe = "unsubscribe"
+	CommandResume      = "resume"
+	CommandTyping      = "typing"
+	CommandPing        = "ping"
+)
+
+// Replies to commands
+const (
+	ReplySubscribed   = "subscribed"
+	ReplyUnsubscribed = "unsubscribed"
+	ReplyResumed      = "resumed"
+	ReplyPong         = "pong"
+	ReplyError        = "error"
+)
+
+// MessageGap tells a resuming client that messages of some topics can't be
+// replayed, so it should refetch what it shows from the API
+const MessageGap = "gap"
+
+// Command is a message from a client, like
+// {"type": "subscribe", "topics": ["incident:42", "alerts"], "ref": "1"}
+type Command struct {
+	Type   string   `json:"type"`
+	Topics []string `json:"topics,omitempty"`
+	Ref    string   `json:"ref,omitempty"` // echoed in the reply
+	// For resume, the ID of the last message the client received
+	LastEventID uint64 `json:"last_event_id,omitempty"`
+	// For typing, the incident and the field being edited, "" when done
+	IncidentID string `json:"incident_id,omitempty"`
+	Field      string `json:"field,omitempty"`
+}
+
+// SubscriptionReply is the payload of subscribed and unsubscribed replies
+type SubscriptionReply struct {
+	Topics   []string          `json:"topics"`             // all topics of the client
+	Rejected map[string]string `json:"rejected,omitempty"` // topic -> reason
+}
+
+// ResumeReply is the payload of resumed replies
+type ResumeReply struct {
+	SubscriptionReply
+	Replayed int `json:"replayed"` // messages that follow the reply
+}
+
+// Gap is the payload of gap messages
+type Gap struct {
+	Topics      []string `json:"topics"`
+	LastEventID uint64   `json:"last_event_id"` // of the resume command
+}
+
+// handle runs a command and returns its reply. Resume sends its own replies
+// and typing has none unless it fails; both return nil.
+func (c *Client) handle(cmd Command) *Message {
+	reply := &Message{Ref: cmd.Ref, Timestamp: getCurrentTimestamp()}
+	switch cmd.Type {
+	case CommandSubscribe:
+		sub, joined := c.subscribe(cmd.Topics)
+		reply.Type = ReplySubscribed
+		reply.Payload = sub
+		if len(joined) > 0 {
+			c.server.joined(c, joined)
+		}
+	case CommandUnsubscribe:
+		sub, left := c.unsubscribe(cmd.Topics)
+		reply.Type = ReplyUnsubscribed
+		reply.Payload = sub
+		if len(left) > 0 {
+			c.server.left(c, left)
+		}
+	case CommandResume:
+		c.server.resume(c, cmd)
+		return nil
+	case CommandTyping:
+		announce, err := c.setTyping(cmd.IncidentID, cmd.Field, time.Now())
+		if err != nil {
+			reply.Type = ReplyError
+			reply.Payload = map[string]string{"message": err.Error()}
+			return reply
+		}
+		if announce {
+			action := PresenceTyping
+			if cmd.Field == "" {
+				action = PresenceIdle
+			}
+			c.server.announce(c, cmd.IncidentID, action, cmd.Field)
+		}
+		return nil
+	case CommandPing:
+		reply.Type = ReplyPong
+	default:
+		reply.Type = ReplyError
+		reply.Payload = map[string]string{"message": fmt.Sprintf("unknown command %q", cmd.Type)}
+	}
+	return reply
+}
+
+// subscribe adds the topics the client may subscribe to. It also returns the
+// incidents the client started viewing.
+func (c *Client) subscribe(topics []string) (SubscriptionReply, []string) {
+	return c.add(c.check(topics))
+}
+
+// check splits topics into those the client may subscribe to and the rejected
+// ones with the reason
+func (c *Client) check(topics []string) ([]string, map[string]string) {
+	rejected := make(map[string]string)
+	allowed := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if _, _, err := ParseTopic(topic); err != nil {
+			rejected[topic] = err.Error()
+		} else if err := c.access.CanSubscribe(topic); err != nil {
+			rejected[topic] = err.Error()
+		} else {
+			allowed = append(allowed, topic)
+		}
+	}
+	return allowed, rejected
+}
+
+// add subscribes to the allowed topics, up to maxSubscriptions, and returns
+// the incidents the client started viewing
+func (c *Client) add(allowed []string, rejected map[string]string) (SubscriptionReply, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var added []string
+	for _, topic := range allowed {
+		if c.topics[topic] {
+			continue
+		}
+		if len(c.topics) >= maxSubscriptions {
+			rejected[topic] = fmt.Sprintf("at most %d topics", maxSubscriptions)
+			continue
+		}
+		c.topics[topic] = true
+		added = append(added, topic)
+	}
+	reply := SubscriptionReply{Topics: c.topics.list()}
+	if len(rejected) > 0 {
+		reply.Rejected = rejected
+	}
+	return reply, viewedIncidents(added)
+}
+
+// resume subscribes a reconnecting client to cmd.Topics and replays their
+// messages after cmd.LastEventID, preceded by a gap message for the topics
+// whose messages are no longer all buffered. Holding s.mu keeps broadcasts
+// from slipping between the replay and live messages.
+func (s *RealtimeServer) resume(c *Client, cmd Command) {
+	allowed, rejected := c.check(cmd.Topics)
+	if joined := s.replayTo(c, cmd, allowed, rejected); len(joined) > 0 {
+		s.joined(c, joined)
+	}
+}
+
+// replayTo does the work of resume under s.mu and returns the incidents the
+// client started viewing
+func (s *RealtimeServer) replayTo(c *Client, cmd Command, allowed []string, rejected map[string]string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.clients[c] {
+		return nil
+	}
+	sub, joined := c.add(allowed, rejected)
+
+	var replay []*Message
+	var gaps []string
+	if cmd.LastEventID > 0 {
+		var messages []*Message
+		messages, gaps = s.replay.since(sub.Topics, cmd.LastEventID)
+		for _, m := range messages {
+			if c.wants(m) {
+				replay = append(replay, m)
+			}
+		}
+		if len(replay) > maxReplay {
+			replay, gaps = nil, sub.Topics
+		}
+	}
+
+	s.deliver(c, &Message{
+		Type:      ReplyResumed,
+		Payload:   ResumeReply{SubscriptionReply: sub, Replayed: len(replay)},
+		Timestamp: getCurrentTimestamp(),
+		Ref:       cmd.Ref,
+	})
+	if len(gaps) > 0 && s.clients[c] {
+		s.deliver(c, &Message{
+			Type:      MessageGap,
+			Payload:   Gap{Topics: gaps, LastEventID: cmd.LastEventID},
+			Timestamp: getCurrentTimestamp(),
+		})
+	}
+	for _, m := range replay {
+		if !s.clients[c] {
+			return nil
+		}
+		s.deliver(c, m)
+	}
+	return joined
+}
+
+// unsubscribe removes topics and returns the incidents the client stopped
+// viewing
+func (c *Client) unsubscribe(topics []string) (SubscriptionReply, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var removed []string
+	for _, topic := range topics {
+		if c.topics[topic] {
+			delete(c.topics, topic)
+			removed = append(removed, topic)
+		}
+	}
+	left := viewedIncidents(removed)
+	for _, id := range left {
+		delete(c.typing, id)
+	}
+	return SubscriptionReply{Topics: c.topics.list()}, left
+}
+
+// list returns the topics in order
+func (s subscriptions) list() []string {
+	topics := make([]string, 0, len(s))
+	for topic := range s {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+// replaySize is how many messages are kept per topic for resuming clients
+const replaySize = 128
+
+// maxReplay limits the messages replayed to one client; a client further
+// behind gets a gap instead
+const maxReplay = 200
+
+// pruneEvery and pruneAfter control when topics without recent messages are
+// dropped: every pruneEvery messages, topics whose newest message is more than
+// pruneAfter messages old
+const (
+	pruneEvery = 1024
+	pruneAfter = 4096
+)
+
+// ring holds the newest messages of one topic
+type ring struct {
+	messages []*Message // oldest first
+	evicted  uint64     // ID of the newest message dropped from the ring
+}
+
+// replayBuffer numbers broadcast messages and keeps the newest of each topic
+type replayBuffer struct {
+	topics map[string]*ring
+	lastID uint64
+	shared bool   // IDs are given by the Postgres bus
+	added  uint64 // messages added, to prune every pruneEvery
+	// Messages of topics without a ring, including those sent before a
+	// restart, have IDs up to floor
+	floor uint64
+}
+
+// newReplayBuffer starts IDs at the current time in microseconds, so they keep
+// increasing across restarts and clients resuming after one see a gap
+func newReplayBuffer() *replayBuffer {
+	start := uint64(time.Now().UnixMicro())
+	return &replayBuffer{topics: make(map[string]*ring), lastID: start, floor: start}
+}
+
+// newSharedReplayBuffer keeps the messages of the Postgres bus, whose IDs
+// follow lastID
+func newSharedReplayBuffer(lastID uint64) *replayBuffer {
+	return &replayBuffer{topics: make(map[string]*ring), lastID: lastID, floor: lastID, shared: true}
+}
+
+// add gives m the next ID, unless the bus gave it one, and keeps it under each
+// of its topics. It reports whether m should be delivered: messages already
+// added are not, and messages the bus failed to publish are delivered without
+// an ID or replay.
+func (b *replayBuffer) add(m *Message) bool {
+	switch {
+	case m.ID == 0 && b.shared:
+		return true
+	case m.ID == 0:
+		m.ID = b.lastID + 1
+	case m.ID <= b.lastID:
+		return false
+	}
+	b.lastID = m.ID
+	for _, topic := range m.target.Topics {
+		r, ok := b.topics[topic]
+		if !ok {
+			r = &ring{evicted: b.floor}
+			b.topics[topic] = r
+		}
+		if len(r.messages) == replaySize {
+			r.evicted = r.messages[0].ID
+			copy(r.messages, r.messages[1:])
+			r.messages[len(r.messages)-1] = m
+		} else {
+			r.messages = append(r.messages, m)
+		}
+	}
+	if b.added++; b.added%pruneEvery == 0 {
+		b.prune()
+	}
+	return true
+}
+
+// prune drops the topics without recent messages
+func (b *replayBuffer) prune() {
+	if b.lastID-b.floor <= pruneAfter {
+		return
+	}
+	b.floor = b.lastID - pruneAfter
+	for topic, r := range b.topics {
+		if r.messages[len(r.messages)-1].ID <= b.floor {
+			delete(b.topics, topic)
+		}
+	}
+}
+
+// since returns the buffered messages of topics with IDs after lastID, oldest
+// first, and the topics whose messages after lastID are no longer all buffered
+func (b *replayBuffer) since(topics []string, lastID uint64) ([]*Message, []string) {
+	if lastID > b.lastID {
+		// From another server run or made up
+		return nil, topics
+	}
+	seen := make(map[uint64]bool)
+	var messages []*Message
+	var gaps []string
+	for _, topic := range topics {
+		r, ok := b.topics[topic]
+		if !ok {
+			if lastID < b.floor {
+				gaps = append(gaps, topic)
+			}
+			continue
+		}
+		if lastID < r.evicted {
+			gaps = append(gaps, topic)
+		}
+		for _, m := range r.messages {
+			if m.ID > lastID && !seen[m.ID] {
+				seen[m.ID] = true
+				messages = append(messages, m)
+			}
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, gaps
+}
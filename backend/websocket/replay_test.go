@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ids(messages []*Message) []uint64 {
+	out := make([]uint64, 0, len(messages))
+	for _, m := range messages {
+		out = append(out, m.ID)
+	}
+	return out
+}
+
+func TestReplayBufferSince(t *testing.T) {
+	b := &replayBuffer{topics: make(map[string]*ring), lastID: 100, floor: 100}
+	for i := 0; i < 3; i++ {
+		b.add(&Message{target: IncidentTarget("acme", "1", "")}) // 101, 102, 103
+	}
+	b.add(&Message{target: AlertTarget("acme", "")}) // 104
+
+	tests := []struct {
+		name     string
+		topics   []string
+		lastID   uint64
+		wantIDs  []uint64
+		wantGaps []string
+	}{
+		{"incident after 101", []string{"incident:1"}, 101, []uint64{102, 103}, nil},
+		{"merged without duplicates", []string{"incidents", "incident:1", "alerts"}, 102, []uint64{103, 104}, nil},
+		{"up to date", []string{"alerts"}, 104, []uint64{}, nil},
+		{"quiet topic", []string{"slo"}, 101, []uint64{}, nil},
+		{"before this run", []string{"slo", "alerts"}, 50, []uint64{104}, []string{"slo", "alerts"}},
+		{"from the future", []string{"alerts"}, 200, []uint64{}, []string{"alerts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, gaps := b.since(tt.topics, tt.lastID)
+			if got := ids(messages); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("since IDs = %v, want %v", got, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(gaps, tt.wantGaps) {
+				t.Errorf("gaps = %v, want %v", gaps, tt.wantGaps)
+			}
+		})
+	}
+}
+
+func TestReplayBufferEviction(t *testing.T) {
+	b := &replayBuffer{topics: make(map[string]*ring)}
+	for i := 0; i < replaySize+10; i++ {
+		b.add(&Message{target: AlertTarget("acme", "")})
+	}
+
+	messages, gaps := b.since([]string{TopicAlerts}, 5)
+	if len(messages) != replaySize || len(gaps) != 1 {
+		t.Errorf("after evicted ID: %d messages, gaps %v; want %d and a gap", len(messages), gaps, replaySize)
+	}
+	messages, gaps = b.since([]string{TopicAlerts}, 10)
+	if len(messages) != replaySize || gaps != nil {
+		t.Errorf("at evicted ID: %d messages, gaps %v; want %d and no gap", len(messages), gaps, replaySize)
+	}
+}
+
+func TestReplayBufferShared(t *testing.T) {
+	b := newSharedReplayBuffer(10)
+	tests := []struct {
+		name string
+		id   uint64
+		want bool
+	}{
+		{"next", 11, true},
+		{"after a skipped ID", 15, true},
+		{"again", 15, false},
+		{"older", 12, false},
+		{"unpublished", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Message{ID: tt.id, target: AlertTarget("acme", "")}
+			if got := b.add(m); got != tt.want {
+				t.Errorf("add(%d) = %v, want %v", tt.id, got, tt.want)
+			}
+			if m.ID != tt.id {
+				t.Errorf("add changed ID %d to %d", tt.id, m.ID)
+			}
+		})
+	}
+	if messages, _ := b.since([]string{TopicAlerts}, 10); !reflect.DeepEqual(ids(messages), []uint64{11, 15}) {
+		t.Errorf("since = %v, want [11 15]", ids(messages))
+	}
+}
@@ -1,7 +1,14 @@
-// Package websocket provides real-time incident updates via WebSocket
+// Package websocket provides real-time incident updates via WebSocket. Each
+// client belongs to an organization, subscribes to topics and only receives
+// the messages of its organization and topics that it may see. Broadcast
+// messages have increasing IDs, and reconnecting clients resume after the last
+// one they received from a per-topic replay buffer. HandleSSE serves the same
+// messages as server-sent events. With several replicas,
+// broadcasts go through Postgres so every replica delivers them.
 package websocket
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"sync"
@@ -12,9 +19,12 @@ import (
 
 // Message represents a WebSocket message
 type Message struct {
-	Type    string      `json:"type"`    // "incident_created", "incident_updated", "correlation_found"
-	Payload interface{} `json:"payload"`
-	Timestamp int64    `json:"timestamp"`
+	Type      string      `json:"type"`         // "incident_created", "incident_updated", "correlation_found"
+	ID        uint64      `json:"id,omitempty"` // increasing ID of broadcast messages
+	Payload   interface{} `json:"payload"`
+	Timestamp int64       `json:"timestamp"`
+	Ref       string      `json:"ref,omitempty"` // of the command a reply answers
+	target    Target
 }
 
 // RealtimeServer manages WebSocket connections for real-time updates
@@ -23,15 +33,34 @@ type RealtimeServer struct {
 	broadcast  chan *Message
 	register   chan *Client
 	unregister chan *Client
+	replay     *replayBuffer
+	bus        *PostgresBus // nil when broadcasts stay in the process
 	mu         sync.RWMutex
 	logger     *log.Logger
 }
 
-// Client represents a WebSocket client
+// Client represents a WebSocket client, or an SSE stream, which has no conn
 type Client struct {
 	conn   *websocket.Conn
 	send   chan *Message
 	server *RealtimeServer
+	orgID  string
+	user   string
+	access Access
+	mu     sync.Mutex
+	topics subscriptions
+	typing map[string]typing // by incident ID
+}
+
+// wants reports whether a broadcast message is for the client
+func (c *Client) wants(m *Message) bool {
+	if c.orgID != m.target.Org {
+		return false
+	}
+	c.mu.Lock()
+	subscribed := c.topics.matches(m.target)
+	c.mu.Unlock()
+	return subscribed && c.access.CanSee(m.target.Service)
 }
 
 var upgrader = websocket.Upgrader{
@@ -50,6 +79,7 @@ func NewRealtimeServer() *RealtimeServer {
 		broadcast:  make(chan *Message, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		replay:     newReplayBuffer(),
 		logger:     log.New(log.Writer(), "[WebSocket] ", log.LstdFlags),
 	}
 }
@@ -60,9 +90,7 @@ func (s *RealtimeServer) Start() {
 		for {
 			select {
 			case client := <-s.register:
-				s.mu.Lock()
-				s.clients[client] = true
-				s.mu.Unlock()
+				s.addClient(client)
 				s.logger.Printf("Client connected. Total: %d", len(s.clients))
 
 			case client := <-s.unregister:
@@ -75,69 +103,118 @@ func (s *RealtimeServer) Start() {
 				s.logger.Printf("Client disconnected. Total: %d", len(s.clients))
 
 			case message := <-s.broadcast:
-				s.mu.RLock()
-				for client := range s.clients {
-					select {
-					case client.send <- message:
-					default:
-						// Client's send channel is full, close it
-						close(client.send)
-						delete(s.clients, client)
+				s.mu.Lock()
+				if s.replay.add(message) {
+					for client := range s.clients {
+						if client.wants(message) {
+							s.deliver(client, message)
+						}
 					}
 				}
-				s.mu.RUnlock()
+				s.mu.Unlock()
 			}
 		}
 	}()
 }
 
-// BroadcastIncidentCreated broadcasts a new incident to all clients
-func (s *RealtimeServer) BroadcastIncidentCreated(incident interface{}) {
-	s.broadcast <- &Message{
-		Type:      "incident_created",
-		Payload:   incident,
-		Timestamp: getCurrentTimestamp(),
-	}
+// addClient registers a client right away, so it can be resumed before the
+// event loop runs
+func (s *RealtimeServer) addClient(client *Client) {
+	s.mu.Lock()
+	s.clients[client] = true
+	s.mu.Unlock()
 }
 
-// BroadcastIncidentUpdated broadcasts an incident update to all clients
-func (s *RealtimeServer) BroadcastIncidentUpdated(incident interface{}) {
-	s.broadcast <- &Message{
-		Type:      "incident_updated",
-		Payload:   incident,
-		Timestamp: getCurrentTimestamp(),
+// deliver queues a message for a client. Clients whose send channel is full
+// are closed; they can reconnect and resume. s.mu must be held.
+func (s *RealtimeServer) deliver(client *Client, message *Message) {
+	select {
+	case client.send <- message:
+	default:
+		close(client.send)
+		delete(s.clients, client)
 	}
 }
 
-// BroadcastCorrelationFound broadcasts a new correlation to all clients
-func (s *RealtimeServer) BroadcastCorrelationFound(data interface{}) {
-	s.broadcast <- &Message{
-		Type:      "correlation_found",
-		Payload:   data,
-		Timestamp: getCurrentTimestamp(),
+// reply sends a message to one client if it is still connected
+func (s *RealtimeServer) reply(client *Client, message *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients[client] {
+		s.deliver(client, message)
 	}
 }
 
-// BroadcastTimelineEvent broadcasts a new timeline event to all clients
-func (s *RealtimeServer) BroadcastTimelineEvent(event interface{}) {
-	s.broadcast <- &Message{
-		Type:      "timeline_event",
-		Payload:   event,
-		Timestamp: getCurrentTimestamp(),
+// StartShared is Start for servers of several replicas: broadcasts are
+// published on the bus, and the messages of every replica are delivered to
+// this one's clients, until ctx is done
+func (s *RealtimeServer) StartShared(ctx context.Context, bus *PostgresBus) error {
+	lastID, err := bus.lastID(ctx)
+	if err != nil {
+		return err
 	}
+	s.replay = newSharedReplayBuffer(lastID)
+	s.bus = bus
+	s.Start()
+	go bus.listen(ctx, lastID, func(m *Message) {
+		s.broadcast <- m
+	})
+	return nil
 }
 
-// BroadcastAlert broadcasts an alert to all clients
-func (s *RealtimeServer) BroadcastAlert(alert interface{}) {
-	s.broadcast <- &Message{
-		Type:      "alert",
-		Payload:   alert,
+func (s *RealtimeServer) publish(messageType string, t Target, payload interface{}) {
+	message := &Message{
+		Type:      messageType,
+		Payload:   payload,
 		Timestamp: getCurrentTimestamp(),
+		target:    t,
+	}
+	if s.bus != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := s.bus.Publish(ctx, message)
+		cancel()
+		if err == nil {
+			return
+		}
+		s.logger.Printf("Failed to publish %s, delivering to local clients only: %v", messageType, err)
 	}
+	s.broadcast <- message
+}
+
+// BroadcastIncidentCreated broadcasts a new incident to the clients of a target
+func (s *RealtimeServer) BroadcastIncidentCreated(t Target, incident interface{}) {
+	s.publish("incident_created", t, incident)
+}
+
+// BroadcastIncidentUpdated broadcasts an incident update to the clients of a target
+func (s *RealtimeServer) BroadcastIncidentUpdated(t Target, incident interface{}) {
+	s.publish("incident_updated", t, incident)
+}
+
+// BroadcastIncidentFieldsChanged broadcasts the fields an incident update
+// changed to the clients of a target
+func (s *RealtimeServer) BroadcastIncidentFieldsChanged(t Target, changes interface{}) {
+	s.publish("incident_fields_changed", t, changes)
 }
 
-// HandleWebSocket handles WebSocket connections
-func (s *RealtimeServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+// BroadcastCorrelationFound broadcasts a new correlation to the clients of a target
+func (s *RealtimeServer) BroadcastCorrelationFound(t Target, data interface{}) {
+	s.publish("correlation_found", t, data)
+}
+
+// BroadcastTimelineEvent broadcasts a new timeline event to the clients of a target
+func (s *RealtimeServer) BroadcastTimelineEvent(t Target, event interface{}) {
+	s.publish("timeline_event", t, event)
+}
+
+// BroadcastAlert broadcasts an alert to the clients of a target
+func (s *RealtimeServer) BroadcastAlert(t Target, alert interface{}) {
+	s.publish("alert", t, alert)
+}
+
+// HandleWebSocket handles WebSocket connections of an organization's users.
+// Clients receive nothing until they subscribe to topics allowed by access.
+func (s *RealtimeServer) HandleWebSocket(w http.ResponseWriter, r *http.Request, orgID string, access Access) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		s.logger.Printf("WebSocket upgrade error: %v", err)
@@ -148,6 +225,11 @@ func (s *RealtimeServer) HandleWebSocket(w http.ResponseWriter, r *http.Request)
 		conn:   conn,
 		send:   make(chan *Message, 256),
 		server: s,
+		orgID:  orgID,
+		user:   access.User(),
+		access: access,
+		topics: make(subscriptions),
+		typing: make(map[string]typing),
 	}
 
 	s.register <- client
@@ -160,6 +242,7 @@ func (s *RealtimeServer) HandleWebSocket(w http.ResponseWriter, r *http.Request)
 // readPump reads messages from the client
 func (c *Client) readPump() {
 	defer func() {
+		c.leave()
 		c.server.unregister <- c
 		c.conn.Close()
 	}()
@@ -171,15 +254,19 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		var msg Message
-		err := c.conn.ReadJSON(&msg)
+		var cmd Command
+		err := c.conn.ReadJSON(&cmd)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.server.logger.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
-		// Echo back the message or handle client commands here if needed
+		// Commands, including pings, keep the connection alive
+		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		if reply := c.handle(cmd); reply != nil {
+			c.server.reply(c, reply)
+		}
 	}
 }
 
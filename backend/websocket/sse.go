@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseHeartbeat is how often an idle event stream gets a comment, which keeps
+// proxies from closing it
+const sseHeartbeat = 15 * time.Second
+
+// sseRetry is how long an EventSource waits before reconnecting
+const sseRetry = 3 * time.Second
+
+// sseTopics returns the topics of topic query parameters, which may be
+// repeated or comma-separated
+func sseTopics(r *http.Request) []string {
+	var topics []string
+	for _, value := range r.URL.Query()["topic"] {
+		for _, topic := range strings.Split(value, ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return topics
+}
+
+// sseLastEventID returns the ID to resume after: the Last-Event-ID header an
+// EventSource sends when it reconnects, or the last_event_id query parameter
+// for the first connection
+func sseLastEventID(r *http.Request) (uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid last event ID")
+	}
+	return id, nil
+}
+
+// sseEvent formats a message as an event. Its data is the same JSON as a
+// websocket message, and broadcast messages carry their ID.
+func sseEvent(m *Message) ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if m.ID != 0 {
+		fmt.Fprintf(&b, "id: %d\n", m.ID)
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+	return b.Bytes(), nil
+}
+
+// HandleSSE streams the messages of an organization's users as server-sent
+// events, for networks that break websocket upgrades. It subscribes like the
+// resume command: to the topics of the query, replaying what came after the
+// last event ID. The first event is the resumed reply.
+func (s *RealtimeServer) HandleSSE(w http.ResponseWriter, r *http.Request, orgID string, access Access) {
+	topics := sseTopics(r)
+	if len(topics) == 0 {
+		http.Error(w, "topic query parameter is required", http.StatusBadRequest)
+		return
+	}
+	lastID, err := sseLastEventID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// write sends a frame right away; the server's write timeout is extended
+	// for each, so the stream can outlive it
+	write := func(frame []byte) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // don't let nginx buffer the stream
+	w.WriteHeader(http.StatusOK)
+	if err := write([]byte(fmt.Sprintf("retry: %d\n\n", sseRetry.Milliseconds()))); err != nil {
+		s.logger.Printf("SSE stream error: %v", err)
+		return
+	}
+
+	client := &Client{
+		send:   make(chan *Message, 256),
+		server: s,
+		orgID:  orgID,
+		user:   access.User(),
+		access: access,
+		topics: make(subscriptions),
+		typing: make(map[string]typing),
+	}
+	s.addClient(client)
+	defer func() {
+		client.leave()
+		s.unregister <- client
+	}()
+	s.resume(client, Command{Type: CommandResume, Topics: topics, LastEventID: lastID})
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var frame []byte
+		select {
+		case <-r.Context().Done():
+			return
+		case message, ok := <-client.send:
+			if !ok {
+				// Too slow; the EventSource reconnects and resumes
+				return
+			}
+			if frame, err = sseEvent(message); err != nil {
+				s.logger.Printf("Failed to encode %s event: %v", message.Type, err)
+				continue
+			}
+		case <-heartbeat.C:
+			frame = []byte(": heartbeat\n\n")
+		}
+		if err := write(frame); err != nil {
+			return
+		}
+	}
+}
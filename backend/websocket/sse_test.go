@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSSERequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		header  string
+		topics  []string
+		lastID  uint64
+		wantErr bool
+	}{
+		{"repeated", "/events?topic=alerts&topic=incident:1", "", []string{"alerts", "incident:1"}, 0, false},
+		{"comma-separated", "/events?topic=alerts,+slo,", "", []string{"alerts", "slo"}, 0, false},
+		{"query ID", "/events?topic=alerts&last_event_id=42", "", []string{"alerts"}, 42, false},
+		{"header wins", "/events?topic=alerts&last_event_id=42", "43", []string{"alerts"}, 43, false},
+		{"invalid ID", "/events?topic=alerts", "abc", []string{"alerts"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			if tt.header != "" {
+				r.Header.Set("Last-Event-ID", tt.header)
+			}
+			if got := sseTopics(r); !reflect.DeepEqual(got, tt.topics) {
+				t.Errorf("sseTopics = %v, want %v", got, tt.topics)
+			}
+			id, err := sseLastEventID(r)
+			if (err != nil) != tt.wantErr || id != tt.lastID {
+				t.Errorf("sseLastEventID = %d, %v; want %d, error %v", id, err, tt.lastID, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSSEEvent(t *testing.T) {
+	got, err := sseEvent(&Message{Type: "alert", ID: 7, Payload: "line\nbreak", Timestamp: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "id: 7\ndata: {\"type\":\"alert\",\"id\":7,\"payload\":\"line\\nbreak\",\"timestamp\":1}\n\n"
+	if string(got) != want {
+		t.Errorf("sseEvent = %q, want %q", got, want)
+	}
+	if got, _ := sseEvent(&Message{Type: ReplyPong}); strings.HasPrefix(string(got), "id:") {
+		t.Errorf("reply has an ID: %q", got)
+	}
+}
+
+func TestHandleSSE(t *testing.T) {
+	s := NewRealtimeServer()
+	s.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.HandleSSE(w, r, "acme", fakeAccess{})
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"?topic=alerts", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() string {
+		for lines.Scan() {
+			if line := lines.Text(); strings.HasPrefix(line, "data: ") {
+				return line
+			}
+		}
+		t.Fatal("stream ended")
+		return ""
+	}
+	if data := next(); !strings.Contains(data, `"type":"resumed"`) {
+		t.Errorf("first event = %s, want resumed", data)
+	}
+	s.BroadcastAlert(AlertTarget("other", ""), "elsewhere")
+	s.BroadcastAlert(AlertTarget("acme", ""), "disk full")
+	if data := next(); !strings.Contains(data, `"payload":"disk full"`) {
+		t.Errorf("event = %s, want the acme alert", data)
+	}
+}
@@ -0,0 +1,104 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Topics clients subscribe to. incident:{id} and service:{name} select the
+// messages of one incident or service; the others select a kind of message.
+const (
+	TopicIncidents = "incidents" // every incident the client may see
+	TopicAlerts    = "alerts"
+	TopicSLO       = "slo"
+
+	incidentPrefix = "incident:"
+	servicePrefix  = "service:"
+)
+
+// maxSubscriptions limits the topics of one client
+const maxSubscriptions = 100
+
+// ErrUnknownTopic is returned for topics that don't exist
+var ErrUnknownTopic = errors.New("unknown topic")
+
+// IncidentTopic is the topic of one incident
+func IncidentTopic(id string) string {
+	return incidentPrefix + id
+}
+
+// ServiceTopic is the topic of one service
+func ServiceTopic(name string) string {
+	return servicePrefix + name
+}
+
+// ParseTopic validates a topic. For incident and service topics, kind is the
+// prefix without its colon and arg the ID or name; otherwise kind is the topic.
+func ParseTopic(topic string) (kind, arg string, err error) {
+	switch topic {
+	case TopicIncidents, TopicAlerts, TopicSLO:
+		return topic, "", nil
+	}
+	for _, prefix := range []string{incidentPrefix, servicePrefix} {
+		if arg, ok := strings.CutPrefix(topic, prefix); ok {
+			if arg == "" {
+				return "", "", fmt.Errorf("%w: %q needs a name", ErrUnknownTopic, topic)
+			}
+			return strings.TrimSuffix(prefix, ":"), arg, nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: %q", ErrUnknownTopic, topic)
+}
+
+// Target selects who receives a message: the clients of Org subscribed to one
+// of Topics that may see Service. An empty Service is about no service.
+type Target struct {
+	Org     string
+	Topics  []string
+	Service string
+}
+
+// IncidentTarget targets the subscribers of an incident, its service and all
+// incidents
+func IncidentTarget(org, incidentID, service string) Target {
+	t := Target{Org: org, Topics: []string{TopicIncidents, IncidentTopic(incidentID)}, Service: service}
+	if service != "" {
+		t.Topics = append(t.Topics, ServiceTopic(service))
+	}
+	return t
+}
+
+// AlertTarget targets the subscribers of alerts and of the service, plus
+// extra topics
+func AlertTarget(org, service string, extra ...string) Target {
+	t := Target{Org: org, Topics: append([]string{TopicAlerts}, extra...), Service: service}
+	if service != "" {
+		t.Topics = append(t.Topics, ServiceTopic(service))
+	}
+	return t
+}
+
+// Access decides what a client may receive, from the caller's permissions
+type Access interface {
+	// CanSubscribe returns an error when the client may not subscribe to a
+	// topic, which has been validated with ParseTopic
+	CanSubscribe(topic string) error
+	// CanSee reports whether the client may see messages about a service
+	CanSee(service string) bool
+	// User names the client to others in presence messages
+	User() string
+}
+
+// subscriptions is a client's set of topics
+type subscriptions map[string]bool
+
+// matches reports whether any topic of t is subscribed to
+func (s subscriptions) matches(t Target) bool {
+	for _, topic := range t.Topics {
+		if s[topic] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseTopic(t *testing.T) {
+	tests := []struct {
+		topic     string
+		kind, arg string
+		wantErr   bool
+	}{
+		{"incidents", "incidents", "", false},
+		{"alerts", "alerts", "", false},
+		{"slo", "slo", "", false},
+		{"incident:42", "incident", "42", false},
+		{"service:api-gateway", "service", "api-gateway", false},
+		{"incident:", "", "", true},
+		{"metrics", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			kind, arg, err := ParseTopic(tt.topic)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownTopic) {
+					t.Errorf("err = %v, want ErrUnknownTopic", err)
+				}
+				return
+			}
+			if err != nil || kind != tt.kind || arg != tt.arg {
+				t.Errorf("ParseTopic(%q) = %q, %q, %v, want %q, %q", tt.topic, kind, arg, err, tt.kind, tt.arg)
+			}
+		})
+	}
+}
+
+// fakeAccess allows every topic but those in denied and every service but
+// hidden
+type fakeAccess struct {
+	denied map[string]bool
+	hidden string
+}
+
+func (a fakeAccess) CanSubscribe(topic string) error {
+	if a.denied[topic] {
+		return errors.New("denied")
+	}
+	return nil
+}
+
+func (a fakeAccess) CanSee(service string) bool {
+	return a.hidden == "" || service != a.hidden
+}
+
+func (a fakeAccess) User() string {
+	return "alice"
+}
+
+func TestClientWants(t *testing.T) {
+	c := &Client{orgID: "acme", access: fakeAccess{hidden: "billing"}, topics: make(subscriptions)}
+	c.subscribe([]string{"incident:1", "alerts"})
+
+	tests := []struct {
+		name   string
+		target Target
+		want   bool
+	}{
+		{"subscribed incident", IncidentTarget("acme", "1", "api"), true},
+		{"other incident", IncidentTarget("acme", "2", "api"), false},
+		{"other organization", IncidentTarget("other", "1", "api"), false},
+		{"alert", AlertTarget("acme", "api"), true},
+		{"hidden service", AlertTarget("acme", "billing"), false},
+		{"slo only", Target{Org: "acme", Topics: []string{TopicSLO}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.wants(&Message{target: tt.target}); got != tt.want {
+				t.Errorf("wants(%+v) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientHandle(t *testing.T) {
+	c := &Client{access: fakeAccess{denied: map[string]bool{"incident:2": true}}, topics: make(subscriptions)}
+
+	reply := c.handle(Command{Type: CommandSubscribe, Topics: []string{"slo", "incident:2", "bogus"}, Ref: "7"})
+	got := reply.Payload.(SubscriptionReply)
+	if reply.Type != ReplySubscribed || reply.Ref != "7" {
+		t.Errorf("reply = %s ref %q, want subscribed ref 7", reply.Type, reply.Ref)
+	}
+	if !reflect.DeepEqual(got.Topics, []string{"slo"}) || len(got.Rejected) != 2 {
+		t.Errorf("subscribe = %+v, want [slo] with 2 rejected", got)
+	}
+
+	reply = c.handle(Command{Type: CommandUnsubscribe, Topics: []string{"slo"}})
+	if got := reply.Payload.(SubscriptionReply); len(got.Topics) != 0 {
+		t.Errorf("unsubscribe left %v", got.Topics)
+	}
+	if reply = c.handle(Command{Type: CommandPing}); reply.Type != ReplyPong {
+		t.Errorf("ping = %s, want pong", reply.Type)
+	}
+	if reply = c.handle(Command{Type: "shout"}); reply.Type != ReplyError {
+		t.Errorf("unknown command = %s, want error", reply.Type)
+	}
+}
@@ -17,6 +17,7 @@ interface ApiError {
   endpoint?: string;  // API endpoint for context
   method?: string;    // HTTP method for context
   duration?: number;  // Request duration in ms
+  current?: any;      // Latest state of a resource that changed meanwhile (409)
 }
 
 // Generate unique trace ID for request tracking
@@ -86,6 +87,7 @@ class ApiErrorHandler {
       method: context.method,
       duration: context.duration,
       requestId: errorData.request_id || errorData.requestId,
+      current: errorData.current,
     };
   }
 }
@@ -97,6 +99,13 @@ export function setTokenExpiredCallback(callback: () => void) {
   onTokenExpired = callback;
 }
 
+// conflictState returns the latest state sent with a 409, when someone else
+// changed a resource since the version the update was for
+export function conflictState(err: unknown): any | undefined {
+  const apiError = err as Partial<ApiError> | undefined;
+  return apiError?.status === 409 ? apiError.current : undefined;
+}
+
 // Import mock data for development
 import { mockData } from './mock-data';
 
@@ -179,6 +188,7 @@ async function apiFetch<T>(endpoint: string, options: FetchOptions = {}): Promis
           method: apiError.method,
           duration: apiError.duration,
           isTokenExpired: apiError.isTokenExpired,
+          current: apiError.current,
         });
 
         // Don't retry non-retryable errors
@@ -240,7 +250,14 @@ export const backendAPI = {
     list: () => apiFetch<any[]>("/incidents"),
     get: (id: string) => apiFetch<any>(`/incidents/${id}`),
     create: (data: any) => apiFetch<any>("/incidents", { method: 'POST', body: data }),
-    update: (id: string, data: any) => apiFetch<any>(`/incidents/${id}`, { method: 'PATCH', body: data }),
+    // Updates only apply to the version the caller last saw; on 409 someone
+    // else changed the incident, see conflictState
+    update: (id: string, data: any, version: number) =>
+      apiFetch<any>(`/incidents/${id}`, {
+        method: 'PATCH',
+        body: data,
+        headers: { 'If-Match': `"${version}"` },
+      }),
     getTimeline: (id: string) => apiFetch<any[]>(`/incidents/${id}/timeline`),
     getCorrelations: (id: string) => apiFetch<any[]>(`/incidents/${id}/correlations`),
     getAnalysis: (id: string) => apiFetch<any>(`/incidents/${id}/analysis`),
@@ -4,7 +4,7 @@ export const incidentsApi = {
   list: () => backendAPI.incidents.list(),
   get: (id: string) => backendAPI.incidents.get(id),
   create: (data: any) => backendAPI.incidents.create(data),
-  update: (id: string, data: any) => backendAPI.incidents.update(id, data),
+  update: (id: string, data: any, version: number) => backendAPI.incidents.update(id, data, version),
   getTimeline: (id: string) => backendAPI.incidents.getTimeline(id),
   getCorrelations: (id: string) => backendAPI.incidents.getCorrelations(id),
   getAnalysis: (id: string) => backendAPI.incidents.getAnalysis(id),
@@ -24,7 +24,7 @@ import { KubernetesTab } from './tabs/KubernetesTab';
 import { SLOStatus } from '../components/SLOStatus';
 import { TabsBar, Tab, TabContent } from '@grafana/ui';
 import { incidentsApi } from '../api/incidents';
-import { backendAPI } from '../api/backend';
+import { backendAPI, conflictState } from '../api/backend';
 
 const theme = {
   bg: '#0d0e12',
@@ -213,12 +213,22 @@ export const IncidentControlPlane: React.FC<IncidentControlPlaneProps> = ({
     return () => clearInterval(interval);
   }, [incident.started_at, incident.resolved_at]);
 
+  // showConflict shows the latest state when someone else changed the incident
+  const showConflict = (error: unknown) => {
+    const current = conflictState(error);
+    if (!current) return false;
+    onIncidentUpdate({ ...incident, ...current });
+    alert('Someone else changed this incident meanwhile. Review their change and try again.');
+    return true;
+  };
+
   // OPERATOR ACTIONS
   const handleAcknowledge = async () => {
     try {
-      const updated = await incidentsApi.update(incident.id, { status: 'investigating' });
-      onIncidentUpdate(updated);
+      const updated = await incidentsApi.update(incident.id, { status: 'investigating' }, incident.version);
+      onIncidentUpdate({ ...incident, ...updated.incident });
     } catch (error) {
+      if (showConflict(error)) return;
       console.error('Failed to acknowledge incident:', error);
       alert('Failed to acknowledge incident');
     }
@@ -242,9 +252,10 @@ export const IncidentControlPlane: React.FC<IncidentControlPlaneProps> = ({
 
   const handleMitigate = async () => {
     try {
-      const updated = await incidentsApi.update(incident.id, { status: 'mitigated' });
-      onIncidentUpdate(updated);
+      const updated = await incidentsApi.update(incident.id, { status: 'mitigated' }, incident.version);
+      onIncidentUpdate({ ...incident, ...updated.incident });
     } catch (error) {
+      if (showConflict(error)) return;
       console.error('Failed to mitigate incident:', error);
       alert('Failed to mitigate incident');
     }
@@ -253,11 +264,12 @@ export const IncidentControlPlane: React.FC<IncidentControlPlaneProps> = ({
   const handleResolve = async () => {
     if (!confirm('Mark this incident as resolved?')) return;
     try {
-      const updated = await incidentsApi.update(incident.id, { status: 'resolved' });
-      onIncidentUpdate(updated);
+      const updated = await incidentsApi.update(incident.id, { status: 'resolved' }, incident.version);
+      onIncidentUpdate({ ...incident, ...updated.incident });
       onIncidentResolved();
       alert('Incident resolved! The system will update automatically.');
     } catch (error) {
+      if (showConflict(error)) return;
       console.error('Failed to resolve incident:', error);
       alert('Failed to resolve incident');
     }
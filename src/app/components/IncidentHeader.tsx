@@ -1,6 +1,7 @@
 import React, { useState } from 'react';
 import { Incident } from '../../models/Incident';
 import { incidentsApi } from '../api/incidents';
+import { conflictState } from '../api/backend';
 
 interface IncidentHeaderProps {
   incident: Incident;
@@ -13,16 +14,26 @@ export const IncidentHeader: React.FC<IncidentHeaderProps> = ({ incident, onUpda
   const [description, setDescription] = useState(incident.description);
   const [severity, setSeverity] = useState(incident.severity);
 
+  // showConflict reloads the incident when someone else changed it meanwhile;
+  // unsaved edits stay in the form
+  const showConflict = (error: unknown) => {
+    if (!conflictState(error)) return false;
+    alert('Someone else changed this incident meanwhile. Review their change and try again.');
+    onUpdate();
+    return true;
+  };
+
   const handleSave = async () => {
     try {
       await incidentsApi.update(incident.id, {
         title,
         description,
         severity,
-      });
+      }, incident.version);
       setIsEditing(false);
       onUpdate();
     } catch (error) {
+      if (showConflict(error)) return;
       console.error('Failed to update incident:', error);
     }
   };
@@ -105,9 +116,10 @@ export const IncidentHeader: React.FC<IncidentHeaderProps> = ({ incident, onUpda
             <button 
               onClick={async () => {
                 try {
-                  await incidentsApi.update(incident.id, { status: 'investigating' });
+                  await incidentsApi.update(incident.id, { status: 'investigating' }, incident.version);
                   onUpdate();
                 } catch (error) {
+                  if (showConflict(error)) return;
                   console.error('Failed to update incident status:', error);
                 }
               }}
@@ -120,10 +132,11 @@ export const IncidentHeader: React.FC<IncidentHeaderProps> = ({ incident, onUpda
             <button 
               onClick={async () => {
                 try {
-                  await incidentsApi.update(incident.id, { status: 'resolved' });
+                  await incidentsApi.update(incident.id, { status: 'resolved' }, incident.version);
                   alert('Incident resolved! The system will reflect this change immediately.');
                   onUpdate();
                 } catch (error) {
+                  if (showConflict(error)) return;
                   console.error('Failed to resolve incident:', error);
                   alert('Failed to resolve incident. Please try again.');
                 }
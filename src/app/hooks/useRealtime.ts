@@ -1,21 +1,30 @@
 /// <reference types="vite/client" />
-import { useState, useEffect, useCallback } from 'react';
+import { useState, useEffect, useCallback, useRef } from 'react';
 
 interface RealtimeMessage {
   type: string;
+  id?: number; // set on broadcast messages, increasing
   payload: any;
   timestamp: number;
+  ref?: string;
 }
 
 interface UseRealtimeOptions {
   url?: string;
+  topics?: string[]; // e.g. 'incidents', 'alerts', 'slo', 'incident:{id}', 'service:{name}'
   onIncidentCreated?: (incident: any) => void;
   onIncidentUpdated?: (incident: any) => void;
   onCorrelationFound?: (data: any) => void;
   onTimelineEvent?: (event: any) => void;
   onAlert?: (alert: any) => void;
+  onGap?: (topics: string[]) => void; // messages were missed; refetch
+  onIncidentFieldsChanged?: (data: any) => void;
+  onPresence?: (event: any) => void; // joined, left, typing or idle
+  onPresenceState?: (state: any) => void; // viewers of an incident on subscribe
 }
 
+const DEFAULT_TOPICS = ['incidents', 'alerts', 'slo'];
+
 /**
  * Hook for real-time incident updates via WebSocket
  * 
@@ -29,17 +38,24 @@ interface UseRealtimeOptions {
  */
 export function useRealtime({
   url = import.meta.env.VITE_WS_URL || 'ws://reliability-backend:9000/api/realtime',
+  topics = DEFAULT_TOPICS,
   onIncidentCreated,
   onIncidentUpdated,
   onCorrelationFound,
   onTimelineEvent,
   onAlert,
+  onGap,
+  onIncidentFieldsChanged,
+  onPresence,
+  onPresenceState,
 }: UseRealtimeOptions = {}) {
   const [connected, setConnected] = useState(false);
   const [lastMessage, setLastMessage] = useState<RealtimeMessage | null>(null);
   const [error, setError] = useState<string | null>(null);
   const [ws, setWs] = useState<WebSocket | null>(null);
   const [retryCount, setRetryCount] = useState(0);
+  // Kept across reconnects to resume after the last message received
+  const lastEventId = useRef(0);
 
   useEffect(() => {
     let cleanup = false;
@@ -49,7 +65,11 @@ export function useRealtime({
       if (cleanup) return;
 
       console.log(`[WebSocket] Connecting to ${url}...`);
-      socket = new WebSocket(url);
+      // Browsers can't send headers with websockets, so the token goes in the URL
+      const token = localStorage.getItem('access_token');
+      socket = new WebSocket(
+        token ? `${url}${url.includes('?') ? '&' : '?'}access_token=${encodeURIComponent(token)}` : url
+      );
 
       socket.onopen = () => {
         if (cleanup) return;
@@ -57,6 +77,13 @@ export function useRealtime({
         setConnected(true);
         setError(null);
         setRetryCount(0);
+        // Nothing is sent until the client subscribes; after a reconnect the
+        // server replays what was missed, or sends a gap
+        socket.send(JSON.stringify(
+          lastEventId.current
+            ? { type: 'resume', topics, last_event_id: lastEventId.current }
+            : { type: 'subscribe', topics }
+        ));
       };
 
       socket.onmessage = (event) => {
@@ -64,6 +91,9 @@ export function useRealtime({
         try {
           const message: RealtimeMessage = JSON.parse(event.data);
           setLastMessage(message);
+          if (message.id && message.id > lastEventId.current) {
+            lastEventId.current = message.id;
+          }
 
           switch (message.type) {
             case 'incident_created': onIncidentCreated?.(message.payload); break;
@@ -71,6 +101,16 @@ export function useRealtime({
             case 'correlation_found': onCorrelationFound?.(message.payload); break;
             case 'timeline_event': onTimelineEvent?.(message.payload); break;
             case 'alert': onAlert?.(message.payload); break;
+            case 'gap': onGap?.(message.payload.topics); break;
+            case 'incident_fields_changed': onIncidentFieldsChanged?.(message.payload); break;
+            case 'presence': onPresence?.(message.payload); break;
+            case 'presence_state': onPresenceState?.(message.payload); break;
+            case 'subscribed':
+            case 'unsubscribed':
+            case 'resumed':
+            case 'pong':
+              break;
+            case 'error': console.warn('[WebSocket] Command failed:', message.payload); break;
             default: console.log('[WebSocket] Unknown message type:', message.type);
           }
         } catch (e) {
@@ -112,7 +152,7 @@ export function useRealtime({
         socket.close();
       }
     };
-  }, [url, retryCount]);
+  }, [url, topics.join(','), retryCount]);
 
   const send = useCallback((message: any) => {
     if (ws?.readyState === WebSocket.OPEN) {
@@ -122,10 +162,17 @@ export function useRealtime({
     }
   }, [ws]);
 
+  // Tell viewers of an incident which field the user edits; repeat every few
+  // seconds while typing, and send an empty field when done
+  const sendTyping = useCallback((incidentId: string, field: string) => {
+    send({ type: 'typing', incident_id: incidentId, field });
+  }, [send]);
+
   return {
     connected,
     lastMessage,
     error,
     send,
+    sendTyping,
   };
 }
@@ -2,7 +2,7 @@ import React, { useState, useEffect } from 'react';
 import { css, keyframes } from '@emotion/css';
 import { useStyles2, useTheme2 } from '@grafana/ui';
 import { GrafanaTheme2 } from '@grafana/data';
-import { backendAPI } from '../app/api/backend';
+import { backendAPI, conflictState } from '../app/api/backend';
 import { useRealtime } from '../app/hooks/useRealtime';
 import { Timeline } from './Timeline';
 import { TelemetryTabs } from './TelemetryTabs';
@@ -312,40 +312,41 @@ export function IncidentControlRoom() {
         }
     });
 
-    const acknowledgeIncident = async (id: string) => {
-        try {
-            console.log('[IncidentControlRoom] Acknowledging incident:', id);
-            await backendAPI.incidents.update(id, { status: 'investigating' });
-            setIncidents(prev =>
-                prev.map(i => i.id === id ? { ...i, status: 'investigating' } : i)
-            );
-            if (selectedIncident?.id === id) {
-                setSelectedIncident((prev: any) => ({ ...prev, status: 'investigating' }));
-            }
-        } catch (err) {
-            const errorMsg = err instanceof Error ? err.message : 'Failed to acknowledge incident';
-            console.error('[IncidentControlRoom] Failed to acknowledge:', errorMsg);
-            setError(errorMsg);
+    // setIncidentState merges the latest state of an incident into the list and
+    // the selection
+    const setIncidentState = (id: string, state: any) => {
+        setIncidents(prev =>
+            prev.map(i => i.id === id ? { ...i, ...state } : i)
+        );
+        if (selectedIncident?.id === id) {
+            setSelectedIncident((prev: any) => ({ ...prev, ...state }));
         }
     };
 
-    const resolveIncident = async (id: string) => {
+    // updateStatus applies a status to the version of the incident shown. When
+    // someone else changed it meanwhile, their change is shown instead.
+    const updateStatus = async (incident: any, status: string, action: string) => {
         try {
-            console.log('[IncidentControlRoom] Resolving incident:', id);
-            await backendAPI.incidents.update(id, { status: 'resolved' });
-            setIncidents(prev =>
-                prev.map(i => i.id === id ? { ...i, status: 'resolved' } : i)
-            );
-            if (selectedIncident?.id === id) {
-                setSelectedIncident((prev: any) => ({ ...prev, status: 'resolved' }));
-            }
+            console.log(`[IncidentControlRoom] ${action} incident:`, incident.id);
+            const updated = await backendAPI.incidents.update(incident.id, { status }, incident.version);
+            setIncidentState(incident.id, { status, version: updated.version });
         } catch (err) {
-            const errorMsg = err instanceof Error ? err.message : 'Failed to resolve incident';
-            console.error('[IncidentControlRoom] Failed to resolve:', errorMsg);
+            const current = conflictState(err);
+            if (current) {
+                setIncidentState(incident.id, current);
+                setError('Someone else changed this incident meanwhile. Review their change and try again.');
+                return;
+            }
+            const errorMsg = err instanceof Error ? err.message : 'Failed to update incident';
+            console.error(`[IncidentControlRoom] ${action} failed:`, errorMsg);
             setError(errorMsg);
         }
     };
 
+    const acknowledgeIncident = (incident: any) => updateStatus(incident, 'investigating', 'Acknowledging');
+
+    const resolveIncident = (incident: any) => updateStatus(incident, 'resolved', 'Resolving');
+
     if (loading) {
         return (
             <div className={styles.container}>
@@ -526,7 +527,7 @@ export function IncidentControlRoom() {
                                 {selectedIncident.status === 'open' && (
                                     <button
                                         className={styles.button(true)}
-                                        onClick={() => acknowledgeIncident(selectedIncident.id)}
+                                        onClick={() => acknowledgeIncident(selectedIncident)}
                                     >
                                         Acknowledge
                                     </button>
@@ -534,7 +535,7 @@ export function IncidentControlRoom() {
                                 {selectedIncident.status !== 'resolved' && (
                                     <button
                                         className={styles.button(false)}
-                                        onClick={() => resolveIncident(selectedIncident.id)}
+                                        onClick={() => resolveIncident(selectedIncident)}
                                     >
                                         Mark Resolved
                                     </button>
@@ -14,4 +14,5 @@ export interface Incident {
   timeline?: any[];
   message?: string;
   timestamp?: string;
+  version: number; // send back when updating
 }